@@ -0,0 +1,170 @@
+/*
+
+  Copyright 2017 Loopring Project Ltd (Loopring Foundation).
+
+  Licensed under the Apache License, Version 2.0 (the "License");
+  you may not use this file except in compliance with the License.
+  You may obtain a copy of the License at
+
+  http://www.apache.org/licenses/LICENSE-2.0
+
+  Unless required by applicable law or agreed to in writing, software
+  distributed under the License is distributed on an "AS IS" BASIS,
+  WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+  See the License for the specific language governing permissions and
+  limitations under the License.
+
+*/
+
+package accountmanager
+
+import (
+	"github.com/Loopring/relay-cluster/dao"
+	"github.com/Loopring/relay-lib/eth/accessor"
+	"github.com/Loopring/relay-lib/eth/loopringaccessor"
+	"github.com/Loopring/relay-lib/log"
+	"github.com/Loopring/relay-lib/types"
+	"github.com/ethereum/go-ethereum/common"
+)
+
+// 记录区块内同步过的owner/token/spender, 区块结束时按该区块高度读取后保存快照
+func (b *ChangedOfBlock) markBalanceSnapshot(owner, token common.Address) {
+	if nil == b.snapshotBalances {
+		b.snapshotBalances = make(map[string]bool)
+	}
+	b.snapshotBalances[string(b.cacheBalanceField(owner, token))] = true
+}
+
+func (b *ChangedOfBlock) markAllowanceSnapshot(owner, token, spender common.Address) {
+	if nil == b.snapshotAllowances {
+		b.snapshotAllowances = make(map[string]bool)
+	}
+	b.snapshotAllowances[string(b.cacheAllowanceField(owner, token, spender))] = true
+}
+
+// 缓存同步读取的是latest, 可能已包含之后区块的变化, 快照需按记录的区块高度重新读取
+func (b *ChangedOfBlock) snapshotReqs() (loopringaccessor.BatchBalanceReqs, loopringaccessor.BatchErc20AllowanceReqs) {
+	blockParameter := types.BigintToHex(b.currentBlockNumber)
+	balanceReqs := loopringaccessor.BatchBalanceReqs{}
+	for field := range b.snapshotBalances {
+		req := &loopringaccessor.BatchBalanceReq{BlockParameter: blockParameter}
+		req.Owner, req.Token = b.parseCacheBalanceField([]byte(field))
+		balanceReqs = append(balanceReqs, req)
+	}
+	allowanceReqs := loopringaccessor.BatchErc20AllowanceReqs{}
+	for field := range b.snapshotAllowances {
+		req := &loopringaccessor.BatchErc20AllowanceReq{BlockParameter: blockParameter}
+		req.Owner, req.Token, req.Spender = b.parseCacheAllowanceField([]byte(field))
+		allowanceReqs = append(allowanceReqs, req)
+	}
+	return balanceReqs, allowanceReqs
+}
+
+// 余额记录的spender为空, 授权按spender分别记录
+func BuildBalanceSnapshots(blockNumber, blockTime int64, balanceReqs loopringaccessor.BatchBalanceReqs, allowanceReqs loopringaccessor.BatchErc20AllowanceReqs) []*dao.BalanceSnapshot {
+	snapshots := make([]*dao.BalanceSnapshot, 0, len(balanceReqs)+len(allowanceReqs))
+	for _, req := range balanceReqs {
+		if nil != req.BalanceErr {
+			log.Errorf("get balance snapshot failed, owner:%s, token:%s, block:%d, err:%s", req.Owner.Hex(), req.Token.Hex(), blockNumber, req.BalanceErr.Error())
+			continue
+		}
+		snapshots = append(snapshots, &dao.BalanceSnapshot{
+			Owner:       req.Owner.Hex(),
+			Token:       req.Token.Hex(),
+			BlockNumber: blockNumber,
+			BlockTime:   blockTime,
+			Balance:     types.BigintToHex(req.Balance.BigInt()),
+		})
+	}
+	for _, req := range allowanceReqs {
+		if nil != req.AllowanceErr {
+			log.Errorf("get allowance snapshot failed, owner:%s, token:%s, spender:%s, block:%d, err:%s", req.Owner.Hex(), req.Token.Hex(), req.Spender.Hex(), blockNumber, req.AllowanceErr.Error())
+			continue
+		}
+		snapshots = append(snapshots, &dao.BalanceSnapshot{
+			Owner:       req.Owner.Hex(),
+			Token:       req.Token.Hex(),
+			Spender:     req.Spender.Hex(),
+			BlockNumber: blockNumber,
+			BlockTime:   blockTime,
+			Allowance:   types.BigintToHex(req.Allowance.BigInt()),
+		})
+	}
+	return snapshots
+}
+
+func (a *AccountManager) saveBalanceSnapshots(block *ChangedOfBlock, blockTime int64) {
+	if nil == a.rds || (len(block.snapshotBalances) == 0 && len(block.snapshotAllowances) == 0) {
+		return
+	}
+	balanceReqs, allowanceReqs := block.snapshotReqs()
+	if err := accessor.BatchCall(types.BigintToHex(block.currentBlockNumber), []accessor.BatchReq{balanceReqs, allowanceReqs}); nil != err {
+		log.Errorf("get balance snapshots of block:%s error:%s", block.currentBlockNumber.String(), err.Error())
+		return
+	}
+	for _, snapshot := range BuildBalanceSnapshots(block.currentBlockNumber.Int64(), blockTime, balanceReqs, allowanceReqs) {
+		if err := a.rds.SaveBalanceSnapshot(snapshot); nil != err {
+			log.Errorf("save balance snapshot of owner:%s token:%s spender:%s block:%d error:%s", snapshot.Owner, snapshot.Token, snapshot.Spender, snapshot.BlockNumber, err.Error())
+		}
+	}
+}
+
+// 分叉区块内的余额记录已不可信, 直接删除
+func (a *AccountManager) deleteForkedBalanceSnapshots(event *types.ForkedEvent) {
+	if nil == a.rds {
+		return
+	}
+	if err := a.rds.DeleteBalanceSnapshots(event.ForkBlock.Int64(), event.DetectedBlock.Int64()); nil != err {
+		log.Errorf("delete balance snapshots from block:%s to block:%s error:%s", event.ForkBlock.String(), event.DetectedBlock.String(), err.Error())
+	}
+}
+
+type BalanceHistoryPoint struct {
+	BlockNumber int64  `json:"blockNumber"`
+	BlockTime   int64  `json:"blockTime"`
+	Balance     string `json:"balance"`
+	Allowance   string `json:"allowance"`
+}
+
+// spender为空时只返回余额
+func BuildBalanceHistory(rds *dao.RdsService, owner, token common.Address, spender string, start, end int64, limit int) ([]BalanceHistoryPoint, error) {
+	snapshots, err := rds.GetBalanceSnapshots(owner.Hex(), token.Hex(), spender, start, end, limit)
+	if nil != err {
+		return nil, err
+	}
+
+	var balance, allowance string
+	if latest, err := rds.GetLatestBalanceSnapshotBefore(owner.Hex(), token.Hex(), "", start); nil == err {
+		balance = latest.Balance
+	}
+	if spender != "" {
+		if latest, err := rds.GetLatestBalanceSnapshotBefore(owner.Hex(), token.Hex(), spender, start); nil == err {
+			allowance = latest.Allowance
+		}
+	}
+	return MergeBalanceHistory(balance, allowance, snapshots), nil
+}
+
+// 同一区块的余额和授权记录合并为一个点, 未变化的字段用上一个值补齐, 区间开始前没有记录的为空
+func MergeBalanceHistory(balance, allowance string, snapshots []dao.BalanceSnapshot) []BalanceHistoryPoint {
+	points := make([]BalanceHistoryPoint, 0, len(snapshots))
+	for _, snapshot := range snapshots {
+		if snapshot.Balance != "" {
+			balance = snapshot.Balance
+		}
+		if snapshot.Allowance != "" {
+			allowance = snapshot.Allowance
+		}
+		if n := len(points); n > 0 && points[n-1].BlockNumber == snapshot.BlockNumber {
+			points[n-1].Balance, points[n-1].Allowance = balance, allowance
+			continue
+		}
+		points = append(points, BalanceHistoryPoint{
+			BlockNumber: snapshot.BlockNumber,
+			BlockTime:   snapshot.BlockTime,
+			Balance:     balance,
+			Allowance:   allowance,
+		})
+	}
+	return points
+}
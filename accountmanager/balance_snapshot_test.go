@@ -0,0 +1,100 @@
+/*
+
+  Copyright 2017 Loopring Project Ltd (Loopring Foundation).
+
+  Licensed under the Apache License, Version 2.0 (the "License");
+  you may not use this file except in compliance with the License.
+  You may obtain a copy of the License at
+
+  http://www.apache.org/licenses/LICENSE-2.0
+
+  Unless required by applicable law or agreed to in writing, software
+  distributed under the License is distributed on an "AS IS" BASIS,
+  WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+  See the License for the specific language governing permissions and
+  limitations under the License.
+
+*/
+
+package accountmanager_test
+
+import (
+	"errors"
+	"github.com/Loopring/relay-cluster/accountmanager"
+	"github.com/Loopring/relay-cluster/dao"
+	"github.com/Loopring/relay-lib/eth/loopringaccessor"
+	"github.com/Loopring/relay-lib/log"
+	"github.com/Loopring/relay-lib/types"
+	"github.com/ethereum/go-ethereum/common"
+	"go.uber.org/zap"
+	"math/big"
+	"sync"
+	"testing"
+)
+
+var testLogger sync.Once
+
+func initTestLogger() {
+	testLogger.Do(func() {
+		cfg := zap.NewDevelopmentConfig()
+		cfg.OutputPaths = []string{"stdout"}
+		log.Initialize(cfg)
+	})
+}
+
+func TestMergeBalanceHistory(t *testing.T) {
+	spender := "0x17233e07c67d086464fD408148c3ABB56245FA64"
+	snapshots := []dao.BalanceSnapshot{
+		{BlockNumber: 11, BlockTime: 110, Balance: "0x2"},
+		{BlockNumber: 12, BlockTime: 120, Spender: spender, Allowance: "0x5"},
+		{BlockNumber: 13, BlockTime: 130, Balance: "0x3"},
+		{BlockNumber: 13, BlockTime: 130, Spender: spender, Allowance: "0x4"},
+	}
+
+	points := accountmanager.MergeBalanceHistory("0x1", "", snapshots)
+	if len(points) != 3 {
+		t.Fatalf("points:%d", len(points))
+	}
+	expected := []accountmanager.BalanceHistoryPoint{
+		{BlockNumber: 11, BlockTime: 110, Balance: "0x2", Allowance: ""},
+		{BlockNumber: 12, BlockTime: 120, Balance: "0x2", Allowance: "0x5"},
+		{BlockNumber: 13, BlockTime: 130, Balance: "0x3", Allowance: "0x4"},
+	}
+	for i, p := range points {
+		if p != expected[i] {
+			t.Errorf("point %d:%+v, expected:%+v", i, p, expected[i])
+		}
+	}
+}
+
+func TestBuildBalanceSnapshots(t *testing.T) {
+	initTestLogger()
+	owner := common.HexToAddress("0x1b978a1d302335a6f2ebe4b8823b5e17c3c84135")
+	token := common.HexToAddress("0xef68e7c694f40c8202821edf525de3782458639f")
+	spender1 := common.HexToAddress("0x17233e07c67d086464fD408148c3ABB56245FA64")
+	spender2 := common.HexToAddress("0x5567ee920f7E62274284985D793344351A00142B")
+
+	balanceReqs := loopringaccessor.BatchBalanceReqs{
+		{Owner: owner, Token: token, Balance: *types.NewBigPtr(big.NewInt(10))},
+		{Owner: owner, Token: types.NilAddress, BalanceErr: errors.New("failed")},
+	}
+	allowanceReqs := loopringaccessor.BatchErc20AllowanceReqs{
+		{Owner: owner, Token: token, Spender: spender1, Allowance: *types.NewBigPtr(big.NewInt(1))},
+		{Owner: owner, Token: token, Spender: spender2, Allowance: *types.NewBigPtr(big.NewInt(2))},
+	}
+
+	snapshots := accountmanager.BuildBalanceSnapshots(100, 1000, balanceReqs, allowanceReqs)
+	expected := []dao.BalanceSnapshot{
+		{Owner: owner.Hex(), Token: token.Hex(), BlockNumber: 100, BlockTime: 1000, Balance: "0xa"},
+		{Owner: owner.Hex(), Token: token.Hex(), Spender: spender1.Hex(), BlockNumber: 100, BlockTime: 1000, Allowance: "0x1"},
+		{Owner: owner.Hex(), Token: token.Hex(), Spender: spender2.Hex(), BlockNumber: 100, BlockTime: 1000, Allowance: "0x2"},
+	}
+	if len(snapshots) != len(expected) {
+		t.Fatalf("snapshots should be %d, got %d", len(expected), len(snapshots))
+	}
+	for i, s := range snapshots {
+		if *s != expected[i] {
+			t.Errorf("snapshot %d:%+v, expected:%+v", i, *s, expected[i])
+		}
+	}
+}
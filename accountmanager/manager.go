@@ -26,6 +26,7 @@ import (
 	"github.com/Loopring/relay-lib/log"
 
 	"fmt"
+	"github.com/Loopring/relay-cluster/dao"
 	"github.com/Loopring/relay-cluster/util"
 	"github.com/Loopring/relay-lib/kafka"
 	"github.com/Loopring/relay-lib/marketutil"
@@ -34,6 +35,14 @@ import (
 	"math/big"
 )
 
+// 区块结束时授权额度已同步到缓存的owner, 成交消耗的授权也在这里更新
+const AllowanceSynced = "AccountManager_AllowanceSynced"
+
+type AllowanceSyncedEvent struct {
+	Owner       common.Address
+	BlockNumber *big.Int
+}
+
 type AccountManager struct {
 	tokenCacheDuration int64
 	ethCacheDuration   int64
@@ -41,6 +50,7 @@ type AccountManager struct {
 	cachedBlockCount *big.Int
 	//block           *ChangedOfBlock
 	producerWrapped *kafka.MessageProducer
+	rds             *dao.RdsService
 }
 
 func isPackegeReady() error {
@@ -53,7 +63,7 @@ func isPackegeReady() error {
 	return nil
 }
 
-func Initialize(options *AccountManagerOptions, brokers []string) AccountManager {
+func Initialize(options *AccountManagerOptions, brokers []string, rds *dao.RdsService) AccountManager {
 	if nil != accManager {
 		log.Fatalf("AccountManager has been init")
 	}
@@ -61,7 +71,7 @@ func Initialize(options *AccountManagerOptions, brokers []string) AccountManager
 		log.Fatalf(err.Error())
 	}
 
-	accountManager := AccountManager{}
+	accountManager := AccountManager{rds: rds}
 	if options.CacheDuration > 0 {
 		accountManager.tokenCacheDuration = options.CacheDuration
 	} else {
@@ -214,11 +224,13 @@ func (a *AccountManager) handleBlockEnd(input eventemitter.EventData) error {
 	block.currentBlockNumber = new(big.Int).Set(event.BlockNumber)
 	changedAddrs, _ := block.syncAndSaveBalances(a.tokenCacheDuration, a.ethCacheDuration)
 	changedAllowanceAddrs, _ := block.syncAndSaveAllowances()
+	a.saveBalanceSnapshots(block, event.BlockTime)
 
 	removeExpiredBlock(block.currentBlockNumber, block.cachedDuration)
 
 	for addr, _ := range changedAllowanceAddrs {
 		changedAddrs[addr] = true
+		eventemitter.Emit(AllowanceSynced, &AllowanceSyncedEvent{Owner: addr, BlockNumber: block.currentBlockNumber})
 	}
 	for addr, _ := range changedAddrs {
 		event := &types.BalanceUpdateEvent{}
@@ -327,6 +339,7 @@ func (a *AccountManager) UnlockedWallet(owner string) (err error) {
 func (a *AccountManager) handleBlockFork(input eventemitter.EventData) (err error) {
 	event := input.(*types.ForkedEvent)
 	log.Infof("the eth network may be forked. flush all cache, detectedBlock:%s", event.DetectedBlock.String())
+	a.deleteForkedBalanceSnapshots(event)
 
 	i := new(big.Int).Set(event.DetectedBlock)
 	changedAddrs := make(map[common.Address]bool)
@@ -0,0 +1,473 @@
+/*
+
+  Copyright 2017 Loopring Project Ltd (Loopring Foundation).
+
+  Licensed under the Apache License, Version 2.0 (the "License");
+  you may not use this file except in compliance with the License.
+  You may obtain a copy of the License at
+
+  http://www.apache.org/licenses/LICENSE-2.0
+
+  Unless required by applicable law or agreed to in writing, software
+  distributed under the License is distributed on an "AS IS" BASIS,
+  WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+  See the License for the specific language governing permissions and
+  limitations under the License.
+
+*/
+
+package accountmanager
+
+import (
+	"errors"
+	"github.com/Loopring/relay-cluster/dao"
+	"github.com/Loopring/relay-lib/eventemitter"
+	"github.com/Loopring/relay-lib/log"
+	"github.com/Loopring/relay-lib/marketcap"
+	"github.com/Loopring/relay-lib/marketutil"
+	"github.com/Loopring/relay-lib/types"
+	"github.com/ethereum/go-ethereum/common"
+	"math/big"
+	"sort"
+	"time"
+)
+
+const (
+	PNL_METHOD_FIFO    = "fifo"
+	PNL_METHOD_AVERAGE = "average"
+
+	pnlCurrency = "USD"
+
+	// 历史价格与成交时间相差超过一天视为没有价格
+	pnlPriceTolerance = 24 * 3600
+
+	// 只能取到当前价格, 区块时间与当前相差超过该值(如同步历史区块)时不记录
+	fillPriceRecordWindow = 10 * 60
+)
+
+var ErrPnlPriceNotFound = errors.New("usd price not found")
+
+// 价格均为每个完整token的美元价格
+type PnlPriceProvider interface {
+	PriceAt(token common.Address, time int64) (float64, error)
+	CurrentPrice(token common.Address) (float64, error)
+	Decimals(token common.Address) (*big.Int, error)
+}
+
+type TokenPnl struct {
+	Symbol       string  `json:"symbol"`
+	Holding      float64 `json:"holding"`
+	CostBasis    float64 `json:"costBasis"`
+	CurrentPrice float64 `json:"currentPrice"`
+	Realized     float64 `json:"realized"`
+	Unrealized   float64 `json:"unrealized"`
+}
+
+// realized包含token处置收益, 手续费和分润支出以及LRC奖励
+type MarketPnl struct {
+	Market   string  `json:"market"`
+	Realized float64 `json:"realized"`
+	Fees     float64 `json:"fees"`
+	Rewards  float64 `json:"rewards"`
+}
+
+// estimatedFills为缺少历史价格而使用当前价格估算的成交数, skippedFills为token未知而未计入的成交数
+// unmatchedDisposals为卖出数量超过成交买入持仓的次数, 超出部分按卖出价计成本
+type PnlReport struct {
+	Owner              string      `json:"owner"`
+	Start              int64       `json:"start"`
+	End                int64       `json:"end"`
+	Method             string      `json:"method"`
+	Tokens             []TokenPnl  `json:"tokens"`
+	Markets            []MarketPnl `json:"markets"`
+	Realized           float64     `json:"realized"`
+	Unrealized         float64     `json:"unrealized"`
+	EstimatedFills     int         `json:"estimatedFills"`
+	SkippedFills       int         `json:"skippedFills"`
+	UnmatchedDisposals int         `json:"unmatchedDisposals"`
+}
+
+type costBook interface {
+	buy(qty, cost float64)
+	// 返回匹配到的数量及其成本
+	sell(qty float64) (matched, cost float64)
+	holding() (qty, cost float64)
+}
+
+type pnlLot struct {
+	qty  float64
+	cost float64
+}
+
+type fifoBook struct {
+	lots []pnlLot
+}
+
+func (b *fifoBook) buy(qty, cost float64) {
+	b.lots = append(b.lots, pnlLot{qty, cost})
+}
+
+func (b *fifoBook) sell(qty float64) (matched, cost float64) {
+	for len(b.lots) > 0 && matched < qty {
+		lot := &b.lots[0]
+		take := qty - matched
+		if take >= lot.qty {
+			matched += lot.qty
+			cost += lot.cost
+			b.lots = b.lots[1:]
+			continue
+		}
+		part := lot.cost * take / lot.qty
+		matched += take
+		cost += part
+		lot.qty -= take
+		lot.cost -= part
+	}
+	return matched, cost
+}
+
+func (b *fifoBook) holding() (qty, cost float64) {
+	for _, lot := range b.lots {
+		qty += lot.qty
+		cost += lot.cost
+	}
+	return qty, cost
+}
+
+type averageBook struct {
+	qty  float64
+	cost float64
+}
+
+func (b *averageBook) buy(qty, cost float64) {
+	b.qty += qty
+	b.cost += cost
+}
+
+func (b *averageBook) sell(qty float64) (matched, cost float64) {
+	if b.qty <= 0 {
+		return 0, 0
+	}
+	matched = qty
+	if matched > b.qty {
+		matched = b.qty
+	}
+	cost = b.cost * matched / b.qty
+	b.qty -= matched
+	b.cost -= cost
+	return matched, cost
+}
+
+func (b *averageBook) holding() (qty, cost float64) {
+	return b.qty, b.cost
+}
+
+type pnlCalculator struct {
+	provider PnlPriceProvider
+	method   string
+	start    int64
+	books    map[common.Address]costBook
+	tokens   map[common.Address]*TokenPnl
+	markets  map[string]*MarketPnl
+	report   *PnlReport
+}
+
+func BuildPnlReport(rds *dao.RdsService, provider PnlPriceProvider, owner common.Address, start, end int64, method string) (*PnlReport, error) {
+	// 成本需要从第一笔成交开始计算, 区间只影响已实现盈亏的统计范围
+	fills, err := rds.GetFillsByOwnerAndTime(owner.Hex(), 0, end)
+	if err != nil {
+		return nil, err
+	}
+	return CalculatePnl(provider, owner, fills, start, end, method)
+}
+
+// fills需按时间正序排列
+func CalculatePnl(provider PnlPriceProvider, owner common.Address, fills []dao.FillEvent, start, end int64, method string) (*PnlReport, error) {
+	if method == "" {
+		method = PNL_METHOD_FIFO
+	}
+	if method != PNL_METHOD_FIFO && method != PNL_METHOD_AVERAGE {
+		return nil, errors.New("pnl method only support fifo and average")
+	}
+
+	c := &pnlCalculator{
+		provider: provider,
+		method:   method,
+		start:    start,
+		books:    make(map[common.Address]costBook),
+		tokens:   make(map[common.Address]*TokenPnl),
+		markets:  make(map[string]*MarketPnl),
+		report:   &PnlReport{Owner: owner.Hex(), Start: start, End: end, Method: method, Tokens: make([]TokenPnl, 0), Markets: make([]MarketPnl, 0)},
+	}
+
+	for _, f := range fills {
+		c.handleFill(f)
+	}
+
+	for token, book := range c.books {
+		tokenPnl := c.token(token)
+		tokenPnl.Holding, tokenPnl.CostBasis = book.holding()
+		if tokenPnl.Holding > 0 {
+			price, err := provider.CurrentPrice(token)
+			if err != nil {
+				log.Debugf("pnl get current price of %s error:%s", token.Hex(), err.Error())
+			} else {
+				tokenPnl.CurrentPrice = price
+				tokenPnl.Unrealized = tokenPnl.Holding*price - tokenPnl.CostBasis
+			}
+		}
+	}
+
+	for _, t := range c.tokens {
+		c.report.Tokens = append(c.report.Tokens, *t)
+		c.report.Realized += t.Realized
+		c.report.Unrealized += t.Unrealized
+	}
+	for _, m := range c.markets {
+		c.report.Markets = append(c.report.Markets, *m)
+	}
+	// 手续费支出和奖励收入不属于token处置, 计入总的已实现盈亏
+	for _, m := range c.markets {
+		c.report.Realized += m.Rewards - m.Fees
+	}
+	sort.Slice(c.report.Tokens, func(i, j int) bool { return c.report.Tokens[i].Symbol < c.report.Tokens[j].Symbol })
+	sort.Slice(c.report.Markets, func(i, j int) bool { return c.report.Markets[i].Market < c.report.Markets[j].Market })
+
+	return c.report, nil
+}
+
+func (c *pnlCalculator) handleFill(f dao.FillEvent) {
+	tokenS := common.HexToAddress(f.TokenS)
+	tokenB := common.HexToAddress(f.TokenB)
+	lrc := marketutil.AliasToAddress("LRC")
+	inRange := f.CreateTime >= c.start
+
+	// 已下架等未知token的成交无法计算数量, 跳过并计数, 不影响其他成交
+	var qtyB float64
+	qtyS, err := c.quantity(tokenS, f.AmountS)
+	if err == nil {
+		qtyB, err = c.quantity(tokenB, f.AmountB)
+	}
+	if err != nil {
+		log.Debugf("pnl skip fill tx:%s, error:%s", f.TxHash, err.Error())
+		c.report.SkippedFills++
+		return
+	}
+
+	// 缺少一侧价格时按成交比例推算, 两侧都没有时使用当前价格
+	priceS, errS := c.provider.PriceAt(tokenS, f.CreateTime)
+	priceB, errB := c.provider.PriceAt(tokenB, f.CreateTime)
+	if errS != nil && errB == nil && qtyS > 0 {
+		priceS, errS = priceB*qtyB/qtyS, nil
+	} else if errB != nil && errS == nil && qtyB > 0 {
+		priceB, errB = priceS*qtyS/qtyB, nil
+	}
+	if errS != nil || errB != nil {
+		c.report.EstimatedFills++
+		if errS != nil {
+			priceS, _ = c.provider.CurrentPrice(tokenS)
+		}
+		if errB != nil {
+			priceB, _ = c.provider.CurrentPrice(tokenB)
+		}
+	}
+
+	// 买入token的成本为付出token的价值
+	value := qtyS * priceS
+	c.dispose(tokenS, f.Market, qtyS, value, inRange)
+	c.book(tokenB).buy(qtyB, value)
+
+	market := c.market(f.Market)
+	if split, err := c.quantity(tokenS, f.SplitS); err == nil && split > 0 {
+		c.dispose(tokenS, f.Market, split, split*priceS, inRange)
+		if inRange {
+			market.Fees += split * priceS
+			market.Realized -= split * priceS
+		}
+	}
+	if split, err := c.quantity(tokenB, f.SplitB); err == nil && split > 0 {
+		c.dispose(tokenB, f.Market, split, split*priceB, inRange)
+		if inRange {
+			market.Fees += split * priceB
+			market.Realized -= split * priceB
+		}
+	}
+
+	lrcFee, _ := c.quantity(lrc, f.LrcFee)
+	lrcReward, _ := c.quantity(lrc, f.LrcReward)
+	if lrcFee <= 0 && lrcReward <= 0 {
+		return
+	}
+	lrcPrice, err := c.provider.PriceAt(lrc, f.CreateTime)
+	if err != nil {
+		lrcPrice, _ = c.provider.CurrentPrice(lrc)
+	}
+	if lrcFee > 0 {
+		c.dispose(lrc, f.Market, lrcFee, lrcFee*lrcPrice, inRange)
+		if inRange {
+			market.Fees += lrcFee * lrcPrice
+			market.Realized -= lrcFee * lrcPrice
+		}
+	}
+	if lrcReward > 0 {
+		c.book(lrc).buy(lrcReward, lrcReward*lrcPrice)
+		if inRange {
+			market.Rewards += lrcReward * lrcPrice
+			market.Realized += lrcReward * lrcPrice
+		}
+	}
+}
+
+// 处置收益计入token和市场, 持仓不足的部分以处置价格作为成本
+func (c *pnlCalculator) dispose(token common.Address, market string, qty, proceeds float64, inRange bool) {
+	if qty <= 0 {
+		return
+	}
+	matched, cost := c.book(token).sell(qty)
+	if matched < qty {
+		cost += proceeds * (qty - matched) / qty
+		if inRange {
+			c.report.UnmatchedDisposals++
+		}
+	}
+	if !inRange {
+		return
+	}
+	gain := proceeds - cost
+	c.token(token).Realized += gain
+	c.market(market).Realized += gain
+}
+
+func (c *pnlCalculator) quantity(token common.Address, amount string) (float64, error) {
+	value, ok := new(big.Int).SetString(amount, 0)
+	if !ok {
+		return 0, errors.New("invalid amount:" + amount)
+	}
+	decimals, err := c.provider.Decimals(token)
+	if err != nil {
+		return 0, err
+	}
+	qty, _ := new(big.Rat).SetFrac(value, decimals).Float64()
+	return qty, nil
+}
+
+func (c *pnlCalculator) book(token common.Address) costBook {
+	if book, ok := c.books[token]; ok {
+		return book
+	}
+	var book costBook
+	if c.method == PNL_METHOD_AVERAGE {
+		book = &averageBook{}
+	} else {
+		book = &fifoBook{}
+	}
+	c.books[token] = book
+	return book
+}
+
+func (c *pnlCalculator) token(token common.Address) *TokenPnl {
+	if t, ok := c.tokens[token]; ok {
+		return t
+	}
+	symbol := marketutil.AddressToAlias(token.Hex())
+	if symbol == "" {
+		symbol = token.Hex()
+	}
+	t := &TokenPnl{Symbol: symbol}
+	c.tokens[token] = t
+	return t
+}
+
+func (c *pnlCalculator) market(market string) *MarketPnl {
+	if m, ok := c.markets[market]; ok {
+		return m
+	}
+	m := &MarketPnl{Market: market}
+	c.markets[market] = m
+	return m
+}
+
+type capPriceProvider struct {
+	rds *dao.RdsService
+	mc  marketcap.MarketCapProvider
+}
+
+func NewPnlPriceProvider(rds *dao.RdsService, mc marketcap.MarketCapProvider) PnlPriceProvider {
+	return &capPriceProvider{rds: rds, mc: mc}
+}
+
+func (p *capPriceProvider) PriceAt(token common.Address, time int64) (float64, error) {
+	price, err := p.rds.GetTokenUsdPriceAt(token.Hex(), time)
+	if err != nil {
+		return 0, ErrPnlPriceNotFound
+	}
+	if diff := price.Time - time; diff > pnlPriceTolerance || diff < -pnlPriceTolerance {
+		return 0, ErrPnlPriceNotFound
+	}
+	return price.Price, nil
+}
+
+func (p *capPriceProvider) CurrentPrice(token common.Address) (float64, error) {
+	price, err := p.mc.GetMarketCapByCurrency(token, pnlCurrency)
+	if err != nil {
+		return 0, err
+	}
+	v, _ := price.Float64()
+	return v, nil
+}
+
+func (p *capPriceProvider) Decimals(token common.Address) (*big.Int, error) {
+	t, err := marketutil.AddressToToken(token)
+	if err != nil {
+		return nil, err
+	}
+	return t.Decimals, nil
+}
+
+// 成交时记录双方token的美元价格, 作为计算历史成本的价格来源
+type FillPriceRecorder struct {
+	rds     *dao.RdsService
+	mc      marketcap.MarketCapProvider
+	watcher *eventemitter.Watcher
+}
+
+func NewFillPriceRecorder(rds *dao.RdsService, mc marketcap.MarketCapProvider) *FillPriceRecorder {
+	r := &FillPriceRecorder{rds: rds, mc: mc}
+	r.watcher = &eventemitter.Watcher{Concurrent: false, Handle: r.handleOrderFilled}
+	return r
+}
+
+func (r *FillPriceRecorder) Start() {
+	eventemitter.On(eventemitter.OrderFilled, r.watcher)
+}
+
+func (r *FillPriceRecorder) Stop() {
+	eventemitter.Un(eventemitter.OrderFilled, r.watcher)
+}
+
+func (r *FillPriceRecorder) handleOrderFilled(input eventemitter.EventData) error {
+	event := input.(*types.OrderFilledEvent)
+	if !IsFillPriceRecordable(event.BlockTime, time.Now().Unix()) {
+		log.Debugf("fill price recorder skip tx %s, block time %d is too far from now", event.TxHash.Hex(), event.BlockTime)
+		return nil
+	}
+	minute := event.BlockTime / 60 * 60
+	for _, token := range []common.Address{event.TokenS, event.TokenB, marketutil.AliasToAddress("LRC")} {
+		price, err := r.mc.GetMarketCapByCurrency(token, pnlCurrency)
+		if err != nil {
+			log.Debugf("fill price recorder get price of %s error:%s", token.Hex(), err.Error())
+			continue
+		}
+		v, _ := price.Float64()
+		if err := r.rds.SaveTokenUsdPrice(&dao.TokenUsdPrice{Token: token.Hex(), Time: minute, Price: v}); err != nil {
+			log.Errorf("fill price recorder save price of %s error:%s", token.Hex(), err.Error())
+		}
+	}
+	return nil
+}
+
+// 当前价格只能代表接近当前时间的成交价格
+func IsFillPriceRecordable(blockTime, now int64) bool {
+	diff := now - blockTime
+	return diff <= fillPriceRecordWindow && diff >= -fillPriceRecordWindow
+}
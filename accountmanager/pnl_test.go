@@ -0,0 +1,139 @@
+/*
+
+  Copyright 2017 Loopring Project Ltd (Loopring Foundation).
+
+  Licensed under the Apache License, Version 2.0 (the "License");
+  you may not use this file except in compliance with the License.
+  You may obtain a copy of the License at
+
+  http://www.apache.org/licenses/LICENSE-2.0
+
+  Unless required by applicable law or agreed to in writing, software
+  distributed under the License is distributed on an "AS IS" BASIS,
+  WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+  See the License for the specific language governing permissions and
+  limitations under the License.
+
+*/
+
+package accountmanager_test
+
+import (
+	"errors"
+	"github.com/Loopring/relay-cluster/accountmanager"
+	"github.com/Loopring/relay-cluster/dao"
+	"github.com/ethereum/go-ethereum/common"
+	"math"
+	"math/big"
+	"testing"
+)
+
+// 固定价格的stub, 数量单位为1, lrc未初始化时为零地址, unknown中的token没有精度
+type stubPriceProvider struct {
+	history map[common.Address]map[int64]float64
+	current map[common.Address]float64
+	unknown map[common.Address]bool
+}
+
+func (p *stubPriceProvider) PriceAt(token common.Address, time int64) (float64, error) {
+	if price, ok := p.history[token][time]; ok {
+		return price, nil
+	}
+	return 0, errors.New("no price")
+}
+
+func (p *stubPriceProvider) CurrentPrice(token common.Address) (float64, error) {
+	return p.current[token], nil
+}
+
+func (p *stubPriceProvider) Decimals(token common.Address) (*big.Int, error) {
+	if p.unknown[token] {
+		return nil, errors.New("unsupported token")
+	}
+	return big.NewInt(1), nil
+}
+
+func TestCalculatePnl(t *testing.T) {
+	initTestLogger()
+
+	var (
+		owner  = common.HexToAddress("0x1b978a1d302335a6f2ebe4b8823b5e17c3c84135")
+		tokenA = common.HexToAddress("0x000000000000000000000000000000000000000a")
+		tokenB = common.HexToAddress("0x000000000000000000000000000000000000000b")
+		tokenC = common.HexToAddress("0x000000000000000000000000000000000000000c")
+		tokenD = common.HexToAddress("0x000000000000000000000000000000000000000d")
+		lrc    = common.Address{}
+	)
+
+	provider := &stubPriceProvider{
+		history: map[common.Address]map[int64]float64{
+			tokenA: {10: 1, 15: 1.5, 20: 2},
+			tokenB: {10: 10, 15: 10, 20: 10, 30: 10},
+			lrc:    {20: 0.5},
+		},
+		current: map[common.Address]float64{tokenA: 3, tokenB: 10, tokenC: 4, lrc: 0.5},
+		unknown: map[common.Address]bool{tokenD: true},
+	}
+
+	fill := func(time int64, tokenS, tokenB common.Address, amountS, amountB, lrcFee string) dao.FillEvent {
+		return dao.FillEvent{CreateTime: time, Market: "A-B", TokenS: tokenS.Hex(), TokenB: tokenB.Hex(), AmountS: amountS, AmountB: amountB, LrcFee: lrcFee, LrcReward: "0", SplitS: "0", SplitB: "0"}
+	}
+	fills := []dao.FillEvent{
+		fill(10, tokenB, tokenA, "10", "100", "0"),
+		fill(15, tokenB, tokenA, "15", "100", "0"),
+		fill(20, tokenA, tokenB, "150", "30", "10"),
+		// tokenC没有历史价格, 按成交比例推算
+		fill(30, tokenB, tokenC, "1", "5", "0"),
+		// tokenD未知, 跳过不影响其他成交
+		fill(40, tokenB, tokenD, "1", "5", "0"),
+	}
+
+	cases := []struct {
+		method     string
+		start      int64
+		realized   float64
+		unrealized float64
+		unmatched  int
+	}{
+		{accountmanager.PNL_METHOD_FIFO, 0, 120, 85, 3},
+		{accountmanager.PNL_METHOD_AVERAGE, 0, 107.5, 97.5, 3},
+		{accountmanager.PNL_METHOD_FIFO, 16, 120, 85, 1},
+	}
+	for _, c := range cases {
+		report, err := accountmanager.CalculatePnl(provider, owner, fills, c.start, 100, c.method)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if math.Abs(report.Realized-c.realized) > 1e-9 || math.Abs(report.Unrealized-c.unrealized) > 1e-9 {
+			t.Fatalf("%s from %d should realize %f and unrealize %f, got %f %f", c.method, c.start, c.realized, c.unrealized, report.Realized, report.Unrealized)
+		}
+		if report.UnmatchedDisposals != c.unmatched || report.EstimatedFills != 0 || report.SkippedFills != 1 {
+			t.Fatalf("%s from %d should have %d unmatched disposals, got %d, estimated %d, skipped %d", c.method, c.start, c.unmatched, report.UnmatchedDisposals, report.EstimatedFills, report.SkippedFills)
+		}
+		if len(report.Markets) != 1 || math.Abs(report.Markets[0].Fees-5) > 1e-9 || math.Abs(report.Markets[0].Realized-c.realized) > 1e-9 {
+			t.Fatalf("market pnl error, got %+v", report.Markets)
+		}
+	}
+
+	if _, err := accountmanager.CalculatePnl(provider, owner, fills, 0, 100, "lifo"); err == nil {
+		t.Fatalf("unsupported method should return error")
+	}
+}
+
+func TestIsFillPriceRecordable(t *testing.T) {
+	now := int64(1530000000)
+	for _, c := range []struct {
+		blockTime int64
+		expected  bool
+	}{
+		{now, true},
+		{now - 600, true},
+		{now - 601, false},
+		{now - 24*3600, false},
+		{now + 30, true},
+	} {
+		if res := accountmanager.IsFillPriceRecordable(c.blockTime, now); res != c.expected {
+			t.Fatalf("block time %d should be recordable:%t, got %t", c.blockTime, c.expected, res)
+		}
+	}
+}
@@ -0,0 +1,383 @@
+/*
+
+  Copyright 2017 Loopring Project Ltd (Loopring Foundation).
+
+  Licensed under the Apache License, Version 2.0 (the "License");
+  you may not use this file except in compliance with the License.
+  You may obtain a copy of the License at
+
+  http://www.apache.org/licenses/LICENSE-2.0
+
+  Unless required by applicable law or agreed to in writing, software
+  distributed under the License is distributed on an "AS IS" BASIS,
+  WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+  See the License for the specific language governing permissions and
+  limitations under the License.
+
+*/
+
+package accountmanager
+
+import (
+	"encoding/base64"
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"github.com/Loopring/relay-cluster/dao"
+	txtyp "github.com/Loopring/relay-cluster/txmanager/types"
+	"github.com/Loopring/relay-lib/marketutil"
+	"github.com/Loopring/relay-lib/types"
+	"github.com/ethereum/go-ethereum/common"
+	"io"
+	"math/big"
+	"strconv"
+	"strings"
+)
+
+const (
+	STATEMENT_TYPE_SELL                = "sell"
+	STATEMENT_TYPE_BUY                 = "buy"
+	STATEMENT_TYPE_LRC_FEE             = "lrc_fee"
+	STATEMENT_TYPE_LRC_REWARD          = "lrc_reward"
+	STATEMENT_TYPE_SPLIT               = "split"
+	STATEMENT_TYPE_GAS                 = "gas"
+	STATEMENT_TYPE_CITY_PARTNER_REWARD = "city_partner_reward"
+)
+
+// 对账单条目, amount为原始数量, change为对余额的影响(approve不影响余额), 数量均为最小单位的十进制字符串
+// balance为该条目后的余额, 以区间开始前所有记录的累计值为初始余额
+type StatementEntry struct {
+	Time    int64  `json:"time"`
+	Type    string `json:"type"`
+	Symbol  string `json:"symbol"`
+	Amount  string `json:"amount"`
+	Change  string `json:"change"`
+	Balance string `json:"balance"`
+	TxHash  string `json:"txHash"`
+	Ref     string `json:"ref"`
+	Market  string `json:"market"`
+}
+
+// balances为本页最后一条记录后的余额, cursor为空表示已到区间末尾
+type Statement struct {
+	Owner    string            `json:"owner"`
+	Start    int64             `json:"start"`
+	End      int64             `json:"end"`
+	Entries  []StatementEntry  `json:"entries"`
+	Balances map[string]string `json:"balances"`
+	Cursor   string            `json:"cursor"`
+}
+
+// 对账单的数据来源, 均按(create_time, id)正序返回(t, id)之后且不晚于end的记录
+type StatementStore interface {
+	GetFillsByOwnerAfter(owner string, t int64, id int, end int64, limit int) ([]dao.FillEvent, error)
+	GetTxViewByOwnerAfter(owner string, t int64, id int, end int64, limit int) ([]dao.TransactionView, error)
+	GetReceivedDetailsByWalletAfter(walletAddress string, t int64, id int, end int64, limit int) ([]dao.CityPartnerReceivedDetail, error)
+	GetTxEntity(hashlist []string) ([]dao.TransactionEntity, error)
+	GetTxViewFirstIds(owner string, hashes []string, types []uint8) (map[string]int, error)
+}
+
+// 同一时间按成交, 转账, 奖励的顺序排列
+const (
+	statementSourceFill = iota
+	statementSourceTxView
+	statementSourceReward
+	statementSourceCount
+)
+
+type statementPosition struct {
+	Time int64 `json:"t"`
+	ID   int   `json:"i"`
+}
+
+// 游标记录每个来源最后处理的记录和此时的余额, 翻页时不需要重新统计之前的记录
+type statementCursor struct {
+	Positions [statementSourceCount]statementPosition `json:"p"`
+	Balances  map[string]string                       `json:"b"`
+}
+
+var ErrInvalidStatementCursor = errors.New("invalid statement cursor")
+
+var StatementCsvHeader = []string{"time", "type", "symbol", "amount", "change", "balance", "txHash", "ref", "market"}
+
+func (e StatementEntry) CsvRecord() []string {
+	return []string{strconv.FormatInt(e.Time, 10), e.Type, e.Symbol, e.Amount, e.Change, e.Balance, e.TxHash, e.Ref, e.Market}
+}
+
+// 成交明细已单独统计, tx view中由成交产生的记录不重复计入
+var statementTxTypes = map[txtyp.TxType]int{
+	txtyp.TX_TYPE_APPROVE:         0,
+	txtyp.TX_TYPE_SEND:            -1,
+	txtyp.TX_TYPE_RECEIVE:         1,
+	txtyp.TX_TYPE_CONVERT_INCOME:  1,
+	txtyp.TX_TYPE_CONVERT_OUTCOME: -1,
+	txtyp.TX_TYPE_CANCEL_ORDER:    0,
+	txtyp.TX_TYPE_CUTOFF:          0,
+	txtyp.TX_TYPE_CUTOFF_PAIR:     0,
+}
+
+// cursor为空时从start开始, 初始余额为start之前所有记录的累计值; 每页约pageSize条, 同一记录产生的条目不拆分到两页
+func BuildStatement(store StatementStore, owner common.Address, start, end int64, cursor string, pageSize int) (*Statement, error) {
+	var (
+		c   *statementCursor
+		err error
+	)
+	if cursor == "" {
+		balances, err := openingBalances(store, owner, start, pageSize)
+		if err != nil {
+			return nil, err
+		}
+		c = &statementCursor{Balances: balances}
+		for i := range c.Positions {
+			c.Positions[i] = statementPosition{Time: start}
+		}
+	} else if c, err = decodeStatementCursor(cursor); err != nil {
+		return nil, err
+	}
+
+	entries, more, err := nextStatementEntries(store, owner, end, c, pageSize)
+	if err != nil {
+		return nil, err
+	}
+
+	statement := &Statement{Owner: owner.Hex(), Start: start, End: end, Entries: entries, Balances: c.Balances}
+	if more {
+		if statement.Cursor, err = encodeStatementCursor(c); err != nil {
+			return nil, err
+		}
+	}
+	return statement, nil
+}
+
+func openingBalances(store StatementStore, owner common.Address, start int64, pageSize int) (map[string]string, error) {
+	c := &statementCursor{Balances: make(map[string]string)}
+	// end为0表示不限制, 此时start之前没有记录
+	if start <= 1 {
+		return c.Balances, nil
+	}
+	for {
+		_, more, err := nextStatementEntries(store, owner, start-1, c, pageSize)
+		if err != nil || !more {
+			return c.Balances, err
+		}
+	}
+}
+
+// 按(时间, 来源, id)合并各来源的记录, 并推进游标中的位置和余额
+// 某个来源取满且已用完时无法确定后续顺序, 留到下一页
+func nextStatementEntries(store StatementStore, owner common.Address, end int64, c *statementCursor, pageSize int) ([]StatementEntry, bool, error) {
+	limit := pageSize + 1
+	pos := c.Positions
+	fills, err := store.GetFillsByOwnerAfter(owner.Hex(), pos[statementSourceFill].Time, pos[statementSourceFill].ID, end, limit)
+	if err != nil {
+		return nil, false, err
+	}
+	views, err := store.GetTxViewByOwnerAfter(owner.Hex(), pos[statementSourceTxView].Time, pos[statementSourceTxView].ID, end, limit)
+	if err != nil {
+		return nil, false, err
+	}
+	rewards, err := store.GetReceivedDetailsByWalletAfter(owner.Hex(), pos[statementSourceReward].Time, pos[statementSourceReward].ID, end, limit)
+	if err != nil {
+		return nil, false, err
+	}
+
+	// gas按交易计一次, 记在该交易的第一条记录上, 只统计owner发起且已上链的交易
+	var hashes []string
+	for _, v := range views {
+		hashes = append(hashes, v.TxHash)
+	}
+	entityMap := make(map[string]dao.TransactionEntity)
+	firstIds := make(map[string]int)
+	if len(hashes) > 0 {
+		entities, err := store.GetTxEntity(hashes)
+		if err != nil {
+			return nil, false, err
+		}
+		for _, e := range entities {
+			entityMap[e.TxHash] = e
+		}
+		if firstIds, err = store.GetTxViewFirstIds(owner.Hex(), hashes, statementTxTypeList()); err != nil {
+			return nil, false, err
+		}
+	}
+
+	sizes := [statementSourceCount]int{len(fills), len(views), len(rewards)}
+	times := func(src, i int) (int64, int) {
+		switch src {
+		case statementSourceFill:
+			return fills[i].CreateTime, fills[i].ID
+		case statementSourceTxView:
+			return views[i].CreateTime, views[i].ID
+		default:
+			return rewards[i].CreateTime, rewards[i].ID
+		}
+	}
+
+	var idx [statementSourceCount]int
+	entries := make([]StatementEntry, 0)
+	for len(entries) < pageSize {
+		src := -1
+		var next int64
+		for k := 0; k < statementSourceCount; k++ {
+			if idx[k] == sizes[k] {
+				if sizes[k] == limit {
+					src = -1
+					break
+				}
+				continue
+			}
+			if t, _ := times(k, idx[k]); src < 0 || t < next {
+				src, next = k, t
+			}
+		}
+		if src < 0 {
+			break
+		}
+
+		switch src {
+		case statementSourceFill:
+			entries = appendFillEntries(entries, fills[idx[src]])
+		case statementSourceTxView:
+			v := views[idx[src]]
+			entity, ok := entityMap[v.TxHash]
+			entries = appendTxViewEntries(entries, owner, v, entity, ok && firstIds[v.TxHash] == v.ID)
+		default:
+			r := rewards[idx[src]]
+			entries = appendStatementEntry(entries, r.CreateTime, STATEMENT_TYPE_CITY_PARTNER_REWARD, r.TokenSymbol, r.Amount, 1, "", r.Orderhash, "")
+		}
+		t, id := times(src, idx[src])
+		c.Positions[src] = statementPosition{Time: t, ID: id}
+		idx[src]++
+	}
+
+	for i := range entries {
+		balance, _ := new(big.Int).SetString(c.Balances[entries[i].Symbol], 10)
+		if balance == nil {
+			balance = big.NewInt(0)
+		}
+		change, _ := new(big.Int).SetString(entries[i].Change, 10)
+		balance.Add(balance, change)
+		entries[i].Balance = balance.String()
+		c.Balances[entries[i].Symbol] = entries[i].Balance
+	}
+
+	more := false
+	for k := 0; k < statementSourceCount; k++ {
+		more = more || idx[k] < sizes[k] || sizes[k] == limit
+	}
+	return entries, more, nil
+}
+
+func appendFillEntries(entries []StatementEntry, f dao.FillEvent) []StatementEntry {
+	symbolS := statementSymbol(f.TokenS)
+	symbolB := statementSymbol(f.TokenB)
+	entries = appendStatementEntry(entries, f.CreateTime, STATEMENT_TYPE_SELL, symbolS, f.AmountS, -1, f.TxHash, f.OrderHash, f.Market)
+	entries = appendStatementEntry(entries, f.CreateTime, STATEMENT_TYPE_BUY, symbolB, f.AmountB, 1, f.TxHash, f.OrderHash, f.Market)
+	entries = appendStatementEntry(entries, f.CreateTime, STATEMENT_TYPE_LRC_FEE, txtyp.SYMBOL_LRC, f.LrcFee, -1, f.TxHash, f.OrderHash, f.Market)
+	entries = appendStatementEntry(entries, f.CreateTime, STATEMENT_TYPE_LRC_REWARD, txtyp.SYMBOL_LRC, f.LrcReward, 1, f.TxHash, f.OrderHash, f.Market)
+	entries = appendStatementEntry(entries, f.CreateTime, STATEMENT_TYPE_SPLIT, symbolS, f.SplitS, -1, f.TxHash, f.OrderHash, f.Market)
+	entries = appendStatementEntry(entries, f.CreateTime, STATEMENT_TYPE_SPLIT, symbolB, f.SplitB, -1, f.TxHash, f.OrderHash, f.Market)
+	return entries
+}
+
+func appendTxViewEntries(entries []StatementEntry, owner common.Address, v dao.TransactionView, entity dao.TransactionEntity, chargeGas bool) []StatementEntry {
+	sign, ok := statementTxTypes[txtyp.TxType(v.Type)]
+	if !ok {
+		return entries
+	}
+	typ := txtyp.TxType(v.Type)
+	status := types.TxStatus(v.Status)
+	if status == types.TX_STATUS_SUCCESS {
+		if typ == txtyp.TX_TYPE_APPROVE {
+			entries = append(entries, StatementEntry{Time: v.CreateTime, Type: txtyp.TypeStr(typ), Symbol: v.Symbol, Amount: v.Amount, Change: "0", TxHash: v.TxHash})
+		} else if sign != 0 {
+			entries = appendStatementEntry(entries, v.CreateTime, txtyp.TypeStr(typ), v.Symbol, v.Amount, sign, v.TxHash, "", "")
+		}
+	}
+
+	if !chargeGas || !strings.EqualFold(entity.From, owner.Hex()) {
+		return entries
+	}
+	if status != types.TX_STATUS_SUCCESS && status != types.TX_STATUS_FAILED {
+		return entries
+	}
+	gasUsed, ok1 := new(big.Int).SetString(entity.GasUsed, 0)
+	gasPrice, ok2 := new(big.Int).SetString(entity.GasPrice, 0)
+	if !ok1 || !ok2 {
+		return entries
+	}
+	return appendStatementEntry(entries, v.CreateTime, STATEMENT_TYPE_GAS, txtyp.SYMBOL_ETH, new(big.Int).Mul(gasUsed, gasPrice).String(), -1, v.TxHash, "", "")
+}
+
+func statementTxTypeList() []uint8 {
+	list := make([]uint8, 0, len(statementTxTypes))
+	for typ := range statementTxTypes {
+		list = append(list, uint8(typ))
+	}
+	return list
+}
+
+func encodeStatementCursor(c *statementCursor) (string, error) {
+	bs, err := json.Marshal(c)
+	if err != nil {
+		return "", err
+	}
+	return base64.RawURLEncoding.EncodeToString(bs), nil
+}
+
+func decodeStatementCursor(s string) (*statementCursor, error) {
+	bs, err := base64.RawURLEncoding.DecodeString(s)
+	if err != nil {
+		return nil, ErrInvalidStatementCursor
+	}
+	var c statementCursor
+	if err := json.Unmarshal(bs, &c); err != nil {
+		return nil, ErrInvalidStatementCursor
+	}
+	if c.Balances == nil {
+		c.Balances = make(map[string]string)
+	}
+	for _, balance := range c.Balances {
+		if _, ok := new(big.Int).SetString(balance, 10); !ok {
+			return nil, ErrInvalidStatementCursor
+		}
+	}
+	return &c, nil
+}
+
+// 数量为0或无法解析的记录忽略
+func appendStatementEntry(entries []StatementEntry, time int64, typ, symbol, amount string, sign int, txHash, ref, market string) []StatementEntry {
+	value, ok := new(big.Int).SetString(amount, 0)
+	if !ok || value.Sign() <= 0 {
+		return entries
+	}
+
+	change := new(big.Int).Set(value)
+	if sign < 0 {
+		change.Neg(change)
+	}
+	return append(entries, StatementEntry{Time: time, Type: typ, Symbol: symbol, Amount: value.String(), Change: change.String(), TxHash: txHash, Ref: ref, Market: market})
+}
+
+func statementSymbol(token string) string {
+	if symbol := marketutil.AddressToAlias(token); symbol != "" {
+		return symbol
+	}
+	return token
+}
+
+func WriteStatementCsv(w io.Writer, entries []StatementEntry, withHeader bool) error {
+	writer := csv.NewWriter(w)
+	if withHeader {
+		if err := writer.Write(StatementCsvHeader); err != nil {
+			return err
+		}
+	}
+	for _, e := range entries {
+		if err := writer.Write(e.CsvRecord()); err != nil {
+			return err
+		}
+	}
+	writer.Flush()
+	return writer.Error()
+}
@@ -0,0 +1,173 @@
+/*
+
+  Copyright 2017 Loopring Project Ltd (Loopring Foundation).
+
+  Licensed under the Apache License, Version 2.0 (the "License");
+  you may not use this file except in compliance with the License.
+  You may obtain a copy of the License at
+
+  http://www.apache.org/licenses/LICENSE-2.0
+
+  Unless required by applicable law or agreed to in writing, software
+  distributed under the License is distributed on an "AS IS" BASIS,
+  WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+  See the License for the specific language governing permissions and
+  limitations under the License.
+
+*/
+
+package accountmanager_test
+
+import (
+	"github.com/Loopring/relay-cluster/accountmanager"
+	"github.com/Loopring/relay-cluster/dao"
+	txtyp "github.com/Loopring/relay-cluster/txmanager/types"
+	"github.com/Loopring/relay-lib/types"
+	"github.com/ethereum/go-ethereum/common"
+	"testing"
+)
+
+type memStatementStore struct {
+	fills    []dao.FillEvent
+	views    []dao.TransactionView
+	entities []dao.TransactionEntity
+	rewards  []dao.CityPartnerReceivedDetail
+}
+
+// 测试数据已按(create_time, id)正序排列
+func statementRows(n int, at func(i int) (int64, int), t int64, id int, end int64, limit int) []int {
+	var rows []int
+	for i := 0; i < n && len(rows) < limit; i++ {
+		ct, cid := at(i)
+		if (ct > t || (ct == t && cid > id)) && (end <= 0 || ct <= end) {
+			rows = append(rows, i)
+		}
+	}
+	return rows
+}
+
+func (s *memStatementStore) GetFillsByOwnerAfter(owner string, t int64, id int, end int64, limit int) ([]dao.FillEvent, error) {
+	var res []dao.FillEvent
+	for _, i := range statementRows(len(s.fills), func(i int) (int64, int) { return s.fills[i].CreateTime, s.fills[i].ID }, t, id, end, limit) {
+		res = append(res, s.fills[i])
+	}
+	return res, nil
+}
+
+func (s *memStatementStore) GetTxViewByOwnerAfter(owner string, t int64, id int, end int64, limit int) ([]dao.TransactionView, error) {
+	var res []dao.TransactionView
+	for _, i := range statementRows(len(s.views), func(i int) (int64, int) { return s.views[i].CreateTime, s.views[i].ID }, t, id, end, limit) {
+		res = append(res, s.views[i])
+	}
+	return res, nil
+}
+
+func (s *memStatementStore) GetReceivedDetailsByWalletAfter(walletAddress string, t int64, id int, end int64, limit int) ([]dao.CityPartnerReceivedDetail, error) {
+	var res []dao.CityPartnerReceivedDetail
+	for _, i := range statementRows(len(s.rewards), func(i int) (int64, int) { return s.rewards[i].CreateTime, s.rewards[i].ID }, t, id, end, limit) {
+		res = append(res, s.rewards[i])
+	}
+	return res, nil
+}
+
+func (s *memStatementStore) GetTxEntity(hashlist []string) ([]dao.TransactionEntity, error) {
+	return s.entities, nil
+}
+
+func (s *memStatementStore) GetTxViewFirstIds(owner string, hashes []string, typeList []uint8) (map[string]int, error) {
+	ids := make(map[string]int)
+	for _, v := range s.views {
+		if id, ok := ids[v.TxHash]; !ok || v.ID < id {
+			ids[v.TxHash] = v.ID
+		}
+	}
+	return ids, nil
+}
+
+func TestBuildStatement(t *testing.T) {
+	owner := common.HexToAddress("0x1b978a1d302335a6f2ebe4b8823b5e17c3c84135")
+	tokenS := "0x0000000000000000000000000000000000000001"
+	tokenB := "0x0000000000000000000000000000000000000002"
+
+	store := &memStatementStore{
+		fills: []dao.FillEvent{
+			{ID: 1, CreateTime: 20, TxHash: "0xf1", OrderHash: "0xo1", TokenS: tokenS, TokenB: tokenB, AmountS: "100", AmountB: "50", LrcFee: "7", LrcReward: "0", SplitS: "0", SplitB: "2"},
+		},
+		views: []dao.TransactionView{
+			{ID: 1, CreateTime: 5, TxHash: "0xt0", Symbol: tokenS, Amount: "1000", Type: uint8(txtyp.TX_TYPE_RECEIVE), Status: uint8(types.TX_STATUS_SUCCESS)},
+			{ID: 2, CreateTime: 10, TxHash: "0xt1", Symbol: tokenS, Amount: "300", Type: uint8(txtyp.TX_TYPE_RECEIVE), Status: uint8(types.TX_STATUS_SUCCESS)},
+			{ID: 3, CreateTime: 30, TxHash: "0xt2", Symbol: tokenS, Amount: "1000", Type: uint8(txtyp.TX_TYPE_APPROVE), Status: uint8(types.TX_STATUS_SUCCESS)},
+			{ID: 4, CreateTime: 40, TxHash: "0xt3", Symbol: tokenS, Amount: "80", Type: uint8(txtyp.TX_TYPE_SEND), Status: uint8(types.TX_STATUS_FAILED)},
+			{ID: 5, CreateTime: 50, TxHash: "0xt4", Symbol: tokenB, Amount: "48", Type: uint8(txtyp.TX_TYPE_SELL), Status: uint8(types.TX_STATUS_SUCCESS)},
+			{ID: 6, CreateTime: 60, TxHash: "0xt5", Symbol: "ETH", Amount: "10", Type: uint8(txtyp.TX_TYPE_CONVERT_OUTCOME), Status: uint8(types.TX_STATUS_SUCCESS)},
+			{ID: 7, CreateTime: 60, TxHash: "0xt5", Symbol: "WETH", Amount: "10", Type: uint8(txtyp.TX_TYPE_CONVERT_INCOME), Status: uint8(types.TX_STATUS_SUCCESS)},
+		},
+		entities: []dao.TransactionEntity{
+			{TxHash: "0xt0", From: "0x0000000000000000000000000000000000000009", GasUsed: "21000", GasPrice: "1"},
+			{TxHash: "0xt1", From: "0x0000000000000000000000000000000000000009", GasUsed: "21000", GasPrice: "1"},
+			{TxHash: "0xt2", From: owner.Hex(), GasUsed: "40000", GasPrice: "2"},
+			{TxHash: "0xt3", From: owner.Hex(), GasUsed: "21000", GasPrice: "2"},
+			{TxHash: "0xt5", From: owner.Hex(), GasUsed: "30000", GasPrice: "1"},
+		},
+		rewards: []dao.CityPartnerReceivedDetail{
+			{ID: 1, CreateTime: 25, TokenSymbol: "LRC", Amount: "0x10", Orderhash: "0xo2"},
+		},
+	}
+
+	// 区间开始前收到的1000作为初始余额
+	expected := []struct {
+		typ     string
+		symbol  string
+		change  string
+		balance string
+	}{
+		{"receive", tokenS, "300", "1300"},
+		{accountmanager.STATEMENT_TYPE_SELL, tokenS, "-100", "1200"},
+		{accountmanager.STATEMENT_TYPE_BUY, tokenB, "50", "50"},
+		{accountmanager.STATEMENT_TYPE_LRC_FEE, "LRC", "-7", "-7"},
+		{accountmanager.STATEMENT_TYPE_SPLIT, tokenB, "-2", "48"},
+		{accountmanager.STATEMENT_TYPE_CITY_PARTNER_REWARD, "LRC", "16", "9"},
+		{"approve", tokenS, "0", "1200"},
+		{accountmanager.STATEMENT_TYPE_GAS, "ETH", "-80000", "-80000"},
+		{accountmanager.STATEMENT_TYPE_GAS, "ETH", "-42000", "-122000"},
+		{"convert_outcome", "ETH", "-10", "-122010"},
+		{accountmanager.STATEMENT_TYPE_GAS, "ETH", "-30000", "-152010"},
+		{"convert_income", "WETH", "10", "10"},
+	}
+
+	for _, pageSize := range []int{1, 2, 3, 100} {
+		var (
+			entries   []accountmanager.StatementEntry
+			statement *accountmanager.Statement
+			cursor    string
+			err       error
+		)
+		for pages := 0; pages == 0 || cursor != ""; pages++ {
+			if pages > len(expected) {
+				t.Fatalf("page size %d: statement doesn't end", pageSize)
+			}
+			if statement, err = accountmanager.BuildStatement(store, owner, 8, 100, cursor, pageSize); err != nil {
+				t.Fatalf("page size %d: build statement error:%s", pageSize, err.Error())
+			}
+			entries = append(entries, statement.Entries...)
+			cursor = statement.Cursor
+		}
+
+		if len(entries) != len(expected) {
+			t.Fatalf("page size %d: statement should have %d entries, got %d", pageSize, len(expected), len(entries))
+		}
+		for i, e := range expected {
+			entry := entries[i]
+			if entry.Type != e.typ || entry.Symbol != e.symbol || entry.Change != e.change || entry.Balance != e.balance {
+				t.Fatalf("page size %d: entry %d should be %+v, got %+v", pageSize, i, e, entry)
+			}
+		}
+		if statement.Balances[tokenS] != "1200" || statement.Balances["ETH"] != "-152010" || statement.Balances["WETH"] != "10" {
+			t.Fatalf("page size %d: closing balances error, got %+v", pageSize, statement.Balances)
+		}
+	}
+
+	if _, err := accountmanager.BuildStatement(store, owner, 8, 100, "invalid", 10); err != accountmanager.ErrInvalidStatementCursor {
+		t.Fatalf("invalid cursor should be rejected, got %v", err)
+	}
+}
@@ -502,6 +502,8 @@ func (accountAllowances *AccountAllowances) getOrSave(ttl int64, tokens, spender
 type ChangedOfBlock struct {
 	currentBlockNumber *big.Int
 	cachedDuration     *big.Int
+	snapshotBalances   map[string]bool
+	snapshotAllowances map[string]bool
 }
 
 func (b *ChangedOfBlock) saveBalanceKey(owner, token common.Address) error {
@@ -578,6 +580,7 @@ func (b *ChangedOfBlock) syncAndSaveBalances(ttl, ethTtl int64) (map[common.Addr
 			balance.LastBlock = types.NewBigPtr(b.currentBlockNumber)
 			balance.Balance = &req.Balance
 			accounts[req.Owner].Balances[req.Token] = balance
+			b.markBalanceSnapshot(req.Owner, req.Token)
 		}
 	}
 	for _, balances := range accounts {
@@ -660,6 +663,7 @@ func (b *ChangedOfBlock) syncAndSaveAllowances() (map[common.Address]bool, error
 				accountAllowances[req.Owner].Allowances[req.Token] = make(map[common.Address]Allowance)
 			}
 			accountAllowances[req.Owner].Allowances[req.Token][req.Spender] = allowance
+			b.markAllowanceSnapshot(req.Owner, req.Token, req.Spender)
 		}
 	}
 	for _, allowances := range accountAllowances {
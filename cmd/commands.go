@@ -0,0 +1,214 @@
+/*
+
+  Copyright 2017 Loopring Project Ltd (Loopring Foundation).
+
+  Licensed under the Apache License, Version 2.0 (the "License");
+  you may not use this file except in compliance with the License.
+  You may obtain a copy of the License at
+
+  http://www.apache.org/licenses/LICENSE-2.0
+
+  Unless required by applicable law or agreed to in writing, software
+  distributed under the License is distributed on an "AS IS" BASIS,
+  WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+  See the License for the specific language governing permissions and
+  limitations under the License.
+
+*/
+
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"reflect"
+	"strconv"
+	"time"
+
+	"github.com/Loopring/relay-cluster/accountmanager"
+	"github.com/Loopring/relay-cluster/dao"
+	"github.com/Loopring/relay-cluster/node"
+	"github.com/Loopring/relay-lib/log"
+	"github.com/Loopring/relay-lib/marketutil"
+	"github.com/ethereum/go-ethereum/common"
+	"gopkg.in/urfave/cli.v1"
+)
+
+const statementExportPageSize = 1000
+
+func commands() []cli.Command {
+	return []cli.Command{
+		{
+			Name:   "rebuild-cutoff-index",
+			Usage:  "rebuild cutoff index from cutoff and cutoffPair event tables",
+			Action: rebuildCutoffIndex,
+		},
+		{
+			Name:   "export-statement",
+			Usage:  "export the statement of fills, transfers and fees for an owner as csv or json",
+			Action: exportStatement,
+			Flags: []cli.Flag{
+				cli.StringFlag{Name: "owner", Usage: "owner address"},
+				cli.StringFlag{Name: "start", Usage: "start date(2006-01-02) or unix timestamp"},
+				cli.StringFlag{Name: "end", Usage: "end date(2006-01-02, inclusive) or unix timestamp, default is now"},
+				cli.StringFlag{Name: "format", Value: "csv", Usage: "csv or json"},
+				cli.StringFlag{Name: "output,o", Usage: "output file, default is stdout"},
+			},
+		},
+	}
+}
+
+func rebuildCutoffIndex(ctx *cli.Context) error {
+	file := ""
+	if ctx.GlobalIsSet("config") {
+		file = ctx.GlobalString("config")
+	}
+	globalConfig := node.LoadConfig(file)
+	if _, err := node.Validator(reflect.ValueOf(globalConfig).Elem()); nil != err {
+		return err
+	}
+
+	logger := log.Initialize(globalConfig.Log)
+	defer func() {
+		if nil != logger {
+			logger.Sync()
+		}
+	}()
+
+	rds := dao.NewDb(&globalConfig.Mysql)
+	count, err := rds.RebuildCutoffIndex()
+	if err != nil {
+		return err
+	}
+
+	fmt.Printf("cutoff index rebuilt, %d records\n", count)
+	return nil
+}
+
+func exportStatement(ctx *cli.Context) error {
+	owner := ctx.String("owner")
+	if !common.IsHexAddress(owner) {
+		return errors.New("owner isn't a valid hex-address")
+	}
+	start, err := parseStatementTime(ctx.String("start"), false)
+	if err != nil {
+		return err
+	}
+	end, err := parseStatementTime(ctx.String("end"), true)
+	if err != nil {
+		return err
+	}
+	if end == 0 {
+		end = time.Now().Unix()
+	}
+	format := ctx.String("format")
+	if format != "csv" && format != "json" {
+		return errors.New("format only support csv and json")
+	}
+
+	file := ""
+	if ctx.GlobalIsSet("config") {
+		file = ctx.GlobalString("config")
+	}
+	globalConfig := node.LoadConfig(file)
+	if _, err := node.Validator(reflect.ValueOf(globalConfig).Elem()); nil != err {
+		return err
+	}
+
+	logger := log.Initialize(globalConfig.Log)
+	defer func() {
+		if nil != logger {
+			logger.Sync()
+		}
+	}()
+
+	rds := dao.NewDb(&globalConfig.Mysql)
+	marketutil.Initialize(&globalConfig.Market)
+
+	var out io.Writer = os.Stdout
+	if path := ctx.String("output"); path != "" {
+		f, err := os.Create(path)
+		if err != nil {
+			return err
+		}
+		defer f.Close()
+		out = f
+	}
+
+	// 按页导出, 避免大账户一次加载所有记录; json格式逐条写入entries
+	var (
+		statement *accountmanager.Statement
+		cursor    string
+		count     int
+	)
+	for {
+		if statement, err = accountmanager.BuildStatement(rds, common.HexToAddress(owner), start, end, cursor, statementExportPageSize); err != nil {
+			return err
+		}
+		if format == "csv" {
+			err = accountmanager.WriteStatementCsv(out, statement.Entries, cursor == "")
+		} else {
+			err = writeStatementJsonEntries(out, statement, cursor == "", &count)
+		}
+		if err != nil {
+			return err
+		}
+		if cursor = statement.Cursor; cursor == "" {
+			break
+		}
+	}
+
+	if format == "json" {
+		balances, err := json.Marshal(statement.Balances)
+		if err != nil {
+			return err
+		}
+		_, err = fmt.Fprintf(out, "\n  ],\n  \"balances\": %s\n}\n", balances)
+		return err
+	}
+	return nil
+}
+
+func writeStatementJsonEntries(out io.Writer, statement *accountmanager.Statement, withHeader bool, count *int) error {
+	if withHeader {
+		if _, err := fmt.Fprintf(out, "{\n  \"owner\": %q,\n  \"start\": %d,\n  \"end\": %d,\n  \"entries\": [", statement.Owner, statement.Start, statement.End); err != nil {
+			return err
+		}
+	}
+	for _, e := range statement.Entries {
+		bs, err := json.Marshal(e)
+		if err != nil {
+			return err
+		}
+		sep := ","
+		if *count == 0 {
+			sep = ""
+		}
+		if _, err = fmt.Fprintf(out, "%s\n    %s", sep, bs); err != nil {
+			return err
+		}
+		*count++
+	}
+	return nil
+}
+
+// 日期按UTC解析, 结束日期包含当天
+func parseStatementTime(value string, isEnd bool) (int64, error) {
+	if value == "" {
+		return 0, nil
+	}
+	if ts, err := strconv.ParseInt(value, 10, 64); err == nil {
+		return ts, nil
+	}
+	t, err := time.Parse("2006-01-02", value)
+	if err != nil {
+		return 0, fmt.Errorf("invalid time %s, should be 2006-01-02 or unix timestamp", value)
+	}
+	if isEnd {
+		return t.AddDate(0, 0, 1).Unix() - 1, nil
+	}
+	return t.Unix(), nil
+}
@@ -39,6 +39,7 @@ func main() {
 	app.Copyright = "Copyright 2013-2017 The Loopring Authors"
 	globalFlags := globalFlags()
 	app.Flags = append(app.Flags, globalFlags...)
+	app.Commands = commands()
 
 	app.Before = func(ctx *cli.Context) error {
 		runtime.GOMAXPROCS(runtime.NumCPU())
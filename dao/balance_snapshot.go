@@ -0,0 +1,78 @@
+/*
+
+  Copyright 2017 Loopring Project Ltd (Loopring Foundation).
+
+  Licensed under the Apache License, Version 2.0 (the "License");
+  you may not use this file except in compliance with the License.
+  You may obtain a copy of the License at
+
+  http://www.apache.org/licenses/LICENSE-2.0
+
+  Unless required by applicable law or agreed to in writing, software
+  distributed under the License is distributed on an "AS IS" BASIS,
+  WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+  See the License for the specific language governing permissions and
+  limitations under the License.
+
+*/
+
+package dao
+
+// 区块结束时按区块高度读取的余额和授权额度, 只记录发生变化的owner/token
+// 余额记录的spender为空, 授权按spender分别记录, 数量为16进制字符串
+type BalanceSnapshot struct {
+	ID          int    `gorm:"column:id;primary_key;" json:"-"`
+	Owner       string `gorm:"column:owner;type:varchar(42);unique_index:idx_balance_snapshot_owner_token_spender_block" json:"owner"`
+	Token       string `gorm:"column:token;type:varchar(42);unique_index:idx_balance_snapshot_owner_token_spender_block" json:"token"`
+	Spender     string `gorm:"column:spender;type:varchar(42);unique_index:idx_balance_snapshot_owner_token_spender_block" json:"spender"`
+	BlockNumber int64  `gorm:"column:block_number;type:bigint;unique_index:idx_balance_snapshot_owner_token_spender_block;index" json:"blockNumber"`
+	BlockTime   int64  `gorm:"column:block_time;type:bigint" json:"blockTime"`
+	Balance     string `gorm:"column:balance;type:varchar(66)" json:"balance"`
+	Allowance   string `gorm:"column:allowance;type:varchar(66)" json:"allowance"`
+}
+
+func (s *RdsService) SaveBalanceSnapshot(snapshot *BalanceSnapshot) error {
+	var current BalanceSnapshot
+	err := s.Db.Where("owner = ? and token = ? and spender = ? and block_number = ?", snapshot.Owner, snapshot.Token, snapshot.Spender, snapshot.BlockNumber).First(&current).Error
+	if err != nil {
+		return s.Add(snapshot)
+	}
+
+	items := map[string]interface{}{"block_time": snapshot.BlockTime}
+	if snapshot.Balance != "" {
+		items["balance"] = snapshot.Balance
+	}
+	if snapshot.Allowance != "" {
+		items["allowance"] = snapshot.Allowance
+	}
+	return s.Db.Model(&BalanceSnapshot{}).Where("id = ?", current.ID).Updates(items).Error
+}
+
+// 按区块正序返回余额记录及spender的授权记录, spender为空时只返回余额; 0表示不限制
+func (s *RdsService) GetBalanceSnapshots(owner, token, spender string, start, end int64, limit int) ([]BalanceSnapshot, error) {
+	var snapshots []BalanceSnapshot
+	db := s.Db.Where("owner = ? and token = ?", owner, token).Where("spender in (?)", []string{"", spender})
+	if start > 0 {
+		db = db.Where("block_time >= ?", start)
+	}
+	if end > 0 {
+		db = db.Where("block_time <= ?", end)
+	}
+	if limit > 0 {
+		db = db.Limit(limit)
+	}
+	err := db.Order("block_number ASC").Order("spender ASC").Find(&snapshots).Error
+	return snapshots, err
+}
+
+// 区间开始前最后一次记录的余额(spender为空)或授权, 用于补齐时间序列的起点
+func (s *RdsService) GetLatestBalanceSnapshotBefore(owner, token, spender string, t int64) (BalanceSnapshot, error) {
+	var snapshot BalanceSnapshot
+	err := s.Db.Where("owner = ? and token = ? and spender = ? and block_time < ?", owner, token, spender, t).
+		Order("block_number DESC").First(&snapshot).Error
+	return snapshot, err
+}
+
+func (s *RdsService) DeleteBalanceSnapshots(from, to int64) error {
+	return s.Db.Where("block_number > ? and block_number <= ?", from, to).Delete(&BalanceSnapshot{}).Error
+}
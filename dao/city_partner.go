@@ -133,6 +133,14 @@ func (s *RdsService) GetAllReceivedByWallet(walletAddress string) ([]*CityPartne
 	return receiveds, err
 }
 
+func (s *RdsService) GetReceivedDetailsByWalletAfter(walletAddress string, t int64, id int, end int64, limit int) ([]CityPartnerReceivedDetail, error) {
+	details := []CityPartnerReceivedDetail{}
+	err := s.Db.Model(&CityPartnerReceivedDetail{}).
+		Where("wallet_address=?", walletAddress).
+		Scopes(ascCursorScope("create_time", t, id, end, limit)).Find(&details).Error
+	return details, err
+}
+
 func (s *RdsService) SaveCustumerInvitationInfo(info *CustumerInvitationInfo) error {
 	var count int
 	now := time.Now().Add(-24 * time.Hour)
@@ -0,0 +1,104 @@
+/*
+
+  Copyright 2017 Loopring Project Ltd (Loopring Foundation).
+
+  Licensed under the Apache License, Version 2.0 (the "License");
+  you may not use this file except in compliance with the License.
+  You may obtain a copy of the License at
+
+  http://www.apache.org/licenses/LICENSE-2.0
+
+  Unless required by applicable law or agreed to in writing, software
+  distributed under the License is distributed on an "AS IS" BASIS,
+  WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+  See the License for the specific language governing permissions and
+  limitations under the License.
+
+*/
+
+package dao
+
+import (
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"github.com/jinzhu/gorm"
+)
+
+// 游标分页, 按(时间, id)倒序做keyset遍历, 遍历期间插入新记录不会导致重复或遗漏
+// 游标对调用方不透明, 内容为最后一条记录的时间和id
+type Cursor struct {
+	Time int64
+	ID   int
+}
+
+var ErrInvalidCursor = errors.New("invalid cursor")
+
+func EncodeCursor(t int64, id int) string {
+	return base64.RawURLEncoding.EncodeToString([]byte(fmt.Sprintf("%d:%d", t, id)))
+}
+
+func DecodeCursor(s string) (*Cursor, error) {
+	bs, err := base64.RawURLEncoding.DecodeString(s)
+	if err != nil {
+		return nil, ErrInvalidCursor
+	}
+
+	var c Cursor
+	if n, err := fmt.Sscanf(string(bs), "%d:%d", &c.Time, &c.ID); err != nil || n != 2 || c.ID <= 0 {
+		return nil, ErrInvalidCursor
+	}
+	if EncodeCursor(c.Time, c.ID) != s {
+		return nil, ErrInvalidCursor
+	}
+
+	return &c, nil
+}
+
+// 空游标从最新记录开始; 多取一条用于判断是否还有下一页
+func cursorScope(timeColumn, cursor string, pageSize int) (func(db *gorm.DB) *gorm.DB, error) {
+	var (
+		c   *Cursor
+		err error
+	)
+
+	if cursor != "" {
+		if c, err = DecodeCursor(cursor); err != nil {
+			return nil, err
+		}
+	}
+
+	return func(db *gorm.DB) *gorm.DB {
+		if c != nil {
+			db = db.Where(fmt.Sprintf("%s < ? or (%s = ? and id < ?)", timeColumn, timeColumn), c.Time, c.Time, c.ID)
+		}
+		return db.Order(timeColumn + " DESC").Order("id DESC").Limit(pageSize + 1)
+	}, nil
+}
+
+// 按(时间, id)正序取(t, id)之后的记录, 用于需要从旧到新遍历的场景; end为0表示不限制
+func ascCursorScope(timeColumn string, t int64, id int, end int64, limit int) func(db *gorm.DB) *gorm.DB {
+	return func(db *gorm.DB) *gorm.DB {
+		db = db.Where(fmt.Sprintf("%s > ? or (%s = ? and id > ?)", timeColumn, timeColumn), t, t, id)
+		if end > 0 {
+			db = db.Where(timeColumn+" <= ?", end)
+		}
+		return db.Order(timeColumn + " ASC").Order("id ASC").Limit(limit)
+	}
+}
+
+func cursorPageSize(pageSize int) int {
+	if pageSize <= 0 {
+		pageSize = 20
+	}
+	return pageSize
+}
+
+// rows为多取一条后的结果数, at返回第i条记录的时间和id
+func nextCursor(rows, pageSize int, at func(i int) (int64, int)) string {
+	if rows <= pageSize {
+		return ""
+	}
+	t, id := at(pageSize - 1)
+	return EncodeCursor(t, id)
+}
@@ -0,0 +1,96 @@
+/*
+
+  Copyright 2017 Loopring Project Ltd (Loopring Foundation).
+
+  Licensed under the Apache License, Version 2.0 (the "License");
+  you may not use this file except in compliance with the License.
+  You may obtain a copy of the License at
+
+  http://www.apache.org/licenses/LICENSE-2.0
+
+  Unless required by applicable law or agreed to in writing, software
+  distributed under the License is distributed on an "AS IS" BASIS,
+  WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+  See the License for the specific language governing permissions and
+  limitations under the License.
+
+*/
+
+package dao_test
+
+import (
+	"fmt"
+	"github.com/Loopring/relay-cluster/dao"
+	"github.com/Loopring/relay-cluster/test"
+	"testing"
+	"time"
+)
+
+func TestCursor_EncodeDecode(t *testing.T) {
+	c, err := dao.DecodeCursor(dao.EncodeCursor(1530000000, 42))
+	if err != nil || c.Time != 1530000000 || c.ID != 42 {
+		t.Fatalf("cursor should round trip, got %+v %v", c, err)
+	}
+
+	for _, s := range []string{"abc", "MTIz", dao.EncodeCursor(1, 0), "MTIzOjQ1Og"} {
+		if _, err := dao.DecodeCursor(s); err != dao.ErrInvalidCursor {
+			t.Fatalf("cursor %s should be invalid", s)
+		}
+	}
+}
+
+// 遍历过程中插入新成交, 已遍历的游标之后不应出现重复或遗漏
+func TestRdsService_FillsCursorQuery(t *testing.T) {
+	rds := test.Rds()
+	market := fmt.Sprintf("CURSOR-%d", time.Now().UnixNano())
+	now := time.Now().Unix()
+
+	insert := func(createTime int64, n int) {
+		for i := 0; i < n; i++ {
+			fill := &dao.FillEvent{Market: market, CreateTime: createTime, Fork: false}
+			if err := rds.Db.Create(fill).Error; err != nil {
+				t.Fatal(err)
+			}
+		}
+	}
+
+	// 同一时间多条记录, 验证按id打破平局
+	insert(now-10, 3)
+	insert(now-20, 4)
+	defer rds.Db.Where("market = ?", market).Delete(&dao.FillEvent{})
+
+	var (
+		seen   = make(map[int]bool)
+		cursor = ""
+		pages  = 0
+	)
+	query := map[string]interface{}{"market": market}
+	for {
+		res, err := rds.FillsCursorQuery(query, cursor, 2)
+		if err != nil {
+			t.Fatal(err)
+		}
+		for _, v := range res.Data {
+			fill := v.(dao.FillEvent)
+			if seen[fill.ID] {
+				t.Fatalf("fill %d returned twice", fill.ID)
+			}
+			seen[fill.ID] = true
+		}
+
+		// 第一页之后插入更新的记录, 不应影响后续页
+		if pages == 0 {
+			insert(now, 3)
+		}
+		pages++
+
+		if res.NextCursor == "" {
+			break
+		}
+		cursor = res.NextCursor
+	}
+
+	if len(seen) != 7 {
+		t.Fatalf("cursor iteration should return 7 fills existing at start, got %d", len(seen))
+	}
+}
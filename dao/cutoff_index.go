@@ -0,0 +1,236 @@
+/*
+
+  Copyright 2017 Loopring Project Ltd (Loopring Foundation).
+
+  Licensed under the Apache License, Version 2.0 (the "License");
+  you may not use this file except in compliance with the License.
+  You may obtain a copy of the License at
+
+  http://www.apache.org/licenses/LICENSE-2.0
+
+  Unless required by applicable law or agreed to in writing, software
+  distributed under the License is distributed on an "AS IS" BASIS,
+  WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+  See the License for the specific language governing permissions and
+  limitations under the License.
+
+*/
+
+package dao
+
+import (
+	"github.com/Loopring/relay-lib/types"
+	"github.com/ethereum/go-ethereum/common"
+	"strings"
+)
+
+// cutoff索引, 每个delegate、owner及交易对只保存最大的cutoff, token1/token2为空表示owner全部订单
+// cutoff存储在delegate合约中, 共用delegate的多个protocol版本共用同一个cutoff
+// 只由成功的cutoff/cutoffPair事件维护, 分叉时根据事件表重新计算
+type CutoffIndex struct {
+	ID          int    `gorm:"column:id;primary_key;"`
+	Delegate    string `gorm:"column:delegate_address;type:varchar(42);unique_index:idx_cutoff_index"`
+	Owner       string `gorm:"column:owner;type:varchar(42);unique_index:idx_cutoff_index"`
+	Token1      string `gorm:"column:token1;type:varchar(42);unique_index:idx_cutoff_index"`
+	Token2      string `gorm:"column:token2;type:varchar(42);unique_index:idx_cutoff_index"`
+	Cutoff      int64  `gorm:"column:cutoff"`
+	BlockNumber int64  `gorm:"column:block_number"`
+	TxHash      string `gorm:"column:tx_hash;type:varchar(82)"`
+}
+
+// 旧版本的索引以protocol(contract_address)为key, AutoMigrate不会删除旧字段及唯一索引
+// 存在旧字段时删除该表, 由CreateTables重建后从事件表回填, 返回是否需要回填
+func (s *RdsService) dropLegacyCutoffIndex() (bool, error) {
+	if !s.Db.HasTable(&CutoffIndex{}) {
+		return false, nil
+	}
+	tableName := s.Db.NewScope(&CutoffIndex{}).TableName()
+	if !s.Db.Dialect().HasColumn(tableName, "contract_address") {
+		return false, nil
+	}
+	return true, s.Db.DropTable(&CutoffIndex{}).Error
+}
+
+// 交易对与token顺序无关
+func sortCutoffPair(token1, token2 string) (string, string) {
+	if token1 == "" || token2 == "" {
+		return "", ""
+	}
+	if strings.ToLower(token1) > strings.ToLower(token2) {
+		return token2, token1
+	}
+	return token1, token2
+}
+
+func (s *RdsService) getCutoffIndex(delegate, owner, token1, token2 string) (CutoffIndex, error) {
+	var item CutoffIndex
+	token1, token2 = sortCutoffPair(token1, token2)
+	err := s.Db.Where("delegate_address = ? and owner = ? and token1 = ? and token2 = ?", delegate, owner, token1, token2).First(&item).Error
+	return item, err
+}
+
+func (s *RdsService) GetCutoffIndex(delegate, owner common.Address) int64 {
+	item, _ := s.getCutoffIndex(delegate.Hex(), owner.Hex(), "", "")
+	return item.Cutoff
+}
+
+func (s *RdsService) GetCutoffPairIndex(delegate, owner, token1, token2 common.Address) int64 {
+	item, _ := s.getCutoffIndex(delegate.Hex(), owner.Hex(), token1.Hex(), token2.Hex())
+	return item.Cutoff
+}
+
+// 合约验证的是创建时间
+func (s *RdsService) IsOrderCutoff(delegate, owner, token1, token2 common.Address, validSince int64) bool {
+	var list []CutoffIndex
+	t1, t2 := sortCutoffPair(token1.Hex(), token2.Hex())
+	err := s.Db.Where("delegate_address = ? and owner = ?", delegate.Hex(), owner.Hex()).
+		Where("(token1 = '' and token2 = '') or (token1 = ? and token2 = ?)", t1, t2).
+		Where("cutoff > ?", validSince).
+		Find(&list).Error
+
+	return err == nil && len(list) > 0
+}
+
+func (s *RdsService) UpdateCutoffIndex(event *types.CutoffEvent) error {
+	return s.saveCutoffIndex(CutoffIndex{
+		Delegate:    event.DelegateAddress.Hex(),
+		Owner:       event.Owner.Hex(),
+		Cutoff:      event.Cutoff.Int64(),
+		BlockNumber: event.BlockNumber.Int64(),
+		TxHash:      event.TxHash.Hex(),
+	})
+}
+
+func (s *RdsService) UpdateCutoffPairIndex(event *types.CutoffPairEvent) error {
+	token1, token2 := sortCutoffPair(event.Token1.Hex(), event.Token2.Hex())
+	return s.saveCutoffIndex(CutoffIndex{
+		Delegate:    event.DelegateAddress.Hex(),
+		Owner:       event.Owner.Hex(),
+		Token1:      token1,
+		Token2:      token2,
+		Cutoff:      event.Cutoff.Int64(),
+		BlockNumber: event.BlockNumber.Int64(),
+		TxHash:      event.TxHash.Hex(),
+	})
+}
+
+// cutoff只能增加, 小于已有cutoff的记录不会覆盖索引
+func (s *RdsService) saveCutoffIndex(item CutoffIndex) error {
+	current, err := s.getCutoffIndex(item.Delegate, item.Owner, item.Token1, item.Token2)
+	if err != nil {
+		return s.Add(&item)
+	}
+	if item.Cutoff < current.Cutoff {
+		return nil
+	}
+
+	item.ID = current.ID
+	return s.Save(&item)
+}
+
+// 分叉事件已被标记为fork后调用, 受影响的索引按剩余的有效事件重新计算
+func (s *RdsService) RollBackCutoffIndex(from, to int64) error {
+	var list []CutoffIndex
+	if err := s.Db.Where("block_number > ? and block_number <= ?", from, to).Find(&list).Error; err != nil {
+		return err
+	}
+
+	for _, v := range list {
+		if err := s.Del(&v); err != nil {
+			return err
+		}
+		if err := s.rebuildCutoffIndexEntry(v.Delegate, v.Owner, v.Token1, v.Token2); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (s *RdsService) rebuildCutoffIndexEntry(delegate, owner, token1, token2 string) error {
+	if token1 == "" {
+		var event CutOffEvent
+		err := s.Db.Where("delegate_address = ? and owner = ?", delegate, owner).
+			Where("status = ? and fork = ?", uint8(types.TX_STATUS_SUCCESS), false).
+			Order("cutoff desc").First(&event).Error
+		if err != nil {
+			return nil
+		}
+		return s.saveCutoffIndex(CutoffIndex{Delegate: delegate, Owner: owner, Cutoff: event.Cutoff, BlockNumber: event.BlockNumber, TxHash: event.TxHash})
+	}
+
+	var event CutOffPairEvent
+	err := s.Db.Where("delegate_address = ? and owner = ?", delegate, owner).
+		Where("(token1 = ? and token2 = ?) or (token1 = ? and token2 = ?)", token1, token2, token2, token1).
+		Where("status = ? and fork = ?", uint8(types.TX_STATUS_SUCCESS), false).
+		Order("cutoff desc").First(&event).Error
+	if err != nil {
+		return nil
+	}
+	return s.saveCutoffIndex(CutoffIndex{Delegate: delegate, Owner: owner, Token1: token1, Token2: token2, Cutoff: event.Cutoff, BlockNumber: event.BlockNumber, TxHash: event.TxHash})
+}
+
+// 根据有效的cutoff/cutoffPair事件计算索引, 每个delegate、owner及交易对只保留最大的cutoff
+func BuildCutoffIndexes(cutoffList []CutOffEvent, cutoffPairList []CutOffPairEvent) []CutoffIndex {
+	var (
+		keys    []string
+		indexes = make(map[string]CutoffIndex)
+	)
+
+	add := func(item CutoffIndex) {
+		key := strings.ToLower(strings.Join([]string{item.Delegate, item.Owner, item.Token1, item.Token2}, "_"))
+		current, ok := indexes[key]
+		if !ok {
+			keys = append(keys, key)
+		} else if item.Cutoff < current.Cutoff {
+			return
+		}
+		indexes[key] = item
+	}
+
+	for _, v := range cutoffList {
+		add(CutoffIndex{Delegate: v.DelegateAddress, Owner: v.Owner, Cutoff: v.Cutoff, BlockNumber: v.BlockNumber, TxHash: v.TxHash})
+	}
+	for _, v := range cutoffPairList {
+		token1, token2 := sortCutoffPair(v.Token1, v.Token2)
+		add(CutoffIndex{Delegate: v.DelegateAddress, Owner: v.Owner, Token1: token1, Token2: token2, Cutoff: v.Cutoff, BlockNumber: v.BlockNumber, TxHash: v.TxHash})
+	}
+
+	list := make([]CutoffIndex, 0, len(keys))
+	for _, key := range keys {
+		list = append(list, indexes[key])
+	}
+	return list
+}
+
+// 根据cutoff/cutoffPair事件表重建索引, 清空与写入在同一事务中完成, 返回索引记录数
+func (s *RdsService) RebuildCutoffIndex() (int, error) {
+	var (
+		cutoffList     []CutOffEvent
+		cutoffPairList []CutOffPairEvent
+	)
+
+	if err := s.Db.Where("status = ? and fork = ?", uint8(types.TX_STATUS_SUCCESS), false).Find(&cutoffList).Error; err != nil {
+		return 0, err
+	}
+	if err := s.Db.Where("status = ? and fork = ?", uint8(types.TX_STATUS_SUCCESS), false).Find(&cutoffPairList).Error; err != nil {
+		return 0, err
+	}
+	list := BuildCutoffIndexes(cutoffList, cutoffPairList)
+
+	tx := s.Db.Begin()
+	if err := tx.Delete(&CutoffIndex{}).Error; err != nil {
+		tx.Rollback()
+		return 0, err
+	}
+	for i := range list {
+		if err := tx.Create(&list[i]).Error; err != nil {
+			tx.Rollback()
+			return 0, err
+		}
+	}
+	if err := tx.Commit().Error; err != nil {
+		return 0, err
+	}
+
+	return len(list), nil
+}
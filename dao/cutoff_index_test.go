@@ -0,0 +1,89 @@
+/*
+
+  Copyright 2017 Loopring Project Ltd (Loopring Foundation).
+
+  Licensed under the Apache License, Version 2.0 (the "License");
+  you may not use this file except in compliance with the License.
+  You may obtain a copy of the License at
+
+  http://www.apache.org/licenses/LICENSE-2.0
+
+  Unless required by applicable law or agreed to in writing, software
+  distributed under the License is distributed on an "AS IS" BASIS,
+  WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+  See the License for the specific language governing permissions and
+  limitations under the License.
+
+*/
+
+package dao_test
+
+import (
+	"github.com/Loopring/relay-cluster/dao"
+	"testing"
+)
+
+const (
+	testDelegate = "0x17233e07c67d086464fD408148c3ABB56245FA64"
+	testOwner    = "0x71C079107B5af8619D54537A93dbF16e5aab4900"
+	testLrc      = "0xEF68e7C694F40c8202821eDF525dE3782458639f"
+	testWeth     = "0xC02aaA39b223FE8D0A0e5C4F27eAD9083C756Cc2"
+)
+
+func TestBuildCutoffIndexes_KeepMaxCutoff(t *testing.T) {
+	cutoffList := []dao.CutOffEvent{
+		{DelegateAddress: testDelegate, Owner: testOwner, Cutoff: 100, BlockNumber: 1},
+		{DelegateAddress: testDelegate, Owner: testOwner, Cutoff: 300, BlockNumber: 3},
+		{DelegateAddress: testDelegate, Owner: testOwner, Cutoff: 200, BlockNumber: 2},
+	}
+
+	list := dao.BuildCutoffIndexes(cutoffList, nil)
+	if len(list) != 1 {
+		t.Fatalf("expected 1 index, got %d", len(list))
+	}
+	if list[0].Cutoff != 300 || list[0].BlockNumber != 3 {
+		t.Fatalf("index should keep the max cutoff, got %d at block %d", list[0].Cutoff, list[0].BlockNumber)
+	}
+}
+
+func TestBuildCutoffIndexes_PairOrder(t *testing.T) {
+	cutoffList := []dao.CutOffEvent{
+		{DelegateAddress: testDelegate, Owner: testOwner, Cutoff: 100},
+	}
+	cutoffPairList := []dao.CutOffPairEvent{
+		{DelegateAddress: testDelegate, Owner: testOwner, Token1: testWeth, Token2: testLrc, Cutoff: 200},
+		{DelegateAddress: testDelegate, Owner: testOwner, Token1: testLrc, Token2: testWeth, Cutoff: 150},
+	}
+
+	list := dao.BuildCutoffIndexes(cutoffList, cutoffPairList)
+	if len(list) != 2 {
+		t.Fatalf("expected owner and pair index, got %d", len(list))
+	}
+	if list[0].Token1 != "" || list[0].Cutoff != 100 {
+		t.Fatalf("owner index not kept, got %+v", list[0])
+	}
+	pair := list[1]
+	if pair.Token1 != testWeth || pair.Token2 != testLrc || pair.Cutoff != 200 {
+		t.Fatalf("pair index should be sorted and keep the max cutoff, got %+v", pair)
+	}
+}
+
+// 共用delegate的多个protocol版本的cutoff事件合并到同一条索引
+func TestBuildCutoffIndexes_SharedDelegate(t *testing.T) {
+	cutoffList := []dao.CutOffEvent{
+		{Protocol: "0x8d8812b72d1e4ffCeC158D25f56748b7d67c1e78", DelegateAddress: testDelegate, Owner: testOwner, Cutoff: 100},
+		{Protocol: "0x781870080C8C24a2FD6882296c49c837b06A65E6", DelegateAddress: testDelegate, Owner: testOwner, Cutoff: 200},
+		{Protocol: "0x781870080C8C24a2FD6882296c49c837b06A65E6", DelegateAddress: "0x5567ee920f7E62274284985D793344351A00142B", Owner: testOwner, Cutoff: 50},
+	}
+
+	list := dao.BuildCutoffIndexes(cutoffList, nil)
+	if len(list) != 2 {
+		t.Fatalf("expected one index per delegate, got %d", len(list))
+	}
+	if list[0].Delegate != testDelegate || list[0].Cutoff != 200 {
+		t.Fatalf("shared delegate should keep the max cutoff of all protocols, got %+v", list[0])
+	}
+	if list[1].Cutoff != 50 {
+		t.Fatalf("other delegate index should not be affected, got %+v", list[1])
+	}
+}
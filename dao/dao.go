@@ -29,6 +29,8 @@ type PageResult struct {
 	PageIndex int           `json:"pageIndex"`
 	PageSize  int           `json:"pageSize"`
 	Total     int           `json:"total"`
+	// 游标模式下返回, 为空表示没有下一页
+	NextCursor string `json:"nextCursor,omitempty"`
 }
 
 type RdsService struct {
@@ -61,11 +63,38 @@ func NewDb(options *libdao.MysqlOptions) *RdsService {
 	tables = append(tables, &CityPartnerReceived{})
 	tables = append(tables, &CustumerInvitationInfo{})
 	tables = append(tables, &CityPartnerReceivedDetail{})
+	tables = append(tables, &TriggerOrder{})
+	tables = append(tables, &IcebergOrder{})
+	tables = append(tables, &CutoffIndex{})
+	tables = append(tables, &StuckOrderRecovery{})
+	tables = append(tables, &OrderLease{})
+	tables = append(tables, &PostOnlyOrder{})
+	tables = append(tables, &TimeInForceOrder{})
+	tables = append(tables, &OrderGroupMember{})
+	tables = append(tables, &OrderSchedule{})
+	tables = append(tables, &ScheduledOrder{})
+	tables = append(tables, &SessionKey{})
+	tables = append(tables, &RingSubmitStat{})
+	tables = append(tables, &TokenUsdPrice{})
+	tables = append(tables, &BalanceSnapshot{})
+
+	rebuildCutoffIndex, err := s.dropLegacyCutoffIndex()
+	if err != nil {
+		log.Fatalf("drop legacy cutoff index error:%s", err.Error())
+	}
 
 	s.SetTables(tables)
 	if err := s.CreateTables(); err != nil {
 		log.Fatalf(err.Error())
 	}
 
+	if rebuildCutoffIndex {
+		count, err := s.RebuildCutoffIndex()
+		if err != nil {
+			log.Fatalf("rebuild cutoff index error:%s", err.Error())
+		}
+		log.Infof("migrate cutoff index to delegate, rebuild %d records", count)
+	}
+
 	return &s
 }
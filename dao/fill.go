@@ -147,6 +147,53 @@ func (s *RdsService) FillsPageQuery(query map[string]interface{}, pageIndex, pag
 	return
 }
 
+func (s *RdsService) FillsCursorQuery(query map[string]interface{}, cursor string, pageSize int) (res PageResult, err error) {
+	fills := make([]FillEvent, 0)
+	pageSize = cursorPageSize(pageSize)
+	res = PageResult{PageSize: pageSize, Data: make([]interface{}, 0)}
+
+	scope, err := cursorScope("create_time", cursor, pageSize)
+	if err != nil {
+		return res, err
+	}
+	if err = s.Db.Where(query).Where("fork=?", false).Scopes(scope).Find(&fills).Error; err != nil {
+		return res, err
+	}
+
+	res.NextCursor = nextCursor(len(fills), pageSize, func(i int) (int64, int) {
+		return fills[i].CreateTime, fills[i].ID
+	})
+	for i, fill := range fills {
+		if i >= pageSize {
+			break
+		}
+		res.Data = append(res.Data, fill)
+	}
+	return
+}
+
+// 按时间正序返回owner在[start, end]内的成交, 0表示不限制
+func (s *RdsService) GetFillsByOwnerAndTime(owner string, start, end int64) ([]FillEvent, error) {
+	fills := make([]FillEvent, 0)
+	db := s.Db.Where("owner = ?", owner).Where("fork = ?", false)
+	if start > 0 {
+		db = db.Where("create_time >= ?", start)
+	}
+	if end > 0 {
+		db = db.Where("create_time <= ?", end)
+	}
+	err := db.Order("create_time ASC").Order("id ASC").Find(&fills).Error
+	return fills, err
+}
+
+// 按时间正序返回owner在(t, id)之后且不晚于end的成交
+func (s *RdsService) GetFillsByOwnerAfter(owner string, t int64, id int, end int64, limit int) ([]FillEvent, error) {
+	fills := make([]FillEvent, 0)
+	err := s.Db.Where("owner = ?", owner).Where("fork = ?", false).
+		Scopes(ascCursorScope("create_time", t, id, end, limit)).Find(&fills).Error
+	return fills, err
+}
+
 func (s *RdsService) GetLatestFills(query map[string]interface{}, limit int) (res []FillEvent, err error) {
 	fills := make([]FillEvent, 0)
 	err = s.Db.Where(query).Where("fork=?", false).Order("create_time desc").Limit(limit).Find(&fills).Error
@@ -0,0 +1,62 @@
+/*
+
+  Copyright 2017 Loopring Project Ltd (Loopring Foundation).
+
+  Licensed under the Apache License, Version 2.0 (the "License");
+  you may not use this file except in compliance with the License.
+  You may obtain a copy of the License at
+
+  http://www.apache.org/licenses/LICENSE-2.0
+
+  Unless required by applicable law or agreed to in writing, software
+  distributed under the License is distributed on an "AS IS" BASIS,
+  WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+  See the License for the specific language governing permissions and
+  limitations under the License.
+
+*/
+
+package dao
+
+import (
+	"math/big"
+)
+
+// 冰山单, 深度及订单簿中只展示displayAmountS部分, 撮合时仍使用订单全部数量
+type IcebergOrder struct {
+	ID             int    `gorm:"column:id;primary_key;" json:"id"`
+	OrderHash      string `gorm:"column:order_hash;type:varchar(82);unique_index" json:"orderHash"`
+	Owner          string `gorm:"column:owner;type:varchar(42)" json:"owner"`
+	DisplayAmountS string `gorm:"column:display_amount_s;type:varchar(40)" json:"displayAmountS"`
+	CreateTime     int64  `gorm:"column:create_time;type:bigint" json:"createTime"`
+}
+
+func (s *RdsService) GetIcebergOrderByHash(orderHash string) (IcebergOrder, error) {
+	var order IcebergOrder
+	err := s.Db.Where("order_hash = ?", orderHash).First(&order).Error
+	return order, err
+}
+
+// 返回orderHash -> displayAmountS, 非冰山单不在结果中
+func (s *RdsService) GetIcebergDisplayAmounts(orderHashes []string) (map[string]*big.Int, error) {
+	var (
+		list []IcebergOrder
+		err  error
+		ret  = make(map[string]*big.Int)
+	)
+
+	if len(orderHashes) == 0 {
+		return ret, nil
+	}
+
+	if err = s.Db.Where("order_hash in (?)", orderHashes).Find(&list).Error; err != nil {
+		return ret, err
+	}
+
+	for _, v := range list {
+		if amount, ok := new(big.Int).SetString(v.DisplayAmountS, 10); ok {
+			ret[v.OrderHash] = amount
+		}
+	}
+	return ret, nil
+}
@@ -25,6 +25,7 @@ import (
 	util "github.com/Loopring/relay-lib/marketutil"
 	"github.com/Loopring/relay-lib/types"
 	"github.com/ethereum/go-ethereum/common"
+	"github.com/jinzhu/gorm"
 	"math/big"
 	"strconv"
 	"strings"
@@ -33,22 +34,28 @@ import (
 
 // order amountS 上限1e30
 
+// 订单来源, 为空时表示通过本relay提交的订单
+const ORDER_SOURCE_SUBMIT_RING = "submit_ring"
+
+// 从成功的submitRing calldata导入的其他relay订单, 只用于关联成交记录, 不进入深度也不提供给miner
+const ORDER_TYPE_FOREIGN = "foreign_order"
+
 type Order struct {
 	ID                    int     `gorm:"column:id;primary_key;"`
 	Protocol              string  `gorm:"column:protocol;type:varchar(42)"`
 	DelegateAddress       string  `gorm:"column:delegate_address;type:varchar(42)"`
-	Owner                 string  `gorm:"column:owner;type:varchar(42)"`
+	Owner                 string  `gorm:"column:owner;type:varchar(42);index:idx_order_owner_create_time"`
 	AuthAddress           string  `gorm:"column:auth_address;type:varchar(42)"`
 	PrivateKey            string  `gorm:"column:priv_key;type:varchar(128)"`
-	WalletAddress         string  `gorm:"column:wallet_address;type:varchar(42)"`
+	WalletAddress         string  `gorm:"column:wallet_address;type:varchar(42);index"`
 	OrderHash             string  `gorm:"column:order_hash;type:varchar(82)"`
-	TokenS                string  `gorm:"column:token_s;type:varchar(42)"`
-	TokenB                string  `gorm:"column:token_b;type:varchar(42)"`
+	TokenS                string  `gorm:"column:token_s;type:varchar(42);index"`
+	TokenB                string  `gorm:"column:token_b;type:varchar(42);index"`
 	AmountS               string  `gorm:"column:amount_s;type:varchar(40)"`
 	AmountB               string  `gorm:"column:amount_b;type:varchar(40)"`
-	CreateTime            int64   `gorm:"column:create_time;type:bigint"`
+	CreateTime            int64   `gorm:"column:create_time;type:bigint;index:idx_order_owner_create_time"`
 	ValidSince            int64   `gorm:"column:valid_since;type:bigint"`
-	ValidUntil            int64   `gorm:"column:valid_until;type:bigint"`
+	ValidUntil            int64   `gorm:"column:valid_until;type:bigint;index"`
 	LrcFee                string  `gorm:"column:lrc_fee;type:varchar(40)"`
 	BuyNoMoreThanAmountB  bool    `gorm:"column:buy_nomore_than_amountb"`
 	MarginSplitPercentage uint8   `gorm:"column:margin_split_percentage;type:tinyint(4)"`
@@ -70,6 +77,8 @@ type Order struct {
 	Market                string  `gorm:"column:market;type:varchar(40)"`
 	Side                  string  `gorm:"column:side;type:varchar(40)"`
 	OrderType             string  `gorm:"column:order_type;type:varchar(40)"`
+	Source                string  `gorm:"column:source;type:varchar(20)"`
+	P2PLockedUntil        int64   `gorm:"column:p2p_locked_until;type:bigint;index"`
 }
 
 // convert types/orderState to dao/order
@@ -213,7 +222,9 @@ func (s *RdsService) MarkMinerOrders(filterOrderhashs []string, blockNumber int6
 	return err
 }
 
-func (s *RdsService) GetOrdersForMiner(protocol, tokenS, tokenB string, length int, validStatus []types.OrderStatus, reservedTime, startBlockNumber, endBlockNumber int64) ([]*Order, error) {
+// protocol为空时返回delegate下所有protocol版本的订单, excludeHashes为已被其他miner租用的订单
+// leaseBlock大于0时排除在该区块仍被其他miner租用的订单
+func (s *RdsService) GetOrdersForMiner(delegate, protocol, tokenS, tokenB string, length int, validStatus []types.OrderStatus, reservedTime, startBlockNumber, endBlockNumber int64, minerId string, leaseBlock int64, excludeHashes []string) ([]*Order, error) {
 	var (
 		list []*Order
 		err  error
@@ -222,7 +233,18 @@ func (s *RdsService) GetOrdersForMiner(protocol, tokenS, tokenB string, length i
 	nowtime := time.Now().Unix()
 	sinceTime := nowtime
 	untilTime := nowtime + reservedTime
-	err = s.Db.Where("delegate_address = ? and token_s = ? and token_b = ?", protocol, tokenS, tokenB).
+	db := s.Db.Where("delegate_address = ? and token_s = ? and token_b = ?", delegate, tokenS, tokenB)
+	if protocol != "" {
+		db = db.Where("protocol = ?", protocol)
+	}
+	if leaseBlock > 0 {
+		leased := s.Db.Model(&OrderLease{}).Select("order_hash").Where("miner_id <> ? and expire_block >= ?", minerId, leaseBlock).QueryExpr()
+		db = db.Where("order_hash not in (?)", leased)
+	}
+	if len(excludeHashes) > 0 {
+		db = db.Where("order_hash not in (?)", excludeHashes)
+	}
+	err = db.
 		Where("valid_since < ?", sinceTime).
 		Where("valid_until >= ? ", untilTime).
 		Where("status in (?) ", validStatus).
@@ -294,7 +316,7 @@ func (s *RdsService) SetCutOffOrders(orderHashList []common.Hash, blockNumber *b
 	return err
 }
 
-func (s *RdsService) GetOrderBook(delegate, tokenS, tokenB common.Address, length int) ([]Order, error) {
+func (s *RdsService) GetOrderBook(delegate, protocol, tokenS, tokenB common.Address, length int) ([]Order, error) {
 	var (
 		list []Order
 		err  error
@@ -302,7 +324,11 @@ func (s *RdsService) GetOrderBook(delegate, tokenS, tokenB common.Address, lengt
 
 	filterStatus := []types.OrderStatus{types.ORDER_NEW, types.ORDER_PARTIAL, types.ORDER_PENDING}
 	nowtime := time.Now().Unix()
-	err = s.Db.Where("delegate_address = ?", delegate.Hex()).
+	db := s.Db.Where("delegate_address = ?", delegate.Hex())
+	if protocol != types.NilAddress {
+		db = db.Where("protocol = ?", protocol.Hex())
+	}
+	err = db.
 		Where("token_s = ? and token_b = ?", tokenS.Hex(), tokenB.Hex()).
 		Where("status in (?)", filterStatus).
 		Where("order_type = ? ", types.ORDER_TYPE_MARKET).
@@ -315,7 +341,57 @@ func (s *RdsService) GetOrderBook(delegate, tokenS, tokenB common.Address, lengt
 	return list, err
 }
 
-func (s *RdsService) OrderPageQuery(query map[string]interface{}, statusList []int, pageIndex, pageSize int) (PageResult, error) {
+// price为amountS/amountB, sell订单的price是市场价格的倒数, 价格范围按side换算为市场价格后比较
+const orderMarketPriceExpr = "(case when side = '" + util.SideSell + "' and price > 0 then 1 / price else price end)"
+
+// 订单查询的范围条件, 零值表示不限制
+type OrderFilter struct {
+	CreateTimeStart int64
+	CreateTimeEnd   int64
+	ValidUntilStart int64
+	ValidUntilEnd   int64
+	MinPrice        float64
+	MaxPrice        float64
+	MinAmountS      *big.Int
+	HasFills        *bool
+}
+
+func (f *OrderFilter) scope(db *gorm.DB) *gorm.DB {
+	if f == nil {
+		return db
+	}
+	if f.CreateTimeStart > 0 {
+		db = db.Where("create_time >= ?", f.CreateTimeStart)
+	}
+	if f.CreateTimeEnd > 0 {
+		db = db.Where("create_time <= ?", f.CreateTimeEnd)
+	}
+	if f.ValidUntilStart > 0 {
+		db = db.Where("valid_until >= ?", f.ValidUntilStart)
+	}
+	if f.ValidUntilEnd > 0 {
+		db = db.Where("valid_until <= ?", f.ValidUntilEnd)
+	}
+	if f.MinPrice > 0 {
+		db = db.Where(orderMarketPriceExpr+" >= ?", f.MinPrice)
+	}
+	if f.MaxPrice > 0 {
+		db = db.Where(orderMarketPriceExpr+" <= ?", f.MaxPrice)
+	}
+	if f.MinAmountS != nil && f.MinAmountS.Sign() > 0 {
+		db = db.Where("cast(amount_s as decimal(40,0)) >= ?", f.MinAmountS.String())
+	}
+	if f.HasFills != nil {
+		if *f.HasFills {
+			db = db.Where("cast(dealt_amount_s as decimal(40,0)) > 0")
+		} else {
+			db = db.Where("cast(dealt_amount_s as decimal(40,0)) = 0")
+		}
+	}
+	return db
+}
+
+func (s *RdsService) OrderPageQuery(query map[string]interface{}, filter *OrderFilter, statusList []int, pageIndex, pageSize int) (PageResult, error) {
 	var (
 		orders        []Order
 		err           error
@@ -332,21 +408,21 @@ func (s *RdsService) OrderPageQuery(query map[string]interface{}, statusList []i
 		pageSize = 20
 	}
 
-	pageResult = PageResult{data, pageIndex, pageSize, 0}
+	pageResult = PageResult{Data: data, PageIndex: pageIndex, PageSize: pageSize}
 
 	openedStatus := []types.OrderStatus{types.ORDER_NEW, types.ORDER_PARTIAL}
 	now := time.Now().Unix()
 
 	if len(statusList) == 1 {
 		if statusList[0] == 6 {
-			if err = s.Db.Where(query).
+			if err = s.Db.Scopes(filter.scope).Where(query).
 				Where("valid_until < ?", now).
 				Where("status in (?)", openedStatus).
 				Offset((pageIndex - 1) * pageSize).Order("create_time DESC").Limit(pageSize).Find(&orders).Error; err != nil {
 				return pageResult, err
 			}
 
-			err = s.Db.Model(&Order{}).Where(query).
+			err = s.Db.Model(&Order{}).Scopes(filter.scope).Where(query).
 				Where("valid_until < ?", now).
 				Where("status in (?)", openedStatus).Count(&pageResult.Total).Error
 
@@ -356,11 +432,11 @@ func (s *RdsService) OrderPageQuery(query map[string]interface{}, statusList []i
 
 		} else {
 			query["status"] = statusList[0]
-			if err = s.Db.Where(query).Offset((pageIndex - 1) * pageSize).Order("create_time DESC").Limit(pageSize).Find(&orders).Error; err != nil {
+			if err = s.Db.Scopes(filter.scope).Where(query).Offset((pageIndex - 1) * pageSize).Order("create_time DESC").Limit(pageSize).Find(&orders).Error; err != nil {
 				return pageResult, err
 			}
 
-			err = s.Db.Model(&Order{}).Where(query).Count(&pageResult.Total).Error
+			err = s.Db.Model(&Order{}).Scopes(filter.scope).Where(query).Count(&pageResult.Total).Error
 			if err != nil {
 				return pageResult, err
 			}
@@ -373,7 +449,7 @@ func (s *RdsService) OrderPageQuery(query map[string]interface{}, statusList []i
 
 		queryOpened := allContain(statusList, openedStatus)
 		if queryOpened {
-			if err = s.Db.Where(query).
+			if err = s.Db.Scopes(filter.scope).Where(query).
 				Where("status in (?)", statusStrList).
 				Where("valid_since < ?", now).
 				Where("valid_until >= ? ", now).
@@ -381,22 +457,22 @@ func (s *RdsService) OrderPageQuery(query map[string]interface{}, statusList []i
 				return pageResult, err
 			}
 
-			err = s.Db.Model(&Order{}).Where(query).
+			err = s.Db.Model(&Order{}).Scopes(filter.scope).Where(query).
 				Where("valid_since < ?", now).
 				Where("valid_until >= ? ", now).
-				Where("status in (?)", openedStatus).Count(&pageResult.Total).Error
+				Where("status in (?)", statusStrList).Count(&pageResult.Total).Error
 
 			if err != nil {
 				return pageResult, err
 			}
 
 		} else {
-			if err = s.Db.Where(query).Where("status in (?)", statusStrList).Offset((pageIndex - 1) * pageSize).Order("create_time DESC").Limit(pageSize).Find(&orders).Error; err != nil {
+			if err = s.Db.Scopes(filter.scope).Where(query).Where("status in (?)", statusStrList).Offset((pageIndex - 1) * pageSize).Order("create_time DESC").Limit(pageSize).Find(&orders).Error; err != nil {
 				return pageResult, err
 			}
 
-			err = s.Db.Model(&Order{}).Where(query).
-				Where("status in (?)", openedStatus).Count(&pageResult.Total).Error
+			err = s.Db.Model(&Order{}).Scopes(filter.scope).Where(query).
+				Where("status in (?)", statusStrList).Count(&pageResult.Total).Error
 
 			if err != nil {
 				return pageResult, err
@@ -404,11 +480,11 @@ func (s *RdsService) OrderPageQuery(query map[string]interface{}, statusList []i
 		}
 
 	} else {
-		if err = s.Db.Where(query).Offset((pageIndex - 1) * pageSize).Order("create_time DESC").Limit(pageSize).Find(&orders).Error; err != nil {
+		if err = s.Db.Scopes(filter.scope).Where(query).Offset((pageIndex - 1) * pageSize).Order("create_time DESC").Limit(pageSize).Find(&orders).Error; err != nil {
 			return pageResult, err
 		}
 
-		err = s.Db.Model(&Order{}).Where(query).Count(&pageResult.Total).Error
+		err = s.Db.Model(&Order{}).Scopes(filter.scope).Where(query).Count(&pageResult.Total).Error
 		if err != nil {
 			return pageResult, err
 		}
@@ -423,6 +499,120 @@ func (s *RdsService) OrderPageQuery(query map[string]interface{}, statusList []i
 	return pageResult, err
 }
 
+// 游标模式, 状态过滤与OrderPageQuery一致, 不统计total
+func (s *RdsService) OrderCursorQuery(query map[string]interface{}, filter *OrderFilter, statusList []int, cursor string, pageSize int) (PageResult, error) {
+	var (
+		orders []Order
+		data   = make([]interface{}, 0)
+	)
+
+	pageSize = cursorPageSize(pageSize)
+	pageResult := PageResult{Data: data, PageSize: pageSize}
+
+	scope, err := cursorScope("create_time", cursor, pageSize)
+	if err != nil {
+		return pageResult, err
+	}
+
+	openedStatus := []types.OrderStatus{types.ORDER_NEW, types.ORDER_PARTIAL}
+	now := time.Now().Unix()
+
+	db := s.Db.Scopes(filter.scope).Where(query)
+	if len(statusList) == 1 && statusList[0] == 6 {
+		db = db.Where("valid_until < ?", now).Where("status in (?)", openedStatus)
+	} else if len(statusList) == 1 {
+		db = db.Where("status = ?", statusList[0])
+	} else if len(statusList) > 1 {
+		db = db.Where("status in (?)", statusList)
+		if allContain(statusList, openedStatus) {
+			db = db.Where("valid_since < ?", now).Where("valid_until >= ? ", now)
+		}
+	}
+
+	if err = db.Scopes(scope).Find(&orders).Error; err != nil {
+		return pageResult, err
+	}
+
+	pageResult.NextCursor = nextCursor(len(orders), pageSize, func(i int) (int64, int) {
+		return orders[i].CreateTime, orders[i].ID
+	})
+	for i, v := range orders {
+		if i >= pageSize {
+			break
+		}
+		data = append(data, v)
+	}
+	pageResult.Data = data
+
+	return pageResult, nil
+}
+
+// 查询开放中的p2p maker订单, amount_s为varchar, 最小数量需转为decimal比较
+func (s *RdsService) P2POrderPageQuery(query map[string]interface{}, minAmountS *big.Int, minPrice, maxPrice float64, pageIndex, pageSize int) (PageResult, error) {
+	var (
+		orders     []Order
+		err        error
+		data       = make([]interface{}, 0)
+		pageResult PageResult
+	)
+
+	if pageIndex <= 0 {
+		pageIndex = 1
+	}
+
+	if pageSize <= 0 {
+		pageSize = 20
+	}
+
+	pageResult = PageResult{Data: data, PageIndex: pageIndex, PageSize: pageSize}
+
+	openedStatus := []types.OrderStatus{types.ORDER_NEW, types.ORDER_PARTIAL}
+	now := time.Now().Unix()
+
+	db := s.Db.Model(&Order{}).Where(query).
+		Where("order_type = ?", types.ORDER_TYPE_P2P).
+		Where("status in (?)", openedStatus).
+		Where("valid_since < ?", now).
+		Where("valid_until >= ? ", now).
+		Where("p2p_locked_until < ?", now)
+
+	if minAmountS != nil && minAmountS.Sign() > 0 {
+		db = db.Where("cast(amount_s as decimal(40,0)) >= ?", minAmountS.String())
+	}
+	if minPrice > 0 {
+		db = db.Where("price >= ?", minPrice)
+	}
+	if maxPrice > 0 {
+		db = db.Where("price <= ?", maxPrice)
+	}
+	if err = db.Offset((pageIndex - 1) * pageSize).Order("create_time DESC").Limit(pageSize).Find(&orders).Error; err != nil {
+		return pageResult, err
+	}
+
+	if err = db.Count(&pageResult.Total).Error; err != nil {
+		return pageResult, err
+	}
+
+	for _, v := range orders {
+		data = append(data, v)
+	}
+	pageResult.Data = data
+
+	return pageResult, err
+}
+
+// taker提交p2p环路后锁定双方订单直到until, 成交后解锁
+func (s *RdsService) LockP2POrders(orderHashes []string, until int64) error {
+	if len(orderHashes) == 0 {
+		return nil
+	}
+	return s.Db.Model(&Order{}).Where("order_hash in (?)", orderHashes).Update("p2p_locked_until", until).Error
+}
+
+func (s *RdsService) UnlockP2POrders(orderHashes []string) error {
+	return s.LockP2POrders(orderHashes, 0)
+}
+
 func containStatus(status int, statusList []types.OrderStatus) bool {
 	if len(statusList) == 0 {
 		return false
@@ -495,16 +685,36 @@ func (s *RdsService) GetFrozenAmount(owner common.Address, token common.Address,
 	return list, err
 }
 
-func (s *RdsService) GetFrozenLrcFee(owner common.Address, statusSet []types.OrderStatus) ([]Order, error) {
+// delegateAddress为空时统计所有delegate下的订单
+func (s *RdsService) GetFrozenLrcFee(owner common.Address, statusSet []types.OrderStatus, delegateAddress common.Address) ([]Order, error) {
 	var (
 		list []Order
 		err  error
 	)
 
 	now := time.Now().Unix()
-	err = s.Db.Model(&Order{}).
+	db := s.Db.Model(&Order{}).
 		Where("lrc_fee > 0 and owner = ? and status in "+buildStatusInSet(statusSet), owner.Hex()).
 		Where("valid_since < ?", now).
+		Where("valid_until >= ? ", now)
+	if delegateAddress != types.NilAddress {
+		db = db.Where("delegate_address = ?", delegateAddress.Hex())
+	}
+	err = db.Find(&list).Error
+	return list, err
+}
+
+// 当前有效期内的订单, 不区分token和delegate
+func (s *RdsService) GetOpenOrdersByOwner(owner common.Address, statusSet []types.OrderStatus) ([]Order, error) {
+	var (
+		list []Order
+		err  error
+	)
+
+	now := time.Now().Unix()
+	err = s.Db.Model(&Order{}).
+		Where("owner = ? and status in "+buildStatusInSet(statusSet), owner.Hex()).
+		Where("valid_since < ?", now).
 		Where("valid_until >= ? ", now).
 		Find(&list).Error
 	return list, err
@@ -592,6 +802,19 @@ func (s *RdsService) FlexCancelOrderByMarket(owner common.Address, cutoff int64,
 		Update("status", status).RowsAffected
 }
 
+// 软撤单前的订单快照, 用于只撤销快照中的订单
+// 只查询order_hash和side
+func (s *RdsService) GetFlexCancellableOrders(owner common.Address, market string, validStatus []types.OrderStatus) ([]Order, error) {
+	var list []Order
+	err := s.Db.Select("order_hash, side").
+		Where("owner=?", owner.Hex()).
+		Where("market=?", market).
+		Where("valid_until >= ? ", time.Now().Unix()).
+		Where("status in (?)", validStatus).
+		Find(&list).Error
+	return list, err
+}
+
 func (s *RdsService) IsOrderOwner(owner common.Address) bool {
 	var data Order
 	err := s.Db.Where("owner=?", owner.Hex()).First(&data).Error
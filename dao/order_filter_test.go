@@ -0,0 +1,103 @@
+/*
+
+  Copyright 2017 Loopring Project Ltd (Loopring Foundation).
+
+  Licensed under the Apache License, Version 2.0 (the "License");
+  you may not use this file except in compliance with the License.
+  You may obtain a copy of the License at
+
+  http://www.apache.org/licenses/LICENSE-2.0
+
+  Unless required by applicable law or agreed to in writing, software
+  distributed under the License is distributed on an "AS IS" BASIS,
+  WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+  See the License for the specific language governing permissions and
+  limitations under the License.
+
+*/
+
+package dao_test
+
+import (
+	"fmt"
+	"github.com/Loopring/relay-cluster/dao"
+	"github.com/Loopring/relay-cluster/test"
+	"github.com/Loopring/relay-lib/types"
+	"math/big"
+	"testing"
+	"time"
+)
+
+func TestRdsService_OrderPageQueryFilter(t *testing.T) {
+	rds := test.Rds()
+	owner := fmt.Sprintf("0x%040d", time.Now().UnixNano())
+	now := time.Now().Unix()
+
+	orders := []*dao.Order{
+		{Owner: owner, CreateTime: now - 100, ValidUntil: now + 100, Price: 0.001, AmountS: "100", DealtAmountS: "0", Status: uint8(types.ORDER_NEW)},
+		{Owner: owner, CreateTime: now - 50, ValidUntil: now + 200, Price: 0.002, AmountS: "1000", DealtAmountS: "10", Status: uint8(types.ORDER_PARTIAL)},
+		{Owner: owner, CreateTime: now - 10, ValidUntil: now + 300, Price: 0.003, AmountS: "5000", DealtAmountS: "5000", Status: uint8(types.ORDER_FINISHED)},
+	}
+	for _, o := range orders {
+		if err := rds.Db.Create(o).Error; err != nil {
+			t.Fatal(err)
+		}
+	}
+	defer rds.Db.Where("owner = ?", owner).Delete(&dao.Order{})
+
+	hasFills := true
+	cases := []struct {
+		filter     *dao.OrderFilter
+		statusList []int
+		total      int
+	}{
+		{nil, nil, 3},
+		{&dao.OrderFilter{CreateTimeStart: now - 60}, nil, 2},
+		{&dao.OrderFilter{ValidUntilEnd: now + 200}, nil, 2},
+		{&dao.OrderFilter{MinPrice: 0.0015, MaxPrice: 0.0025}, nil, 1},
+		{&dao.OrderFilter{MinAmountS: big.NewInt(1000)}, nil, 2},
+		{&dao.OrderFilter{HasFills: &hasFills}, nil, 2},
+		{nil, []int{int(types.ORDER_NEW), int(types.ORDER_FINISHED)}, 2},
+	}
+	for i, c := range cases {
+		res, err := rds.OrderPageQuery(map[string]interface{}{"owner": owner}, c.filter, c.statusList, 1, 20)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if res.Total != c.total || len(res.Data) != c.total {
+			t.Fatalf("case %d should return %d orders, got total %d data %d", i, c.total, res.Total, len(res.Data))
+		}
+	}
+}
+
+// sell订单的price为市场价格的倒数, 按市场价格过滤
+func TestRdsService_OrderPageQueryPriceBySide(t *testing.T) {
+	rds := test.Rds()
+	owner := fmt.Sprintf("0x%040d", time.Now().UnixNano())
+	now := time.Now().Unix()
+
+	orders := []*dao.Order{
+		{Owner: owner, CreateTime: now, Side: "buy", Price: 0.002, Status: uint8(types.ORDER_NEW)},
+		{Owner: owner, CreateTime: now, Side: "sell", Price: 500, Status: uint8(types.ORDER_NEW)},
+		{Owner: owner, CreateTime: now, Side: "sell", Price: 0.002, Status: uint8(types.ORDER_NEW)},
+	}
+	for _, o := range orders {
+		if err := rds.Db.Create(o).Error; err != nil {
+			t.Fatal(err)
+		}
+	}
+	defer rds.Db.Where("owner = ?", owner).Delete(&dao.Order{})
+
+	res, err := rds.OrderPageQuery(map[string]interface{}{"owner": owner}, &dao.OrderFilter{MinPrice: 0.0015, MaxPrice: 0.0025}, nil, 1, 20)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if res.Total != 2 {
+		t.Fatalf("buy order at 0.002 and sell order at 1/500 should match, got total %d", res.Total)
+	}
+	for _, v := range res.Data {
+		if o := v.(dao.Order); o.Side == "sell" && o.Price != 500 {
+			t.Fatalf("sell order with price %f should not match", o.Price)
+		}
+	}
+}
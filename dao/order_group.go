@@ -0,0 +1,128 @@
+/*
+
+  Copyright 2017 Loopring Project Ltd (Loopring Foundation).
+
+  Licensed under the Apache License, Version 2.0 (the "License");
+  you may not use this file except in compliance with the License.
+  You may obtain a copy of the License at
+
+  http://www.apache.org/licenses/LICENSE-2.0
+
+  Unless required by applicable law or agreed to in writing, software
+  distributed under the License is distributed on an "AS IS" BASIS,
+  WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+  See the License for the specific language governing permissions and
+  limitations under the License.
+
+*/
+
+package dao
+
+import (
+	"github.com/Loopring/relay-lib/types"
+	"time"
+)
+
+const (
+	ORDER_GROUP_TYPE_OCO = "oco"
+
+	ORDER_GROUP_MEMBER_ACTIVE    = "active"
+	ORDER_GROUP_MEMBER_TRIGGERED = "triggered"
+	ORDER_GROUP_MEMBER_CANCELLED = "cancelled"
+)
+
+// 订单组成员, OCO组中任一订单成交时其他订单被软取消
+// PrevStatus保存被取消前的订单状态, 触发的fill分叉回滚时用于恢复
+type OrderGroupMember struct {
+	ID           int    `gorm:"column:id;primary_key;" json:"id"`
+	GroupId      string `gorm:"column:group_id;type:varchar(82);index" json:"groupId"`
+	GroupType    string `gorm:"column:group_type;type:varchar(20)" json:"groupType"`
+	OrderHash    string `gorm:"column:order_hash;type:varchar(82);unique_index" json:"orderHash"`
+	Owner        string `gorm:"column:owner;type:varchar(42)" json:"owner"`
+	Status       string `gorm:"column:status;type:varchar(20)" json:"status"`
+	PrevStatus   uint8  `gorm:"column:prev_status;type:tinyint(4)" json:"-"`
+	TriggerTx    string `gorm:"column:trigger_tx;type:varchar(82)" json:"triggerTx"`
+	TriggerBlock int64  `gorm:"column:trigger_block;type:bigint" json:"triggerBlock"`
+	CreateTime   int64  `gorm:"column:create_time;type:bigint" json:"createTime"`
+	UpdateTime   int64  `gorm:"column:update_time;type:bigint" json:"updateTime"`
+}
+
+func (s *RdsService) GetOrderGroupMember(orderHash string) (OrderGroupMember, error) {
+	var member OrderGroupMember
+	err := s.Db.Where("order_hash = ?", orderHash).First(&member).Error
+	return member, err
+}
+
+func (s *RdsService) GetOrderGroupMembers(groupId string) ([]OrderGroupMember, error) {
+	var list []OrderGroupMember
+	err := s.Db.Where("group_id = ?", groupId).Order("id ASC").Find(&list).Error
+	return list, err
+}
+
+// 返回orderHash -> groupId, 不属于任何组的订单不在结果中
+func (s *RdsService) GetOrderGroupIds(orderHashes []string) (map[string]string, error) {
+	var (
+		list []OrderGroupMember
+		ret  = make(map[string]string)
+	)
+
+	if len(orderHashes) == 0 {
+		return ret, nil
+	}
+
+	err := s.Db.Where("order_hash in (?)", orderHashes).Find(&list).Error
+	for _, v := range list {
+		ret[v.OrderHash] = v.GroupId
+	}
+	return ret, err
+}
+
+// 仅在成员当前为active时更新, 返回值为0说明组已被其他fill触发
+func (s *RdsService) TriggerOrderGroupMember(orderHash, txhash string, blockNumber int64) int64 {
+	items := map[string]interface{}{
+		"status":        ORDER_GROUP_MEMBER_TRIGGERED,
+		"trigger_tx":    txhash,
+		"trigger_block": blockNumber,
+		"update_time":   time.Now().Unix(),
+	}
+	return s.Db.Model(&OrderGroupMember{}).Where("order_hash = ? and status = ?", orderHash, ORDER_GROUP_MEMBER_ACTIVE).Updates(items).RowsAffected
+}
+
+func (s *RdsService) CancelOrderGroupMember(orderHash, txhash string, blockNumber int64, prevStatus types.OrderStatus) error {
+	items := map[string]interface{}{
+		"status":        ORDER_GROUP_MEMBER_CANCELLED,
+		"prev_status":   uint8(prevStatus),
+		"trigger_tx":    txhash,
+		"trigger_block": blockNumber,
+		"update_time":   time.Now().Unix(),
+	}
+	return s.Db.Model(&OrderGroupMember{}).Where("order_hash = ? and status = ?", orderHash, ORDER_GROUP_MEMBER_ACTIVE).Updates(items).Error
+}
+
+// 触发区块在分叉区间内的组成员
+func (s *RdsService) GetForkedOrderGroupMembers(from, to int64) ([]OrderGroupMember, error) {
+	var list []OrderGroupMember
+	err := s.Db.Where("trigger_block > ? and trigger_block <= ?", from, to).
+		Where("status in (?)", []string{ORDER_GROUP_MEMBER_TRIGGERED, ORDER_GROUP_MEMBER_CANCELLED}).
+		Find(&list).Error
+	return list, err
+}
+
+func (s *RdsService) ResetOrderGroupMember(orderHash string) error {
+	items := map[string]interface{}{
+		"status":        ORDER_GROUP_MEMBER_ACTIVE,
+		"prev_status":   0,
+		"trigger_tx":    "",
+		"trigger_block": 0,
+		"update_time":   time.Now().Unix(),
+	}
+	return s.Db.Model(&OrderGroupMember{}).Where("order_hash = ?", orderHash).Updates(items).Error
+}
+
+// 恢复被订单组软取消的订单, 订单已不是软取消状态时不做修改
+func (s *RdsService) RestoreFlexCancelledOrder(orderHash string, status types.OrderStatus) int64 {
+	return s.Db.Model(&Order{}).
+		Where("order_hash = ?", orderHash).
+		Where("status = ?", types.ORDER_FLEX_CANCEL).
+		Update("status", status).RowsAffected
+}
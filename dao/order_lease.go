@@ -0,0 +1,78 @@
+/*
+
+  Copyright 2017 Loopring Project Ltd (Loopring Foundation).
+
+  Licensed under the Apache License, Version 2.0 (the "License");
+  you may not use this file except in compliance with the License.
+  You may obtain a copy of the License at
+
+  http://www.apache.org/licenses/LICENSE-2.0
+
+  Unless required by applicable law or agreed to in writing, software
+  distributed under the License is distributed on an "AS IS" BASIS,
+  WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+  See the License for the specific language governing permissions and
+  limitations under the License.
+
+*/
+
+package dao
+
+import "time"
+
+// miner获取订单时按区块租用, 租期内其他miner不会再获取到该订单
+type OrderLease struct {
+	ID          int    `gorm:"column:id;primary_key;" json:"id"`
+	OrderHash   string `gorm:"column:order_hash;type:varchar(82);unique_index" json:"orderHash"`
+	MinerId     string `gorm:"column:miner_id;type:varchar(64)" json:"minerId"`
+	LeaseBlock  int64  `gorm:"column:lease_block;type:bigint" json:"leaseBlock"`
+	ExpireBlock int64  `gorm:"column:expire_block;type:bigint" json:"expireBlock"`
+	UpdateTime  int64  `gorm:"column:update_time;type:bigint" json:"updateTime"`
+}
+
+func (s *RdsService) GetOrderLease(orderHash string) (OrderLease, error) {
+	var lease OrderLease
+	err := s.Db.Where("order_hash = ?", orderHash).First(&lease).Error
+	return lease, err
+}
+
+// 订单未被租用, 租约已过期或已属于该miner时租用成功
+// 依赖order_hash唯一索引及带条件的update, 多个miner同时请求时只有一个能成功
+func (s *RdsService) TryLeaseOrder(orderHash, minerId string, blockNumber, expireBlock int64) bool {
+	lease := &OrderLease{
+		OrderHash:   orderHash,
+		MinerId:     minerId,
+		LeaseBlock:  blockNumber,
+		ExpireBlock: expireBlock,
+		UpdateTime:  time.Now().Unix(),
+	}
+
+	current, err := s.GetOrderLease(orderHash)
+	if err != nil {
+		return s.Db.Create(lease).Error == nil
+	}
+
+	db := s.Db.Model(&OrderLease{}).Where("id = ?", current.ID)
+	if current.MinerId != minerId {
+		db = db.Where("miner_id = ? and expire_block < ?", current.MinerId, blockNumber)
+	}
+	db = db.Updates(map[string]interface{}{
+		"miner_id":     minerId,
+		"lease_block":  blockNumber,
+		"expire_block": expireBlock,
+		"update_time":  lease.UpdateTime,
+	})
+
+	return db.Error == nil && (current.MinerId == minerId || db.RowsAffected > 0)
+}
+
+func (s *RdsService) ReleaseOrderLeases(orderHashes []string) error {
+	if len(orderHashes) == 0 {
+		return nil
+	}
+	return s.Db.Where("order_hash in (?)", orderHashes).Delete(&OrderLease{}).Error
+}
+
+func (s *RdsService) DelExpiredOrderLeases(blockNumber int64) error {
+	return s.Db.Where("expire_block < ?", blockNumber).Delete(&OrderLease{}).Error
+}
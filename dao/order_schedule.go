@@ -0,0 +1,179 @@
+/*
+
+  Copyright 2017 Loopring Project Ltd (Loopring Foundation).
+
+  Licensed under the Apache License, Version 2.0 (the "License");
+  you may not use this file except in compliance with the License.
+  You may obtain a copy of the License at
+
+  http://www.apache.org/licenses/LICENSE-2.0
+
+  Unless required by applicable law or agreed to in writing, software
+  distributed under the License is distributed on an "AS IS" BASIS,
+  WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+  See the License for the specific language governing permissions and
+  limitations under the License.
+
+*/
+
+package dao
+
+import (
+	"encoding/json"
+	"github.com/Loopring/relay-lib/types"
+	"time"
+)
+
+const (
+	SCHEDULE_STATUS_ACTIVE    = "active"
+	SCHEDULE_STATUS_PAUSED    = "paused"
+	SCHEDULE_STATUS_CANCELLED = "cancelled"
+	SCHEDULE_STATUS_FINISHED  = "finished"
+
+	SCHEDULED_ORDER_PENDING   = "pending"
+	SCHEDULED_ORDER_RELEASED  = "released"
+	SCHEDULED_ORDER_FAILED    = "failed"
+	SCHEDULED_ORDER_CANCELLED = "cancelled"
+)
+
+// 预签名订单计划(TWAP), 订单在releaseTime到达前只保存在scheduled_order中
+// 市场价格相对referencePrice偏离超过maxDeviation时计划自动暂停
+type OrderSchedule struct {
+	ID             int     `gorm:"column:id;primary_key;" json:"id"`
+	ScheduleId     string  `gorm:"column:schedule_id;type:varchar(82);unique_index" json:"scheduleId"`
+	Owner          string  `gorm:"column:owner;type:varchar(42)" json:"owner"`
+	Market         string  `gorm:"column:market;type:varchar(40)" json:"market"`
+	Side           string  `gorm:"column:side;type:varchar(40)" json:"side"`
+	PriceSource    string  `gorm:"column:price_source;type:varchar(20)" json:"priceSource"`
+	ReferencePrice float64 `gorm:"column:reference_price;type:decimal(28,16);" json:"referencePrice"`
+	MaxDeviation   float64 `gorm:"column:max_deviation;type:decimal(10,4);" json:"maxDeviation"`
+	Status         string  `gorm:"column:status;type:varchar(20)" json:"status"`
+	PauseReason    string  `gorm:"column:pause_reason;type:varchar(255)" json:"pauseReason"`
+	CreateTime     int64   `gorm:"column:create_time;type:bigint" json:"createTime"`
+	UpdateTime     int64   `gorm:"column:update_time;type:bigint" json:"updateTime"`
+}
+
+type ScheduledOrder struct {
+	ID          int    `gorm:"column:id;primary_key;" json:"id"`
+	ScheduleId  string `gorm:"column:schedule_id;type:varchar(82);index" json:"scheduleId"`
+	OrderHash   string `gorm:"column:order_hash;type:varchar(82);unique_index" json:"orderHash"`
+	ReleaseTime int64  `gorm:"column:release_time;type:bigint" json:"releaseTime"`
+	RawOrder    string `gorm:"column:raw_order;type:text" json:"-"`
+	Status      string `gorm:"column:status;type:varchar(20)" json:"status"`
+	ErrMsg      string `gorm:"column:err_msg;type:varchar(255)" json:"errMsg"`
+	UpdateTime  int64  `gorm:"column:update_time;type:bigint" json:"updateTime"`
+}
+
+func (o *ScheduledOrder) ConvertDown(order *types.Order) error {
+	data, err := json.Marshal(order)
+	if err != nil {
+		return err
+	}
+
+	o.OrderHash = order.Hash.Hex()
+	o.RawOrder = string(data)
+	return nil
+}
+
+func (o *ScheduledOrder) ConvertUp(order *types.Order) error {
+	return json.Unmarshal([]byte(o.RawOrder), order)
+}
+
+func (s *RdsService) GetOrderSchedule(scheduleId string) (OrderSchedule, error) {
+	var schedule OrderSchedule
+	err := s.Db.Where("schedule_id = ?", scheduleId).First(&schedule).Error
+	return schedule, err
+}
+
+func (s *RdsService) GetActiveOrderSchedules() ([]OrderSchedule, error) {
+	var list []OrderSchedule
+	err := s.Db.Where("status = ?", SCHEDULE_STATUS_ACTIVE).Order("create_time ASC").Find(&list).Error
+	return list, err
+}
+
+func (s *RdsService) GetScheduledOrders(scheduleId string) ([]ScheduledOrder, error) {
+	var list []ScheduledOrder
+	err := s.Db.Where("schedule_id = ?", scheduleId).Order("release_time ASC").Find(&list).Error
+	return list, err
+}
+
+func (s *RdsService) GetDueScheduledOrders(scheduleId string, now int64) ([]ScheduledOrder, error) {
+	var list []ScheduledOrder
+	err := s.Db.Where("schedule_id = ?", scheduleId).
+		Where("status = ?", SCHEDULED_ORDER_PENDING).
+		Where("release_time <= ?", now).
+		Order("release_time ASC").
+		Find(&list).Error
+	return list, err
+}
+
+func (s *RdsService) CountPendingScheduledOrders(scheduleId string) (int, error) {
+	var count int
+	err := s.Db.Model(&ScheduledOrder{}).Where("schedule_id = ? and status = ?", scheduleId, SCHEDULED_ORDER_PENDING).Count(&count).Error
+	return count, err
+}
+
+func (s *RdsService) OrderSchedulePageQuery(query map[string]interface{}, pageIndex, pageSize int) (PageResult, error) {
+	var (
+		schedules  []OrderSchedule
+		err        error
+		data       = make([]interface{}, 0)
+		pageResult PageResult
+	)
+
+	if pageIndex <= 0 {
+		pageIndex = 1
+	}
+
+	if pageSize <= 0 {
+		pageSize = 20
+	}
+
+	pageResult = PageResult{Data: data, PageIndex: pageIndex, PageSize: pageSize}
+
+	if err = s.Db.Where(query).Offset((pageIndex - 1) * pageSize).Order("create_time DESC").Limit(pageSize).Find(&schedules).Error; err != nil {
+		return pageResult, err
+	}
+
+	if err = s.Db.Model(&OrderSchedule{}).Where(query).Count(&pageResult.Total).Error; err != nil {
+		return pageResult, err
+	}
+
+	for _, v := range schedules {
+		data = append(data, v)
+	}
+	pageResult.Data = data
+
+	return pageResult, err
+}
+
+// 仅在当前状态为fromStatus时更新, 返回值为0说明状态已被修改
+func (s *RdsService) UpdateOrderScheduleStatus(scheduleId, fromStatus, toStatus, reason string) int64 {
+	items := map[string]interface{}{
+		"status":       toStatus,
+		"pause_reason": reason,
+		"update_time":  time.Now().Unix(),
+	}
+	return s.Db.Model(&OrderSchedule{}).Where("schedule_id = ? and status = ?", scheduleId, fromStatus).Updates(items).RowsAffected
+}
+
+func (s *RdsService) SetOrderScheduleReferencePrice(scheduleId string, price float64) error {
+	return s.Db.Model(&OrderSchedule{}).Where("schedule_id = ?", scheduleId).Update("reference_price", price).Error
+}
+
+func (s *RdsService) UpdateScheduledOrderStatus(orderHash, fromStatus, toStatus, errMsg string) int64 {
+	items := map[string]interface{}{
+		"status":      toStatus,
+		"err_msg":     errMsg,
+		"update_time": time.Now().Unix(),
+	}
+	return s.Db.Model(&ScheduledOrder{}).Where("order_hash = ? and status = ?", orderHash, fromStatus).Updates(items).RowsAffected
+}
+
+func (s *RdsService) CancelPendingScheduledOrders(scheduleId string) error {
+	items := map[string]interface{}{
+		"status":      SCHEDULED_ORDER_CANCELLED,
+		"update_time": time.Now().Unix(),
+	}
+	return s.Db.Model(&ScheduledOrder{}).Where("schedule_id = ? and status = ?", scheduleId, SCHEDULED_ORDER_PENDING).Updates(items).Error
+}
@@ -31,6 +31,7 @@ type OrderPendingTransaction struct {
 	OrderStatus uint8  `gorm:"column:order_status;type:tinyint(4)"`
 	TxHash      string `gorm:"column:tx_hash;type:varchar(82)"`
 	Nonce       int64  `gorm:"column:nonce;type:bigint"`
+	CreateTime  int64  `gorm:"column:create_time;type:bigint"`
 }
 
 // convert types/orderTxRecord to dao/ordertx
@@ -83,9 +84,22 @@ func (s *RdsService) DelPendingOrderTx(owner common.Address, orderhash common.Ha
 		list = append(list, v.Hex())
 	}
 
-	return s.Db.Delete(&OrderPendingTransaction{}).
-		Where("owner=?", owner.Hash()).
+	return s.Db.Where("owner=?", owner.Hex()).
 		Where("order_hash=?", orderhash.Hex()).
 		Where("tx_hash in (?)", list).
+		Delete(&OrderPendingTransaction{}).
 		RowsAffected
 }
+
+// 创建时间早于before的pending tx, 用于清理链上已丢弃或被替换的tx
+func (s *RdsService) GetStalePendingOrderTxs(before int64) ([]OrderPendingTransaction, error) {
+	var list []OrderPendingTransaction
+	err := s.Db.Where("create_time > 0 and create_time < ?", before).Order("nonce ASC").Find(&list).Error
+	return list, err
+}
+
+// create_time字段新增前写入的记录为0, 以当前时间补齐, 避免被当作过期tx清理
+func (s *RdsService) BackfillPendingOrderTxCreateTime(now int64) (int64, error) {
+	ret := s.Db.Model(&OrderPendingTransaction{}).Where("create_time = 0").Update("create_time", now)
+	return ret.RowsAffected, ret.Error
+}
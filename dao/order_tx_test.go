@@ -0,0 +1,59 @@
+/*
+
+  Copyright 2017 Loopring Project Ltd (Loopring Foundation).
+
+  Licensed under the Apache License, Version 2.0 (the "License");
+  you may not use this file except in compliance with the License.
+  You may obtain a copy of the License at
+
+  http://www.apache.org/licenses/LICENSE-2.0
+
+  Unless required by applicable law or agreed to in writing, software
+  distributed under the License is distributed on an "AS IS" BASIS,
+  WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+  See the License for the specific language governing permissions and
+  limitations under the License.
+
+*/
+
+package dao_test
+
+import (
+	"github.com/Loopring/relay-cluster/dao"
+	"github.com/Loopring/relay-cluster/test"
+	"github.com/ethereum/go-ethereum/common"
+	"testing"
+)
+
+// 只删除owner、订单及txhash都匹配的记录
+func TestRdsService_DelPendingOrderTx(t *testing.T) {
+	rds := test.Rds()
+	owner := common.HexToAddress("0xb1018949b241D76A1AB2094f473E9bEfeAbB5Ead")
+	orderhash := common.HexToHash("0x52c90064a0503ce566a50876fc41e0d549bffd2ba757f859b1749a75be798819")
+	txhash1 := common.HexToHash("0x01")
+	txhash2 := common.HexToHash("0x02")
+
+	for _, txhash := range []common.Hash{txhash1, txhash2} {
+		model := &dao.OrderPendingTransaction{Owner: owner.Hex(), OrderHash: orderhash.Hex(), TxHash: txhash.Hex()}
+		if err := rds.Add(model); err != nil {
+			t.Fatal(err.Error())
+		}
+	}
+
+	otherOwner := common.HexToAddress("0x71C079107B5af8619D54537A93dbF16e5aab4900")
+	if n := rds.DelPendingOrderTx(otherOwner, orderhash, []common.Hash{txhash1}); n != 0 {
+		t.Fatalf("should not delete pending tx of other owner, deleted %d", n)
+	}
+	if n := rds.DelPendingOrderTx(owner, orderhash, []common.Hash{txhash1}); n != 1 {
+		t.Fatalf("expected 1 pending tx deleted, got %d", n)
+	}
+
+	list, err := rds.GetPendingOrderTxSortedByNonce(owner, orderhash)
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	if len(list) != 1 || list[0].TxHash != txhash2.Hex() {
+		t.Fatalf("only tx %s should remain, got %d txs", txhash2.Hex(), len(list))
+	}
+	rds.DelPendingOrderTx(owner, orderhash, []common.Hash{txhash2})
+}
@@ -0,0 +1,51 @@
+/*
+
+  Copyright 2017 Loopring Project Ltd (Loopring Foundation).
+
+  Licensed under the Apache License, Version 2.0 (the "License");
+  you may not use this file except in compliance with the License.
+  You may obtain a copy of the License at
+
+  http://www.apache.org/licenses/LICENSE-2.0
+
+  Unless required by applicable law or agreed to in writing, software
+  distributed under the License is distributed on an "AS IS" BASIS,
+  WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+  See the License for the specific language governing permissions and
+  limitations under the License.
+
+*/
+
+package dao
+
+// post-only订单只作为maker挂单, 提交时会与对手盘成交的订单被拒绝
+type PostOnlyOrder struct {
+	ID         int    `gorm:"column:id;primary_key;" json:"id"`
+	OrderHash  string `gorm:"column:order_hash;type:varchar(82);unique_index" json:"orderHash"`
+	Owner      string `gorm:"column:owner;type:varchar(42)" json:"owner"`
+	CreateTime int64  `gorm:"column:create_time;type:bigint" json:"createTime"`
+}
+
+func (s *RdsService) GetPostOnlyOrderByHash(orderHash string) (PostOnlyOrder, error) {
+	var order PostOnlyOrder
+	err := s.Db.Where("order_hash = ?", orderHash).First(&order).Error
+	return order, err
+}
+
+// 返回orderHashes中的post-only订单
+func (s *RdsService) GetPostOnlyOrderHashes(orderHashes []string) (map[string]bool, error) {
+	var (
+		list []PostOnlyOrder
+		ret  = make(map[string]bool)
+	)
+
+	if len(orderHashes) == 0 {
+		return ret, nil
+	}
+
+	err := s.Db.Where("order_hash in (?)", orderHashes).Find(&list).Error
+	for _, v := range list {
+		ret[v.OrderHash] = true
+	}
+	return ret, err
+}
@@ -0,0 +1,113 @@
+/*
+
+  Copyright 2017 Loopring Project Ltd (Loopring Foundation).
+
+  Licensed under the Apache License, Version 2.0 (the "License");
+  you may not use this file except in compliance with the License.
+  You may obtain a copy of the License at
+
+  http://www.apache.org/licenses/LICENSE-2.0
+
+  Unless required by applicable law or agreed to in writing, software
+  distributed under the License is distributed on an "AS IS" BASIS,
+  WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+  See the License for the specific language governing permissions and
+  limitations under the License.
+
+*/
+
+package dao
+
+import "time"
+
+// 订单参与提交环路的结果统计, consecutiveFails为同一原因连续失败的次数
+type RingSubmitStat struct {
+	ID               int    `gorm:"column:id;primary_key;" json:"id"`
+	OrderHash        string `gorm:"column:order_hash;type:varchar(82);unique_index" json:"orderHash"`
+	Owner            string `gorm:"column:owner;type:varchar(42);index" json:"owner"`
+	SubmitCount      int64  `gorm:"column:submit_count;type:bigint" json:"submitCount"`
+	FailCount        int64  `gorm:"column:fail_count;type:bigint" json:"failCount"`
+	ConsecutiveFails int64  `gorm:"column:consecutive_fails;type:bigint" json:"consecutiveFails"`
+	LastCause        string `gorm:"column:last_cause;type:varchar(40)" json:"lastCause"`
+	LastErr          string `gorm:"column:last_err;type:varchar(255)" json:"lastErr"`
+	LastTxHash       string `gorm:"column:last_tx_hash;type:varchar(82)" json:"lastTxHash"`
+	IneligibleUntil  int64  `gorm:"column:ineligible_until;type:bigint" json:"ineligibleUntil"`
+	UpdateTime       int64  `gorm:"column:update_time;type:bigint" json:"updateTime"`
+}
+
+func (s *RdsService) GetRingSubmitStat(orderHash string) (RingSubmitStat, error) {
+	var stat RingSubmitStat
+	err := s.Db.Where("order_hash = ?", orderHash).First(&stat).Error
+	return stat, err
+}
+
+func (s *RdsService) SaveRingSubmitStat(stat *RingSubmitStat) error {
+	stat.UpdateTime = time.Now().Unix()
+	if stat.ID == 0 {
+		return s.Add(stat)
+	}
+	return s.Save(stat)
+}
+
+// 成交后不再计算连续失败, 同时恢复撮合资格
+func (s *RdsService) ResetRingSubmitFails(orderHash string) error {
+	items := map[string]interface{}{
+		"consecutive_fails": 0,
+		"ineligible_until":  0,
+		"update_time":       time.Now().Unix(),
+	}
+	return s.Db.Model(&RingSubmitStat{}).Where("order_hash = ? and consecutive_fails > 0", orderHash).Updates(items).Error
+}
+
+func (s *RdsService) GetIneligibleOrderHashes(now int64) ([]string, error) {
+	var hashes []string
+	err := s.Db.Model(&RingSubmitStat{}).Where("ineligible_until > ?", now).Pluck("order_hash", &hashes).Error
+	return hashes, err
+}
+
+// 提交失败的订单立即回到撮合池
+func (s *RdsService) ResetMinerOrders(orderHashes []string) error {
+	if len(orderHashes) == 0 {
+		return nil
+	}
+	return s.Db.Model(&Order{}).Where("order_hash in (?)", orderHashes).Update("miner_block_mark", 0).Error
+}
+
+func (s *RdsService) RingSubmitStatPageQuery(query map[string]interface{}, onlyIneligible bool, pageIndex, pageSize int) (PageResult, error) {
+	var (
+		stats      []RingSubmitStat
+		err        error
+		data       = make([]interface{}, 0)
+		pageResult PageResult
+	)
+
+	if pageIndex <= 0 {
+		pageIndex = 1
+	}
+
+	if pageSize <= 0 {
+		pageSize = 20
+	}
+
+	pageResult = PageResult{Data: data, PageIndex: pageIndex, PageSize: pageSize}
+
+	db := s.Db.Model(&RingSubmitStat{}).Where(query)
+	if onlyIneligible {
+		db = db.Where("ineligible_until > ?", time.Now().Unix())
+	}
+
+	if err = db.Offset((pageIndex - 1) * pageSize).Order("fail_count DESC").Limit(pageSize).Find(&stats).Error; err != nil {
+		return pageResult, err
+	}
+
+	if err = db.Count(&pageResult.Total).Error; err != nil {
+		return pageResult, err
+	}
+
+	for _, v := range stats {
+		data = append(data, v)
+	}
+	pageResult.Data = data
+
+	return pageResult, err
+}
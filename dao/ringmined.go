@@ -150,6 +150,31 @@ func (s *RdsService) RingMinedPageQuery(query map[string]interface{}, pageIndex,
 	return
 }
 
+func (s *RdsService) RingMinedCursorQuery(query map[string]interface{}, cursor string, pageSize int) (res PageResult, err error) {
+	ringMined := make([]RingMinedEvent, 0)
+	pageSize = cursorPageSize(pageSize)
+	res = PageResult{PageSize: pageSize, Data: make([]interface{}, 0)}
+
+	scope, err := cursorScope("time", cursor, pageSize)
+	if err != nil {
+		return res, err
+	}
+	if err = s.Db.Where(query).Where("fork = ?", false).Scopes(scope).Find(&ringMined).Error; err != nil {
+		return res, err
+	}
+
+	res.NextCursor = nextCursor(len(ringMined), pageSize, func(i int) (int64, int) {
+		return ringMined[i].Time, ringMined[i].ID
+	})
+	for i, rm := range ringMined {
+		if i >= pageSize {
+			break
+		}
+		res.Data = append(res.Data, rm)
+	}
+	return
+}
+
 func (s *RdsService) GetRingminedMethods(lastId int, limit int) ([]RingMinedEvent, error) {
 	var (
 		list []RingMinedEvent
@@ -0,0 +1,113 @@
+/*
+
+  Copyright 2017 Loopring Project Ltd (Loopring Foundation).
+
+  Licensed under the Apache License, Version 2.0 (the "License");
+  you may not use this file except in compliance with the License.
+  You may obtain a copy of the License at
+
+  http://www.apache.org/licenses/LICENSE-2.0
+
+  Unless required by applicable law or agreed to in writing, software
+  distributed under the License is distributed on an "AS IS" BASIS,
+  WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+  See the License for the specific language governing permissions and
+  limitations under the License.
+
+*/
+
+package dao
+
+import (
+	"errors"
+	"strings"
+	"time"
+)
+
+const (
+	SESSION_KEY_STATUS_ACTIVE  = "active"
+	SESSION_KEY_STATUS_REVOKED = "revoked"
+)
+
+// owner授权的会话密钥, 可代替owner签名订单和软撤单
+// markets为空时不限制市场, maxNotional为0时不限制单笔订单计价币数量
+// signTime为最近一次授权或撤销签名的timestamp, 撤销后记录保留, 旧签名不能被重放
+type SessionKey struct {
+	ID             int     `gorm:"column:id;primary_key;" json:"id"`
+	Owner          string  `gorm:"column:owner;type:varchar(42);unique_index:idx_session_key_owner" json:"owner"`
+	SessionAddress string  `gorm:"column:session_address;type:varchar(42);unique_index:idx_session_key_owner" json:"sessionAddress"`
+	Markets        string  `gorm:"column:markets;type:varchar(1024)" json:"markets"`
+	MaxNotional    float64 `gorm:"column:max_notional;type:decimal(28,8);" json:"maxNotional"`
+	ExpireTime     int64   `gorm:"column:expire_time;type:bigint" json:"expireTime"`
+	Status         string  `gorm:"column:status;type:varchar(20)" json:"status"`
+	SignTime       int64   `gorm:"column:sign_time;type:bigint" json:"signTime"`
+	CreateTime     int64   `gorm:"column:create_time;type:bigint" json:"createTime"`
+	UpdateTime     int64   `gorm:"column:update_time;type:bigint" json:"updateTime"`
+}
+
+func (k *SessionKey) IsValid(now int64) bool {
+	return k.Status == SESSION_KEY_STATUS_ACTIVE && k.ExpireTime > now
+}
+
+// market为空表示不限定市场的操作, 只有未限制市场的会话密钥可以执行
+func (k *SessionKey) AllowMarket(market string) bool {
+	if k.Markets == "" {
+		return true
+	}
+	if market == "" {
+		return false
+	}
+	for _, v := range strings.Split(k.Markets, ",") {
+		if strings.ToUpper(v) == strings.ToUpper(market) {
+			return true
+		}
+	}
+	return false
+}
+
+func (k *SessionKey) AllowNotional(notional float64) bool {
+	return k.MaxNotional <= 0 || notional <= k.MaxNotional
+}
+
+func (s *RdsService) GetSessionKey(owner, sessionAddress string) (SessionKey, error) {
+	var key SessionKey
+	err := s.Db.Where("owner = ? and session_address = ?", owner, sessionAddress).First(&key).Error
+	return key, err
+}
+
+func (s *RdsService) GetSessionKeys(owner string) ([]SessionKey, error) {
+	var list []SessionKey
+	err := s.Db.Where("owner = ?", owner).Order("create_time DESC").Find(&list).Error
+	return list, err
+}
+
+// 同一会话地址重复授权时覆盖原授权, 签名timestamp不大于已记录的signTime时不更新
+func (s *RdsService) SaveSessionKey(key *SessionKey) error {
+	current, err := s.GetSessionKey(key.Owner, key.SessionAddress)
+	if err != nil {
+		return s.Add(key)
+	}
+
+	key.ID = current.ID
+	items := map[string]interface{}{
+		"markets":      key.Markets,
+		"max_notional": key.MaxNotional,
+		"expire_time":  key.ExpireTime,
+		"status":       key.Status,
+		"sign_time":    key.SignTime,
+		"update_time":  key.UpdateTime,
+	}
+	if s.Db.Model(&SessionKey{}).Where("id = ? and sign_time < ?", current.ID, key.SignTime).Updates(items).RowsAffected == 0 {
+		return errors.New("sign timestamp must be bigger than the last one")
+	}
+	return nil
+}
+
+func (s *RdsService) RevokeSessionKey(owner, sessionAddress string, signTime int64) int64 {
+	items := map[string]interface{}{
+		"status":      SESSION_KEY_STATUS_REVOKED,
+		"sign_time":   signTime,
+		"update_time": time.Now().Unix(),
+	}
+	return s.Db.Model(&SessionKey{}).Where("owner = ? and session_address = ? and status = ? and sign_time < ?", owner, sessionAddress, SESSION_KEY_STATUS_ACTIVE, signTime).Updates(items).RowsAffected
+}
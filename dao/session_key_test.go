@@ -0,0 +1,48 @@
+/*
+
+  Copyright 2017 Loopring Project Ltd (Loopring Foundation).
+
+  Licensed under the Apache License, Version 2.0 (the "License");
+  you may not use this file except in compliance with the License.
+  You may obtain a copy of the License at
+
+  http://www.apache.org/licenses/LICENSE-2.0
+
+  Unless required by applicable law or agreed to in writing, software
+  distributed under the License is distributed on an "AS IS" BASIS,
+  WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+  See the License for the specific language governing permissions and
+  limitations under the License.
+
+*/
+
+package dao_test
+
+import (
+	"github.com/Loopring/relay-cluster/dao"
+	"testing"
+)
+
+func TestSessionKey_Limits(t *testing.T) {
+	key := dao.SessionKey{Markets: "LRC-WETH,RDN-WETH", MaxNotional: 1.5, ExpireTime: 100, Status: dao.SESSION_KEY_STATUS_ACTIVE}
+
+	if !key.IsValid(99) || key.IsValid(100) {
+		t.Fatalf("session key should be valid before expire time only")
+	}
+	if !key.AllowMarket("lrc-weth") || key.AllowMarket("EOS-WETH") || key.AllowMarket("") {
+		t.Fatalf("session key should only allow delegated markets")
+	}
+	if !key.AllowNotional(1.5) || key.AllowNotional(1.6) {
+		t.Fatalf("session key should limit notional")
+	}
+
+	key.Status = dao.SESSION_KEY_STATUS_REVOKED
+	if key.IsValid(0) {
+		t.Fatalf("revoked session key should be invalid")
+	}
+
+	unlimited := dao.SessionKey{}
+	if !unlimited.AllowMarket("") || !unlimited.AllowNotional(1e18) {
+		t.Fatalf("session key without limits should allow all")
+	}
+}
@@ -0,0 +1,71 @@
+/*
+
+  Copyright 2017 Loopring Project Ltd (Loopring Foundation).
+
+  Licensed under the Apache License, Version 2.0 (the "License");
+  you may not use this file except in compliance with the License.
+  You may obtain a copy of the License at
+
+  http://www.apache.org/licenses/LICENSE-2.0
+
+  Unless required by applicable law or agreed to in writing, software
+  distributed under the License is distributed on an "AS IS" BASIS,
+  WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+  See the License for the specific language governing permissions and
+  limitations under the License.
+
+*/
+
+package dao
+
+const (
+	STUCK_TX_DROPPED  = "dropped"
+	STUCK_TX_REPLACED = "replaced"
+)
+
+// 订单因pending tx丢失或被替换而卡在pending/cancelling/cutoffing状态时的恢复记录
+type StuckOrderRecovery struct {
+	ID            int    `gorm:"column:id;primary_key;" json:"id"`
+	OrderHash     string `gorm:"column:order_hash;type:varchar(82)" json:"orderHash"`
+	Owner         string `gorm:"column:owner;type:varchar(42)" json:"owner"`
+	TxHash        string `gorm:"column:tx_hash;type:varchar(82)" json:"txHash"`
+	Nonce         int64  `gorm:"column:nonce;type:bigint" json:"nonce"`
+	Reason        string `gorm:"column:reason;type:varchar(20)" json:"reason"`
+	StuckStatus   uint8  `gorm:"column:stuck_status;type:tinyint(4)" json:"stuckStatus"`
+	RestoreStatus uint8  `gorm:"column:restore_status;type:tinyint(4)" json:"restoreStatus"`
+	CreateTime    int64  `gorm:"column:create_time;type:bigint" json:"createTime"`
+}
+
+func (s *RdsService) StuckOrderRecoveryPageQuery(query map[string]interface{}, pageIndex, pageSize int) (PageResult, error) {
+	var (
+		list       []StuckOrderRecovery
+		err        error
+		data       = make([]interface{}, 0)
+		pageResult PageResult
+	)
+
+	if pageIndex <= 0 {
+		pageIndex = 1
+	}
+
+	if pageSize <= 0 {
+		pageSize = 20
+	}
+
+	pageResult = PageResult{Data: data, PageIndex: pageIndex, PageSize: pageSize}
+
+	if err = s.Db.Where(query).Offset((pageIndex - 1) * pageSize).Order("create_time DESC").Limit(pageSize).Find(&list).Error; err != nil {
+		return pageResult, err
+	}
+
+	if err = s.Db.Model(&StuckOrderRecovery{}).Where(query).Count(&pageResult.Total).Error; err != nil {
+		return pageResult, err
+	}
+
+	for _, v := range list {
+		data = append(data, v)
+	}
+	pageResult.Data = data
+
+	return pageResult, err
+}
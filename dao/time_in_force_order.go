@@ -0,0 +1,117 @@
+/*
+
+  Copyright 2017 Loopring Project Ltd (Loopring Foundation).
+
+  Licensed under the Apache License, Version 2.0 (the "License");
+  you may not use this file except in compliance with the License.
+  You may obtain a copy of the License at
+
+  http://www.apache.org/licenses/LICENSE-2.0
+
+  Unless required by applicable law or agreed to in writing, software
+  distributed under the License is distributed on an "AS IS" BASIS,
+  WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+  See the License for the specific language governing permissions and
+  limitations under the License.
+
+*/
+
+package dao
+
+import "time"
+
+const (
+	TIME_IN_FORCE_IOC = "IOC"
+	TIME_IN_FORCE_FOK = "FOK"
+
+	TIME_IN_FORCE_STATUS_ACTIVE    = "active"
+	TIME_IN_FORCE_STATUS_FILLED    = "filled"
+	TIME_IN_FORCE_STATUS_CANCELLED = "cancelled"
+)
+
+// IOC订单在指定区块数或秒数后自动软取消未成交部分, FOK订单未在一个环路中完全成交时自动软取消
+type TimeInForceOrder struct {
+	ID           int    `gorm:"column:id;primary_key;" json:"id"`
+	OrderHash    string `gorm:"column:order_hash;type:varchar(82);unique_index" json:"orderHash"`
+	Owner        string `gorm:"column:owner;type:varchar(42)" json:"owner"`
+	TimeInForce  string `gorm:"column:time_in_force;type:varchar(10)" json:"timeInForce"`
+	CreateBlock  int64  `gorm:"column:create_block;type:bigint" json:"createBlock"`
+	Status       string `gorm:"column:status;type:varchar(20)" json:"status"`
+	CancelReason string `gorm:"column:cancel_reason;type:varchar(255)" json:"cancelReason"`
+	CreateTime   int64  `gorm:"column:create_time;type:bigint" json:"createTime"`
+	UpdateTime   int64  `gorm:"column:update_time;type:bigint" json:"updateTime"`
+}
+
+func (s *RdsService) GetTimeInForceOrderByHash(orderHash string) (TimeInForceOrder, error) {
+	var order TimeInForceOrder
+	err := s.Db.Where("order_hash = ?", orderHash).First(&order).Error
+	return order, err
+}
+
+func (s *RdsService) GetActiveTimeInForceOrders() ([]TimeInForceOrder, error) {
+	var list []TimeInForceOrder
+	err := s.Db.Where("status = ?", TIME_IN_FORCE_STATUS_ACTIVE).Order("create_time ASC").Find(&list).Error
+	return list, err
+}
+
+// 返回orderHashes中有效的IOC/FOK订单的类型
+func (s *RdsService) GetActiveTimeInForces(orderHashes []string) (map[string]string, error) {
+	var (
+		list []TimeInForceOrder
+		ret  = make(map[string]string)
+	)
+
+	if len(orderHashes) == 0 {
+		return ret, nil
+	}
+
+	err := s.Db.Where("order_hash in (?)", orderHashes).Where("status = ?", TIME_IN_FORCE_STATUS_ACTIVE).Find(&list).Error
+	for _, v := range list {
+		ret[v.OrderHash] = v.TimeInForce
+	}
+	return ret, err
+}
+
+func (s *RdsService) TimeInForceOrderPageQuery(query map[string]interface{}, pageIndex, pageSize int) (PageResult, error) {
+	var (
+		orders     []TimeInForceOrder
+		err        error
+		data       = make([]interface{}, 0)
+		pageResult PageResult
+	)
+
+	if pageIndex <= 0 {
+		pageIndex = 1
+	}
+
+	if pageSize <= 0 {
+		pageSize = 20
+	}
+
+	pageResult = PageResult{Data: data, PageIndex: pageIndex, PageSize: pageSize}
+
+	if err = s.Db.Where(query).Offset((pageIndex - 1) * pageSize).Order("create_time DESC").Limit(pageSize).Find(&orders).Error; err != nil {
+		return pageResult, err
+	}
+
+	if err = s.Db.Model(&TimeInForceOrder{}).Where(query).Count(&pageResult.Total).Error; err != nil {
+		return pageResult, err
+	}
+
+	for _, v := range orders {
+		data = append(data, v)
+	}
+	pageResult.Data = data
+
+	return pageResult, err
+}
+
+// 仅在当前状态为active时更新, 返回值为0说明已被处理
+func (s *RdsService) FinishTimeInForceOrder(orderHash, status, reason string) int64 {
+	items := map[string]interface{}{
+		"status":        status,
+		"cancel_reason": reason,
+		"update_time":   time.Now().Unix(),
+	}
+	return s.Db.Model(&TimeInForceOrder{}).Where("order_hash = ? and status = ?", orderHash, TIME_IN_FORCE_STATUS_ACTIVE).Updates(items).RowsAffected
+}
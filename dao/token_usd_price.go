@@ -0,0 +1,49 @@
+/*
+
+  Copyright 2017 Loopring Project Ltd (Loopring Foundation).
+
+  Licensed under the Apache License, Version 2.0 (the "License");
+  you may not use this file except in compliance with the License.
+  You may obtain a copy of the License at
+
+  http://www.apache.org/licenses/LICENSE-2.0
+
+  Unless required by applicable law or agreed to in writing, software
+  distributed under the License is distributed on an "AS IS" BASIS,
+  WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+  See the License for the specific language governing permissions and
+  limitations under the License.
+
+*/
+
+package dao
+
+// 成交时记录的token美元价格, 按分钟去重, 用于计算历史成本
+type TokenUsdPrice struct {
+	ID    int     `gorm:"column:id;primary_key;" json:"id"`
+	Token string  `gorm:"column:token;type:varchar(42);unique_index:idx_token_usd_price_time" json:"token"`
+	Time  int64   `gorm:"column:time;type:bigint;unique_index:idx_token_usd_price_time" json:"time"`
+	Price float64 `gorm:"column:price;type:decimal(28,10)" json:"price"`
+}
+
+func (s *RdsService) SaveTokenUsdPrice(price *TokenUsdPrice) error {
+	var count int
+	if err := s.Db.Model(&TokenUsdPrice{}).Where("token = ? and time = ?", price.Token, price.Time).Count(&count).Error; err != nil {
+		return err
+	}
+	if count > 0 {
+		return nil
+	}
+	return s.Add(price)
+}
+
+// 优先取t之前最近的价格, 没有时取之后最近的价格
+func (s *RdsService) GetTokenUsdPriceAt(token string, t int64) (TokenUsdPrice, error) {
+	var price TokenUsdPrice
+	err := s.Db.Where("token = ? and time <= ?", token, t).Order("time DESC").First(&price).Error
+	if err == nil {
+		return price, nil
+	}
+	err = s.Db.Where("token = ? and time > ?", token, t).Order("time ASC").First(&price).Error
+	return price, err
+}
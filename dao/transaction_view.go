@@ -143,6 +143,62 @@ func (s *RdsService) GetTxViewByOwner(owner string, symbol string, status types.
 	return txs, err
 }
 
+// update_time会随状态变化, 游标模式按create_time遍历
+func (s *RdsService) GetTxViewByOwnerCursor(owner string, symbol string, status types.TxStatus, typ txtyp.TxType, cursor string, limit int) ([]TransactionView, string, error) {
+	var txs []TransactionView
+
+	limit = cursorPageSize(limit)
+	scope, err := cursorScope("create_time", cursor, limit)
+	if err != nil {
+		return txs, "", err
+	}
+
+	query := assembleTxViewQuery(owner, symbol, status, typ)
+	if err = s.Db.Where(query).Scopes(scope).Find(&txs).Error; err != nil {
+		return txs, "", err
+	}
+
+	next := nextCursor(len(txs), limit, func(i int) (int64, int) {
+		return txs[i].CreateTime, txs[i].ID
+	})
+	if len(txs) > limit {
+		txs = txs[:limit]
+	}
+
+	return txs, next, nil
+}
+
+func (s *RdsService) GetTxViewByOwnerAfter(owner string, t int64, id int, end int64, limit int) ([]TransactionView, error) {
+	var txs []TransactionView
+
+	err := s.Db.Where("owner = ?", owner).Where("fork = ?", false).
+		Scopes(ascCursorScope("create_time", t, id, end, limit)).Find(&txs).Error
+
+	return txs, err
+}
+
+// 同一交易可能对应owner的多条记录(如convert), 返回每个交易在指定类型中最小的记录id
+func (s *RdsService) GetTxViewFirstIds(owner string, hashes []string, types []uint8) (map[string]int, error) {
+	var rows []struct {
+		TxHash string `gorm:"column:tx_hash"`
+		ID     int    `gorm:"column:id"`
+	}
+	ids := make(map[string]int)
+	if len(hashes) == 0 {
+		return ids, nil
+	}
+
+	err := s.Db.Model(&TransactionView{}).Select("tx_hash, min(id) as id").
+		Where("owner = ?", owner).Where("fork = ?", false).
+		Where("tx_hash in (?)", hashes).Where("tx_type in (?)", types).
+		Group("tx_hash").Scan(&rows).Error
+	for _, r := range rows {
+		ids[r.TxHash] = r.ID
+	}
+
+	return ids, err
+}
+
 func (s *RdsService) RollBackTxView(from, to int64) error {
 	return s.Db.Model(&TransactionView{}).Where("block_number > ? and block_number <= ?", from, to).Update("fork", true).Error
 }
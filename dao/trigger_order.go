@@ -0,0 +1,132 @@
+/*
+
+  Copyright 2017 Loopring Project Ltd (Loopring Foundation).
+
+  Licensed under the Apache License, Version 2.0 (the "License");
+  you may not use this file except in compliance with the License.
+  You may obtain a copy of the License at
+
+  http://www.apache.org/licenses/LICENSE-2.0
+
+  Unless required by applicable law or agreed to in writing, software
+  distributed under the License is distributed on an "AS IS" BASIS,
+  WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+  See the License for the specific language governing permissions and
+  limitations under the License.
+
+*/
+
+package dao
+
+import (
+	"encoding/json"
+	"github.com/Loopring/relay-lib/types"
+	"time"
+)
+
+const (
+	TRIGGER_TYPE_STOP_LOSS   = "stop_loss"
+	TRIGGER_TYPE_TAKE_PROFIT = "take_profit"
+
+	TRIGGER_STATUS_PENDING   = "pending"
+	TRIGGER_STATUS_TRIGGERED = "triggered"
+	TRIGGER_STATUS_CANCELLED = "cancelled"
+	TRIGGER_STATUS_FAILED    = "failed"
+)
+
+// 条件单, 订单在触发前只保存在该表中, 不进入订单簿
+type TriggerOrder struct {
+	ID              int     `gorm:"column:id;primary_key;" json:"id"`
+	OrderHash       string  `gorm:"column:order_hash;type:varchar(82);unique_index" json:"orderHash"`
+	Owner           string  `gorm:"column:owner;type:varchar(42)" json:"owner"`
+	DelegateAddress string  `gorm:"column:delegate_address;type:varchar(42)" json:"delegateAddress"`
+	Market          string  `gorm:"column:market;type:varchar(40)" json:"market"`
+	Side            string  `gorm:"column:side;type:varchar(40)" json:"side"`
+	TriggerType     string  `gorm:"column:trigger_type;type:varchar(20)" json:"triggerType"`
+	TriggerPrice    float64 `gorm:"column:trigger_price;type:decimal(28,16);" json:"triggerPrice"`
+	PriceSource     string  `gorm:"column:price_source;type:varchar(20)" json:"priceSource"`
+	RawOrder        string  `gorm:"column:raw_order;type:text" json:"-"`
+	Status          string  `gorm:"column:status;type:varchar(20)" json:"status"`
+	ErrMsg          string  `gorm:"column:err_msg;type:varchar(255)" json:"errMsg"`
+	CreateTime      int64   `gorm:"column:create_time;type:bigint" json:"createTime"`
+	UpdateTime      int64   `gorm:"column:update_time;type:bigint" json:"updateTime"`
+}
+
+func (t *TriggerOrder) ConvertDown(order *types.Order) error {
+	data, err := json.Marshal(order)
+	if err != nil {
+		return err
+	}
+
+	t.OrderHash = order.Hash.Hex()
+	t.Owner = order.Owner.Hex()
+	t.DelegateAddress = order.DelegateAddress.Hex()
+	t.Market = order.Market
+	t.Side = order.Side
+	t.RawOrder = string(data)
+	return nil
+}
+
+func (t *TriggerOrder) ConvertUp(order *types.Order) error {
+	return json.Unmarshal([]byte(t.RawOrder), order)
+}
+
+func (s *RdsService) GetTriggerOrderByHash(orderHash string) (TriggerOrder, error) {
+	var order TriggerOrder
+	err := s.Db.Where("order_hash = ?", orderHash).First(&order).Error
+	return order, err
+}
+
+func (s *RdsService) GetPendingTriggerOrders() ([]TriggerOrder, error) {
+	var (
+		list []TriggerOrder
+		err  error
+	)
+
+	err = s.Db.Where("status = ?", TRIGGER_STATUS_PENDING).Order("create_time ASC").Find(&list).Error
+	return list, err
+}
+
+func (s *RdsService) TriggerOrderPageQuery(query map[string]interface{}, pageIndex, pageSize int) (PageResult, error) {
+	var (
+		orders     []TriggerOrder
+		err        error
+		data       = make([]interface{}, 0)
+		pageResult PageResult
+	)
+
+	if pageIndex <= 0 {
+		pageIndex = 1
+	}
+
+	if pageSize <= 0 {
+		pageSize = 20
+	}
+
+	pageResult = PageResult{Data: data, PageIndex: pageIndex, PageSize: pageSize}
+
+	if err = s.Db.Where(query).Offset((pageIndex - 1) * pageSize).Order("create_time DESC").Limit(pageSize).Find(&orders).Error; err != nil {
+		return pageResult, err
+	}
+
+	if err = s.Db.Model(&TriggerOrder{}).Where(query).Count(&pageResult.Total).Error; err != nil {
+		return pageResult, err
+	}
+
+	for _, v := range orders {
+		data = append(data, v)
+	}
+	pageResult.Data = data
+
+	return pageResult, err
+}
+
+// 仅在当前状态为fromStatus时更新, 返回值为0说明状态已被其他节点修改
+func (s *RdsService) UpdateTriggerOrderStatus(orderHash, fromStatus, toStatus, errMsg string) int64 {
+	items := map[string]interface{}{
+		"status":      toStatus,
+		"err_msg":     errMsg,
+		"update_time": time.Now().Unix(),
+	}
+	return s.Db.Model(&TriggerOrder{}).Where("order_hash = ? and status = ?", orderHash, fromStatus).Updates(items).RowsAffected
+}
@@ -0,0 +1,42 @@
+/*
+
+  Copyright 2017 Loopring Project Ltd (Loopring Foundation).
+
+  Licensed under the Apache License, Version 2.0 (the "License");
+  you may not use this file except in compliance with the License.
+  You may obtain a copy of the License at
+
+  http://www.apache.org/licenses/LICENSE-2.0
+
+  Unless required by applicable law or agreed to in writing, software
+  distributed under the License is distributed on an "AS IS" BASIS,
+  WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+  See the License for the specific language governing permissions and
+  limitations under the License.
+
+*/
+
+package gateway
+
+import (
+	"errors"
+	omcache "github.com/Loopring/relay-cluster/ordermanager/cache"
+	"github.com/Loopring/relay-cluster/ordermanager/manager"
+	"github.com/ethereum/go-ethereum/common"
+)
+
+// 未成交订单需要的授权(含lrcFee)超过delegate授权额度的部分, 没有缺口时返回空数组
+func (w *WalletServiceImpl) GetAllowanceShortfalls(query SingleOwner) (res []*omcache.AllowanceShortfall, err error) {
+	if !common.IsHexAddress(query.Owner) {
+		return res, errors.New("owner isn't a valid hex-address")
+	}
+
+	res, err = manager.GetAllowanceShortfalls(common.HexToAddress(query.Owner))
+	if err != nil {
+		return res, err
+	}
+	if res == nil {
+		res = make([]*omcache.AllowanceShortfall, 0)
+	}
+	return res, nil
+}
@@ -0,0 +1,84 @@
+/*
+
+  Copyright 2017 Loopring Project Ltd (Loopring Foundation).
+
+  Licensed under the Apache License, Version 2.0 (the "License");
+  you may not use this file except in compliance with the License.
+  You may obtain a copy of the License at
+
+  http://www.apache.org/licenses/LICENSE-2.0
+
+  Unless required by applicable law or agreed to in writing, software
+  distributed under the License is distributed on an "AS IS" BASIS,
+  WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+  See the License for the specific language governing permissions and
+  limitations under the License.
+
+*/
+
+package gateway
+
+import (
+	"errors"
+	"github.com/Loopring/relay-cluster/accountmanager"
+	"github.com/Loopring/relay-lib/eth/loopringaccessor"
+	util "github.com/Loopring/relay-lib/marketutil"
+	"github.com/Loopring/relay-lib/types"
+	"github.com/ethereum/go-ethereum/common"
+	"strings"
+	"time"
+)
+
+const maxBalanceHistoryLimit = 1000
+
+type BalanceHistoryQuery struct {
+	Owner           string `json:"owner"`
+	Token           string `json:"token"`
+	DelegateAddress string `json:"delegateAddress"`
+	Start           int64  `json:"start"`
+	End             int64  `json:"end"`
+	Limit           int    `json:"limit"`
+}
+
+func (w *WalletServiceImpl) GetBalanceHistory(query BalanceHistoryQuery) (res []accountmanager.BalanceHistoryPoint, err error) {
+	if !common.IsHexAddress(query.Owner) {
+		return nil, errors.New("owner isn't a valid hex-address")
+	}
+	token, err := balanceHistoryToken(query.Token)
+	if nil != err {
+		return nil, err
+	}
+	spender := ""
+	if query.DelegateAddress != "" {
+		if !common.IsHexAddress(query.DelegateAddress) || !loopringaccessor.SupportedDelegateAddress(common.HexToAddress(query.DelegateAddress)) {
+			return nil, errors.New("unsupported delegate address:" + query.DelegateAddress)
+		}
+		spender = common.HexToAddress(query.DelegateAddress).Hex()
+	}
+	if query.End <= 0 {
+		query.End = time.Now().Unix()
+	}
+	if query.Start < 0 || query.Start > query.End {
+		return nil, errors.New("start can't be bigger than end")
+	}
+	if query.Limit <= 0 || query.Limit > maxBalanceHistoryLimit {
+		query.Limit = maxBalanceHistoryLimit
+	}
+
+	return accountmanager.BuildBalanceHistory(w.rds, common.HexToAddress(query.Owner), token, spender, query.Start, query.End, query.Limit)
+}
+
+// ETH余额记录在零地址下
+func balanceHistoryToken(token string) (common.Address, error) {
+	if strings.ToUpper(token) == "ETH" {
+		return types.NilAddress, nil
+	}
+	if common.IsHexAddress(token) {
+		return common.HexToAddress(token), nil
+	}
+	addr := util.AliasToAddress(strings.ToUpper(token))
+	if types.IsZeroAddress(addr) {
+		return addr, errors.New("unsupported token:" + token)
+	}
+	return addr, nil
+}
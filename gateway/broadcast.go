@@ -26,6 +26,11 @@ import (
 	"github.com/Loopring/relay-lib/types"
 )
 
+// 撤单与订单共用广播通道, 通过flexCancel字段区分
+type broadcastFlexCancel struct {
+	FlexCancel *CancelOrderQuery `json:"flexCancel"`
+}
+
 //to broadcast
 func listenOrderForBroadcast() error {
 	gatewayOrderWatcher := &eventemitter.Watcher{Concurrent: true, Handle: handleGatewayOrder}
@@ -43,6 +48,11 @@ func listenOrderFromBroacast() error {
 				select {
 				case dataI := <-orderChan:
 					if data, ok := dataI.([]byte); ok {
+						cancel := &broadcastFlexCancel{}
+						if err := json.Unmarshal(data, cancel); nil == err && nil != cancel.FlexCancel {
+							handleFlexCancelFromBroadcast(cancel.FlexCancel)
+							continue
+						}
 						order := &types.Order{}
 						if err := json.Unmarshal(data, order); nil != err {
 							log.Errorf("err:%s", err.Error())
@@ -74,3 +84,25 @@ func handleGatewayOrder(input eventemitter.EventData) error {
 	}
 	return nil
 }
+
+func pubFlexCancel(req *CancelOrderQuery) error {
+	data, err := json.Marshal(broadcastFlexCancel{FlexCancel: req})
+	if nil != err {
+		log.Errorf("err:%s", err.Error())
+		return err
+	}
+	if err1 := broadcast.PubOrder(req.SignHash().Hex(), data); nil != err1 {
+		log.Errorf("err:%s", err1.Error())
+		return err1
+	}
+	return nil
+}
+
+func handleFlexCancelFromBroadcast(req *CancelOrderQuery) {
+	log.Debugf("received flex cancel, owner:%s, type:%d, nonce:%d", req.Sign.Owner, req.Type, req.Nonce)
+	if nums, err := applyFlexCancel(req); nil != err {
+		log.Errorf("err:%s", err.Error())
+	} else {
+		log.Debugf("flex cancel from broadcast, owner:%s, affected orders:%d", req.Sign.Owner, nums)
+	}
+}
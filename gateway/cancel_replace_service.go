@@ -0,0 +1,215 @@
+/*
+
+  Copyright 2017 Loopring Project Ltd (Loopring Foundation).
+
+  Licensed under the Apache License, Version 2.0 (the "License");
+  you may not use this file except in compliance with the License.
+  You may obtain a copy of the License at
+
+  http://www.apache.org/licenses/LICENSE-2.0
+
+  Unless required by applicable law or agreed to in writing, software
+  distributed under the License is distributed on an "AS IS" BASIS,
+  WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+  See the License for the specific language governing permissions and
+  limitations under the License.
+
+*/
+
+package gateway
+
+import (
+	"errors"
+	"fmt"
+	"github.com/Loopring/relay-cluster/dao"
+	omcm "github.com/Loopring/relay-cluster/ordermanager/common"
+	"github.com/Loopring/relay-cluster/ordermanager/manager"
+	"github.com/Loopring/relay-lib/log"
+	util "github.com/Loopring/relay-lib/marketutil"
+	"github.com/Loopring/relay-lib/types"
+	"github.com/ethereum/go-ethereum/common"
+)
+
+type CancelReplaceRequest struct {
+	Cancel CancelOrderQuery       `json:"cancel"`
+	Order  types.OrderJsonRequest `json:"order"`
+}
+
+type MarketQuote struct {
+	Cancel CancelOrderQuery         `json:"cancel"`
+	Orders []types.OrderJsonRequest `json:"orders"`
+}
+
+type MassQuoteRequest struct {
+	Quotes []MarketQuote `json:"quotes"`
+}
+
+type QuoteResult struct {
+	OrderHash string `json:"orderHash"`
+	Accepted  bool   `json:"accepted"`
+	Error     string `json:"error,omitempty"`
+}
+
+type ReplaceResult struct {
+	Market          string        `json:"market"`
+	Replaced        bool          `json:"replaced"`
+	CancelledOrders int64         `json:"cancelledOrders"`
+	Error           string        `json:"error,omitempty"`
+	Orders          []QuoteResult `json:"orders"`
+}
+
+// 软撤单(按hash)与新订单作为一次操作, 新订单未被接受时原订单保持不变
+func (w *WalletServiceImpl) CancelReplace(req CancelReplaceRequest) (res ReplaceResult, err error) {
+	if types.FlexCancelType(req.Cancel.Type) != types.FLEX_CANCEL_BY_HASH {
+		return res, errors.New("cancel replace only supports cancel by hash")
+	}
+	return replaceOrders(&gatewayOrderReplacer{}, &redisFlexCancelNonceStore{}, &req.Cancel, []types.OrderJsonRequest{req.Order})
+}
+
+// 每个market使用一个按market撤单的签名, 各market之间互不影响
+func (w *WalletServiceImpl) MassQuote(req MassQuoteRequest) (res []ReplaceResult, err error) {
+	if len(req.Quotes) == 0 {
+		return res, errors.New("quotes can't be null")
+	}
+
+	for i := range req.Quotes {
+		quote := &req.Quotes[i]
+		if types.FlexCancelType(quote.Cancel.Type) != types.FLEX_CANCEL_BY_MARKET {
+			res = append(res, ReplaceResult{Error: "mass quote only supports cancel by market"})
+			continue
+		}
+		result, err := replaceOrders(&gatewayOrderReplacer{}, &redisFlexCancelNonceStore{}, &quote.Cancel, quote.Orders)
+		if err != nil {
+			result.Error = err.Error()
+		}
+		res = append(res, result)
+	}
+	return res, nil
+}
+
+// 替换订单依赖的订单簿操作, 生产环境为本relay的gateway和ordermanager
+type orderReplacer interface {
+	// 撤单范围内的原订单及其market, 按hash撤单时原订单的side为空
+	Originals(cancel *CancelOrderQuery, owner common.Address) (string, []dao.Order, error)
+	Validate(order *types.Order) error
+	Submit(order *types.Order) error
+	Cancel(owner common.Address, orderHash string) (int64, error)
+	// 通知撤单范围内被撤销的订单
+	Notify(cancel *CancelOrderQuery)
+}
+
+// 先校验撤单签名和全部新订单, 全部通过后才接受新订单并撤销快照中的原订单,
+// 因此原订单和新订单之间不存在都不在订单簿中的时间窗口
+// 按hash撤单时新订单被接受即撤销原订单, 按market撤单时只撤销有同方向新订单被接受的原订单
+// 没有新订单被接受时nonce不被占用, 可在签名有效期内重试
+func replaceOrders(replacer orderReplacer, nonces flexCancelNonceStore, cancel *CancelOrderQuery, reqs []types.OrderJsonRequest) (res ReplaceResult, err error) {
+	res.Orders = make([]QuoteResult, 0)
+	if len(reqs) == 0 {
+		return res, errors.New("replacement orders can't be null")
+	}
+	if err = verifyFlexCancelSign(cancel); err != nil {
+		return res, err
+	}
+	owner := common.HexToAddress(cancel.Sign.Owner)
+
+	market, originals, err := replacer.Originals(cancel, owner)
+	if err != nil {
+		return res, err
+	}
+	res.Market = market
+
+	var orders []*types.Order
+	for i := range reqs {
+		order := types.ToOrder(&reqs[i])
+		result := QuoteResult{OrderHash: order.GenerateHash().Hex()}
+		if order.Owner != owner {
+			err = errors.New("order owner not matched with cancel owner")
+		} else if err = replacer.Validate(order); err == nil && res.Market != "" && order.Market != res.Market {
+			err = fmt.Errorf("order market %s not matched with %s", order.Market, res.Market)
+		}
+		if err != nil {
+			result.Error = err.Error()
+		}
+		res.Orders = append(res.Orders, result)
+		orders = append(orders, order)
+	}
+	for _, v := range res.Orders {
+		if v.Error != "" {
+			return res, errors.New("replacement orders not accepted, original orders kept")
+		}
+	}
+
+	err = useFlexCancelNonce(nonces, cancel.Sign.Owner, cancel.Nonce, func() error {
+		acceptedSides := make(map[string]bool)
+		for i, order := range orders {
+			if err := replacer.Submit(order); err != nil {
+				res.Orders[i].Error = err.Error()
+				continue
+			}
+			res.Orders[i].Accepted = true
+			acceptedSides[order.Side] = true
+		}
+		if len(acceptedSides) == 0 {
+			return errors.New("replacement orders not accepted, original orders kept")
+		}
+
+		for _, v := range originals {
+			if v.Side != "" && !acceptedSides[v.Side] {
+				continue
+			}
+			nums, err := replacer.Cancel(owner, v.OrderHash)
+			if err != nil {
+				log.Errorf("replace orders, flex cancel order:%s error:%s", v.OrderHash, err.Error())
+				continue
+			}
+			res.CancelledOrders += nums
+		}
+		return nil
+	})
+	if err != nil {
+		return res, err
+	}
+	res.Replaced = true
+
+	if res.CancelledOrders > 0 {
+		go replacer.Notify(cancel)
+	}
+	// 按market撤单广播后会撤销其他relay已收到的新订单, 只广播按hash撤单
+	if gateway.isBroadcast && types.FlexCancelType(cancel.Type) == types.FLEX_CANCEL_BY_HASH {
+		go pubFlexCancel(cancel)
+	}
+	return res, nil
+}
+
+type gatewayOrderReplacer struct{}
+
+func (r *gatewayOrderReplacer) Originals(cancel *CancelOrderQuery, owner common.Address) (string, []dao.Order, error) {
+	if types.FlexCancelType(cancel.Type) == types.FLEX_CANCEL_BY_HASH {
+		return flexCancelMarket(cancel), []dao.Order{{OrderHash: common.HexToHash(cancel.OrderHash).Hex()}}, nil
+	}
+
+	market, err := util.WrapMarketByAddress(cancel.TokenS, cancel.TokenB)
+	if err != nil {
+		return market, nil, err
+	}
+	list, err := gateway.rds.GetFlexCancellableOrders(owner, market, omcm.ValidFlexCancelStatus)
+	return market, list, err
+}
+
+func (r *gatewayOrderReplacer) Validate(order *types.Order) error {
+	return validateInputOrder(order)
+}
+
+func (r *gatewayOrderReplacer) Submit(order *types.Order) error {
+	_, err := HandleInputOrder(order)
+	return err
+}
+
+func (r *gatewayOrderReplacer) Cancel(owner common.Address, orderHash string) (int64, error) {
+	event := &types.FlexCancelOrderEvent{Owner: owner, OrderHash: common.HexToHash(orderHash), Type: types.FLEX_CANCEL_BY_HASH}
+	return manager.FlexCancelOrder(event)
+}
+
+func (r *gatewayOrderReplacer) Notify(cancel *CancelOrderQuery) {
+	notifyFlexCancelled(cancel)
+}
@@ -0,0 +1,199 @@
+/*
+
+  Copyright 2017 Loopring Project Ltd (Loopring Foundation).
+
+  Licensed under the Apache License, Version 2.0 (the "License");
+  you may not use this file except in compliance with the License.
+  You may obtain a copy of the License at
+
+  http://www.apache.org/licenses/LICENSE-2.0
+
+  Unless required by applicable law or agreed to in writing, software
+  distributed under the License is distributed on an "AS IS" BASIS,
+  WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+  See the License for the specific language governing permissions and
+  limitations under the License.
+
+*/
+
+package gateway
+
+import (
+	"errors"
+	"github.com/Loopring/relay-cluster/dao"
+	"github.com/Loopring/relay-lib/crypto"
+	"github.com/Loopring/relay-lib/marketutil"
+	"github.com/Loopring/relay-lib/types"
+	"github.com/ethereum/go-ethereum/common"
+	"math/big"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+)
+
+var (
+	replaceTestLrc  = common.HexToAddress("0xef68e7c694f40c8202821edf525de3782458639f")
+	replaceTestWeth = common.HexToAddress("0xc02aaa39b223fe8d0a0e5c4f27ead9083c756cc2")
+)
+
+type memOrderReplacer struct {
+	originals []dao.Order
+	rejected  map[string]bool
+	submitted []string
+	cancelled []string
+}
+
+func (m *memOrderReplacer) Originals(cancel *CancelOrderQuery, owner common.Address) (string, []dao.Order, error) {
+	return "LRC-WETH", m.originals, nil
+}
+
+// 卖LRC为sell, 买LRC为buy
+func (m *memOrderReplacer) Validate(order *types.Order) error {
+	order.Hash = order.GenerateHash()
+	order.Market = "LRC-WETH"
+	order.Side = marketutil.SideBuy
+	if order.TokenS == replaceTestLrc {
+		order.Side = marketutil.SideSell
+	}
+	return nil
+}
+
+func (m *memOrderReplacer) Submit(order *types.Order) error {
+	if m.rejected[order.Hash.Hex()] {
+		return errors.New("order rejected")
+	}
+	m.submitted = append(m.submitted, order.Hash.Hex())
+	return nil
+}
+
+func (m *memOrderReplacer) Cancel(owner common.Address, orderHash string) (int64, error) {
+	m.cancelled = append(m.cancelled, orderHash)
+	return 1, nil
+}
+
+func (m *memOrderReplacer) Notify(cancel *CancelOrderQuery) {}
+
+func newReplaceTestStore(originals []dao.Order) (*memOrderReplacer, *memFlexCancelNonceStore) {
+	return &memOrderReplacer{originals: originals, rejected: make(map[string]bool)}, newMemFlexCancelNonceStore()
+}
+
+func newReplaceTestCancel(t *testing.T, signer crypto.EthPrivateKeyCrypto, cancelType types.FlexCancelType, nonce int64) *CancelOrderQuery {
+	cancel := &CancelOrderQuery{
+		Type:   uint8(cancelType),
+		TokenS: replaceTestLrc.Hex(),
+		TokenB: replaceTestWeth.Hex(),
+		Nonce:  nonce,
+		Sign:   SignInfo{Owner: signer.Address().Hex(), Timestamp: strconv.FormatInt(time.Now().Unix(), 10)},
+	}
+	if cancelType == types.FLEX_CANCEL_BY_HASH {
+		cancel.OrderHash = common.HexToHash("0x01").Hex()
+	}
+	signTestHash(t, signer, &cancel.Sign, cancel.SignHash())
+	return cancel
+}
+
+func newReplaceTestOrder(owner common.Address, sell bool, amount int64) types.OrderJsonRequest {
+	req := types.OrderJsonRequest{
+		TokenS:     replaceTestWeth,
+		TokenB:     replaceTestLrc,
+		AmountS:    big.NewInt(1),
+		AmountB:    big.NewInt(amount),
+		ValidSince: big.NewInt(0),
+		ValidUntil: big.NewInt(0),
+		LrcFee:     big.NewInt(0),
+		Owner:      owner,
+	}
+	if sell {
+		req.TokenS, req.TokenB = replaceTestLrc, replaceTestWeth
+		req.AmountS, req.AmountB = big.NewInt(amount), big.NewInt(1)
+	}
+	return req
+}
+
+func TestReplaceOrders_ByHash(t *testing.T) {
+	signer := newTestSigner(t)
+	mem, store := newReplaceTestStore([]dao.Order{{OrderHash: common.HexToHash("0x01").Hex()}})
+
+	res, err := replaceOrders(mem, store, newReplaceTestCancel(t, signer, types.FLEX_CANCEL_BY_HASH, 1), []types.OrderJsonRequest{newReplaceTestOrder(signer.Address(), false, 100)})
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	if !res.Replaced || res.CancelledOrders != 1 || len(mem.submitted) != 1 || !res.Orders[0].Accepted {
+		t.Fatalf("original order should be replaced, got %+v", res)
+	}
+	if last, _ := store.Last(strings.ToLower(signer.Address().Hex())); last != 1 {
+		t.Fatalf("nonce should be used after replaced, last:%d", last)
+	}
+}
+
+// 新订单未被接受时原订单保持不变, nonce可重试
+func TestReplaceOrders_NotAcceptedKeepNonce(t *testing.T) {
+	signer := newTestSigner(t)
+	mem, store := newReplaceTestStore([]dao.Order{{OrderHash: common.HexToHash("0x01").Hex()}})
+
+	order := newReplaceTestOrder(signer.Address(), false, 100)
+	mem.rejected[types.ToOrder(&order).GenerateHash().Hex()] = true
+	cancel := newReplaceTestCancel(t, signer, types.FLEX_CANCEL_BY_HASH, 2)
+	res, err := replaceOrders(mem, store, cancel, []types.OrderJsonRequest{order})
+	if err == nil || res.Replaced || len(mem.cancelled) != 0 {
+		t.Fatalf("original order should be kept when replacement is rejected, got %+v", res)
+	}
+	if res.Orders[0].Accepted || res.Orders[0].Error == "" {
+		t.Fatalf("rejected order result should have error, got %+v", res.Orders[0])
+	}
+	if last, _ := store.Last(strings.ToLower(signer.Address().Hex())); last != 0 {
+		t.Fatalf("nonce should not be used, last:%d", last)
+	}
+
+	// 同一签名重试
+	mem.rejected = make(map[string]bool)
+	if res, err = replaceOrders(mem, store, cancel, []types.OrderJsonRequest{order}); err != nil || !res.Replaced {
+		t.Fatalf("retry with the same nonce should succeed, err:%v", err)
+	}
+}
+
+// 其他owner的订单在接受前被拒绝, 不提交也不占用nonce
+func TestReplaceOrders_InvalidOrder(t *testing.T) {
+	signer := newTestSigner(t)
+	mem, store := newReplaceTestStore([]dao.Order{{OrderHash: common.HexToHash("0x01").Hex()}})
+
+	orders := []types.OrderJsonRequest{
+		newReplaceTestOrder(signer.Address(), false, 100),
+		newReplaceTestOrder(common.HexToAddress("0x71C079107B5af8619D54537A93dbF16e5aab4900"), true, 100),
+	}
+	res, err := replaceOrders(mem, store, newReplaceTestCancel(t, signer, types.FLEX_CANCEL_BY_MARKET, 3), orders)
+	if err == nil || res.Replaced || len(mem.submitted) != 0 || len(mem.cancelled) != 0 {
+		t.Fatalf("no order should be submitted when one replacement is invalid, got %+v", res)
+	}
+	if last, _ := store.Last(strings.ToLower(signer.Address().Hex())); last != 0 {
+		t.Fatalf("nonce should not be used, last:%d", last)
+	}
+}
+
+// 只撤销有同方向新订单被接受的原订单
+func TestReplaceOrders_PartialAccepted(t *testing.T) {
+	signer := newTestSigner(t)
+	originals := []dao.Order{
+		{OrderHash: common.HexToHash("0x01").Hex(), Side: marketutil.SideBuy},
+		{OrderHash: common.HexToHash("0x02").Hex(), Side: marketutil.SideSell},
+		{OrderHash: common.HexToHash("0x03").Hex(), Side: marketutil.SideBuy},
+	}
+	mem, store := newReplaceTestStore(originals)
+
+	buy, sell := newReplaceTestOrder(signer.Address(), false, 100), newReplaceTestOrder(signer.Address(), true, 120)
+	mem.rejected[types.ToOrder(&sell).GenerateHash().Hex()] = true
+	res, err := replaceOrders(mem, store, newReplaceTestCancel(t, signer, types.FLEX_CANCEL_BY_MARKET, 4), []types.OrderJsonRequest{buy, sell})
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	if !res.Replaced || !res.Orders[0].Accepted || res.Orders[1].Accepted {
+		t.Fatalf("only buy order should be accepted, got %+v", res)
+	}
+	if res.CancelledOrders != 2 || len(mem.cancelled) != 2 || mem.cancelled[0] != originals[0].OrderHash || mem.cancelled[1] != originals[2].OrderHash {
+		t.Fatalf("only buy originals should be cancelled, got %v", mem.cancelled)
+	}
+	if last, _ := store.Last(strings.ToLower(signer.Address().Hex())); last != 4 {
+		t.Fatalf("nonce should be used after replaced, last:%d", last)
+	}
+}
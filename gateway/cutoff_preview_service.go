@@ -0,0 +1,157 @@
+/*
+
+  Copyright 2017 Loopring Project Ltd (Loopring Foundation).
+
+  Licensed under the Apache License, Version 2.0 (the "License");
+  you may not use this file except in compliance with the License.
+  You may obtain a copy of the License at
+
+  http://www.apache.org/licenses/LICENSE-2.0
+
+  Unless required by applicable law or agreed to in writing, software
+  distributed under the License is distributed on an "AS IS" BASIS,
+  WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+  See the License for the specific language governing permissions and
+  limitations under the License.
+
+*/
+
+package gateway
+
+import (
+	"errors"
+	"github.com/Loopring/relay-cluster/dao"
+	omcm "github.com/Loopring/relay-cluster/ordermanager/common"
+	util "github.com/Loopring/relay-lib/marketutil"
+	"github.com/Loopring/relay-lib/types"
+	"github.com/ethereum/go-ethereum/common"
+	"math/big"
+)
+
+type CutoffPreviewQuery struct {
+	Owner           string `json:"owner"`
+	DelegateAddress string `json:"delegateAddress"`
+	Token1          string `json:"token1"`
+	Token2          string `json:"token2"`
+	Cutoff          int64  `json:"cutoff"`
+}
+
+type CutoffPreviewOrder struct {
+	OrderHash       string `json:"orderHash"`
+	Market          string `json:"market"`
+	Side            string `json:"side"`
+	TokenS          string `json:"tokenS"`
+	Status          string `json:"status"`
+	ValidSince      int64  `json:"validSince"`
+	RemainedAmountS string `json:"remainedAmountS"`
+	LrcFee          string `json:"lrcFee"`
+}
+
+type CutoffPreviewResult struct {
+	Owner         string               `json:"owner"`
+	Cutoff        int64                `json:"cutoff"`
+	LastCutoff    int64                `json:"lastCutoff"`
+	Effective     bool                 `json:"effective"`
+	Orders        []CutoffPreviewOrder `json:"orders"`
+	FrozenAmounts map[string]string    `json:"frozenAmounts"`
+	FrozenLrcFee  string               `json:"frozenLrcFee"`
+}
+
+// 与HandleCutoffEvent/HandleCutoffPair使用相同的查询条件和生效判断, 只读不修改订单状态
+// cutoff按delegate记录, 必须指定delegateAddress, 只包含该delegate的订单
+func (w *WalletServiceImpl) GetCutoffPreview(query CutoffPreviewQuery) (res CutoffPreviewResult, err error) {
+	if !common.IsHexAddress(query.Owner) {
+		return res, errors.New("owner isn't a valid hex-address")
+	}
+	if query.Cutoff <= 0 {
+		return res, errors.New("cutoff must be bigger than zero")
+	}
+	if !common.IsHexAddress(query.DelegateAddress) {
+		return res, errors.New("delegate address must be supplied")
+	}
+	if (query.Token1 == "") != (query.Token2 == "") {
+		return res, errors.New("token1 and token2 must be applied together")
+	}
+
+	owner := common.HexToAddress(query.Owner)
+	delegate := common.HexToAddress(query.DelegateAddress)
+	cutoff := big.NewInt(query.Cutoff)
+
+	var orders []dao.Order
+	if query.Token1 == "" {
+		orders, err = w.rds.GetCutoffOrders(owner, cutoff, omcm.ValidCutoffStatus)
+		res.LastCutoff = w.rds.GetCutoffIndex(delegate, owner)
+	} else {
+		token1, token2 := cutoffPreviewToken(query.Token1), cutoffPreviewToken(query.Token2)
+		orders, err = w.rds.GetCutoffPairOrders(owner, token1, token2, cutoff, omcm.ValidCutoffStatus)
+		res.LastCutoff = w.rds.GetCutoffPairIndex(delegate, owner, token1, token2)
+	}
+	if err != nil {
+		return res, err
+	}
+
+	return buildCutoffPreview(owner, delegate, query.Cutoff, res.LastCutoff, orders), nil
+}
+
+func buildCutoffPreview(owner, delegate common.Address, cutoff, lastCutoff int64, orders []dao.Order) (res CutoffPreviewResult) {
+	res.Owner = owner.Hex()
+	res.Cutoff = cutoff
+	res.LastCutoff = lastCutoff
+	res.Effective = omcm.IsCutoffApplicable(lastCutoff, cutoff)
+	res.Orders = make([]CutoffPreviewOrder, 0)
+	res.FrozenAmounts = make(map[string]string)
+
+	frozen := make(map[string]*big.Int)
+	lrcFee := big.NewInt(0)
+	for _, v := range orders {
+		if common.HexToAddress(v.DelegateAddress) != delegate {
+			continue
+		}
+		if v.ValidSince >= cutoff {
+			continue
+		}
+
+		var state types.OrderState
+		if err := v.ConvertUp(&state); err != nil {
+			continue
+		}
+
+		remained, _ := state.RemainedAmount()
+		remainedS := new(big.Int).Div(remained.Num(), remained.Denom())
+		symbol := util.AddressToAlias(state.RawOrder.TokenS.Hex())
+		if _, ok := frozen[symbol]; !ok {
+			frozen[symbol] = big.NewInt(0)
+		}
+		frozen[symbol].Add(frozen[symbol], remainedS)
+
+		fee := big.NewInt(0)
+		if state.RawOrder.LrcFee != nil {
+			fee = state.RawOrder.LrcFee
+		}
+		lrcFee.Add(lrcFee, fee)
+
+		res.Orders = append(res.Orders, CutoffPreviewOrder{
+			OrderHash:       state.RawOrder.Hash.Hex(),
+			Market:          state.RawOrder.Market,
+			Side:            state.RawOrder.Side,
+			TokenS:          symbol,
+			Status:          getStringStatus(state),
+			ValidSince:      state.RawOrder.ValidSince.Int64(),
+			RemainedAmountS: types.BigintToHex(remainedS),
+			LrcFee:          types.BigintToHex(fee),
+		})
+	}
+
+	for k, v := range frozen {
+		res.FrozenAmounts[k] = types.BigintToHex(v)
+	}
+	res.FrozenLrcFee = types.BigintToHex(lrcFee)
+	return res
+}
+
+func cutoffPreviewToken(token string) common.Address {
+	if util.IsAddress(token) {
+		return common.HexToAddress(token)
+	}
+	return util.AliasToAddress(token)
+}
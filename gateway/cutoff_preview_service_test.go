@@ -0,0 +1,107 @@
+/*
+
+  Copyright 2017 Loopring Project Ltd (Loopring Foundation).
+
+  Licensed under the Apache License, Version 2.0 (the "License");
+  you may not use this file except in compliance with the License.
+  You may obtain a copy of the License at
+
+  http://www.apache.org/licenses/LICENSE-2.0
+
+  Unless required by applicable law or agreed to in writing, software
+  distributed under the License is distributed on an "AS IS" BASIS,
+  WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+  See the License for the specific language governing permissions and
+  limitations under the License.
+
+*/
+
+package gateway
+
+import (
+	"github.com/Loopring/relay-cluster/dao"
+	"github.com/Loopring/relay-lib/types"
+	"github.com/ethereum/go-ethereum/common"
+	"math/big"
+	"testing"
+)
+
+var (
+	cutoffTestOwner     = common.HexToAddress("0x71C079107B5af8619D54537A93dbF16e5aab4900")
+	cutoffTestDelegate1 = common.HexToAddress("0x17233e07c67d086464fD408148c3ABB56245FA64")
+	cutoffTestDelegate2 = common.HexToAddress("0x5567ee920f7E62274284985D793344351A00142B")
+)
+
+func newCutoffTestOrder(delegate common.Address, validSince int64) dao.Order {
+	order := types.Order{
+		DelegateAddress: delegate,
+		Owner:           cutoffTestOwner,
+		TokenS:          common.HexToAddress("0xEF68e7C694F40c8202821eDF525dE3782458639f"),
+		TokenB:          common.HexToAddress("0xC02aaA39b223FE8D0A0e5C4F27eAD9083C756Cc2"),
+		AmountS:         big.NewInt(1000),
+		AmountB:         big.NewInt(1),
+		ValidSince:      big.NewInt(validSince),
+		ValidUntil:      big.NewInt(0),
+		LrcFee:          big.NewInt(5),
+	}
+
+	return dao.Order{
+		DelegateAddress:  delegate.Hex(),
+		Owner:            cutoffTestOwner.Hex(),
+		TokenS:           order.TokenS.Hex(),
+		TokenB:           order.TokenB.Hex(),
+		AmountS:          "1000",
+		AmountB:          "1",
+		DealtAmountS:     "0",
+		DealtAmountB:     "0",
+		SplitAmountS:     "0",
+		SplitAmountB:     "0",
+		CancelledAmountS: "0",
+		CancelledAmountB: "0",
+		LrcFee:           "5",
+		ValidSince:       validSince,
+		OrderHash:        order.GenerateHash().Hex(),
+		OrderType:        types.ORDER_TYPE_MARKET,
+		Status:           uint8(types.ORDER_NEW),
+	}
+}
+
+func TestBuildCutoffPreview(t *testing.T) {
+	orders := []dao.Order{
+		newCutoffTestOrder(cutoffTestDelegate1, 100),
+		newCutoffTestOrder(cutoffTestDelegate2, 150),
+		newCutoffTestOrder(cutoffTestDelegate1, 200),
+	}
+
+	// validSince等于cutoff的订单不会被cutoff
+	res := buildCutoffPreview(cutoffTestOwner, cutoffTestDelegate1, 200, 0, orders)
+	if len(res.Orders) != 1 || res.Orders[0].ValidSince != 100 {
+		t.Fatalf("only orders with validSince before cutoff should be previewed, got %+v", res.Orders)
+	}
+
+	res = buildCutoffPreview(cutoffTestOwner, cutoffTestDelegate1, 201, 0, orders)
+	if len(res.Orders) != 2 || res.Orders[0].ValidSince != 100 || res.Orders[1].ValidSince != 200 {
+		t.Fatalf("only orders of the delegate should be previewed, got %+v", res.Orders)
+	}
+	if res.FrozenLrcFee != types.BigintToHex(big.NewInt(10)) {
+		t.Fatalf("frozen lrc fee should be summed, got %s", res.FrozenLrcFee)
+	}
+}
+
+// 与HandleCutoffEvent一致, cutoff等于当前cutoff时同样生效
+func TestBuildCutoffPreview_Effective(t *testing.T) {
+	for _, c := range []struct {
+		cutoff, lastCutoff int64
+		effective          bool
+	}{
+		{200, 0, true},
+		{200, 199, true},
+		{200, 200, true},
+		{200, 300, false},
+	} {
+		res := buildCutoffPreview(cutoffTestOwner, cutoffTestDelegate1, c.cutoff, c.lastCutoff, nil)
+		if res.Effective != c.effective || res.LastCutoff != c.lastCutoff {
+			t.Fatalf("cutoff %d with last cutoff %d, expected effective %t, got %+v", c.cutoff, c.lastCutoff, c.effective, res)
+		}
+	}
+}
@@ -0,0 +1,100 @@
+/*
+
+  Copyright 2017 Loopring Project Ltd (Loopring Foundation).
+
+  Licensed under the Apache License, Version 2.0 (the "License");
+  you may not use this file except in compliance with the License.
+  You may obtain a copy of the License at
+
+  http://www.apache.org/licenses/LICENSE-2.0
+
+  Unless required by applicable law or agreed to in writing, software
+  distributed under the License is distributed on an "AS IS" BASIS,
+  WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+  See the License for the specific language governing permissions and
+  limitations under the License.
+
+*/
+
+package gateway
+
+import (
+	"errors"
+	omcache "github.com/Loopring/relay-cluster/ordermanager/cache"
+	"github.com/Loopring/relay-cluster/ordermanager/manager"
+	"github.com/Loopring/relay-lib/crypto"
+	"github.com/Loopring/relay-lib/log"
+	"github.com/Loopring/relay-lib/types"
+	"github.com/ethereum/go-ethereum/common"
+	"math"
+	"math/big"
+	"strconv"
+	"strings"
+	"time"
+)
+
+type DeadManSwitchHeartbeatQuery struct {
+	Sign    SignInfo `json:"sign"`
+	Timeout int64    `json:"timeout"`
+	Markets []string `json:"markets"`
+}
+
+// 签名内容包含timeout和markets, 防止其他接口的签名被用于设置开关
+func (req *DeadManSwitchHeartbeatQuery) SignHash() common.Hash {
+	h := &common.Hash{}
+	hashBytes := crypto.GenerateHash(
+		common.HexToAddress(req.Sign.Owner).Bytes(),
+		common.LeftPadBytes(big.NewInt(req.Timeout).Bytes(), 32),
+		[]byte(strings.Join(req.Markets, ",")),
+		[]byte(req.Sign.Timestamp),
+	)
+	h.SetBytes(hashBytes)
+	return *h
+}
+
+func verifyHeartbeatSign(req *DeadManSwitchHeartbeatQuery) (int64, error) {
+	if !common.IsHexAddress(req.Sign.Owner) {
+		return 0, errors.New("owner isn't a valid hex-address")
+	}
+	ts, err := strconv.ParseInt(req.Sign.Timestamp, 10, 64)
+	if err != nil {
+		return 0, err
+	}
+	if math.Abs(float64(time.Now().Unix()-ts)) > 60*10 {
+		return 0, errors.New("timestamp had expired")
+	}
+
+	sig, _ := crypto.VRSToSig(req.Sign.V, types.HexToBytes32(req.Sign.R).Bytes(), types.HexToBytes32(req.Sign.S).Bytes())
+	hash := req.SignHash()
+	if addressBytes, err := crypto.SigToAddress(hash.Bytes(), sig); nil != err {
+		log.Errorf("heartbeat signer address error:%s", err.Error())
+		return 0, errors.New("sign is incorrect")
+	} else if common.BytesToAddress(addressBytes) != common.HexToAddress(req.Sign.Owner) {
+		return 0, errors.New("sign address not matched")
+	}
+	return ts, nil
+}
+
+// 超过timeout秒未收到心跳时撤销该owner(指定markets)的所有订单
+func (w *WalletServiceImpl) DeadManSwitchHeartbeat(req DeadManSwitchHeartbeatQuery) (res *omcache.DeadManSwitch, err error) {
+	ts, err := verifyHeartbeatSign(&req)
+	if err != nil {
+		return res, err
+	}
+	return manager.HeartbeatDeadManSwitch(common.HexToAddress(req.Sign.Owner), req.Timeout, req.Markets, ts)
+}
+
+func (w *WalletServiceImpl) GetDeadManSwitch(query SingleOwner) (res *omcache.DeadManSwitch, err error) {
+	if !common.IsHexAddress(query.Owner) {
+		return res, errors.New("owner isn't a valid hex-address")
+	}
+
+	res, err = omcache.GetDeadManSwitch(common.HexToAddress(query.Owner))
+	if err != nil {
+		return res, err
+	}
+	if res == nil {
+		res = &omcache.DeadManSwitch{Owner: common.HexToAddress(query.Owner).Hex()}
+	}
+	return res, nil
+}
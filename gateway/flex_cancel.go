@@ -0,0 +1,226 @@
+/*
+
+  Copyright 2017 Loopring Project Ltd (Loopring Foundation).
+
+  Licensed under the Apache License, Version 2.0 (the "License");
+  you may not use this file except in compliance with the License.
+  You may obtain a copy of the License at
+
+  http://www.apache.org/licenses/LICENSE-2.0
+
+  Unless required by applicable law or agreed to in writing, software
+  distributed under the License is distributed on an "AS IS" BASIS,
+  WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+  See the License for the specific language governing permissions and
+  limitations under the License.
+
+*/
+
+package gateway
+
+import (
+	"errors"
+	"github.com/Loopring/relay-cluster/ordermanager/manager"
+	kafkaUtil "github.com/Loopring/relay-cluster/util"
+	"github.com/Loopring/relay-lib/cache"
+	"github.com/Loopring/relay-lib/crypto"
+	"github.com/Loopring/relay-lib/kafka"
+	"github.com/Loopring/relay-lib/log"
+	util "github.com/Loopring/relay-lib/marketutil"
+	"github.com/Loopring/relay-lib/types"
+	"github.com/ethereum/go-ethereum/common"
+	"math"
+	"math/big"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	flexCancelNoncePreKey     = "flex_cancel_nonce_"
+	flexCancelNonceLockPreKey = "flex_cancel_nonce_lock_"
+	flexCancelSignExpire      = 60 * 10
+)
+
+// 撤单nonce的存储, 生产环境为redis, 多个relay共享
+type flexCancelNonceStore interface {
+	// 原子地占用nonce, 已被占用时返回false
+	Reserve(owner string, nonce int64) (bool, error)
+	Release(owner string, nonce int64) error
+	// 已成功撤单的最大nonce, 没有记录时为0
+	Last(owner string) (int64, error)
+	Commit(owner string, nonce int64) error
+}
+
+type FlexCancelResult struct {
+	AffectedOrders int64 `json:"affectedOrders"`
+}
+
+// 签名内容为撤单类型、范围及nonce, 同一签名不能用于其他范围的撤单
+func (req *CancelOrderQuery) SignHash() common.Hash {
+	h := &common.Hash{}
+	hashBytes := crypto.GenerateHash(
+		[]byte{req.Type},
+		common.HexToAddress(req.Sign.Owner).Bytes(),
+		common.HexToHash(req.OrderHash).Bytes(),
+		common.LeftPadBytes(big.NewInt(req.CutoffTime).Bytes(), 32),
+		common.HexToAddress(req.TokenS).Bytes(),
+		common.HexToAddress(req.TokenB).Bytes(),
+		common.LeftPadBytes(big.NewInt(req.Nonce).Bytes(), 32),
+		[]byte(req.Sign.Timestamp),
+	)
+	h.SetBytes(hashBytes)
+	return *h
+}
+
+func (req *CancelOrderQuery) ToEvent() *types.FlexCancelOrderEvent {
+	event := &types.FlexCancelOrderEvent{}
+	event.OrderHash = common.HexToHash(req.OrderHash)
+	event.Owner = common.HexToAddress(req.Sign.Owner)
+	event.TokenS = common.HexToAddress(req.TokenS)
+	event.TokenB = common.HexToAddress(req.TokenB)
+	event.CutoffTime = req.CutoffTime
+	event.Type = types.FlexCancelType(req.Type)
+	return event
+}
+
+func verifyFlexCancelSign(req *CancelOrderQuery) error {
+	if !common.IsHexAddress(req.Sign.Owner) {
+		return errors.New("owner isn't a valid hex-address")
+	}
+	if req.Nonce <= 0 {
+		return errors.New("nonce must be bigger than zero")
+	}
+	ts, err := strconv.ParseInt(req.Sign.Timestamp, 10, 64)
+	if err != nil {
+		return errors.New("timestamp isn't a valid unix time")
+	}
+	if math.Abs(float64(time.Now().Unix()-ts)) > flexCancelSignExpire {
+		return errors.New("timestamp had expired")
+	}
+
+	sig, _ := crypto.VRSToSig(req.Sign.V, types.HexToBytes32(req.Sign.R).Bytes(), types.HexToBytes32(req.Sign.S).Bytes())
+	hash := req.SignHash()
+	if addressBytes, err := crypto.SigToAddress(hash.Bytes(), sig); nil != err {
+		log.Errorf("flex cancel signer address error:%s", err.Error())
+		return errors.New("sign is incorrect")
+	} else if signer := common.BytesToAddress(addressBytes); signer != common.HexToAddress(req.Sign.Owner) {
+		if err := verifySessionSigner(gateway.rds, common.HexToAddress(req.Sign.Owner), signer, flexCancelMarket(req), 0); err != nil {
+			return errors.New("sign address not matched, " + err.Error())
+		}
+	}
+	return nil
+}
+
+// 会话密钥只能撤销其授权市场内的订单, 按owner或时间撤单不限定市场
+func flexCancelMarket(req *CancelOrderQuery) string {
+	switch types.FlexCancelType(req.Type) {
+	case types.FLEX_CANCEL_BY_HASH:
+		if state, err := gateway.om.GetOrderByHash(common.HexToHash(req.OrderHash)); err == nil {
+			return state.RawOrder.Market
+		}
+	case types.FLEX_CANCEL_BY_MARKET:
+		market, _ := util.WrapMarketByAddress(req.TokenS, req.TokenB)
+		return market
+	}
+	return ""
+}
+
+// nonce必须大于该owner上一次成功撤单的nonce, 防止签名被重放, 同时过滤其他relay广播回来的重复撤单
+// 并发的相同nonce只有一个能执行撤单, 撤单失败时释放nonce, 成功后才记为已使用
+func useFlexCancelNonce(store flexCancelNonceStore, owner string, nonce int64, cancel func() error) error {
+	owner = strings.ToLower(owner)
+	if reserved, err := store.Reserve(owner, nonce); err != nil {
+		return err
+	} else if !reserved {
+		return errors.New("nonce has been used")
+	}
+
+	if last, err := store.Last(owner); err != nil {
+		store.Release(owner, nonce)
+		return err
+	} else if nonce <= last {
+		store.Release(owner, nonce)
+		return errors.New("nonce must be bigger than the last one")
+	}
+
+	if err := cancel(); err != nil {
+		store.Release(owner, nonce)
+		return err
+	}
+	return store.Commit(owner, nonce)
+}
+
+// 校验签名和nonce后在本地撤单, 返回本地受影响的订单数量
+func applyFlexCancel(req *CancelOrderQuery) (int64, error) {
+	if err := verifyFlexCancelSign(req); err != nil {
+		return 0, err
+	}
+
+	var nums int64
+	err := useFlexCancelNonce(&redisFlexCancelNonceStore{}, req.Sign.Owner, req.Nonce, func() (err error) {
+		nums, err = manager.FlexCancelOrder(req.ToEvent())
+		return err
+	})
+	if err != nil {
+		return nums, err
+	}
+	if nums > 0 {
+		go notifyFlexCancelled(req)
+	}
+	return nums, nil
+}
+
+// 占用的nonce保留到签名过期, 之后由时间戳和Last拒绝重放
+type redisFlexCancelNonceStore struct{}
+
+func (r *redisFlexCancelNonceStore) Reserve(owner string, nonce int64) (bool, error) {
+	key := flexCancelNonceLockPreKey + owner + "_" + strconv.FormatInt(nonce, 10)
+	count, err := cache.Incr(key)
+	if err != nil {
+		return false, err
+	}
+	if count != 1 {
+		return false, nil
+	}
+	return true, cache.ExpireAt(key, time.Now().Unix()+flexCancelSignExpire*2)
+}
+
+func (r *redisFlexCancelNonceStore) Release(owner string, nonce int64) error {
+	return cache.Del(flexCancelNonceLockPreKey + owner + "_" + strconv.FormatInt(nonce, 10))
+}
+
+func (r *redisFlexCancelNonceStore) Last(owner string) (int64, error) {
+	data, err := cache.ZRange(flexCancelNoncePreKey+owner, -1, -1, false)
+	if err != nil || len(data) == 0 {
+		return 0, err
+	}
+	return strconv.ParseInt(string(data[0]), 10, 64)
+}
+
+// 有序集合按nonce排序, 并发提交时最大值不会被较小的nonce覆盖
+func (r *redisFlexCancelNonceStore) Commit(owner string, nonce int64) error {
+	key := flexCancelNoncePreKey + owner
+	member := []byte(strconv.FormatInt(nonce, 10))
+	if err := cache.ZAdd(key, -1, member, member); err != nil {
+		return err
+	}
+	_, err := cache.ZRemRangeByScore(key, 0, nonce-1)
+	return err
+}
+
+func notifyFlexCancelled(req *CancelOrderQuery) {
+	orderQuery := OrderQuery{Owner: req.Sign.Owner, OrderType: types.ORDER_TYPE_MARKET}
+	if types.FlexCancelType(req.Type) == types.FLEX_CANCEL_BY_HASH {
+		orderQuery.OrderHash = common.HexToHash(req.OrderHash).Hex()
+	} else if types.FlexCancelType(req.Type) == types.FLEX_CANCEL_BY_MARKET {
+		orderQuery.Market, _ = util.WrapMarketByAddress(req.TokenS, req.TokenB)
+	} else {
+		// other conditions will notify all market, not implement now
+	}
+	orderQueryMap, _, _, _ := convertFromQuery(&orderQuery)
+	ot, err := gateway.om.GetLatestOrders(orderQueryMap, 1)
+	if err == nil && len(ot) > 0 {
+		kafkaUtil.ProducerSocketIOMessage(kafka.Kafka_Topic_SocketIO_Order_Updated, ot[0])
+	}
+}
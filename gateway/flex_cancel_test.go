@@ -0,0 +1,155 @@
+/*
+
+  Copyright 2017 Loopring Project Ltd (Loopring Foundation).
+
+  Licensed under the Apache License, Version 2.0 (the "License");
+  you may not use this file except in compliance with the License.
+  You may obtain a copy of the License at
+
+  http://www.apache.org/licenses/LICENSE-2.0
+
+  Unless required by applicable law or agreed to in writing, software
+  distributed under the License is distributed on an "AS IS" BASIS,
+  WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+  See the License for the specific language governing permissions and
+  limitations under the License.
+
+*/
+
+package gateway
+
+import (
+	"errors"
+	"strconv"
+	"sync"
+	"sync/atomic"
+	"testing"
+)
+
+type memFlexCancelNonceStore struct {
+	mtx      sync.Mutex
+	reserved map[string]bool
+	last     map[string]int64
+}
+
+func newMemFlexCancelNonceStore() *memFlexCancelNonceStore {
+	return &memFlexCancelNonceStore{reserved: make(map[string]bool), last: make(map[string]int64)}
+}
+
+func (m *memFlexCancelNonceStore) key(owner string, nonce int64) string {
+	return owner + "_" + strconv.FormatInt(nonce, 10)
+}
+
+func (m *memFlexCancelNonceStore) Reserve(owner string, nonce int64) (bool, error) {
+	m.mtx.Lock()
+	defer m.mtx.Unlock()
+	if m.reserved[m.key(owner, nonce)] {
+		return false, nil
+	}
+	m.reserved[m.key(owner, nonce)] = true
+	return true, nil
+}
+
+func (m *memFlexCancelNonceStore) Release(owner string, nonce int64) error {
+	m.mtx.Lock()
+	defer m.mtx.Unlock()
+	delete(m.reserved, m.key(owner, nonce))
+	return nil
+}
+
+func (m *memFlexCancelNonceStore) Last(owner string) (int64, error) {
+	m.mtx.Lock()
+	defer m.mtx.Unlock()
+	return m.last[owner], nil
+}
+
+func (m *memFlexCancelNonceStore) Commit(owner string, nonce int64) error {
+	m.mtx.Lock()
+	defer m.mtx.Unlock()
+	if nonce > m.last[owner] {
+		m.last[owner] = nonce
+	}
+	return nil
+}
+
+const flexCancelTestOwner = "0x1b978a1d302335a6f2ebe4b8823b5e17c3c84135"
+
+func TestUseFlexCancelNonceReplay(t *testing.T) {
+	store := newMemFlexCancelNonceStore()
+	cancelled := 0
+	cancel := func() error { cancelled++; return nil }
+
+	if err := useFlexCancelNonce(store, flexCancelTestOwner, 10, cancel); err != nil {
+		t.Fatal(err)
+	}
+	if err := useFlexCancelNonce(store, flexCancelTestOwner, 10, cancel); err == nil {
+		t.Fatal("replayed nonce should be rejected")
+	}
+	// 占用记录过期后仍由Last拒绝
+	store.Release(flexCancelTestOwner, 10)
+	if err := useFlexCancelNonce(store, flexCancelTestOwner, 10, cancel); err == nil {
+		t.Fatal("replayed nonce should be rejected after the reservation expired")
+	}
+	if cancelled != 1 {
+		t.Fatalf("cancelled %d times, expected 1", cancelled)
+	}
+}
+
+func TestUseFlexCancelNonceOutOfOrder(t *testing.T) {
+	store := newMemFlexCancelNonceStore()
+	cancel := func() error { return nil }
+
+	if err := useFlexCancelNonce(store, flexCancelTestOwner, 20, cancel); err != nil {
+		t.Fatal(err)
+	}
+	if err := useFlexCancelNonce(store, flexCancelTestOwner, 15, cancel); err == nil {
+		t.Fatal("nonce smaller than the last one should be rejected")
+	}
+	// 被拒绝的nonce不会占用
+	if reserved, _ := store.Reserve(flexCancelTestOwner, 15); !reserved {
+		t.Fatal("rejected nonce should be released")
+	}
+	if err := useFlexCancelNonce(store, flexCancelTestOwner, 21, cancel); err != nil {
+		t.Fatal(err)
+	}
+	if last, _ := store.Last(flexCancelTestOwner); last != 21 {
+		t.Fatalf("last nonce %d, expected 21", last)
+	}
+}
+
+func TestUseFlexCancelNonceFailedCancel(t *testing.T) {
+	store := newMemFlexCancelNonceStore()
+
+	if err := useFlexCancelNonce(store, flexCancelTestOwner, 30, func() error { return errors.New("db error") }); err == nil {
+		t.Fatal("cancel error should be returned")
+	}
+	if last, _ := store.Last(flexCancelTestOwner); last != 0 {
+		t.Fatalf("failed cancel should not use the nonce, last:%d", last)
+	}
+	if err := useFlexCancelNonce(store, flexCancelTestOwner, 30, func() error { return nil }); err != nil {
+		t.Fatalf("nonce of failed cancel should be reusable, err:%s", err.Error())
+	}
+}
+
+func TestUseFlexCancelNonceConcurrent(t *testing.T) {
+	store := newMemFlexCancelNonceStore()
+	var cancelled int32
+	var wg sync.WaitGroup
+	start := make(chan struct{})
+	for i := 0; i < 20; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			<-start
+			useFlexCancelNonce(store, flexCancelTestOwner, 40, func() error {
+				atomic.AddInt32(&cancelled, 1)
+				return nil
+			})
+		}()
+	}
+	close(start)
+	wg.Wait()
+	if cancelled != 1 {
+		t.Fatalf("cancelled %d times, expected 1", cancelled)
+	}
+}
@@ -24,6 +24,7 @@ import (
 	"errors"
 	"fmt"
 	"github.com/Loopring/relay-cluster/accountmanager"
+	"github.com/Loopring/relay-cluster/dao"
 	"github.com/Loopring/relay-cluster/ordermanager/manager"
 	"github.com/Loopring/relay-cluster/ordermanager/viewer"
 	"github.com/Loopring/relay-lib/broadcast"
@@ -40,12 +41,14 @@ import (
 )
 
 type Gateway struct {
-	filters          []Filter
-	om               viewer.OrderViewer
-	am               accountmanager.AccountManager
-	isBroadcast      bool
-	maxBroadcastTime int
-	marketCap        marketcap.MarketCapProvider
+	filters             []Filter
+	om                  viewer.OrderViewer
+	am                  accountmanager.AccountManager
+	isBroadcast         bool
+	maxBroadcastTime    int
+	marketCap           marketcap.MarketCapProvider
+	deprecatedProtocols map[common.Address]bool
+	rds                 *dao.RdsService
 }
 
 var gateway Gateway
@@ -71,16 +74,21 @@ type GatewayFiltersOptions struct {
 }
 
 type GateWayOptions struct {
-	IsBroadcast      bool
-	MaxBroadcastTime int
-	MatrixPubOptions []matrix.MatrixPublisherOption
-	MatrixSubOptions []matrix.MatrixSubscriberOption
+	IsBroadcast         bool
+	MaxBroadcastTime    int
+	DeprecatedProtocols []string
+	MatrixPubOptions    []matrix.MatrixPublisherOption
+	MatrixSubOptions    []matrix.MatrixSubscriberOption
 }
 
-func Initialize(filterOptions *GatewayFiltersOptions, options *GateWayOptions, om viewer.OrderViewer, marketCap marketcap.MarketCapProvider, am accountmanager.AccountManager) {
-	gateway = Gateway{filters: make([]Filter, 0), om: om, isBroadcast: options.IsBroadcast, maxBroadcastTime: options.MaxBroadcastTime, am: am}
+func Initialize(filterOptions *GatewayFiltersOptions, options *GateWayOptions, om viewer.OrderViewer, marketCap marketcap.MarketCapProvider, am accountmanager.AccountManager, rds *dao.RdsService) {
+	gateway = Gateway{filters: make([]Filter, 0), om: om, isBroadcast: options.IsBroadcast, maxBroadcastTime: options.MaxBroadcastTime, am: am, rds: rds}
 
 	gateway.marketCap = marketCap
+	gateway.deprecatedProtocols = parseDeprecatedProtocols(options.DeprecatedProtocols, loopringaccessor.ProtocolAddresses())
+
+	// new protocol filter
+	protocolFilter := &ProtocolFilter{}
 
 	// new pow filter
 	powFilter := &PowFilter{Difficulty: types.HexToBigint(filterOptions.PowFilter.Difficulty)}
@@ -108,11 +116,12 @@ func Initialize(filterOptions *GatewayFiltersOptions, options *GateWayOptions, o
 	tokenFilter := &TokenFilter{}
 
 	// new sign filter
-	signFilter := &SignFilter{}
+	signFilter := &SignFilter{keys: rds}
 
 	// new cutoff filter
 	cutoffFilter := &CutoffFilter{om: om}
 
+	gateway.filters = append(gateway.filters, protocolFilter)
 	gateway.filters = append(gateway.filters, powFilter)
 	gateway.filters = append(gateway.filters, baseFilter)
 	gateway.filters = append(gateway.filters, signFilter)
@@ -143,39 +152,29 @@ func HandleInputOrder(input eventemitter.EventData) (orderHash string, err error
 	)
 
 	order := input.(*types.Order)
-	order.Hash = order.GenerateHash()
-
+	err = prepareInputOrder(order)
 	orderHash = order.Hash.Hex()
 	//log.Info(">>>>>>>>input order hash is : " + order.Hash.Hex())
-
-	market, err := util.WrapMarketByAddress(order.TokenB.Hex(), order.TokenS.Hex())
 	if err != nil {
 		return orderHash, err
 	}
-	order.Market = market
-	order.Side = util.GetSide(order.TokenS.Hex(), order.TokenB.Hex())
 
 	//TODO(xiaolu) 这里需要测试一下，超时error和查询数据为空的error，处理方式不应该一样
 	if state, err = gateway.om.GetOrderByHash(order.Hash); err != nil && err.Error() == "record not found" {
-		eventemitter.Emit(eventemitter.NewOrderForBroadcast, order)
-
-		if err = generatePrice(order); err != nil {
-			return orderHash, err
+		if !isLocalOnlyOrder(order.Hash) {
+			eventemitter.Emit(eventemitter.NewOrderForBroadcast, order)
 		}
 
-		for _, v := range gateway.filters {
-			valid, err := v.filter(order)
-			if !valid {
-				log.Errorf(err.Error())
-				return orderHash, err
-			}
+		if err = filterInputOrder(order); err != nil {
+			log.Errorf(err.Error())
+			return orderHash, err
 		}
 		state = &types.OrderState{}
 		state.RawOrder = *order
 		eventemitter.Emit(eventemitter.NewOrder, state)
 	} else {
 		broadcastTime := state.BroadcastTime + 1
-		if gateway.isBroadcast && broadcastTime < gateway.maxBroadcastTime {
+		if gateway.isBroadcast && broadcastTime < gateway.maxBroadcastTime && !isLocalOnlyOrder(state.RawOrder.Hash) {
 			eventemitter.Emit(eventemitter.NewOrderForBroadcast, state.RawOrder)
 			if err = manager.UpdateBroadcastTimeByHash(state.RawOrder.Hash, broadcastTime+1); nil != err {
 				return orderHash, err
@@ -188,6 +187,64 @@ func HandleInputOrder(input eventemitter.EventData) (orderHash string, err error
 	return orderHash, err
 }
 
+// 冰山单、post-only、IOC/FOK、订单组等属性只保存在本节点, 其他节点收到后会按普通订单处理, 因此不广播
+func isLocalOnlyOrder(orderHash common.Hash) bool {
+	if _, err := gateway.rds.GetIcebergOrderByHash(orderHash.Hex()); err == nil {
+		return true
+	}
+	if _, err := gateway.rds.GetPostOnlyOrderByHash(orderHash.Hex()); err == nil {
+		return true
+	}
+	if _, err := gateway.rds.GetTimeInForceOrderByHash(orderHash.Hex()); err == nil {
+		return true
+	}
+	if _, err := gateway.rds.GetOrderGroupMember(orderHash.Hex()); err == nil {
+		return true
+	}
+	return false
+}
+
+// 条件单触发、计划订单释放时按普通订单流程提交
+func SubmitDeferredOrder(order *types.Order) error {
+	_, err := HandleInputOrder(order)
+	return err
+}
+
+// 与HandleInputOrder相同的校验, 但不入库也不广播, 用于需要先确认订单可被接受的操作
+func validateInputOrder(order *types.Order) error {
+	if err := prepareInputOrder(order); err != nil {
+		return err
+	}
+	if _, err := gateway.om.GetOrderByHash(order.Hash); err == nil {
+		return errors.New("order existed, please not submit again")
+	}
+	return filterInputOrder(order)
+}
+
+func prepareInputOrder(order *types.Order) error {
+	order.Hash = order.GenerateHash()
+
+	market, err := util.WrapMarketByAddress(order.TokenB.Hex(), order.TokenS.Hex())
+	if err != nil {
+		return err
+	}
+	order.Market = market
+	order.Side = util.GetSide(order.TokenS.Hex(), order.TokenB.Hex())
+	return nil
+}
+
+func filterInputOrder(order *types.Order) error {
+	if err := generatePrice(order); err != nil {
+		return err
+	}
+	for _, v := range gateway.filters {
+		if valid, err := v.filter(order); !valid {
+			return err
+		}
+	}
+	return nil
+}
+
 func generatePrice(order *types.Order) error {
 	tokenS, err := util.AddressToToken(order.TokenS)
 	if err != nil {
@@ -335,20 +392,42 @@ func (f *BaseFilter) filter(o *types.Order) (bool, error) {
 }
 
 type SignFilter struct {
+	keys sessionKeyStore
 }
 
 func (f *SignFilter) filter(o *types.Order) (bool, error) {
 	o.Hash = o.GenerateHash()
 
-	if addr, err := o.SignerAddress(); nil != err {
-		return false, err
-	} else if addr != o.Owner {
-		return false, fmt.Errorf("gateway,sign filter,o.Owner %s and signeraddress %s are not match", o.Owner.Hex(), addr.Hex())
+	if err := verifyOrderSigner(f.keys, o); err != nil {
+		return false, fmt.Errorf("gateway,sign filter,%s", err.Error())
 	}
-
 	return true, nil
 }
 
+// 所有订单入口(包括条件单、计划订单)使用相同的签名校验, 非owner签名时必须是该owner有效的会话密钥
+func verifyOrderSigner(keys sessionKeyStore, o *types.Order) error {
+	addr, err := o.SignerAddress()
+	if nil != err {
+		return err
+	}
+	if addr == o.Owner {
+		return nil
+	}
+
+	market, err := util.WrapMarketByAddress(o.TokenB.Hex(), o.TokenS.Hex())
+	if err != nil {
+		return err
+	}
+	notional, err := orderNotional(o)
+	if err != nil {
+		return err
+	}
+	if err := verifySessionSigner(keys, o.Owner, addr, market, notional); err != nil {
+		return fmt.Errorf("o.Owner %s and signeraddress %s are not match, %s", o.Owner.Hex(), addr.Hex(), err.Error())
+	}
+	return nil
+}
+
 type TokenFilter struct {
 	AllowTokens  map[common.Address]bool
 	DeniedTokens map[common.Address]bool
@@ -382,7 +461,7 @@ type CutoffFilter struct {
 
 // 如果订单接收在cutoff(cancel)事件之后，则该订单直接过滤
 func (f *CutoffFilter) filter(o *types.Order) (bool, error) {
-	if f.om.IsOrderCutoff(o.Protocol, o.Owner, o.TokenS, o.TokenB, o.ValidSince) {
+	if f.om.IsOrderCutoff(o.DelegateAddress, o.Owner, o.TokenS, o.TokenB, o.ValidSince) {
 		return false, fmt.Errorf("gateway,cutoff filter order:%s should be cutoff", o.Owner.Hex())
 	}
 
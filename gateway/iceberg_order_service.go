@@ -0,0 +1,158 @@
+/*
+
+  Copyright 2017 Loopring Project Ltd (Loopring Foundation).
+
+  Licensed under the Apache License, Version 2.0 (the "License");
+  you may not use this file except in compliance with the License.
+  You may obtain a copy of the License at
+
+  http://www.apache.org/licenses/LICENSE-2.0
+
+  Unless required by applicable law or agreed to in writing, software
+  distributed under the License is distributed on an "AS IS" BASIS,
+  WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+  See the License for the specific language governing permissions and
+  limitations under the License.
+
+*/
+
+package gateway
+
+import (
+	"errors"
+	"github.com/Loopring/relay-cluster/dao"
+	"github.com/Loopring/relay-lib/log"
+	"github.com/Loopring/relay-lib/types"
+	"github.com/ethereum/go-ethereum/common"
+	"math/big"
+	"time"
+)
+
+type IcebergOrderRequest struct {
+	Order          types.OrderJsonRequest `json:"order"`
+	DisplayAmountS string                 `json:"displayAmountS"`
+}
+
+func (w *WalletServiceImpl) SubmitIcebergOrder(req IcebergOrderRequest) (orderHash string, err error) {
+	displayAmountS, ok := new(big.Int).SetString(req.DisplayAmountS, 0)
+	if !ok || displayAmountS.Sign() <= 0 {
+		return orderHash, errors.New("display amountS must be bigger than zero")
+	}
+
+	req.Order.OrderType = types.ORDER_TYPE_MARKET
+	order := types.ToOrder(&req.Order)
+	if order.AmountS == nil || displayAmountS.Cmp(order.AmountS) >= 0 {
+		return orderHash, errors.New("display amountS must be less than amountS")
+	}
+	order.Hash = order.GenerateHash()
+	orderHash = order.Hash.Hex()
+
+	if _, err := w.rds.GetIcebergOrderByHash(orderHash); err == nil {
+		return orderHash, errors.New("order existed, please not submit again")
+	}
+
+	// 先保存展示数量, 避免订单入库后深度短暂暴露全部数量
+	iceberg := &dao.IcebergOrder{
+		OrderHash:      orderHash,
+		Owner:          order.Owner.Hex(),
+		DisplayAmountS: displayAmountS.String(),
+		CreateTime:     time.Now().Unix(),
+	}
+	if err = w.rds.Add(iceberg); err != nil {
+		return orderHash, err
+	}
+
+	if orderHash, err = HandleInputOrder(order); err != nil {
+		w.rds.Del(iceberg)
+	}
+	return orderHash, err
+}
+
+func (w *WalletServiceImpl) getIcebergDisplayAmounts(states []types.OrderState) map[string]*big.Int {
+	displayAmounts, err := w.queryIcebergDisplayAmounts(states)
+	if err != nil {
+		log.Errorf("get iceberg display amounts error:%s", err.Error())
+	}
+	return displayAmounts
+}
+
+func (w *WalletServiceImpl) queryIcebergDisplayAmounts(states []types.OrderState) (map[string]*big.Int, error) {
+	hashes := make([]string, 0)
+	for _, s := range states {
+		hashes = append(hashes, s.RawOrder.Hash.Hex())
+	}
+	return w.rds.GetIcebergDisplayAmounts(hashes)
+}
+
+// 可见数量为当前分片剩余部分, 分片成交完后按displayAmountS补充, 但不超过订单剩余数量
+func icebergVisibleAmount(state types.OrderState, remainedS, remainedB *big.Rat, displayAmountS *big.Int) (*big.Rat, *big.Rat) {
+	consumed := new(big.Rat).Sub(new(big.Rat).SetInt(state.RawOrder.AmountS), remainedS)
+	consumedInt := new(big.Int)
+	if consumed.Sign() > 0 {
+		consumedInt.Quo(consumed.Num(), consumed.Denom())
+	}
+
+	visibleInt := new(big.Int).Sub(displayAmountS, new(big.Int).Mod(consumedInt, displayAmountS))
+	visibleS := new(big.Rat).SetInt(visibleInt)
+	if visibleS.Cmp(remainedS) >= 0 || remainedS.Sign() <= 0 {
+		return remainedS, remainedB
+	}
+
+	visibleB := new(big.Rat).Mul(remainedB, new(big.Rat).Quo(visibleS, remainedS))
+	return visibleS, visibleB
+}
+
+// 冰山单按当前分片的比例缩小lrcFee, 避免通过手续费推算订单总量
+func icebergVisibleLrcFee(state types.OrderState, displayAmountS *big.Int) *big.Rat {
+	lrcFee := new(big.Rat).SetInt(state.RawOrder.LrcFee)
+	remainedS, remainedB := state.RemainedAmount()
+	visibleS, _ := icebergVisibleAmount(state, remainedS, remainedB, displayAmountS)
+	return lrcFee.Mul(lrcFee, new(big.Rat).SetFrac(visibleS.Num(), new(big.Int).Mul(visibleS.Denom(), state.RawOrder.AmountS)))
+}
+
+// 对外只展示已成交/撤销部分加当前分片, amountB和lrcFee按相同比例缩小
+func maskIcebergOrder(rst *OrderJsonResult, state types.OrderState, displayAmountS *big.Int) {
+	remainedS, remainedB := state.RemainedAmount()
+	visibleS, _ := icebergVisibleAmount(state, remainedS, remainedB, displayAmountS)
+
+	shownS := new(big.Rat).Sub(new(big.Rat).SetInt(state.RawOrder.AmountS), remainedS)
+	shownS.Add(shownS, visibleS)
+	ratio := new(big.Rat).Quo(shownS, new(big.Rat).SetInt(state.RawOrder.AmountS))
+
+	ratToInt := func(r *big.Rat) *big.Int {
+		return new(big.Int).Quo(r.Num(), r.Denom())
+	}
+	rst.RawOrder.AmountS = types.BigintToHex(ratToInt(shownS))
+	rst.RawOrder.AmountB = types.BigintToHex(ratToInt(new(big.Rat).Mul(new(big.Rat).SetInt(state.RawOrder.AmountB), ratio)))
+	rst.RawOrder.LrcFee = types.BigintToHex(ratToInt(new(big.Rat).Mul(new(big.Rat).SetInt(state.RawOrder.LrcFee), ratio)))
+	rst.Iceberg = true
+}
+
+// 订单查询结果中隐藏冰山单总量, 只有带owner签名的查询可以看到自己订单的全部数量
+// 无法确认是否为冰山单时返回错误, 不展示可能包含冰山单总量的结果
+func (w *WalletServiceImpl) ordersToJson(states []types.OrderState, viewer common.Address) ([]OrderJsonResult, error) {
+	displayAmounts, err := w.queryIcebergDisplayAmounts(states)
+	if err != nil {
+		return nil, err
+	}
+	res := make([]OrderJsonResult, 0, len(states))
+	for _, state := range states {
+		rst := orderStateToJson(state)
+		if displayAmountS, ok := displayAmounts[state.RawOrder.Hash.Hex()]; ok && state.RawOrder.Owner != viewer {
+			maskIcebergOrder(&rst, state, displayAmountS)
+		}
+		res = append(res, rst)
+	}
+	return res, nil
+}
+
+// sign为空或校验失败时按匿名查询处理
+func orderQueryViewer(sign *SignInfo) common.Address {
+	if sign == nil {
+		return types.NilAddress
+	}
+	if ok, _ := verifySign(*sign); !ok {
+		return types.NilAddress
+	}
+	return common.HexToAddress(sign.Owner)
+}
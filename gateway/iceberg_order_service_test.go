@@ -0,0 +1,94 @@
+/*
+
+  Copyright 2017 Loopring Project Ltd (Loopring Foundation).
+
+  Licensed under the Apache License, Version 2.0 (the "License");
+  you may not use this file except in compliance with the License.
+  You may obtain a copy of the License at
+
+  http://www.apache.org/licenses/LICENSE-2.0
+
+  Unless required by applicable law or agreed to in writing, software
+  distributed under the License is distributed on an "AS IS" BASIS,
+  WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+  See the License for the specific language governing permissions and
+  limitations under the License.
+
+*/
+
+package gateway
+
+import (
+	"github.com/Loopring/relay-lib/types"
+	"math/big"
+	"testing"
+)
+
+func newIcebergTestState(dealtAmountS int64) types.OrderState {
+	state := types.OrderState{}
+	state.RawOrder.AmountS = big.NewInt(1000)
+	state.RawOrder.AmountB = big.NewInt(500)
+	state.RawOrder.LrcFee = big.NewInt(200)
+	state.DealtAmountS = big.NewInt(dealtAmountS)
+	state.DealtAmountB = big.NewInt(dealtAmountS / 2)
+	state.CancelledAmountS = big.NewInt(0)
+	state.CancelledAmountB = big.NewInt(0)
+	state.SplitAmountS = big.NewInt(0)
+	state.SplitAmountB = big.NewInt(0)
+	return state
+}
+
+func TestIcebergVisibleAmount(t *testing.T) {
+	displayAmountS := big.NewInt(100)
+	cases := []struct {
+		dealtAmountS int64
+		visibleS     int64
+		visibleB     int64
+	}{
+		{0, 100, 50},
+		{30, 70, 35},
+		{100, 100, 50},
+		{950, 50, 25},
+		{960, 40, 20},
+		{1000, 0, 0},
+	}
+
+	for _, c := range cases {
+		state := newIcebergTestState(c.dealtAmountS)
+		remainedS, remainedB := state.RemainedAmount()
+		visibleS, visibleB := icebergVisibleAmount(state, remainedS, remainedB, displayAmountS)
+		if visibleS.Cmp(new(big.Rat).SetInt64(c.visibleS)) != 0 || visibleB.Cmp(new(big.Rat).SetInt64(c.visibleB)) != 0 {
+			t.Errorf("dealt %d, visible %s/%s, expected %d/%d", c.dealtAmountS, visibleS.RatString(), visibleB.RatString(), c.visibleS, c.visibleB)
+		}
+	}
+}
+
+func TestIcebergVisibleLrcFee(t *testing.T) {
+	state := newIcebergTestState(30)
+	if fee := icebergVisibleLrcFee(state, big.NewInt(100)); fee.Cmp(big.NewRat(14, 1)) != 0 {
+		t.Errorf("visible lrcFee %s, expected 14", fee.RatString())
+	}
+}
+
+func TestMaskIcebergOrder(t *testing.T) {
+	state := newIcebergTestState(30)
+	rst := orderStateToJson(state)
+	maskIcebergOrder(&rst, state, big.NewInt(100))
+
+	if !rst.Iceberg {
+		t.Error("masked order should be marked as iceberg")
+	}
+	// 已成交30加当前分片剩余70
+	if rst.RawOrder.AmountS != types.BigintToHex(big.NewInt(100)) {
+		t.Errorf("amountS %s, expected 100", rst.RawOrder.AmountS)
+	}
+	if rst.RawOrder.AmountB != types.BigintToHex(big.NewInt(50)) {
+		t.Errorf("amountB %s, expected 50", rst.RawOrder.AmountB)
+	}
+	if rst.RawOrder.LrcFee != types.BigintToHex(big.NewInt(20)) {
+		t.Errorf("lrcFee %s, expected 20", rst.RawOrder.LrcFee)
+	}
+	if rst.DealtAmountS != types.BigintToHex(big.NewInt(30)) {
+		t.Errorf("dealtAmountS %s, expected 30", rst.DealtAmountS)
+	}
+}
@@ -23,6 +23,8 @@ import (
 	"github.com/Loopring/relay-cluster/ordermanager/manager"
 	"github.com/Loopring/relay-cluster/ordermanager/viewer"
 	"github.com/Loopring/relay-lib/motan"
+	"github.com/Loopring/relay-lib/types"
+	"github.com/ethereum/go-ethereum/common"
 	"math/big"
 	"time"
 )
@@ -31,6 +33,33 @@ type MotanService struct {
 	accountManager accountmanager.AccountManager
 }
 
+// 与motan.MinerOrdersReq字段相同, 增加MinerId用于订单租用
+type MinerLeaseOrdersReq struct {
+	MinerId              string
+	Delegate             common.Address
+	TokenS               common.Address
+	TokenB               common.Address
+	Length               int
+	ReservedTime         int64
+	StartBlockNumber     int64
+	EndBlockNumber       int64
+	FilterOrderHashLists []*types.OrderDelayList
+}
+
+// 与motan.MinerOrdersRes相同, TimeInForce为订单hash到IOC/FOK的映射
+// FOK订单需要在一个环路中完全成交, 否则会被取消
+type MinerLeaseOrdersRes struct {
+	List        []*types.OrderState
+	TimeInForce map[string]string
+	Err         string
+}
+
+// 与motan.MinerOrdersRes相同, 请求缺少MinerId等错误通过Err返回
+type MinerOrdersRes struct {
+	List []*types.OrderState
+	Err  string
+}
+
 func (s *MotanService) GetBalanceAndAllowance(req *motan.AccountBalanceAndAllowanceReq) *motan.AccountBalanceAndAllowanceRes {
 	//start := msecNow()
 
@@ -60,11 +89,16 @@ func (s *MotanService) GetBalanceAndAllowance(req *motan.AccountBalanceAndAllowa
 	return res
 }
 
-func (s *MotanService) GetMinerOrders(req *motan.MinerOrdersReq) *motan.MinerOrdersRes {
+// 订单同样租给req.MinerId, 各miner必须传入自己的MinerId
+func (s *MotanService) GetMinerOrders(req *MinerLeaseOrdersReq) *MinerOrdersRes {
 	//start := msecNow()
 
-	res := &motan.MinerOrdersRes{}
-	res.List = manager.MinerOrders(req.Delegate, req.TokenS, req.TokenB, req.Length, req.ReservedTime, req.StartBlockNumber, req.EndBlockNumber, req.FilterOrderHashLists...)
+	res := &MinerOrdersRes{}
+	list, err := manager.MinerOrders(req.MinerId, req.Delegate, req.TokenS, req.TokenB, req.Length, req.ReservedTime, req.StartBlockNumber, req.EndBlockNumber, req.FilterOrderHashLists...)
+	if err != nil {
+		res.Err = err.Error()
+	}
+	res.List = list
 
 	//stop := msecNow()
 	//log.Debugf("motan service, GetMinerOrders list length:%d, execute time:%d(msec)", len(res.List), stop-start)
@@ -72,6 +106,19 @@ func (s *MotanService) GetMinerOrders(req *motan.MinerOrdersReq) *motan.MinerOrd
 	return res
 }
 
+// 返回的订单租给req.MinerId, 租期内其他miner不会获取到这些订单
+func (s *MotanService) LeaseMinerOrders(req *MinerLeaseOrdersReq) *MinerLeaseOrdersRes {
+	res := &MinerLeaseOrdersRes{TimeInForce: make(map[string]string)}
+	list, err := manager.MinerOrders(req.MinerId, req.Delegate, req.TokenS, req.TokenB, req.Length, req.ReservedTime, req.StartBlockNumber, req.EndBlockNumber, req.FilterOrderHashLists...)
+	if err != nil {
+		res.Err = err.Error()
+		return res
+	}
+	res.List = list
+	res.TimeInForce = manager.MinerOrderTimeInForces(res.List)
+	return res
+}
+
 func StartMotanService(options motan.MotanServerOptions, accountManager accountmanager.AccountManager, orderViewer viewer.OrderViewer) {
 	service := &MotanService{}
 	service.accountManager = accountManager
@@ -0,0 +1,179 @@
+/*
+
+  Copyright 2017 Loopring Project Ltd (Loopring Foundation).
+
+  Licensed under the Apache License, Version 2.0 (the "License");
+  you may not use this file except in compliance with the License.
+  You may obtain a copy of the License at
+
+  http://www.apache.org/licenses/LICENSE-2.0
+
+  Unless required by applicable law or agreed to in writing, software
+  distributed under the License is distributed on an "AS IS" BASIS,
+  WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+  See the License for the specific language governing permissions and
+  limitations under the License.
+
+*/
+
+package gateway
+
+import (
+	"errors"
+	"fmt"
+	"github.com/Loopring/relay-cluster/dao"
+	"github.com/Loopring/relay-cluster/ordermanager/manager"
+	"github.com/Loopring/relay-lib/log"
+	"github.com/Loopring/relay-lib/types"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/crypto"
+	"time"
+)
+
+type OrderGroupRequest struct {
+	GroupType string                   `json:"groupType"`
+	Orders    []types.OrderJsonRequest `json:"orders"`
+}
+
+type OrderGroupQuery struct {
+	GroupId string `json:"groupId"`
+}
+
+type OrderGroupJsonResult struct {
+	GroupId   string                 `json:"groupId"`
+	GroupType string                 `json:"groupType"`
+	Members   []dao.OrderGroupMember `json:"members"`
+	Orders    []OrderJsonResult      `json:"orders"`
+}
+
+// OCO订单组, 组内任一订单成交后其他订单被软取消, 返回groupId
+func (w *WalletServiceImpl) SubmitOrderGroup(req OrderGroupRequest) (groupId string, err error) {
+	if req.GroupType == "" {
+		req.GroupType = dao.ORDER_GROUP_TYPE_OCO
+	}
+	if req.GroupType != dao.ORDER_GROUP_TYPE_OCO {
+		return groupId, errors.New("unsupported group type")
+	}
+	if len(req.Orders) < 2 {
+		return groupId, errors.New("order group needs at least 2 orders")
+	}
+
+	var (
+		orders []*types.Order
+		hashes []byte
+	)
+	for i := range req.Orders {
+		req.Orders[i].OrderType = types.ORDER_TYPE_MARKET
+		order := types.ToOrder(&req.Orders[i])
+		order.Hash = order.GenerateHash()
+		if len(orders) > 0 && order.Owner != orders[0].Owner {
+			return groupId, errors.New("orders in group must have the same owner")
+		}
+		if _, err := w.rds.GetOrderGroupMember(order.Hash.Hex()); err == nil {
+			return groupId, fmt.Errorf("order %s already in a group", order.Hash.Hex())
+		}
+		orders = append(orders, order)
+		hashes = append(hashes, order.Hash.Bytes()...)
+	}
+	groupId = crypto.Keccak256Hash(hashes).Hex()
+
+	// 先保存组成员, 避免订单入库后在组建立前成交
+	var members []*dao.OrderGroupMember
+	now := time.Now().Unix()
+	for _, order := range orders {
+		member := &dao.OrderGroupMember{
+			GroupId:    groupId,
+			GroupType:  req.GroupType,
+			OrderHash:  order.Hash.Hex(),
+			Owner:      order.Owner.Hex(),
+			Status:     dao.ORDER_GROUP_MEMBER_ACTIVE,
+			CreateTime: now,
+			UpdateTime: now,
+		}
+		if err = w.rds.Add(member); err != nil {
+			w.rollbackOrderGroup(members, nil)
+			return groupId, err
+		}
+		members = append(members, member)
+	}
+
+	var submitted []*types.Order
+	for _, order := range orders {
+		if _, err = HandleInputOrder(order); err != nil {
+			w.rollbackOrderGroup(members, submitted)
+			return groupId, fmt.Errorf("order %s submit failed:%s", order.Hash.Hex(), err.Error())
+		}
+		submitted = append(submitted, order)
+	}
+
+	return groupId, nil
+}
+
+// 组内订单部分提交失败时删除组, 并软取消已提交的订单
+func (w *WalletServiceImpl) rollbackOrderGroup(members []*dao.OrderGroupMember, submitted []*types.Order) {
+	for _, v := range members {
+		w.rds.Del(v)
+	}
+	for _, v := range submitted {
+		event := &types.FlexCancelOrderEvent{Owner: v.Owner, OrderHash: v.Hash, Type: types.FLEX_CANCEL_BY_HASH}
+		if _, err := manager.FlexCancelOrder(event); err != nil {
+			log.Errorf("rollback order group, flex cancel order:%s error:%s", v.Hash.Hex(), err.Error())
+		}
+	}
+}
+
+func (w *WalletServiceImpl) GetOrderGroup(query OrderGroupQuery) (res OrderGroupJsonResult, err error) {
+	if query.GroupId == "" {
+		return res, errors.New("group id can't be null")
+	}
+
+	members, err := w.rds.GetOrderGroupMembers(common.HexToHash(query.GroupId).Hex())
+	if err != nil {
+		return res, err
+	}
+	if len(members) == 0 {
+		return res, errors.New("order group not found")
+	}
+
+	res.GroupId = members[0].GroupId
+	res.GroupType = members[0].GroupType
+	res.Members = members
+	res.Orders = make([]OrderJsonResult, 0)
+	for _, v := range members {
+		state, err := w.orderViewer.GetOrderByHash(common.HexToHash(v.OrderHash))
+		if err != nil {
+			continue
+		}
+		orders, err := w.ordersToJson([]types.OrderState{*state}, types.NilAddress)
+		if err != nil {
+			return res, err
+		}
+		order := orders[0]
+		order.GroupId = v.GroupId
+		res.Orders = append(res.Orders, order)
+	}
+	return res, nil
+}
+
+// 为订单查询结果补充所属订单组
+func (w *WalletServiceImpl) fillOrderGroupIds(orders []interface{}) {
+	hashes := make([]string, 0)
+	for _, v := range orders {
+		hashes = append(hashes, v.(OrderJsonResult).RawOrder.Hash)
+	}
+
+	groupIds, err := w.rds.GetOrderGroupIds(hashes)
+	if err != nil {
+		log.Errorf("get order group ids error:%s", err.Error())
+		return
+	}
+	if len(groupIds) == 0 {
+		return
+	}
+
+	for i, v := range orders {
+		order := v.(OrderJsonResult)
+		order.GroupId = groupIds[order.RawOrder.Hash]
+		orders[i] = order
+	}
+}
@@ -0,0 +1,318 @@
+/*
+
+  Copyright 2017 Loopring Project Ltd (Loopring Foundation).
+
+  Licensed under the Apache License, Version 2.0 (the "License");
+  you may not use this file except in compliance with the License.
+  You may obtain a copy of the License at
+
+  http://www.apache.org/licenses/LICENSE-2.0
+
+  Unless required by applicable law or agreed to in writing, software
+  distributed under the License is distributed on an "AS IS" BASIS,
+  WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+  See the License for the specific language governing permissions and
+  limitations under the License.
+
+*/
+
+package gateway
+
+import (
+	"errors"
+	"fmt"
+	"github.com/Loopring/relay-cluster/dao"
+	"github.com/Loopring/relay-cluster/market"
+	"github.com/Loopring/relay-lib/crypto"
+	"github.com/Loopring/relay-lib/log"
+	util "github.com/Loopring/relay-lib/marketutil"
+	"github.com/Loopring/relay-lib/types"
+	"github.com/ethereum/go-ethereum/common"
+	"math"
+	"strconv"
+	"time"
+)
+
+const (
+	ORDER_SCHEDULE_ACTION_PAUSE  = "pause"
+	ORDER_SCHEDULE_ACTION_RESUME = "resume"
+	ORDER_SCHEDULE_ACTION_CANCEL = "cancel"
+)
+
+type ScheduledOrderRequest struct {
+	Order       types.OrderJsonRequest `json:"order"`
+	ReleaseTime int64                  `json:"releaseTime"`
+}
+
+type OrderScheduleRequest struct {
+	Orders         []ScheduledOrderRequest `json:"orders"`
+	PriceSource    string                  `json:"priceSource"`
+	ReferencePrice float64                 `json:"referencePrice"`
+	MaxDeviation   float64                 `json:"maxDeviation"`
+}
+
+type OrderScheduleQuery struct {
+	ScheduleId string `json:"scheduleId"`
+	Owner      string `json:"owner"`
+	Market     string `json:"market"`
+	Status     string `json:"status"`
+	PageIndex  int    `json:"pageIndex"`
+	PageSize   int    `json:"pageSize"`
+}
+
+type OrderScheduleActionQuery struct {
+	Sign           SignInfo `json:"sign"`
+	ScheduleId     string   `json:"scheduleId"`
+	ReferencePrice float64  `json:"referencePrice"`
+}
+
+// 签名内容包含操作类型、scheduleId及参考价格, 签名不能用于其他计划或其他操作
+func (req *OrderScheduleActionQuery) SignHash(action string) common.Hash {
+	h := &common.Hash{}
+	hashBytes := crypto.GenerateHash(
+		[]byte(action),
+		common.HexToAddress(req.Sign.Owner).Bytes(),
+		common.HexToHash(req.ScheduleId).Bytes(),
+		[]byte(strconv.FormatFloat(req.ReferencePrice, 'f', -1, 64)),
+		[]byte(req.Sign.Timestamp),
+	)
+	h.SetBytes(hashBytes)
+	return *h
+}
+
+func verifyOrderScheduleSign(req *OrderScheduleActionQuery, action string) error {
+	if !common.IsHexAddress(req.Sign.Owner) {
+		return errors.New("owner isn't a valid hex-address")
+	}
+	ts, err := strconv.ParseInt(req.Sign.Timestamp, 10, 64)
+	if err != nil {
+		return errors.New("timestamp isn't a valid unix time")
+	}
+	if math.Abs(float64(time.Now().Unix()-ts)) > 60*10 {
+		return errors.New("timestamp had expired")
+	}
+
+	sig, _ := crypto.VRSToSig(req.Sign.V, types.HexToBytes32(req.Sign.R).Bytes(), types.HexToBytes32(req.Sign.S).Bytes())
+	hash := req.SignHash(action)
+	if addressBytes, err := crypto.SigToAddress(hash.Bytes(), sig); nil != err {
+		log.Errorf("order schedule signer address error:%s", err.Error())
+		return errors.New("sign is incorrect")
+	} else if common.BytesToAddress(addressBytes) != common.HexToAddress(req.Sign.Owner) {
+		return errors.New("sign address not matched")
+	}
+	return nil
+}
+
+type OrderScheduleJsonResult struct {
+	dao.OrderSchedule
+	Orders []dao.ScheduledOrder `json:"orders"`
+}
+
+// 预签名订单按releaseTime由OrderScheduler依次提交, 返回scheduleId
+func (w *WalletServiceImpl) SubmitOrderSchedule(req OrderScheduleRequest) (scheduleId string, err error) {
+	if len(req.Orders) == 0 {
+		return scheduleId, errors.New("schedule has no orders")
+	}
+	if req.MaxDeviation < 0 || req.ReferencePrice < 0 {
+		return scheduleId, errors.New("max deviation and reference price can't be negative")
+	}
+	if req.PriceSource == "" {
+		req.PriceSource = market.PriceSourceLoopring
+	}
+	if !market.IsValidPriceSource(req.PriceSource) {
+		return scheduleId, errors.New("unsupported price source")
+	}
+
+	var (
+		scheduled []*dao.ScheduledOrder
+		first     *types.Order
+		hashes    []byte
+	)
+	for i, v := range req.Orders {
+		if v.ReleaseTime <= 0 {
+			return scheduleId, fmt.Errorf("release time of order %d can't be null", i)
+		}
+		order := types.ToOrder(&req.Orders[i].Order)
+		order.Hash = order.GenerateHash()
+
+		if err := verifyOrderSigner(w.rds, order); err != nil {
+			return scheduleId, fmt.Errorf("order %s %s", order.Hash.Hex(), err.Error())
+		}
+		if order.ValidUntil == nil || order.ValidUntil.Int64() <= v.ReleaseTime {
+			return scheduleId, fmt.Errorf("order %s expires before release time", order.Hash.Hex())
+		}
+
+		order.Market, err = util.WrapMarketByAddress(order.TokenB.Hex(), order.TokenS.Hex())
+		if err != nil {
+			return scheduleId, err
+		}
+		order.Side = util.GetSide(order.TokenS.Hex(), order.TokenB.Hex())
+		if first == nil {
+			first = order
+		} else if order.Owner != first.Owner || order.Market != first.Market || order.Side != first.Side {
+			return scheduleId, errors.New("orders in schedule must have the same owner, market and side")
+		}
+
+		if _, err := w.orderViewer.GetOrderByHash(order.Hash); err == nil {
+			return scheduleId, fmt.Errorf("order %s existed, please not submit again", order.Hash.Hex())
+		}
+
+		item := &dao.ScheduledOrder{}
+		if err = item.ConvertDown(order); err != nil {
+			return scheduleId, err
+		}
+		item.ReleaseTime = v.ReleaseTime
+		item.Status = dao.SCHEDULED_ORDER_PENDING
+		scheduled = append(scheduled, item)
+		hashes = append(hashes, order.Hash.Bytes()...)
+	}
+	scheduleId = common.BytesToHash(crypto.GenerateHash(hashes)).Hex()
+	if _, err := w.rds.GetOrderSchedule(scheduleId); err == nil {
+		return scheduleId, errors.New("schedule existed, please not submit again")
+	}
+
+	now := time.Now().Unix()
+	var saved []*dao.ScheduledOrder
+	for _, v := range scheduled {
+		v.ScheduleId = scheduleId
+		v.UpdateTime = now
+		if err = w.rds.Add(v); err != nil {
+			w.rollbackScheduledOrders(saved)
+			return scheduleId, err
+		}
+		saved = append(saved, v)
+	}
+
+	// 计划最后入库, 保存完成前scheduler不会释放其中的订单
+	schedule := &dao.OrderSchedule{
+		ScheduleId:     scheduleId,
+		Owner:          first.Owner.Hex(),
+		Market:         first.Market,
+		Side:           first.Side,
+		PriceSource:    req.PriceSource,
+		ReferencePrice: req.ReferencePrice,
+		MaxDeviation:   req.MaxDeviation,
+		Status:         dao.SCHEDULE_STATUS_ACTIVE,
+		CreateTime:     now,
+		UpdateTime:     now,
+	}
+	if err = w.rds.Add(schedule); err != nil {
+		w.rollbackScheduledOrders(saved)
+		return scheduleId, err
+	}
+
+	return scheduleId, nil
+}
+
+func (w *WalletServiceImpl) rollbackScheduledOrders(saved []*dao.ScheduledOrder) {
+	for _, v := range saved {
+		w.rds.Del(v)
+	}
+}
+
+func (w *WalletServiceImpl) GetOrderSchedule(query OrderScheduleQuery) (res OrderScheduleJsonResult, err error) {
+	if query.ScheduleId == "" {
+		return res, errors.New("schedule id can't be null")
+	}
+
+	schedule, err := w.rds.GetOrderSchedule(common.HexToHash(query.ScheduleId).Hex())
+	if err != nil {
+		return res, errors.New("order schedule not found")
+	}
+
+	orders, err := w.rds.GetScheduledOrders(schedule.ScheduleId)
+	if err != nil {
+		return res, err
+	}
+
+	return OrderScheduleJsonResult{OrderSchedule: schedule, Orders: orders}, nil
+}
+
+func (w *WalletServiceImpl) GetOrderSchedules(query OrderScheduleQuery) (res PageResult, err error) {
+	if !common.IsHexAddress(query.Owner) {
+		return res, errors.New("owner isn't a valid hex-address")
+	}
+
+	queryMap := make(map[string]interface{})
+	queryMap["owner"] = common.HexToAddress(query.Owner).Hex()
+	if query.Market != "" {
+		queryMap["market"] = query.Market
+	}
+	if query.Status != "" {
+		queryMap["status"] = query.Status
+	}
+
+	src, err := w.rds.OrderSchedulePageQuery(queryMap, query.PageIndex, query.PageSize)
+	if err != nil {
+		return res, err
+	}
+
+	return PageResult{Total: src.Total, PageIndex: src.PageIndex, PageSize: src.PageSize, Data: src.Data}, nil
+}
+
+func (w *WalletServiceImpl) PauseOrderSchedule(req OrderScheduleActionQuery) (res string, err error) {
+	schedule, err := w.signedOrderSchedule(req, ORDER_SCHEDULE_ACTION_PAUSE)
+	if err != nil {
+		return res, err
+	}
+
+	if w.rds.UpdateOrderScheduleStatus(schedule.ScheduleId, dao.SCHEDULE_STATUS_ACTIVE, dao.SCHEDULE_STATUS_PAUSED, "paused by owner") == 0 {
+		return res, errors.New("only active schedule can be paused")
+	}
+	return schedule.ScheduleId, nil
+}
+
+// 恢复时可重新指定参考价格, 否则仍以原参考价格判断偏离
+func (w *WalletServiceImpl) ResumeOrderSchedule(req OrderScheduleActionQuery) (res string, err error) {
+	schedule, err := w.signedOrderSchedule(req, ORDER_SCHEDULE_ACTION_RESUME)
+	if err != nil {
+		return res, err
+	}
+	if schedule.Status != dao.SCHEDULE_STATUS_PAUSED {
+		return res, errors.New("only paused schedule can be resumed")
+	}
+
+	if req.ReferencePrice > 0 {
+		if err = w.rds.SetOrderScheduleReferencePrice(schedule.ScheduleId, req.ReferencePrice); err != nil {
+			return res, err
+		}
+	}
+	if w.rds.UpdateOrderScheduleStatus(schedule.ScheduleId, dao.SCHEDULE_STATUS_PAUSED, dao.SCHEDULE_STATUS_ACTIVE, "") == 0 {
+		return res, errors.New("only paused schedule can be resumed")
+	}
+	return schedule.ScheduleId, nil
+}
+
+// 取消计划只影响未释放的订单, 已提交的订单需单独取消
+func (w *WalletServiceImpl) CancelOrderSchedule(req OrderScheduleActionQuery) (res string, err error) {
+	schedule, err := w.signedOrderSchedule(req, ORDER_SCHEDULE_ACTION_CANCEL)
+	if err != nil {
+		return res, err
+	}
+
+	if schedule.Status != dao.SCHEDULE_STATUS_ACTIVE && schedule.Status != dao.SCHEDULE_STATUS_PAUSED {
+		return res, errors.New("only active or paused schedule can be cancelled")
+	}
+	if w.rds.UpdateOrderScheduleStatus(schedule.ScheduleId, schedule.Status, dao.SCHEDULE_STATUS_CANCELLED, "") == 0 {
+		return res, errors.New("schedule status changed, please retry")
+	}
+	if err = w.rds.CancelPendingScheduledOrders(schedule.ScheduleId); err != nil {
+		return res, err
+	}
+	return schedule.ScheduleId, nil
+}
+
+func (w *WalletServiceImpl) signedOrderSchedule(req OrderScheduleActionQuery, action string) (schedule dao.OrderSchedule, err error) {
+	if err = verifyOrderScheduleSign(&req, action); err != nil {
+		return schedule, err
+	}
+
+	schedule, err = w.rds.GetOrderSchedule(common.HexToHash(req.ScheduleId).Hex())
+	if err != nil {
+		return schedule, errors.New("order schedule not found")
+	}
+	if common.HexToAddress(schedule.Owner) != common.HexToAddress(req.Sign.Owner) {
+		return schedule, errors.New("sign address not matched")
+	}
+	return schedule, nil
+}
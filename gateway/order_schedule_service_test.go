@@ -0,0 +1,84 @@
+/*
+
+  Copyright 2017 Loopring Project Ltd (Loopring Foundation).
+
+  Licensed under the Apache License, Version 2.0 (the "License");
+  you may not use this file except in compliance with the License.
+  You may obtain a copy of the License at
+
+  http://www.apache.org/licenses/LICENSE-2.0
+
+  Unless required by applicable law or agreed to in writing, software
+  distributed under the License is distributed on an "AS IS" BASIS,
+  WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+  See the License for the specific language governing permissions and
+  limitations under the License.
+
+*/
+
+package gateway
+
+import (
+	"github.com/Loopring/relay-lib/crypto"
+	"github.com/Loopring/relay-lib/types"
+	"github.com/ethereum/go-ethereum/common"
+	"strconv"
+	"testing"
+	"time"
+)
+
+const testSignerKey = "4c0883a69102937d6231471b5dbb6204fe5129617082792ae468d01a3f362318"
+
+func newTestSigner(t *testing.T) crypto.EthPrivateKeyCrypto {
+	signer, err := crypto.NewPrivateKeyCrypto(false, testSignerKey)
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	crypto.Initialize(signer)
+	return signer
+}
+
+func signTestHash(t *testing.T, signer crypto.EthPrivateKeyCrypto, sign *SignInfo, hash common.Hash) {
+	sig, err := signer.Sign(hash.Bytes(), signer.Address())
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	v, r, s := crypto.SigToVRS(sig)
+	sign.V, sign.R, sign.S = v, types.BytesToBytes32(r).Hex(), types.BytesToBytes32(s).Hex()
+}
+
+func TestVerifyOrderScheduleSign(t *testing.T) {
+	signer := newTestSigner(t)
+	req := &OrderScheduleActionQuery{
+		ScheduleId:     "0x2b6e2d6f9b6ecab0b6bbd70e5b0f1f2c9c0a6a39a8b7bf3ac62e6a7ed85e19e3",
+		ReferencePrice: 0.00088,
+		Sign:           SignInfo{Owner: signer.Address().Hex(), Timestamp: strconv.FormatInt(time.Now().Unix(), 10)},
+	}
+	signTestHash(t, signer, &req.Sign, req.SignHash(ORDER_SCHEDULE_ACTION_RESUME))
+
+	if err := verifyOrderScheduleSign(req, ORDER_SCHEDULE_ACTION_RESUME); err != nil {
+		t.Fatal(err.Error())
+	}
+	if err := verifyOrderScheduleSign(req, ORDER_SCHEDULE_ACTION_CANCEL); err == nil {
+		t.Fatalf("resume sign should not be accepted for cancel")
+	}
+
+	other := *req
+	other.ScheduleId = "0x7e2a21a0f6a5ebd0a8c6b6b8e1a9e8e7b4d0de9d86e0a64b27a7c5f70a1ff4e2"
+	if err := verifyOrderScheduleSign(&other, ORDER_SCHEDULE_ACTION_RESUME); err == nil {
+		t.Fatalf("sign should not be accepted for other schedule")
+	}
+
+	other = *req
+	other.ReferencePrice = 0.001
+	if err := verifyOrderScheduleSign(&other, ORDER_SCHEDULE_ACTION_RESUME); err == nil {
+		t.Fatalf("sign should not be accepted with other reference price")
+	}
+
+	expired := *req
+	expired.Sign.Timestamp = strconv.FormatInt(time.Now().Unix()-3600, 10)
+	signTestHash(t, signer, &expired.Sign, expired.SignHash(ORDER_SCHEDULE_ACTION_RESUME))
+	if err := verifyOrderScheduleSign(&expired, ORDER_SCHEDULE_ACTION_RESUME); err == nil {
+		t.Fatalf("expired sign should not be accepted")
+	}
+}
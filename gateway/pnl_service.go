@@ -0,0 +1,49 @@
+/*
+
+  Copyright 2017 Loopring Project Ltd (Loopring Foundation).
+
+  Licensed under the Apache License, Version 2.0 (the "License");
+  you may not use this file except in compliance with the License.
+  You may obtain a copy of the License at
+
+  http://www.apache.org/licenses/LICENSE-2.0
+
+  Unless required by applicable law or agreed to in writing, software
+  distributed under the License is distributed on an "AS IS" BASIS,
+  WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+  See the License for the specific language governing permissions and
+  limitations under the License.
+
+*/
+
+package gateway
+
+import (
+	"errors"
+	"github.com/Loopring/relay-cluster/accountmanager"
+	"github.com/ethereum/go-ethereum/common"
+	"time"
+)
+
+type PnlQuery struct {
+	Owner  string `json:"owner"`
+	Start  int64  `json:"start"`
+	End    int64  `json:"end"`
+	Method string `json:"method"`
+}
+
+// 已实现盈亏只统计[start, end]内的成交, 成本从第一笔成交开始计算
+func (w *WalletServiceImpl) GetPnl(query PnlQuery) (res *accountmanager.PnlReport, err error) {
+	if !common.IsHexAddress(query.Owner) {
+		return nil, errors.New("owner isn't a valid hex-address")
+	}
+	if query.End <= 0 {
+		query.End = time.Now().Unix()
+	}
+	if query.Start < 0 || query.Start > query.End {
+		return nil, errors.New("start can't be bigger than end")
+	}
+
+	provider := accountmanager.NewPnlPriceProvider(w.rds, w.marketCap)
+	return accountmanager.BuildPnlReport(w.rds, provider, common.HexToAddress(query.Owner), query.Start, query.End, query.Method)
+}
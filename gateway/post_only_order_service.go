@@ -0,0 +1,100 @@
+/*
+
+  Copyright 2017 Loopring Project Ltd (Loopring Foundation).
+
+  Licensed under the Apache License, Version 2.0 (the "License");
+  you may not use this file except in compliance with the License.
+  You may obtain a copy of the License at
+
+  http://www.apache.org/licenses/LICENSE-2.0
+
+  Unless required by applicable law or agreed to in writing, software
+  distributed under the License is distributed on an "AS IS" BASIS,
+  WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+  See the License for the specific language governing permissions and
+  limitations under the License.
+
+*/
+
+package gateway
+
+import (
+	"errors"
+	"fmt"
+	"github.com/Loopring/relay-cluster/dao"
+	omcm "github.com/Loopring/relay-cluster/ordermanager/common"
+	"github.com/Loopring/relay-lib/types"
+	"time"
+)
+
+// 订单被拒绝的原因码, 通过jsonrpc error code返回
+const (
+	ORDER_REJECT_POST_ONLY_CROSSING = 40001
+)
+
+type OrderRejectError struct {
+	Code   int
+	Reason string
+}
+
+func (e *OrderRejectError) Error() string {
+	return e.Reason
+}
+
+func (e *OrderRejectError) ErrorCode() int {
+	return e.Code
+}
+
+type PostOnlyOrderRequest struct {
+	Order types.OrderJsonRequest `json:"order"`
+}
+
+// post-only订单与当前对手盘最优价格交叉时拒绝, 保证订单只作为maker挂单
+func (w *WalletServiceImpl) SubmitPostOnlyOrder(req PostOnlyOrderRequest) (orderHash string, err error) {
+	req.Order.OrderType = types.ORDER_TYPE_MARKET
+	order := types.ToOrder(&req.Order)
+	order.Hash = order.GenerateHash()
+	orderHash = order.Hash.Hex()
+
+	if _, err := w.rds.GetPostOnlyOrderByHash(orderHash); err == nil {
+		return orderHash, errors.New("order existed, please not submit again")
+	}
+
+	book, err := w.orderViewer.GetOrderBook(order.DelegateAddress, order.Protocol, order.TokenB, order.TokenS, 1)
+	if err != nil {
+		return orderHash, err
+	}
+	if err = checkPostOnlyCrossing(order, book); err != nil {
+		return orderHash, err
+	}
+
+	postOnly := &dao.PostOnlyOrder{
+		OrderHash:  orderHash,
+		Owner:      order.Owner.Hex(),
+		CreateTime: time.Now().Unix(),
+	}
+	if err = w.rds.Add(postOnly); err != nil {
+		return orderHash, err
+	}
+
+	if orderHash, err = HandleInputOrder(order); err != nil {
+		w.rds.Del(postOnly)
+	}
+	return orderHash, err
+}
+
+// book为对手盘(tokenS与order.TokenB相同), 按价格从优到劣排序
+func checkPostOnlyCrossing(order *types.Order, book []types.OrderState) error {
+	if len(book) == 0 {
+		return nil
+	}
+
+	best := book[0].RawOrder
+	if omcm.IsOrderCrossing(order, &best) {
+		return &OrderRejectError{
+			Code:   ORDER_REJECT_POST_ONLY_CROSSING,
+			Reason: fmt.Sprintf("post-only order would cross the best opposite order %s", best.Hash.Hex()),
+		}
+	}
+	return nil
+}
@@ -0,0 +1,54 @@
+/*
+
+  Copyright 2017 Loopring Project Ltd (Loopring Foundation).
+
+  Licensed under the Apache License, Version 2.0 (the "License");
+  you may not use this file except in compliance with the License.
+  You may obtain a copy of the License at
+
+  http://www.apache.org/licenses/LICENSE-2.0
+
+  Unless required by applicable law or agreed to in writing, software
+  distributed under the License is distributed on an "AS IS" BASIS,
+  WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+  See the License for the specific language governing permissions and
+  limitations under the License.
+
+*/
+
+package gateway
+
+import (
+	"github.com/Loopring/relay-lib/types"
+	"github.com/ethereum/go-ethereum/common"
+	"math/big"
+	"testing"
+)
+
+func TestCheckPostOnlyCrossing(t *testing.T) {
+	lrc := common.HexToAddress("0xEF68e7C694F40c8202821eDF525dE3782458639f")
+	weth := common.HexToAddress("0xC02aaA39b223FE8D0A0e5C4F27eAD9083C756Cc2")
+	order := &types.Order{TokenS: lrc, TokenB: weth, AmountS: big.NewInt(100), AmountB: big.NewInt(1)}
+
+	newBook := func(amountS, amountB int64) []types.OrderState {
+		state := types.OrderState{}
+		state.RawOrder = types.Order{TokenS: weth, TokenB: lrc, AmountS: big.NewInt(amountS), AmountB: big.NewInt(amountB)}
+		return []types.OrderState{state}
+	}
+
+	if err := checkPostOnlyCrossing(order, nil); err != nil {
+		t.Fatalf("empty book should not reject, got %s", err.Error())
+	}
+	if err := checkPostOnlyCrossing(order, newBook(1, 200)); err != nil {
+		t.Fatalf("not crossing order should not reject, got %s", err.Error())
+	}
+
+	err := checkPostOnlyCrossing(order, newBook(1, 100))
+	rejectErr, ok := err.(*OrderRejectError)
+	if !ok {
+		t.Fatalf("crossing order should be rejected with OrderRejectError, got %v", err)
+	}
+	if rejectErr.ErrorCode() != ORDER_REJECT_POST_ONLY_CROSSING || rejectErr.ErrorCode() != 40001 {
+		t.Fatalf("expected reject code 40001, got %d", rejectErr.ErrorCode())
+	}
+}
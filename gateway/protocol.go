@@ -0,0 +1,94 @@
+/*
+
+  Copyright 2017 Loopring Project Ltd (Loopring Foundation).
+
+  Licensed under the Apache License, Version 2.0 (the "License");
+  you may not use this file except in compliance with the License.
+  You may obtain a copy of the License at
+
+  http://www.apache.org/licenses/LICENSE-2.0
+
+  Unless required by applicable law or agreed to in writing, software
+  distributed under the License is distributed on an "AS IS" BASIS,
+  WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+  See the License for the specific language governing permissions and
+  limitations under the License.
+
+*/
+
+package gateway
+
+import (
+	"fmt"
+	"github.com/Loopring/relay-lib/eth/loopringaccessor"
+	"github.com/Loopring/relay-lib/log"
+	"github.com/Loopring/relay-lib/types"
+	"github.com/ethereum/go-ethereum/common"
+	"sort"
+)
+
+type ProtocolInfo struct {
+	Version         string `json:"version"`
+	Protocol        string `json:"protocol"`
+	DelegateAddress string `json:"delegateAddress"`
+	LrcToken        string `json:"lrcToken"`
+	TokenRegistry   string `json:"tokenRegistry"`
+	Deprecated      bool   `json:"deprecated"`
+}
+
+// 配置项可以是protocol版本号或者合约地址
+func parseDeprecatedProtocols(list []string, protocols map[common.Address]*loopringaccessor.ProtocolAddress) map[common.Address]bool {
+	deprecated := make(map[common.Address]bool)
+	for _, v := range list {
+		if common.IsHexAddress(v) {
+			deprecated[common.HexToAddress(v)] = true
+			continue
+		}
+
+		found := false
+		for addr, protocol := range protocols {
+			if protocol.Version == v {
+				deprecated[addr] = true
+				found = true
+			}
+		}
+		if !found {
+			log.Errorf("gateway, deprecated protocol:%s not found in loopring protocol config", v)
+		}
+	}
+	return deprecated
+}
+
+func IsProtocolDeprecated(protocol common.Address) bool {
+	return gateway.deprecatedProtocols[protocol]
+}
+
+// 已废弃的protocol不再接收新订单, 已有订单仍可被撮合直到完成或过期
+type ProtocolFilter struct {
+}
+
+func (f *ProtocolFilter) filter(o *types.Order) (bool, error) {
+	if IsProtocolDeprecated(o.Protocol) {
+		return false, fmt.Errorf("protocol %s is deprecated, new orders are not accepted", o.Protocol.Hex())
+	}
+	return true, nil
+}
+
+func (w *WalletServiceImpl) GetProtocols() (res []ProtocolInfo, err error) {
+	res = make([]ProtocolInfo, 0)
+	for addr, protocol := range loopringaccessor.ProtocolAddresses() {
+		res = append(res, ProtocolInfo{
+			Version:         protocol.Version,
+			Protocol:        addr.Hex(),
+			DelegateAddress: protocol.DelegateAddress.Hex(),
+			LrcToken:        protocol.LrcTokenAddress.Hex(),
+			TokenRegistry:   protocol.TokenRegistryAddress.Hex(),
+			Deprecated:      IsProtocolDeprecated(addr),
+		})
+	}
+
+	sort.Slice(res, func(i, j int) bool {
+		return res[i].Version < res[j].Version
+	})
+	return res, nil
+}
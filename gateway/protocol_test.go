@@ -0,0 +1,49 @@
+/*
+
+  Copyright 2017 Loopring Project Ltd (Loopring Foundation).
+
+  Licensed under the Apache License, Version 2.0 (the "License");
+  you may not use this file except in compliance with the License.
+  You may obtain a copy of the License at
+
+  http://www.apache.org/licenses/LICENSE-2.0
+
+  Unless required by applicable law or agreed to in writing, software
+  distributed under the License is distributed on an "AS IS" BASIS,
+  WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+  See the License for the specific language governing permissions and
+  limitations under the License.
+
+*/
+
+package gateway
+
+import (
+	"github.com/Loopring/relay-lib/eth/loopringaccessor"
+	"github.com/ethereum/go-ethereum/common"
+	"testing"
+)
+
+func TestParseDeprecatedProtocols(t *testing.T) {
+	delegate := common.HexToAddress("0x17233e07c67d086464fD408148c3ABB56245FA64")
+	v1 := common.HexToAddress("0x8d8812b72d1e4ffCeC158D25f56748b7d67c1e78")
+	v1b := common.HexToAddress("0x781870080C8C24a2FD6882296c49c837b06A65E6")
+	v2 := common.HexToAddress("0x5567ee920f7E62274284985D793344351A00142B")
+	protocols := map[common.Address]*loopringaccessor.ProtocolAddress{
+		v1:  {Version: "v1.0", ContractAddress: v1, DelegateAddress: delegate},
+		v1b: {Version: "v1.0", ContractAddress: v1b, DelegateAddress: delegate},
+		v2:  {Version: "v1.5", ContractAddress: v2, DelegateAddress: delegate},
+	}
+
+	// 版本号匹配该版本的所有protocol
+	deprecated := parseDeprecatedProtocols([]string{"v1.0"}, protocols)
+	if !deprecated[v1] || !deprecated[v1b] || deprecated[v2] {
+		t.Fatalf("version should deprecate all protocols of that version only, got %v", deprecated)
+	}
+
+	// 合约地址只匹配该protocol, 共用的delegate不受影响
+	deprecated = parseDeprecatedProtocols([]string{v2.Hex()}, protocols)
+	if len(deprecated) != 1 || !deprecated[v2] || deprecated[delegate] {
+		t.Fatalf("address should deprecate that protocol only, got %v", deprecated)
+	}
+}
@@ -0,0 +1,49 @@
+/*
+
+  Copyright 2017 Loopring Project Ltd (Loopring Foundation).
+
+  Licensed under the Apache License, Version 2.0 (the "License");
+  you may not use this file except in compliance with the License.
+  You may obtain a copy of the License at
+
+  http://www.apache.org/licenses/LICENSE-2.0
+
+  Unless required by applicable law or agreed to in writing, software
+  distributed under the License is distributed on an "AS IS" BASIS,
+  WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+  See the License for the specific language governing permissions and
+  limitations under the License.
+
+*/
+
+package gateway
+
+import (
+	"github.com/ethereum/go-ethereum/common"
+)
+
+type RingSubmitStatQuery struct {
+	OrderHash      string `json:"orderHash"`
+	Owner          string `json:"owner"`
+	OnlyIneligible bool   `json:"onlyIneligible"`
+	PageIndex      int    `json:"pageIndex"`
+	PageSize       int    `json:"pageSize"`
+}
+
+// 订单参与提交环路的失败统计, 供运营人员排查
+func (w *WalletServiceImpl) GetRingSubmitStats(query RingSubmitStatQuery) (res PageResult, err error) {
+	queryMap := make(map[string]interface{})
+	if query.OrderHash != "" {
+		queryMap["order_hash"] = common.HexToHash(query.OrderHash).Hex()
+	}
+	if common.IsHexAddress(query.Owner) {
+		queryMap["owner"] = common.HexToAddress(query.Owner).Hex()
+	}
+
+	src, err := w.rds.RingSubmitStatPageQuery(queryMap, query.OnlyIneligible, query.PageIndex, query.PageSize)
+	if err != nil {
+		return res, err
+	}
+
+	return PageResult{Total: src.Total, PageIndex: src.PageIndex, PageSize: src.PageSize, Data: src.Data}, nil
+}
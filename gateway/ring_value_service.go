@@ -0,0 +1,204 @@
+/*
+
+  Copyright 2017 Loopring Project Ltd (Loopring Foundation).
+
+  Licensed under the Apache License, Version 2.0 (the "License");
+  you may not use this file except in compliance with the License.
+  You may obtain a copy of the License at
+
+  http://www.apache.org/licenses/LICENSE-2.0
+
+  Unless required by applicable law or agreed to in writing, software
+  distributed under the License is distributed on an "AS IS" BASIS,
+  WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+  See the License for the specific language governing permissions and
+  limitations under the License.
+
+*/
+
+package gateway
+
+import (
+	"github.com/Loopring/relay-cluster/dao"
+	"github.com/Loopring/relay-lib/log"
+	util "github.com/Loopring/relay-lib/marketutil"
+	"github.com/ethereum/go-ethereum/common"
+	"math/big"
+	"sort"
+	"time"
+)
+
+// 价格时间与区块时间的最大误差
+const ringPriceTolerance = 3600
+
+type RingMinedValueQuery struct {
+	DelegateAddress string `json:"delegateAddress"`
+	RingIndex       string `json:"ringIndex"`
+	Currency        string `json:"currency"`
+}
+
+type FillValue struct {
+	Fill           dao.FillEvent `json:"fill"`
+	AmountSValue   float64       `json:"amountSValue"`
+	AmountBValue   float64       `json:"amountBValue"`
+	LrcFeeValue    float64       `json:"lrcFeeValue"`
+	LrcRewardValue float64       `json:"lrcRewardValue"`
+	SplitSValue    float64       `json:"splitSValue"`
+	SplitBValue    float64       `json:"splitBValue"`
+}
+
+type RingGasCost struct {
+	GasUsed   string  `json:"gasUsed"`
+	GasPrice  string  `json:"gasPrice"`
+	Cost      string  `json:"cost"`
+	CostValue float64 `json:"costValue"`
+}
+
+type RingMinedValueDetail struct {
+	RingInfo            RingMinedInfo `json:"ringInfo"`
+	Currency            string        `json:"currency"`
+	PriceTime           int64         `json:"priceTime"`
+	MissingPrices       []string      `json:"missingPrices"`
+	Fills               []FillValue   `json:"fills"`
+	TotalLrcFeeValue    float64       `json:"totalLrcFeeValue"`
+	TotalLrcRewardValue float64       `json:"totalLrcRewardValue"`
+	TotalSplitValue     float64       `json:"totalSplitValue"`
+	Gas                 RingGasCost   `json:"gas"`
+	MinerProfit         float64       `json:"minerProfit"`
+}
+
+// 环路详情, fill的数量及费用按currency计价, minerProfit = lrcFee + split - lrcReward - gas
+// 按环路区块时间计价, 缺少价格的token计价为0并返回在missingPrices中
+func (w *WalletServiceImpl) GetRingMinedValueDetail(query RingMinedValueQuery) (res RingMinedValueDetail, err error) {
+	detail, err := w.GetRingMinedDetail(RingMinedQuery{DelegateAddress: query.DelegateAddress, RingIndex: query.RingIndex})
+	if err != nil {
+		return res, err
+	}
+
+	currency := query.Currency
+	if currency == "" {
+		currency = "USD"
+	}
+
+	res.RingInfo = detail.RingInfo
+	res.Currency = currency
+	res.PriceTime = detail.RingInfo.Time
+	res.MissingPrices = make([]string, 0)
+	res.Fills = make([]FillValue, 0)
+
+	valuer := &ringValuer{w: w, currency: currency, time: res.PriceTime, missing: make(map[common.Address]bool)}
+	lrcAddress := util.AllTokens["LRC"].Protocol
+	for _, f := range detail.Fills {
+		fv := FillValue{Fill: f}
+		fv.AmountSValue = valuer.value(common.HexToAddress(f.TokenS), f.AmountS)
+		fv.AmountBValue = valuer.value(common.HexToAddress(f.TokenB), f.AmountB)
+		fv.LrcFeeValue = valuer.value(lrcAddress, f.LrcFee)
+		fv.LrcRewardValue = valuer.value(lrcAddress, f.LrcReward)
+		fv.SplitSValue = valuer.value(common.HexToAddress(f.TokenS), f.SplitS)
+		fv.SplitBValue = valuer.value(common.HexToAddress(f.TokenB), f.SplitB)
+
+		res.TotalLrcFeeValue += fv.LrcFeeValue
+		res.TotalLrcRewardValue += fv.LrcRewardValue
+		res.TotalSplitValue += fv.SplitSValue + fv.SplitBValue
+		res.Fills = append(res.Fills, fv)
+	}
+
+	res.Gas = w.ringGasCost(res.RingInfo.TxHash, valuer)
+	for token := range valuer.missing {
+		res.MissingPrices = append(res.MissingPrices, token.Hex())
+	}
+	sort.Strings(res.MissingPrices)
+	res.MinerProfit = res.TotalLrcFeeValue + res.TotalSplitValue - res.TotalLrcRewardValue - res.Gas.CostValue
+
+	return res, nil
+}
+
+// 环路交易的gas消耗, 从TransactionEntity中获取
+func (w *WalletServiceImpl) ringGasCost(txhash string, valuer *ringValuer) (cost RingGasCost) {
+	cost.Cost = "0"
+
+	txs, err := w.rds.GetTxEntity([]string{txhash})
+	if err != nil || len(txs) == 0 {
+		log.Debugf("ring tx:%s entity not found", txhash)
+		return cost
+	}
+
+	tx := txs[0]
+	gasUsed, ok1 := new(big.Int).SetString(tx.GasUsed, 0)
+	gasPrice, ok2 := new(big.Int).SetString(tx.GasPrice, 0)
+	if !ok1 || !ok2 {
+		return cost
+	}
+
+	cost.GasUsed = gasUsed.String()
+	cost.GasPrice = gasPrice.String()
+	cost.Cost = new(big.Int).Mul(gasUsed, gasPrice).String()
+	cost.CostValue = valuer.value(util.AllTokens["WETH"].Protocol, cost.Cost)
+
+	return cost
+}
+
+// 按区块时间计价, 价格来源:
+// 1. currency为USD时使用成交时记录的价格, 见accountmanager.FillPriceRecorder
+// 2. 区块时间与当前时间相差不超过ringPriceTolerance时使用marketcap当前价格
+// 都不满足时记录到missing中
+type ringValuer struct {
+	w        *WalletServiceImpl
+	currency string
+	time     int64
+	missing  map[common.Address]bool
+}
+
+func (v *ringValuer) value(token common.Address, amount string) float64 {
+	if len(amount) == 0 || amount == "0" {
+		return 0
+	}
+
+	value, ok := new(big.Int).SetString(amount, 0)
+	if !ok {
+		return 0
+	}
+
+	if ret, ok := v.recordedValue(token, value); ok {
+		return ret
+	}
+
+	if !withinPriceTolerance(time.Now().Unix(), v.time) {
+		v.missing[token] = true
+		return 0
+	}
+
+	legalValue, err := v.w.marketCap.LegalCurrencyValueByCurrency(token, new(big.Rat).SetInt(value), v.currency)
+	if err != nil {
+		log.Debugf("get legal currency value of token:%s error:%s", token.Hex(), err.Error())
+		v.missing[token] = true
+		return 0
+	}
+
+	ret, _ := legalValue.Float64()
+	return ret
+}
+
+func (v *ringValuer) recordedValue(token common.Address, amount *big.Int) (float64, bool) {
+	if v.currency != "USD" {
+		return 0, false
+	}
+
+	price, err := v.w.rds.GetTokenUsdPriceAt(token.Hex(), v.time)
+	if err != nil || !withinPriceTolerance(price.Time, v.time) {
+		return 0, false
+	}
+
+	t, err := util.AddressToToken(token)
+	if err != nil {
+		return 0, false
+	}
+
+	ret, _ := new(big.Rat).Mul(new(big.Rat).SetFrac(amount, t.Decimals), new(big.Rat).SetFloat64(price.Price)).Float64()
+	return ret, true
+}
+
+func withinPriceTolerance(priceTime, blockTime int64) bool {
+	diff := priceTime - blockTime
+	return diff <= ringPriceTolerance && diff >= -ringPriceTolerance
+}
@@ -0,0 +1,47 @@
+/*
+
+  Copyright 2017 Loopring Project Ltd (Loopring Foundation).
+
+  Licensed under the Apache License, Version 2.0 (the "License");
+  you may not use this file except in compliance with the License.
+  You may obtain a copy of the License at
+
+  http://www.apache.org/licenses/LICENSE-2.0
+
+  Unless required by applicable law or agreed to in writing, software
+  distributed under the License is distributed on an "AS IS" BASIS,
+  WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+  See the License for the specific language governing permissions and
+  limitations under the License.
+
+*/
+
+package gateway
+
+import (
+	"github.com/ethereum/go-ethereum/common"
+	"testing"
+	"time"
+)
+
+func TestWithinPriceTolerance(t *testing.T) {
+	if !withinPriceTolerance(1000+ringPriceTolerance, 1000) || !withinPriceTolerance(1000-ringPriceTolerance, 1000) {
+		t.Fatalf("price within tolerance should be accepted")
+	}
+	if withinPriceTolerance(1001+ringPriceTolerance, 1000) || withinPriceTolerance(999-ringPriceTolerance, 1000) {
+		t.Fatalf("price out of tolerance should be rejected")
+	}
+}
+
+// 历史环路没有记录价格时不使用当前价格, 标记为缺少价格
+func TestRingValuerMissingPrice(t *testing.T) {
+	token := common.HexToAddress("0xEF68e7C694F40c8202821eDF525dE3782458639f")
+	valuer := &ringValuer{currency: "CNY", time: time.Now().Unix() - 2*ringPriceTolerance, missing: make(map[common.Address]bool)}
+
+	if v := valuer.value(token, "0"); v != 0 || len(valuer.missing) != 0 {
+		t.Fatalf("zero amount should not need a price")
+	}
+	if v := valuer.value(token, "0x1b1ae4d6e2ef500000"); v != 0 || !valuer.missing[token] {
+		t.Fatalf("old ring without recorded price should be flagged as missing, value:%f", v)
+	}
+}
@@ -0,0 +1,208 @@
+/*
+
+  Copyright 2017 Loopring Project Ltd (Loopring Foundation).
+
+  Licensed under the Apache License, Version 2.0 (the "License");
+  you may not use this file except in compliance with the License.
+  You may obtain a copy of the License at
+
+  http://www.apache.org/licenses/LICENSE-2.0
+
+  Unless required by applicable law or agreed to in writing, software
+  distributed under the License is distributed on an "AS IS" BASIS,
+  WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+  See the License for the specific language governing permissions and
+  limitations under the License.
+
+*/
+
+package gateway
+
+import (
+	"errors"
+	"fmt"
+	"github.com/Loopring/relay-cluster/dao"
+	"github.com/Loopring/relay-lib/crypto"
+	"github.com/Loopring/relay-lib/log"
+	util "github.com/Loopring/relay-lib/marketutil"
+	"github.com/Loopring/relay-lib/types"
+	"github.com/ethereum/go-ethereum/common"
+	"math"
+	"math/big"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	maxSessionKeyLifetime = 30 * 24 * 3600
+
+	SESSION_KEY_ACTION_REGISTER = "register"
+	SESSION_KEY_ACTION_REVOKE   = "revoke"
+)
+
+type SessionKeyDelegation struct {
+	Sign           SignInfo `json:"sign"`
+	SessionAddress string   `json:"sessionAddress"`
+	ExpireTime     int64    `json:"expireTime"`
+	Markets        []string `json:"markets"`
+	MaxNotional    string   `json:"maxNotional"`
+}
+
+type SessionKeyRevokeQuery struct {
+	Sign           SignInfo `json:"sign"`
+	SessionAddress string   `json:"sessionAddress"`
+}
+
+// 签名内容包含操作类型、会话地址、过期时间及限制条件, 必须由owner主密钥签名
+func (req *SessionKeyDelegation) SignHash() common.Hash {
+	h := &common.Hash{}
+	hashBytes := crypto.GenerateHash(
+		[]byte(SESSION_KEY_ACTION_REGISTER),
+		common.HexToAddress(req.Sign.Owner).Bytes(),
+		common.HexToAddress(req.SessionAddress).Bytes(),
+		common.LeftPadBytes(big.NewInt(req.ExpireTime).Bytes(), 32),
+		[]byte(strings.Join(req.Markets, ",")),
+		[]byte(req.MaxNotional),
+		[]byte(req.Sign.Timestamp),
+	)
+	h.SetBytes(hashBytes)
+	return *h
+}
+
+func (req *SessionKeyRevokeQuery) SignHash() common.Hash {
+	h := &common.Hash{}
+	hashBytes := crypto.GenerateHash(
+		[]byte(SESSION_KEY_ACTION_REVOKE),
+		common.HexToAddress(req.Sign.Owner).Bytes(),
+		common.HexToAddress(req.SessionAddress).Bytes(),
+		[]byte(req.Sign.Timestamp),
+	)
+	h.SetBytes(hashBytes)
+	return *h
+}
+
+// 校验owner主密钥对hash的签名, 返回签名timestamp
+func verifySessionKeySign(sign SignInfo, sessionAddress string, hash common.Hash) (int64, error) {
+	if !common.IsHexAddress(sign.Owner) || !common.IsHexAddress(sessionAddress) {
+		return 0, errors.New("owner or session address isn't a valid hex-address")
+	}
+	ts, err := strconv.ParseInt(sign.Timestamp, 10, 64)
+	if err != nil {
+		return 0, err
+	}
+	if math.Abs(float64(time.Now().Unix()-ts)) > 60*10 {
+		return 0, errors.New("timestamp had expired")
+	}
+
+	sig, _ := crypto.VRSToSig(sign.V, types.HexToBytes32(sign.R).Bytes(), types.HexToBytes32(sign.S).Bytes())
+	if addressBytes, err := crypto.SigToAddress(hash.Bytes(), sig); nil != err {
+		log.Errorf("session key delegation signer address error:%s", err.Error())
+		return 0, errors.New("sign is incorrect")
+	} else if common.BytesToAddress(addressBytes) != common.HexToAddress(sign.Owner) {
+		return 0, errors.New("sign address not matched")
+	}
+	return ts, nil
+}
+
+func (w *WalletServiceImpl) RegisterSessionKey(req SessionKeyDelegation) (res dao.SessionKey, err error) {
+	ts, err := verifySessionKeySign(req.Sign, req.SessionAddress, req.SignHash())
+	if err != nil {
+		return res, err
+	}
+
+	now := time.Now().Unix()
+	if req.ExpireTime <= now || req.ExpireTime > now+maxSessionKeyLifetime {
+		return res, errors.New("expire time must be in the future and within 30 days")
+	}
+	if common.HexToAddress(req.SessionAddress) == common.HexToAddress(req.Sign.Owner) {
+		return res, errors.New("session address can't be the owner")
+	}
+	markets := make([]string, 0)
+	for _, v := range req.Markets {
+		if !util.IsSupportedMarket(v) {
+			return res, errors.New("unsupported market:" + v)
+		}
+		markets = append(markets, strings.ToUpper(v))
+	}
+	var maxNotional float64
+	if req.MaxNotional != "" {
+		if maxNotional, err = strconv.ParseFloat(req.MaxNotional, 64); err != nil || maxNotional < 0 {
+			return res, errors.New("max notional must be a non-negative number")
+		}
+	}
+
+	res = dao.SessionKey{
+		Owner:          common.HexToAddress(req.Sign.Owner).Hex(),
+		SessionAddress: common.HexToAddress(req.SessionAddress).Hex(),
+		Markets:        strings.Join(markets, ","),
+		MaxNotional:    maxNotional,
+		ExpireTime:     req.ExpireTime,
+		Status:         dao.SESSION_KEY_STATUS_ACTIVE,
+		SignTime:       ts,
+		CreateTime:     now,
+		UpdateTime:     now,
+	}
+	if err = w.rds.SaveSessionKey(&res); err != nil {
+		return res, err
+	}
+	return res, nil
+}
+
+func (w *WalletServiceImpl) RevokeSessionKey(req SessionKeyRevokeQuery) (res string, err error) {
+	ts, err := verifySessionKeySign(req.Sign, req.SessionAddress, req.SignHash())
+	if err != nil {
+		return res, err
+	}
+
+	owner := common.HexToAddress(req.Sign.Owner).Hex()
+	session := common.HexToAddress(req.SessionAddress).Hex()
+	if w.rds.RevokeSessionKey(owner, session, ts) == 0 {
+		return res, errors.New("no active session key found or sign timestamp isn't bigger than the last one")
+	}
+	return session, nil
+}
+
+func (w *WalletServiceImpl) GetSessionKeys(query SingleOwner) (res []dao.SessionKey, err error) {
+	if !common.IsHexAddress(query.Owner) {
+		return res, errors.New("owner isn't a valid hex-address")
+	}
+	return w.rds.GetSessionKeys(common.HexToAddress(query.Owner).Hex())
+}
+
+type sessionKeyStore interface {
+	GetSessionKey(owner, sessionAddress string) (dao.SessionKey, error)
+}
+
+// 每次请求都重新查询授权, 撤销或过期后立即失效
+func verifySessionSigner(keys sessionKeyStore, owner, signer common.Address, market string, notional float64) error {
+	key, err := keys.GetSessionKey(owner.Hex(), signer.Hex())
+	if err != nil {
+		return fmt.Errorf("signer %s is neither the owner nor a session key of it", signer.Hex())
+	}
+	if !key.IsValid(time.Now().Unix()) {
+		return fmt.Errorf("session key %s is revoked or expired", signer.Hex())
+	}
+	if !key.AllowMarket(market) {
+		return fmt.Errorf("session key %s isn't allowed in market %s", signer.Hex(), market)
+	}
+	if !key.AllowNotional(notional) {
+		return fmt.Errorf("session key %s notional limit exceeded", signer.Hex())
+	}
+	return nil
+}
+
+// 订单计价币(如LRC-WETH中的WETH)的数量
+func orderNotional(o *types.Order) (float64, error) {
+	token, amount := o.TokenS, o.AmountS
+	if util.GetSide(o.TokenS.Hex(), o.TokenB.Hex()) == util.SideSell {
+		token, amount = o.TokenB, o.AmountB
+	}
+
+	t, err := util.AddressToToken(token)
+	if err != nil {
+		return 0, err
+	}
+	notional, _ := new(big.Rat).SetFrac(amount, t.Decimals).Float64()
+	return notional, nil
+}
@@ -0,0 +1,173 @@
+/*
+
+  Copyright 2017 Loopring Project Ltd (Loopring Foundation).
+
+  Licensed under the Apache License, Version 2.0 (the "License");
+  you may not use this file except in compliance with the License.
+  You may obtain a copy of the License at
+
+  http://www.apache.org/licenses/LICENSE-2.0
+
+  Unless required by applicable law or agreed to in writing, software
+  distributed under the License is distributed on an "AS IS" BASIS,
+  WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+  See the License for the specific language governing permissions and
+  limitations under the License.
+
+*/
+
+package gateway
+
+import (
+	"errors"
+	"github.com/Loopring/relay-cluster/dao"
+	util "github.com/Loopring/relay-lib/marketutil"
+	"github.com/Loopring/relay-lib/types"
+	"github.com/ethereum/go-ethereum/common"
+	"math/big"
+	"strconv"
+	"testing"
+	"time"
+)
+
+func TestVerifySessionKeySign(t *testing.T) {
+	signer := newTestSigner(t)
+	timestamp := strconv.FormatInt(time.Now().Unix(), 10)
+	session := "0x47fe1648b80fa04584241781488ce4c0aaca23e4"
+
+	register := &SessionKeyDelegation{
+		SessionAddress: session,
+		ExpireTime:     time.Now().Unix() + 3600,
+		Markets:        []string{"LRC-WETH"},
+		Sign:           SignInfo{Owner: signer.Address().Hex(), Timestamp: timestamp},
+	}
+	signTestHash(t, signer, &register.Sign, register.SignHash())
+	ts, err := verifySessionKeySign(register.Sign, register.SessionAddress, register.SignHash())
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	if strconv.FormatInt(ts, 10) != timestamp {
+		t.Fatalf("expected sign timestamp %s, got %d", timestamp, ts)
+	}
+
+	// 授权签名不能用于撤销
+	revoke := &SessionKeyRevokeQuery{SessionAddress: session, Sign: register.Sign}
+	if _, err := verifySessionKeySign(revoke.Sign, revoke.SessionAddress, revoke.SignHash()); err == nil {
+		t.Fatalf("register sign should not be accepted for revoke")
+	}
+	signTestHash(t, signer, &revoke.Sign, revoke.SignHash())
+	if _, err := verifySessionKeySign(revoke.Sign, revoke.SessionAddress, revoke.SignHash()); err != nil {
+		t.Fatal(err.Error())
+	}
+
+	// 不能用于其他会话地址
+	other := *revoke
+	other.SessionAddress = "0x251f3bd45b06a8b29cb6d171131e192c1254fec1"
+	if _, err := verifySessionKeySign(other.Sign, other.SessionAddress, other.SignHash()); err == nil {
+		t.Fatalf("revoke sign should not be accepted for other session address")
+	}
+}
+
+type memSessionKeys map[string]dao.SessionKey
+
+func (m memSessionKeys) GetSessionKey(owner, sessionAddress string) (dao.SessionKey, error) {
+	if key, ok := m[owner+sessionAddress]; ok {
+		return key, nil
+	}
+	return dao.SessionKey{}, errors.New("record not found")
+}
+
+// 测试用的LRC-WETH市场
+func setTestMarketTokens(t *testing.T) (lrc, weth common.Address) {
+	lrc = common.HexToAddress("0xef68e7c694f40c8202821edf525de3782458639f")
+	weth = common.HexToAddress("0xc02aaa39b223fe8d0a0e5c4f27ead9083c756cc2")
+	decimals := new(big.Int).Exp(big.NewInt(10), big.NewInt(18), nil)
+	lrcToken := types.Token{Protocol: lrc, Symbol: "LRC", Decimals: decimals}
+	wethToken := types.Token{Protocol: weth, Symbol: "WETH", Decimals: decimals, IsMarket: true}
+
+	prevAll, prevTokens, prevMarkets := util.AllTokens, util.SupportTokens, util.SupportMarkets
+	util.AllTokens = map[string]types.Token{"LRC": lrcToken, "WETH": wethToken}
+	util.SupportTokens = map[string]types.Token{"LRC": lrcToken}
+	util.SupportMarkets = map[string]types.Token{"WETH": wethToken}
+	t.Cleanup(func() {
+		util.AllTokens, util.SupportTokens, util.SupportMarkets = prevAll, prevTokens, prevMarkets
+	})
+	return lrc, weth
+}
+
+func TestVerifyOrderSigner(t *testing.T) {
+	signer := newTestSigner(t)
+	lrc, weth := setTestMarketTokens(t)
+	ether := new(big.Int).Exp(big.NewInt(10), big.NewInt(18), nil)
+	order := &types.Order{
+		TokenS:     lrc,
+		TokenB:     weth,
+		AmountS:    new(big.Int).Mul(big.NewInt(1000), ether),
+		AmountB:    new(big.Int).Mul(big.NewInt(2), ether),
+		ValidSince: big.NewInt(0),
+		ValidUntil: big.NewInt(0),
+		LrcFee:     big.NewInt(0),
+		Owner:      signer.Address(),
+	}
+	signOrder := func() {
+		order.Hash = order.GenerateHash()
+		sig, err := signer.Sign(order.Hash.Bytes(), signer.Address())
+		if err != nil {
+			t.Fatal(err.Error())
+		}
+		order.V, order.R, order.S = signerVRS(sig)
+	}
+	signOrder()
+
+	keys := memSessionKeys{}
+	if err := verifyOrderSigner(keys, order); err != nil {
+		t.Fatal(err.Error())
+	}
+
+	// 非owner签名且未授权会话密钥时不被接受
+	owner := common.HexToAddress("0x71C079107B5af8619D54537A93dbF16e5aab4900")
+	order.Owner = owner
+	signOrder()
+	if err := verifyOrderSigner(keys, order); err == nil {
+		t.Fatalf("order not signed by owner or session key should be rejected")
+	}
+
+	// 卖出1000LRC换2WETH, 计价币数量为2
+	key := dao.SessionKey{
+		Owner:          owner.Hex(),
+		SessionAddress: signer.Address().Hex(),
+		Markets:        "LRC-WETH",
+		MaxNotional:    2.5,
+		ExpireTime:     time.Now().Unix() + 3600,
+		Status:         dao.SESSION_KEY_STATUS_ACTIVE,
+	}
+	keys[owner.Hex()+signer.Address().Hex()] = key
+	if err := verifyOrderSigner(keys, order); err != nil {
+		t.Fatalf("order signed by session key should be accepted, %s", err.Error())
+	}
+
+	limited := key
+	limited.MaxNotional = 1.5
+	keys[owner.Hex()+signer.Address().Hex()] = limited
+	if err := verifyOrderSigner(keys, order); err == nil {
+		t.Fatalf("order over session key max notional should be rejected")
+	}
+
+	otherMarket := key
+	otherMarket.Markets = "RDN-WETH"
+	keys[owner.Hex()+signer.Address().Hex()] = otherMarket
+	if err := verifyOrderSigner(keys, order); err == nil {
+		t.Fatalf("order out of session key markets should be rejected")
+	}
+
+	expired := key
+	expired.ExpireTime = time.Now().Unix() - 1
+	keys[owner.Hex()+signer.Address().Hex()] = expired
+	if err := verifyOrderSigner(keys, order); err == nil {
+		t.Fatalf("order signed by expired session key should be rejected")
+	}
+}
+
+func signerVRS(sig []byte) (uint8, types.Bytes32, types.Bytes32) {
+	return sig[64] + 27, types.BytesToBytes32(sig[0:32]), types.BytesToBytes32(sig[32:64])
+}
@@ -6,7 +6,9 @@ import (
 	"fmt"
 	"github.com/Loopring/relay-cluster/dao"
 	"github.com/Loopring/relay-cluster/market"
+	omcache "github.com/Loopring/relay-cluster/ordermanager/cache"
 	txtyp "github.com/Loopring/relay-cluster/txmanager/types"
+	kafkaUtil "github.com/Loopring/relay-cluster/util"
 	"github.com/Loopring/relay-lib/eth/loopringaccessor"
 	"github.com/Loopring/relay-lib/kafka"
 	"github.com/Loopring/relay-lib/log"
@@ -100,6 +102,11 @@ const (
 	eventKeyOrderTracing        = "orderTracing"
 	eventKeyEstimatedGasPrice   = "estimatedGasPrice"
 	eventKeyOrderAllocateChange = "orderAllocateChange"
+	eventKeyP2POrders           = "p2pOrders"
+	eventKeyTriggerOrders       = "triggerOrders"
+	eventKeyTimeInForceOrders   = "timeInForceOrders"
+	eventKeyDeadManSwitch       = "deadManSwitch"
+	eventKeyAllowanceShortfall  = "allowanceShortfall"
 
 	eventKeyGlobalTicker       = "globalTicker"
 	eventKeyGlobalTrend        = "globalTrend"
@@ -148,11 +155,15 @@ func NewSocketIOService(port string, walletService WalletServiceImpl, brokers []
 		kafka.Kafka_Topic_SocketIO_Cutoff:        {types.CutoffEvent{}, so.handleCutOff},
 		kafka.Kafka_Topic_SocketIO_Cutoff_Pair:   {types.CutoffPairEvent{}, so.handleCutOffPair},
 
-		kafka.Kafka_Topic_SocketIO_BalanceUpdated:      {types.BalanceUpdateEvent{}, so.handleBalanceUpdate},
-		kafka.Kafka_Topic_SocketIO_Transaction_Updated: {txtyp.TransactionView{}, so.handleTransactionUpdate},
-		Kafka_Topic_SocketIO_Order_Transfer:            {OrderTransfer{}, so.handleOrderTransfer},
-		Kafka_Topic_SocketIO_Scan_Login:                {LoginInfo{}, so.handleScanLogin},
-		Kafka_Topic_SocketIO_Notify_Circulr:            {NotifyCirculrBody{}, so.handleCirculrNotify},
+		kafka.Kafka_Topic_SocketIO_BalanceUpdated:          {types.BalanceUpdateEvent{}, so.handleBalanceUpdate},
+		kafka.Kafka_Topic_SocketIO_Transaction_Updated:     {txtyp.TransactionView{}, so.handleTransactionUpdate},
+		Kafka_Topic_SocketIO_Order_Transfer:                {OrderTransfer{}, so.handleOrderTransfer},
+		Kafka_Topic_SocketIO_Scan_Login:                    {LoginInfo{}, so.handleScanLogin},
+		Kafka_Topic_SocketIO_Notify_Circulr:                {NotifyCirculrBody{}, so.handleCirculrNotify},
+		kafkaUtil.Kafka_Topic_SocketIO_Trigger_Order:       {dao.TriggerOrder{}, so.handleTriggerOrderUpdate},
+		kafkaUtil.Kafka_Topic_SocketIO_Time_In_Force_Order: {dao.TimeInForceOrder{}, so.handleTimeInForceOrderUpdate},
+		kafkaUtil.Kafka_Topic_SocketIO_Dead_Man_Switch:     {omcache.DeadManSwitch{}, so.handleDeadManSwitchUpdate},
+		kafkaUtil.Kafka_Topic_SocketIO_Allowance_Shortfall: {omcache.AllowanceShortfall{}, so.handleAllowanceShortfall},
 	}
 
 	so.eventTypeRoute = map[string]InvokeInfo{
@@ -172,6 +183,11 @@ func NewSocketIOService(port string, walletService WalletServiceImpl, brokers []
 		eventKeyOrders:              {"GetLatestOrders", LatestOrderQuery{}, false, emitTypeByEvent, DefaultCronSpec5Minute},
 		eventKeyOrderTracing:        {"GetOrderByHash", OrderQuery{}, false, emitTypeByEvent, DefaultCronSpec5Minute},
 		eventKeyOrderAllocateChange: {"GetAllEstimatedAllocatedAmount", EstimatedAllocatedAllowanceQuery{}, false, emitTypeByEvent, DefaultCronSpec1Minute},
+		eventKeyP2POrders:           {"GetP2POrders", P2POrderQuery{}, false, emitTypeByEvent, DefaultCronSpec1Minute},
+		eventKeyTriggerOrders:       {"GetTriggerOrders", TriggerOrderQuery{}, false, emitTypeByEvent, DefaultCronSpec5Minute},
+		eventKeyTimeInForceOrders:   {"GetTimeInForceOrders", TimeInForceOrderQuery{}, false, emitTypeByEvent, DefaultCronSpec5Minute},
+		eventKeyDeadManSwitch:       {"GetDeadManSwitch", SingleOwner{}, false, emitTypeByEvent, DefaultCronSpec5Minute},
+		eventKeyAllowanceShortfall:  {"GetAllowanceShortfalls", SingleOwner{}, false, emitTypeByEvent, DefaultCronSpec5Minute},
 
 		eventKeyGlobalTicker:       {"GetGlobalTicker", SingleToken{}, true, emitTypeByEvent, DefaultCronSpec5Minute},
 		eventKeyGlobalTrend:        {"GetGlobalTrend", SingleToken{}, true, emitTypeByEvent, DefaultCronSpec5Minute},
@@ -462,9 +478,9 @@ func (so *SocketIOServiceImpl) getDepthPushData(eventKey string, markets map[str
 		var data interface{}
 		var err error
 		if eventKeyDepth == eventKey {
-			data, err = so.walletService.GetDepth(DepthQuery{delegate, mkt})
+			data, err = so.walletService.GetDepth(DepthQuery{DelegateAddress: delegate, Market: mkt})
 		} else {
-			data, err = so.walletService.GetUnmergedOrderBook(DepthQuery{delegate, mkt})
+			data, err = so.walletService.GetUnmergedOrderBook(DepthQuery{DelegateAddress: delegate, Market: mkt})
 		}
 
 		if err == nil {
@@ -1101,7 +1117,11 @@ func (so *SocketIOServiceImpl) handleOrderTracing(input interface{}) (err error)
 				} else if strings.ToUpper(orderHash) == strings.ToUpper(query.OrderHash) {
 					log.Info("emit " + ctx)
 					resp := SocketIOJsonResp{}
-					resp.Data = orderStateToJson(*req)
+					if orders, err := so.walletService.ordersToJson([]types.OrderState{*req}, types.NilAddress); err != nil {
+						resp.Error = err.Error()
+					} else {
+						resp.Data = orders[0]
+					}
 					respJson, _ := json.Marshal(resp)
 					v.Emit(eventKeyOrderTracing+EventPostfixRes, string(respJson[:]))
 				}
@@ -1123,6 +1143,7 @@ func (so *SocketIOServiceImpl) handleOrderUpdate(input interface{}) (err error)
 	so.handleOrderAllocateChange(input)
 
 	if order.RawOrder.OrderType == types.ORDER_TYPE_P2P {
+		so.handleP2POrdersUpdate(order)
 		return nil
 	}
 
@@ -1132,6 +1153,43 @@ func (so *SocketIOServiceImpl) handleOrderUpdate(input interface{}) (err error)
 	return nil
 }
 
+func (so *SocketIOServiceImpl) handleP2POrdersUpdate(input interface{}) (err error) {
+
+	req := input.(*types.OrderState)
+
+	so.connIdMap.Range(func(key, value interface{}) bool {
+		v := value.(socketio.Conn)
+		if v.Context() != nil {
+			businesses := v.Context().(map[string]string)
+			ctx, ok := businesses[eventKeyP2POrders]
+
+			if ok {
+				query := &P2POrderQuery{}
+				err = json.Unmarshal([]byte(ctx), query)
+				if err != nil {
+					log.Error("query unmarshal error, " + err.Error())
+				} else if p2pQueryMatched(query, req) {
+					so.EmitNowByEventType(eventKeyP2POrders, v, ctx)
+				}
+			}
+		}
+		return true
+	})
+
+	return nil
+}
+
+// 订阅条件中未指定的token/owner/delegate不作为过滤条件
+func p2pQueryMatched(query *P2POrderQuery, order *types.OrderState) bool {
+	matched := func(filter string, addr common.Address) bool {
+		return filter == "" || strings.ToLower(filter) == strings.ToLower(addr.Hex())
+	}
+	return matched(query.TokenS, order.RawOrder.TokenS) &&
+		matched(query.TokenB, order.RawOrder.TokenB) &&
+		matched(query.Owner, order.RawOrder.Owner) &&
+		matched(query.DelegateAddress, order.RawOrder.DelegateAddress)
+}
+
 func (so *SocketIOServiceImpl) handleCutOff(input interface{}) (err error) {
 	//log.Infof("[SOCKETIO-RECEIVE-EVENT] order update.")
 	//req := input.(*socketioutil.KafkaMsg)
@@ -1252,3 +1310,111 @@ func (so *SocketIOServiceImpl) handleCirculrNotify(input interface{}) (err error
 
 	return nil
 }
+
+func (so *SocketIOServiceImpl) handleTriggerOrderUpdate(input interface{}) (err error) {
+
+	req := input.(*dao.TriggerOrder)
+	log.Infof("received trigger order %s, status %s ", req.OrderHash, req.Status)
+
+	so.connIdMap.Range(func(key, value interface{}) bool {
+		v := value.(socketio.Conn)
+		if v.Context() != nil {
+			businesses := v.Context().(map[string]string)
+			ctx, ok := businesses[eventKeyTriggerOrders]
+
+			if ok {
+				query := &TriggerOrderQuery{}
+				err = json.Unmarshal([]byte(ctx), query)
+				if err != nil {
+					log.Error("query unmarshal error, " + err.Error())
+				} else if strings.ToLower(req.Owner) == strings.ToLower(query.Owner) {
+					so.EmitNowByEventType(eventKeyTriggerOrders, v, ctx)
+				}
+			}
+		}
+		return true
+	})
+
+	return nil
+}
+
+func (so *SocketIOServiceImpl) handleTimeInForceOrderUpdate(input interface{}) (err error) {
+
+	req := input.(*dao.TimeInForceOrder)
+	log.Infof("received time in force order %s, status %s ", req.OrderHash, req.Status)
+
+	so.connIdMap.Range(func(key, value interface{}) bool {
+		v := value.(socketio.Conn)
+		if v.Context() != nil {
+			businesses := v.Context().(map[string]string)
+			ctx, ok := businesses[eventKeyTimeInForceOrders]
+
+			if ok {
+				query := &TimeInForceOrderQuery{}
+				err = json.Unmarshal([]byte(ctx), query)
+				if err != nil {
+					log.Error("query unmarshal error, " + err.Error())
+				} else if strings.ToLower(req.Owner) == strings.ToLower(query.Owner) {
+					so.EmitNowByEventType(eventKeyTimeInForceOrders, v, ctx)
+				}
+			}
+		}
+		return true
+	})
+
+	return nil
+}
+
+func (so *SocketIOServiceImpl) handleDeadManSwitchUpdate(input interface{}) (err error) {
+
+	req := input.(*omcache.DeadManSwitch)
+	log.Infof("received dead man switch of %s, cancelled orders %d ", req.Owner, req.CancelledOrders)
+
+	so.connIdMap.Range(func(key, value interface{}) bool {
+		v := value.(socketio.Conn)
+		if v.Context() != nil {
+			businesses := v.Context().(map[string]string)
+			ctx, ok := businesses[eventKeyDeadManSwitch]
+
+			if ok {
+				query := &SingleOwner{}
+				err = json.Unmarshal([]byte(ctx), query)
+				if err != nil {
+					log.Error("query unmarshal error, " + err.Error())
+				} else if strings.ToLower(req.Owner) == strings.ToLower(query.Owner) {
+					so.EmitNowByEventType(eventKeyDeadManSwitch, v, ctx)
+				}
+			}
+		}
+		return true
+	})
+
+	return nil
+}
+
+func (so *SocketIOServiceImpl) handleAllowanceShortfall(input interface{}) (err error) {
+
+	req := input.(*omcache.AllowanceShortfall)
+	log.Infof("received allowance shortfall of %s, token %s, shortfall %s ", req.Owner, req.Token, req.Shortfall)
+
+	so.connIdMap.Range(func(key, value interface{}) bool {
+		v := value.(socketio.Conn)
+		if v.Context() != nil {
+			businesses := v.Context().(map[string]string)
+			ctx, ok := businesses[eventKeyAllowanceShortfall]
+
+			if ok {
+				query := &SingleOwner{}
+				err = json.Unmarshal([]byte(ctx), query)
+				if err != nil {
+					log.Error("query unmarshal error, " + err.Error())
+				} else if strings.ToLower(req.Owner) == strings.ToLower(query.Owner) {
+					so.EmitNowByEventType(eventKeyAllowanceShortfall, v, ctx)
+				}
+			}
+		}
+		return true
+	})
+
+	return nil
+}
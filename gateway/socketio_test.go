@@ -0,0 +1,50 @@
+/*
+
+  Copyright 2017 Loopring Project Ltd (Loopring Foundation).
+
+  Licensed under the Apache License, Version 2.0 (the "License");
+  you may not use this file except in compliance with the License.
+  You may obtain a copy of the License at
+
+  http://www.apache.org/licenses/LICENSE-2.0
+
+  Unless required by applicable law or agreed to in writing, software
+  distributed under the License is distributed on an "AS IS" BASIS,
+  WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+  See the License for the specific language governing permissions and
+  limitations under the License.
+
+*/
+
+package gateway
+
+import (
+	"github.com/Loopring/relay-lib/types"
+	"github.com/ethereum/go-ethereum/common"
+	"testing"
+)
+
+func TestP2PQueryMatched(t *testing.T) {
+	order := &types.OrderState{}
+	order.RawOrder.TokenS = common.HexToAddress("0xef68e7c694f40c8202821edf525de3782458639f")
+	order.RawOrder.TokenB = common.HexToAddress("0xc02aaa39b223fe8d0a0e5c4f27ead9083c756cc2")
+	order.RawOrder.Owner = common.HexToAddress("0x1b978a1d302335a6f2ebe4b8823b5e17c3c84135")
+
+	cases := []struct {
+		query   P2POrderQuery
+		matched bool
+	}{
+		{P2POrderQuery{}, true},
+		{P2POrderQuery{TokenS: "0xEF68E7C694F40C8202821EDF525DE3782458639F"}, true},
+		{P2POrderQuery{TokenB: "0xc02aaa39b223fe8d0a0e5c4f27ead9083c756cc2"}, true},
+		{P2POrderQuery{TokenS: "0xef68e7c694f40c8202821edf525de3782458639f", TokenB: "0xc02aaa39b223fe8d0a0e5c4f27ead9083c756cc2"}, true},
+		{P2POrderQuery{TokenS: "0xc02aaa39b223fe8d0a0e5c4f27ead9083c756cc2"}, false},
+		{P2POrderQuery{Owner: "0x1b978a1d302335a6f2ebe4b8823b5e17c3c84135"}, true},
+		{P2POrderQuery{Owner: "0x0000000000000000000000000000000000000001"}, false},
+	}
+	for i, c := range cases {
+		if matched := p2pQueryMatched(&c.query, order); matched != c.matched {
+			t.Errorf("case %d, matched:%t, expected:%t", i, matched, c.matched)
+		}
+	}
+}
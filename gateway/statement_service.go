@@ -0,0 +1,107 @@
+/*
+
+  Copyright 2017 Loopring Project Ltd (Loopring Foundation).
+
+  Licensed under the Apache License, Version 2.0 (the "License");
+  you may not use this file except in compliance with the License.
+  You may obtain a copy of the License at
+
+  http://www.apache.org/licenses/LICENSE-2.0
+
+  Unless required by applicable law or agreed to in writing, software
+  distributed under the License is distributed on an "AS IS" BASIS,
+  WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+  See the License for the specific language governing permissions and
+  limitations under the License.
+
+*/
+
+package gateway
+
+import (
+	"bytes"
+	"errors"
+	"github.com/Loopring/relay-cluster/accountmanager"
+	"github.com/ethereum/go-ethereum/common"
+	"time"
+)
+
+const (
+	STATEMENT_FORMAT_JSON = "json"
+	STATEMENT_FORMAT_CSV  = "csv"
+
+	statementMaxRange    = 366 * 24 * 3600
+	statementMaxPageSize = 1000
+)
+
+type StatementQuery struct {
+	Owner    string `json:"owner"`
+	Start    int64  `json:"start"`
+	End      int64  `json:"end"`
+	Format   string `json:"format"`
+	Cursor   string `json:"cursor"`
+	PageSize int    `json:"pageSize"`
+}
+
+type StatementResult struct {
+	Owner    string                          `json:"owner"`
+	Start    int64                           `json:"start"`
+	End      int64                           `json:"end"`
+	Balances map[string]string               `json:"balances"`
+	Entries  []accountmanager.StatementEntry `json:"entries,omitempty"`
+	Csv      string                          `json:"csv,omitempty"`
+	PageSize int                             `json:"pageSize"`
+	Cursor   string                          `json:"cursor"`
+}
+
+// 对账单按游标分页, 翻页时从游标中的位置和余额继续, balances为本页最后一条记录后的余额
+func (w *WalletServiceImpl) GetStatement(query StatementQuery) (res StatementResult, err error) {
+	if !common.IsHexAddress(query.Owner) {
+		return res, errors.New("owner isn't a valid hex-address")
+	}
+	if query.End <= 0 {
+		query.End = time.Now().Unix()
+	}
+	if query.Start <= 0 || query.Start > query.End {
+		return res, errors.New("start must be positive and not bigger than end")
+	}
+	if query.End-query.Start > statementMaxRange {
+		return res, errors.New("statement range can't exceed one year")
+	}
+	if query.Format == "" {
+		query.Format = STATEMENT_FORMAT_JSON
+	}
+	if query.Format != STATEMENT_FORMAT_JSON && query.Format != STATEMENT_FORMAT_CSV {
+		return res, errors.New("format only support json and csv")
+	}
+	if query.PageSize <= 0 || query.PageSize > statementMaxPageSize {
+		query.PageSize = statementMaxPageSize
+	}
+
+	statement, err := accountmanager.BuildStatement(w.rds, common.HexToAddress(query.Owner), query.Start, query.End, query.Cursor, query.PageSize)
+	if err != nil {
+		return res, err
+	}
+
+	res = StatementResult{
+		Owner:    statement.Owner,
+		Start:    statement.Start,
+		End:      statement.End,
+		Balances: statement.Balances,
+		PageSize: query.PageSize,
+		Cursor:   statement.Cursor,
+	}
+
+	entries := statement.Entries
+	if query.Format == STATEMENT_FORMAT_CSV {
+		var buf bytes.Buffer
+		if err = accountmanager.WriteStatementCsv(&buf, entries, query.Cursor == ""); err != nil {
+			return res, err
+		}
+		res.Csv = buf.String()
+	} else {
+		res.Entries = entries
+	}
+
+	return res, nil
+}
@@ -0,0 +1,51 @@
+/*
+
+  Copyright 2017 Loopring Project Ltd (Loopring Foundation).
+
+  Licensed under the Apache License, Version 2.0 (the "License");
+  you may not use this file except in compliance with the License.
+  You may obtain a copy of the License at
+
+  http://www.apache.org/licenses/LICENSE-2.0
+
+  Unless required by applicable law or agreed to in writing, software
+  distributed under the License is distributed on an "AS IS" BASIS,
+  WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+  See the License for the specific language governing permissions and
+  limitations under the License.
+
+*/
+
+package gateway
+
+import (
+	"errors"
+	"github.com/ethereum/go-ethereum/common"
+)
+
+type StuckOrderRecoveryQuery struct {
+	Owner     string `json:"owner"`
+	OrderHash string `json:"orderHash"`
+	PageIndex int    `json:"pageIndex"`
+	PageSize  int    `json:"pageSize"`
+}
+
+// 查询因pending tx丢失或被替换而被恢复状态的订单
+func (w *WalletServiceImpl) GetStuckOrderRecoveries(query StuckOrderRecoveryQuery) (res PageResult, err error) {
+	if !common.IsHexAddress(query.Owner) {
+		return res, errors.New("owner isn't a valid hex-address")
+	}
+
+	queryMap := make(map[string]interface{})
+	queryMap["owner"] = common.HexToAddress(query.Owner).Hex()
+	if query.OrderHash != "" {
+		queryMap["order_hash"] = common.HexToHash(query.OrderHash).Hex()
+	}
+
+	src, err := w.rds.StuckOrderRecoveryPageQuery(queryMap, query.PageIndex, query.PageSize)
+	if err != nil {
+		return res, err
+	}
+
+	return PageResult{Total: src.Total, PageIndex: src.PageIndex, PageSize: src.PageSize, Data: src.Data}, nil
+}
@@ -0,0 +1,99 @@
+/*
+
+  Copyright 2017 Loopring Project Ltd (Loopring Foundation).
+
+  Licensed under the Apache License, Version 2.0 (the "License");
+  you may not use this file except in compliance with the License.
+  You may obtain a copy of the License at
+
+  http://www.apache.org/licenses/LICENSE-2.0
+
+  Unless required by applicable law or agreed to in writing, software
+  distributed under the License is distributed on an "AS IS" BASIS,
+  WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+  See the License for the specific language governing permissions and
+  limitations under the License.
+
+*/
+
+package gateway
+
+import (
+	"errors"
+	"github.com/Loopring/relay-cluster/dao"
+	"github.com/Loopring/relay-lib/eth/accessor"
+	"github.com/Loopring/relay-lib/types"
+	"github.com/ethereum/go-ethereum/common"
+	"time"
+)
+
+type TimeInForceOrderRequest struct {
+	Order       types.OrderJsonRequest `json:"order"`
+	TimeInForce string                 `json:"timeInForce"`
+}
+
+type TimeInForceOrderQuery struct {
+	Owner     string `json:"owner"`
+	Status    string `json:"status"`
+	PageIndex int    `json:"pageIndex"`
+	PageSize  int    `json:"pageSize"`
+}
+
+// IOC订单超时后自动软取消未成交部分, FOK订单未在一个环路中完全成交时自动软取消
+func (w *WalletServiceImpl) SubmitTimeInForceOrder(req TimeInForceOrderRequest) (orderHash string, err error) {
+	if req.TimeInForce != dao.TIME_IN_FORCE_IOC && req.TimeInForce != dao.TIME_IN_FORCE_FOK {
+		return orderHash, errors.New("time in force must be IOC or FOK")
+	}
+
+	req.Order.OrderType = types.ORDER_TYPE_MARKET
+	order := types.ToOrder(&req.Order)
+	order.Hash = order.GenerateHash()
+	orderHash = order.Hash.Hex()
+
+	if _, err := w.rds.GetTimeInForceOrderByHash(orderHash); err == nil {
+		return orderHash, errors.New("order existed, please not submit again")
+	}
+
+	var blockNumber types.Big
+	if err = accessor.BlockNumber(&blockNumber); err != nil {
+		return orderHash, err
+	}
+
+	now := time.Now().Unix()
+	tif := &dao.TimeInForceOrder{
+		OrderHash:   orderHash,
+		Owner:       order.Owner.Hex(),
+		TimeInForce: req.TimeInForce,
+		CreateBlock: blockNumber.Int64(),
+		Status:      dao.TIME_IN_FORCE_STATUS_ACTIVE,
+		CreateTime:  now,
+		UpdateTime:  now,
+	}
+	if err = w.rds.Add(tif); err != nil {
+		return orderHash, err
+	}
+
+	if orderHash, err = HandleInputOrder(order); err != nil {
+		w.rds.Del(tif)
+	}
+	return orderHash, err
+}
+
+func (w *WalletServiceImpl) GetTimeInForceOrders(query TimeInForceOrderQuery) (res PageResult, err error) {
+	if !common.IsHexAddress(query.Owner) {
+		return res, errors.New("owner isn't a valid hex-address")
+	}
+
+	queryMap := make(map[string]interface{})
+	queryMap["owner"] = common.HexToAddress(query.Owner).Hex()
+	if query.Status != "" {
+		queryMap["status"] = query.Status
+	}
+
+	src, err := w.rds.TimeInForceOrderPageQuery(queryMap, query.PageIndex, query.PageSize)
+	if err != nil {
+		return res, err
+	}
+
+	return PageResult{Total: src.Total, PageIndex: src.PageIndex, PageSize: src.PageSize, Data: src.Data}, nil
+}
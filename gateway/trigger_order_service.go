@@ -0,0 +1,154 @@
+/*
+
+  Copyright 2017 Loopring Project Ltd (Loopring Foundation).
+
+  Licensed under the Apache License, Version 2.0 (the "License");
+  you may not use this file except in compliance with the License.
+  You may obtain a copy of the License at
+
+  http://www.apache.org/licenses/LICENSE-2.0
+
+  Unless required by applicable law or agreed to in writing, software
+  distributed under the License is distributed on an "AS IS" BASIS,
+  WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+  See the License for the specific language governing permissions and
+  limitations under the License.
+
+*/
+
+package gateway
+
+import (
+	"errors"
+	"github.com/Loopring/relay-cluster/dao"
+	"github.com/Loopring/relay-cluster/market"
+	kafkaUtil "github.com/Loopring/relay-cluster/util"
+	util "github.com/Loopring/relay-lib/marketutil"
+	"github.com/Loopring/relay-lib/types"
+	"github.com/ethereum/go-ethereum/common"
+	"time"
+)
+
+type TriggerOrderRequest struct {
+	Order        types.OrderJsonRequest `json:"order"`
+	TriggerType  string                 `json:"triggerType"`
+	TriggerPrice float64                `json:"triggerPrice"`
+	PriceSource  string                 `json:"priceSource"`
+}
+
+type TriggerOrderQuery struct {
+	Owner     string `json:"owner"`
+	Market    string `json:"market"`
+	Status    string `json:"status"`
+	PageIndex int    `json:"pageIndex"`
+	PageSize  int    `json:"pageSize"`
+}
+
+type CancelTriggerOrderQuery struct {
+	Sign      SignInfo `json:"sign"`
+	OrderHash string   `json:"orderHash"`
+}
+
+func (w *WalletServiceImpl) SubmitTriggerOrder(req TriggerOrderRequest) (orderHash string, err error) {
+	if req.TriggerType != dao.TRIGGER_TYPE_STOP_LOSS && req.TriggerType != dao.TRIGGER_TYPE_TAKE_PROFIT {
+		return orderHash, errors.New("trigger type must be stop_loss or take_profit")
+	}
+	if req.TriggerPrice <= 0 {
+		return orderHash, errors.New("trigger price must be bigger than zero")
+	}
+	if req.PriceSource == "" {
+		req.PriceSource = market.PriceSourceLoopring
+	}
+	if !market.IsValidPriceSource(req.PriceSource) {
+		return orderHash, errors.New("unsupported price source")
+	}
+
+	if req.Order.OrderType != types.ORDER_TYPE_MARKET && req.Order.OrderType != types.ORDER_TYPE_P2P {
+		req.Order.OrderType = types.ORDER_TYPE_MARKET
+	}
+	order := types.ToOrder(&req.Order)
+	order.Hash = order.GenerateHash()
+	orderHash = order.Hash.Hex()
+
+	if err := verifyOrderSigner(w.rds, order); err != nil {
+		return orderHash, err
+	}
+	if order.ValidUntil == nil || order.ValidUntil.Int64() <= time.Now().Unix() {
+		return orderHash, errors.New("order had expired")
+	}
+
+	order.Market, err = util.WrapMarketByAddress(order.TokenB.Hex(), order.TokenS.Hex())
+	if err != nil {
+		return orderHash, err
+	}
+	order.Side = util.GetSide(order.TokenS.Hex(), order.TokenB.Hex())
+
+	if _, err := w.orderViewer.GetOrderByHash(order.Hash); err == nil {
+		return orderHash, errors.New("order existed, please not submit again")
+	}
+	if _, err := w.rds.GetTriggerOrderByHash(orderHash); err == nil {
+		return orderHash, errors.New("trigger order existed, please not submit again")
+	}
+
+	trigger := &dao.TriggerOrder{}
+	if err = trigger.ConvertDown(order); err != nil {
+		return orderHash, err
+	}
+	trigger.TriggerType = req.TriggerType
+	trigger.TriggerPrice = req.TriggerPrice
+	trigger.PriceSource = req.PriceSource
+	trigger.Status = dao.TRIGGER_STATUS_PENDING
+	trigger.CreateTime = time.Now().Unix()
+	trigger.UpdateTime = trigger.CreateTime
+	if err = w.rds.Add(trigger); err != nil {
+		return orderHash, err
+	}
+
+	kafkaUtil.NotifyTriggerOrder(trigger)
+	return orderHash, nil
+}
+
+func (w *WalletServiceImpl) GetTriggerOrders(query TriggerOrderQuery) (res PageResult, err error) {
+	if !common.IsHexAddress(query.Owner) {
+		return res, errors.New("owner isn't a valid hex-address")
+	}
+
+	queryMap := make(map[string]interface{})
+	queryMap["owner"] = common.HexToAddress(query.Owner).Hex()
+	if query.Market != "" {
+		queryMap["market"] = query.Market
+	}
+	if query.Status != "" {
+		queryMap["status"] = query.Status
+	}
+
+	src, err := w.rds.TriggerOrderPageQuery(queryMap, query.PageIndex, query.PageSize)
+	if err != nil {
+		return res, err
+	}
+
+	return PageResult{Total: src.Total, PageIndex: src.PageIndex, PageSize: src.PageSize, Data: src.Data}, nil
+}
+
+func (w *WalletServiceImpl) CancelTriggerOrder(req CancelTriggerOrderQuery) (res string, err error) {
+	isCorrect, err := verifySign(req.Sign)
+	if !isCorrect {
+		return res, err
+	}
+
+	trigger, err := w.rds.GetTriggerOrderByHash(common.HexToHash(req.OrderHash).Hex())
+	if err != nil {
+		return res, errors.New("trigger order not found")
+	}
+	if common.HexToAddress(trigger.Owner) != common.HexToAddress(req.Sign.Owner) {
+		return res, errors.New("sign address not matched")
+	}
+
+	if w.rds.UpdateTriggerOrderStatus(trigger.OrderHash, dao.TRIGGER_STATUS_PENDING, dao.TRIGGER_STATUS_CANCELLED, "") == 0 {
+		return res, errors.New("only pending trigger order can be cancelled")
+	}
+
+	trigger.Status = dao.TRIGGER_STATUS_CANCELLED
+	kafkaUtil.NotifyTriggerOrder(&trigger)
+	return trigger.OrderHash, nil
+}
@@ -81,6 +81,8 @@ type PageResult struct {
 	PageIndex int           `json:"pageIndex"`
 	PageSize  int           `json:"pageSize"`
 	Total     int           `json:"total"`
+	// 游标模式下返回, 为空表示没有下一页
+	NextCursor string `json:"nextCursor,omitempty"`
 }
 
 type Depth struct {
@@ -219,44 +221,74 @@ type TransactionQuery struct {
 	TrxHashes []string `json:"trxHashes"`
 	PageIndex int      `json:"pageIndex"`
 	PageSize  int      `json:"pageSize"`
+	Cursor    *string  `json:"cursor"`
 }
 
 type OrderQuery struct {
-	Status          string   `json:"status"`
-	PageIndex       int      `json:"pageIndex"`
-	PageSize        int      `json:"pageSize"`
-	DelegateAddress string   `json:"delegateAddress"`
-	Owner           string   `json:"owner"`
-	Market          string   `json:"market"`
-	OrderHash       string   `json:"orderHash"`
-	OrderHashes     []string `json:"orderHashes"`
-	Side            string   `json:"side"`
-	OrderType       string   `json:"orderType"`
+	Status          string    `json:"status"`
+	Statuses        []string  `json:"statuses"`
+	PageIndex       int       `json:"pageIndex"`
+	PageSize        int       `json:"pageSize"`
+	Cursor          *string   `json:"cursor"`
+	DelegateAddress string    `json:"delegateAddress"`
+	Owner           string    `json:"owner"`
+	Market          string    `json:"market"`
+	OrderHash       string    `json:"orderHash"`
+	OrderHashes     []string  `json:"orderHashes"`
+	Side            string    `json:"side"`
+	OrderType       string    `json:"orderType"`
+	TokenS          string    `json:"tokenS"`
+	TokenB          string    `json:"tokenB"`
+	WalletAddress   string    `json:"walletAddress"`
+	CreateTimeStart int64     `json:"createTimeStart"`
+	CreateTimeEnd   int64     `json:"createTimeEnd"`
+	ValidUntilStart int64     `json:"validUntilStart"`
+	ValidUntilEnd   int64     `json:"validUntilEnd"`
+	MinPrice        float64   `json:"minPrice"`
+	MaxPrice        float64   `json:"maxPrice"`
+	MinAmountS      string    `json:"minAmountS"`
+	HasFills        *bool     `json:"hasFills"`
+	Sign            *SignInfo `json:"sign"`
+}
+
+type P2POrderQuery struct {
+	DelegateAddress string  `json:"delegateAddress"`
+	Owner           string  `json:"owner"`
+	TokenS          string  `json:"tokenS"`
+	TokenB          string  `json:"tokenB"`
+	MinAmountS      string  `json:"minAmountS"`
+	MinPrice        float64 `json:"minPrice"`
+	MaxPrice        float64 `json:"maxPrice"`
+	PageIndex       int     `json:"pageIndex"`
+	PageSize        int     `json:"pageSize"`
 }
 
 type DepthQuery struct {
 	DelegateAddress string `json:"delegateAddress"`
 	Market          string `json:"market"`
+	Protocol        string `json:"protocol,omitempty"`
 }
 
 type FillQuery struct {
-	DelegateAddress string `json:"delegateAddress"`
-	Market          string `json:"market"`
-	Owner           string `json:"owner"`
-	OrderHash       string `json:"orderHash"`
-	RingHash        string `json:"ringHash"`
-	PageIndex       int    `json:"pageIndex"`
-	PageSize        int    `json:"pageSize"`
-	Side            string `json:"side"`
-	OrderType       string `json:"orderType"`
+	DelegateAddress string  `json:"delegateAddress"`
+	Market          string  `json:"market"`
+	Owner           string  `json:"owner"`
+	OrderHash       string  `json:"orderHash"`
+	RingHash        string  `json:"ringHash"`
+	PageIndex       int     `json:"pageIndex"`
+	PageSize        int     `json:"pageSize"`
+	Cursor          *string `json:"cursor"`
+	Side            string  `json:"side"`
+	OrderType       string  `json:"orderType"`
 }
 
 type RingMinedQuery struct {
-	DelegateAddress string `json:"delegateAddress"`
-	ProtocolAddress string `json:"protocolAddress"`
-	RingIndex       string `json:"ringIndex"`
-	PageIndex       int    `json:"pageIndex"`
-	PageSize        int    `json:"pageSize"`
+	DelegateAddress string  `json:"delegateAddress"`
+	ProtocolAddress string  `json:"protocolAddress"`
+	RingIndex       string  `json:"ringIndex"`
+	PageIndex       int     `json:"pageIndex"`
+	PageSize        int     `json:"pageSize"`
+	Cursor          *string `json:"cursor"`
 }
 
 type RawOrderJsonResult struct {
@@ -293,6 +325,9 @@ type OrderJsonResult struct {
 	CancelledAmountS string             `json:"cancelledAmountS"`
 	CancelledAmountB string             `json:"cancelledAmountB"`
 	Status           string             `json:"status"`
+	Lease            *dao.OrderLease    `json:"lease,omitempty"`
+	GroupId          string             `json:"groupId,omitempty"`
+	Iceberg          bool               `json:"iceberg,omitempty"`
 }
 
 type PriceQuote struct {
@@ -359,6 +394,7 @@ type CancelOrderQuery struct {
 	TokenS     string   `json:"tokenS"`
 	TokenB     string   `json:"tokenB"`
 	Type       uint8    `json:"type"`
+	Nonce      int64    `json:"nonce"`
 }
 
 type SignInfo struct {
@@ -626,12 +662,50 @@ func (w *WalletServiceImpl) SubmitOrder(order *types.OrderJsonRequest) (res stri
 }
 
 func (w *WalletServiceImpl) GetOrders(query *OrderQuery) (res PageResult, err error) {
+	var src dao.PageResult
 	orderQuery, statusList, pi, ps := convertFromQuery(query)
-	src, err := w.orderViewer.GetOrders(orderQuery, statusList, pi, ps)
+	filter, err := applyOrderFilter(query, orderQuery)
+	if err != nil {
+		return res, err
+	}
+	if query.Cursor != nil {
+		src, err = w.orderViewer.GetOrdersByCursor(orderQuery, filter, statusList, *query.Cursor, ps)
+	} else {
+		src, err = w.orderViewer.GetOrders(orderQuery, filter, statusList, pi, ps)
+	}
 	if err != nil {
 		log.Info("query order error : " + err.Error())
 	}
 
+	rst := PageResult{Total: src.Total, PageIndex: src.PageIndex, PageSize: src.PageSize, NextCursor: src.NextCursor, Data: make([]interface{}, 0)}
+
+	states := make([]types.OrderState, 0)
+	for _, d := range src.Data {
+		states = append(states, d.(types.OrderState))
+	}
+	orders, jsonErr := w.ordersToJson(states, orderQueryViewer(query.Sign))
+	if jsonErr != nil {
+		return res, jsonErr
+	}
+	for _, o := range orders {
+		rst.Data = append(rst.Data, o)
+	}
+	w.fillOrderGroupIds(rst.Data)
+	return rst, err
+}
+
+// 查询开放中的p2p maker订单, 已被taker锁定的订单不返回
+func (w *WalletServiceImpl) GetP2POrders(query P2POrderQuery) (res PageResult, err error) {
+	orderQuery, minAmountS, err := convertFromP2PQuery(query)
+	if err != nil {
+		return res, err
+	}
+
+	src, err := w.orderViewer.GetP2POrders(orderQuery, minAmountS, query.MinPrice, query.MaxPrice, query.PageIndex, query.PageSize)
+	if err != nil {
+		log.Info("query p2p order error : " + err.Error())
+	}
+
 	rst := PageResult{Total: src.Total, PageIndex: src.PageIndex, PageSize: src.PageSize, Data: make([]interface{}, 0)}
 
 	for _, d := range src.Data {
@@ -641,28 +715,6 @@ func (w *WalletServiceImpl) GetOrders(query *OrderQuery) (res PageResult, err er
 	return rst, err
 }
 
-// 查询p2p订单, 订单类型固定, market不限
-//func (w *WalletServiceImpl) GetP2pOrders(query *OrderQuery) (res PageResult, err error) {
-//	orderQuery, statusList, pi, ps := convertFromQuery(query)
-//	orderQuery["order_type"] = types.ORDER_TYPE_P2P
-//	if _, ok := orderQuery["market"]; ok {
-//		delete(orderQuery, "market")
-//	}
-//
-//	src, err := w.orderViewer.GetOrders(orderQuery, statusList, pi, ps)
-//	if err != nil {
-//		log.Info("query order error : " + err.Error())
-//	}
-//
-//	rst := PageResult{Total: src.Total, PageIndex: src.PageIndex, PageSize: src.PageSize, Data: make([]interface{}, 0)}
-//
-//	for _, d := range src.Data {
-//		o := d.(types.OrderState)
-//		rst.Data = append(rst.Data, orderStateToJson(o))
-//	}
-//	return rst, err
-//}
-
 func (w *WalletServiceImpl) GetOrderByHash(query OrderQuery) (order OrderJsonResult, err error) {
 	if len(query.OrderHash) == 0 {
 		return order, errors.New("order hash can't be null")
@@ -671,7 +723,18 @@ func (w *WalletServiceImpl) GetOrderByHash(query OrderQuery) (order OrderJsonRes
 		if err != nil {
 			return order, err
 		} else {
-			return orderStateToJson(*state), err
+			orders, err := w.ordersToJson([]types.OrderState{*state}, orderQueryViewer(query.Sign))
+			if err != nil {
+				return order, err
+			}
+			order = orders[0]
+			if lease, err := w.rds.GetOrderLease(state.RawOrder.Hash.Hex()); err == nil {
+				order.Lease = &lease
+			}
+			if member, err := w.rds.GetOrderGroupMember(state.RawOrder.Hash.Hex()); err == nil {
+				order.GroupId = member.GroupId
+			}
+			return order, nil
 		}
 	}
 }
@@ -692,10 +755,11 @@ func (w *WalletServiceImpl) GetOrdersByHashes(query OrderQuery) (order []OrderJs
 		if err != nil {
 			return order, err
 		} else {
-			for _, order := range orderList {
-				rst = append(rst, orderStateToJson(order))
+			orders, err := w.ordersToJson(orderList, orderQueryViewer(query.Sign))
+			if err != nil {
+				return order, err
 			}
-			return rst, err
+			return append(rst, orders...), nil
 		}
 	}
 }
@@ -763,11 +827,7 @@ func (w *WalletServiceImpl) GetLatestOrders(query LatestOrderQuery) (res []Order
 		return res, err
 	}
 
-	res = make([]OrderJsonResult, 0)
-	for _, d := range queryRst {
-		res = append(res, orderStateToJson(d))
-	}
-	return res, err
+	return w.ordersToJson(queryRst, types.NilAddress)
 }
 
 func (w *WalletServiceImpl) GetLatestMarketOrders(query LatestOrderQuery) (res []OrderJsonResult, err error) {
@@ -845,9 +905,20 @@ func (w *WalletServiceImpl) getInnerOrderBook(query DepthQuery, defaultDepthLeng
 		empty[i] = make([]string, 0)
 	}
 
+	// 指定protocol时只返回该protocol版本的订单
+	protocol := types.NilAddress
+	if query.Protocol != "" {
+		protocol = common.HexToAddress(query.Protocol)
+		if !loopringaccessor.IsRelateProtocol(protocol, common.HexToAddress(delegateAddress)) {
+			err = errors.New("protocol and delegate address are not matched")
+			return
+		}
+	}
+
 	//(TODO) 考虑到需要聚合的情况，所以每次取2倍的数据，先聚合完了再cut, 不是完美方案，后续再优化
 	asks, askErr := w.orderViewer.GetOrderBook(
 		common.HexToAddress(delegateAddress),
+		protocol,
 		util.AllTokens[a].Protocol,
 		util.AllTokens[b].Protocol, defaultDepthLength)
 
@@ -858,6 +929,7 @@ func (w *WalletServiceImpl) getInnerOrderBook(query DepthQuery, defaultDepthLeng
 
 	bids, bidErr := w.orderViewer.GetOrderBook(
 		common.HexToAddress(delegateAddress),
+		protocol,
 		util.AllTokens[b].Protocol,
 		util.AllTokens[a].Protocol, defaultDepthLength)
 
@@ -870,13 +942,24 @@ func (w *WalletServiceImpl) getInnerOrderBook(query DepthQuery, defaultDepthLeng
 }
 
 func (w *WalletServiceImpl) GetFills(query FillQuery) (dao.PageResult, error) {
-	res, err := w.orderViewer.FillsPageQuery(fillQueryToMap(query))
+	var (
+		res dao.PageResult
+		err error
+	)
+	if query.Cursor != nil {
+		fillQuery, _, ps := fillQueryToMap(query)
+		if res, err = w.orderViewer.FillsCursorQuery(fillQuery, *query.Cursor, ps); err == dao.ErrInvalidCursor {
+			return dao.PageResult{}, err
+		}
+	} else {
+		res, err = w.orderViewer.FillsPageQuery(fillQueryToMap(query))
+	}
 
 	if err != nil {
 		return dao.PageResult{}, nil
 	}
 
-	result := dao.PageResult{PageIndex: res.PageIndex, PageSize: res.PageSize, Total: res.Total, Data: make([]interface{}, 0)}
+	result := dao.PageResult{PageIndex: res.PageIndex, PageSize: res.PageSize, Total: res.Total, NextCursor: res.NextCursor, Data: make([]interface{}, 0)}
 
 	for _, f := range res.Data {
 		fill := f.(dao.FillEvent)
@@ -925,6 +1008,10 @@ func (w *WalletServiceImpl) GetTrend(query TrendQuery) (res []market.Trend, err
 }
 
 func (w *WalletServiceImpl) GetRingMined(query RingMinedQuery) (res dao.PageResult, err error) {
+	if query.Cursor != nil {
+		ringQuery, _, ps := ringMinedQueryToMap(query)
+		return w.orderViewer.RingMinedCursorQuery(ringQuery, *query.Cursor, ps)
+	}
 	return w.orderViewer.RingMinedPageQuery(ringMinedQueryToMap(query))
 }
 
@@ -1018,14 +1105,19 @@ func (w *WalletServiceImpl) GetEstimatedAllocatedAllowance(query EstimatedAlloca
 	return types.BigintToHex(amount), err
 }
 
-func (w *WalletServiceImpl) GetFrozenLRCFee(query SingleOwner) (frozenAmount string, err error) {
+func (w *WalletServiceImpl) GetFrozenLRCFee(query CommonTokenRequest) (frozenAmount string, err error) {
 	statusSet := make([]types.OrderStatus, 0)
 	statusSet = append(statusSet, types.ORDER_NEW)
 	statusSet = append(statusSet, types.ORDER_PARTIAL)
 
 	owner := query.Owner
 
-	allLrcFee, err := w.orderViewer.GetFrozenLRCFee(common.HexToAddress(owner), statusSet)
+	delegateAddress := types.NilAddress
+	if common.IsHexAddress(query.DelegateAddress) {
+		delegateAddress = common.HexToAddress(query.DelegateAddress)
+	}
+
+	allLrcFee, err := w.orderViewer.GetFrozenLRCFee(common.HexToAddress(owner), statusSet, delegateAddress)
 	if err != nil {
 		return "", err
 	}
@@ -1071,7 +1163,7 @@ func (w *WalletServiceImpl) GetAllEstimatedAllocatedAmount(query EstimatedAlloca
 		resultMap[k] = types.BigintToHex(v)
 	}
 
-	lrcFee, err := w.GetFrozenLRCFee(SingleOwner{query.Owner})
+	lrcFee, err := w.GetFrozenLRCFee(CommonTokenRequest{DelegateAddress: query.DelegateAddress, Owner: query.Owner})
 	if err != nil {
 		return result, err
 	}
@@ -1084,7 +1176,8 @@ func (w *WalletServiceImpl) getAllOrdersByOwner(owner, delegateAddress string) (
 	allOrders := make([]interface{}, 0)
 
 	orderQuery := OrderQuery{Owner: owner, DelegateAddress: delegateAddress, PageIndex: 1, PageSize: 200, Status: "ORDER_OPENED"}
-	pageRst, err := w.orderViewer.GetOrders(convertFromQuery(&orderQuery))
+	query, statusList, pi, ps := convertFromQuery(&orderQuery)
+	pageRst, err := w.orderViewer.GetOrders(query, nil, statusList, pi, ps)
 	if err != nil {
 		return orders, err
 	}
@@ -1094,7 +1187,8 @@ func (w *WalletServiceImpl) getAllOrdersByOwner(owner, delegateAddress string) (
 	if pageRst.Total > 200 {
 		for i := 2; i < (pageRst.Total/200)+1; i++ {
 			orderQuery = OrderQuery{Owner: owner, DelegateAddress: delegateAddress, PageIndex: i, PageSize: 200, Status: "ORDER_OPENED"}
-			pageRst, err = w.orderViewer.GetOrders(convertFromQuery(&orderQuery))
+			query, statusList, pi, ps = convertFromQuery(&orderQuery)
+			pageRst, err = w.orderViewer.GetOrders(query, nil, statusList, pi, ps)
 			if err != nil {
 				return orders, err
 			}
@@ -1159,6 +1253,17 @@ func (w *WalletServiceImpl) GetTransactions(query TransactionQuery) (PageResult,
 
 	rst.Data = make([]interface{}, 0)
 	rst.PageIndex, rst.PageSize, limit, offset = pagination(query.PageIndex, query.PageSize)
+
+	// 游标模式不统计total
+	if query.Cursor != nil {
+		rst.PageIndex = 0
+		txs, rst.NextCursor, err = txmanager.GetTransactionsByCursor(query.Owner, query.Symbol, query.Status, query.TxType, *query.Cursor, limit)
+		for _, v := range txs {
+			rst.Data = append(rst.Data, v)
+		}
+		return rst, err
+	}
+
 	rst.Total, err = txmanager.GetAllTransactionCount(query.Owner, query.Symbol, query.Status, query.TxType)
 	if err != nil {
 		return rst, err
@@ -1271,6 +1376,13 @@ func convertFromQuery(orderQuery *OrderQuery) (query map[string]interface{}, sta
 
 	query = make(map[string]interface{})
 	statusList = convertStatus(orderQuery.Status)
+	for _, s := range orderQuery.Statuses {
+		for _, status := range convertStatus(s) {
+			if !containsOrderStatus(statusList, status) {
+				statusList = append(statusList, status)
+			}
+		}
+	}
 	if orderQuery.Owner != "" {
 		query["owner"] = orderQuery.Owner
 	}
@@ -1290,7 +1402,7 @@ func convertFromQuery(orderQuery *OrderQuery) (query map[string]interface{}, sta
 		query["order_hash"] = orderQuery.OrderHash
 	}
 
-	if orderQuery.OrderType == types.ORDER_TYPE_MARKET || orderQuery.OrderType == types.ORDER_TYPE_P2P {
+	if orderQuery.OrderType == types.ORDER_TYPE_MARKET || orderQuery.OrderType == types.ORDER_TYPE_P2P || orderQuery.OrderType == dao.ORDER_TYPE_FOREIGN {
 		query["order_type"] = orderQuery.OrderType
 	} else {
 		query["order_type"] = types.ORDER_TYPE_MARKET
@@ -1302,6 +1414,115 @@ func convertFromQuery(orderQuery *OrderQuery) (query map[string]interface{}, sta
 
 }
 
+// 范围条件转为dao.OrderFilter, token和钱包地址为等值条件直接写入query
+func applyOrderFilter(orderQuery *OrderQuery, query map[string]interface{}) (*dao.OrderFilter, error) {
+	filter := &dao.OrderFilter{
+		CreateTimeStart: orderQuery.CreateTimeStart,
+		CreateTimeEnd:   orderQuery.CreateTimeEnd,
+		ValidUntilStart: orderQuery.ValidUntilStart,
+		ValidUntilEnd:   orderQuery.ValidUntilEnd,
+		MinPrice:        orderQuery.MinPrice,
+		MaxPrice:        orderQuery.MaxPrice,
+		HasFills:        orderQuery.HasFills,
+	}
+
+	if orderQuery.TokenS != "" {
+		tokenS, err := orderFilterToken(orderQuery.TokenS)
+		if err != nil {
+			return nil, err
+		}
+		query["token_s"] = tokenS
+	}
+	if orderQuery.TokenB != "" {
+		tokenB, err := orderFilterToken(orderQuery.TokenB)
+		if err != nil {
+			return nil, err
+		}
+		query["token_b"] = tokenB
+	}
+	if orderQuery.WalletAddress != "" {
+		if !common.IsHexAddress(orderQuery.WalletAddress) {
+			return nil, errors.New("walletAddress isn't a valid hex-address")
+		}
+		query["wallet_address"] = common.HexToAddress(orderQuery.WalletAddress).Hex()
+	}
+	if orderQuery.MinAmountS != "" {
+		var ok bool
+		if filter.MinAmountS, ok = new(big.Int).SetString(orderQuery.MinAmountS, 0); !ok {
+			return nil, errors.New("minAmountS isn't a valid number")
+		}
+	}
+	if filter.MinPrice > 0 && filter.MaxPrice > 0 && filter.MinPrice > filter.MaxPrice {
+		return nil, errors.New("minPrice can't be bigger than maxPrice")
+	}
+	if filter.CreateTimeStart > 0 && filter.CreateTimeEnd > 0 && filter.CreateTimeStart > filter.CreateTimeEnd {
+		return nil, errors.New("createTimeStart can't be bigger than createTimeEnd")
+	}
+	if filter.ValidUntilStart > 0 && filter.ValidUntilEnd > 0 && filter.ValidUntilStart > filter.ValidUntilEnd {
+		return nil, errors.New("validUntilStart can't be bigger than validUntilEnd")
+	}
+
+	return filter, nil
+}
+
+// token支持合约地址或币种符号
+func orderFilterToken(token string) (string, error) {
+	if common.IsHexAddress(token) {
+		return common.HexToAddress(token).Hex(), nil
+	}
+	addr := util.AliasToAddress(strings.ToUpper(token))
+	if types.IsZeroAddress(addr) {
+		return "", errors.New("unsupported token:" + token)
+	}
+	return addr.Hex(), nil
+}
+
+func containsOrderStatus(statusList []types.OrderStatus, status types.OrderStatus) bool {
+	for _, s := range statusList {
+		if s == status {
+			return true
+		}
+	}
+	return false
+}
+
+func convertFromP2PQuery(p2pQuery P2POrderQuery) (query map[string]interface{}, minAmountS *big.Int, err error) {
+
+	query = make(map[string]interface{})
+	if p2pQuery.Owner != "" {
+		if !common.IsHexAddress(p2pQuery.Owner) {
+			return query, nil, errors.New("owner isn't a valid hex-address")
+		}
+		query["owner"] = common.HexToAddress(p2pQuery.Owner).Hex()
+	}
+	if common.IsHexAddress(p2pQuery.DelegateAddress) {
+		query["delegate_address"] = p2pQuery.DelegateAddress
+	}
+	if p2pQuery.TokenS != "" {
+		if !common.IsHexAddress(p2pQuery.TokenS) {
+			return query, nil, errors.New("tokenS isn't a valid hex-address")
+		}
+		query["token_s"] = common.HexToAddress(p2pQuery.TokenS).Hex()
+	}
+	if p2pQuery.TokenB != "" {
+		if !common.IsHexAddress(p2pQuery.TokenB) {
+			return query, nil, errors.New("tokenB isn't a valid hex-address")
+		}
+		query["token_b"] = common.HexToAddress(p2pQuery.TokenB).Hex()
+	}
+	if p2pQuery.MinAmountS != "" {
+		var ok bool
+		if minAmountS, ok = new(big.Int).SetString(p2pQuery.MinAmountS, 0); !ok {
+			return query, nil, errors.New("minAmountS isn't a valid number")
+		}
+	}
+	if p2pQuery.MinPrice > 0 && p2pQuery.MaxPrice > 0 && p2pQuery.MinPrice > p2pQuery.MaxPrice {
+		return query, nil, errors.New("minPrice can't be bigger than maxPrice")
+	}
+
+	return query, minAmountS, nil
+}
+
 func convertStatus(s string) []types.OrderStatus {
 	switch s {
 	case "ORDER_OPENED":
@@ -1372,11 +1593,12 @@ func (w *WalletServiceImpl) calculateDepth(states []types.OrderState, length int
 	}
 
 	depthMap := make(map[string]DepthElement)
+	displayAmounts := w.getIcebergDisplayAmounts(states)
 
 	for _, s := range states {
 
 		price := *s.RawOrder.Price
-		minAmountS, minAmountB, err := w.calculateOrderBookAmount(s, isAsk, tokenSDecimal, tokenBDecimal)
+		minAmountS, minAmountB, err := w.calculateOrderBookAmount(s, isAsk, tokenSDecimal, tokenBDecimal, displayAmounts[s.RawOrder.Hash.Hex()])
 
 		if err != nil {
 			//log.Errorf("calculate min amount error " + err.Error())
@@ -1443,6 +1665,7 @@ func (w *WalletServiceImpl) generateOrderBook(states []types.OrderState, isAsk b
 		return nil, errors.New("orders can't be nil")
 	}
 	elements = make([]OrderBookElement, 0)
+	displayAmounts := w.getIcebergDisplayAmounts(states)
 
 	for _, s := range states {
 		o := OrderBookElement{}
@@ -1451,10 +1674,14 @@ func (w *WalletServiceImpl) generateOrderBook(states []types.OrderState, isAsk b
 		o.SplitB = fmtFloat(new(big.Rat).SetFrac(s.SplitAmountB, tokenBDecimal))
 		lrcToken := util.AllTokens["LRC"]
 		o.LrcFee = fmtFloat(new(big.Rat).SetFrac(s.RawOrder.LrcFee, lrcToken.Decimals))
+		if displayAmountS, ok := displayAmounts[s.RawOrder.Hash.Hex()]; ok {
+			lrcFee := icebergVisibleLrcFee(s, displayAmountS)
+			o.LrcFee = fmtFloat(lrcFee.Quo(lrcFee, new(big.Rat).SetInt(lrcToken.Decimals)))
+		}
 		o.ValidUntil = s.RawOrder.ValidUntil.Int64()
 
 		price := *s.RawOrder.Price
-		amountS, amountB, err := w.calculateOrderBookAmount(s, isAsk, tokenSDecimal, tokenBDecimal)
+		amountS, amountB, err := w.calculateOrderBookAmount(s, isAsk, tokenSDecimal, tokenBDecimal, displayAmounts[s.RawOrder.Hash.Hex()])
 		if err != nil {
 			continue
 		}
@@ -1484,9 +1711,12 @@ func (w *WalletServiceImpl) generateOrderBook(states []types.OrderState, isAsk b
 	return elements, nil
 }
 
-func (w *WalletServiceImpl) calculateOrderBookAmount(state types.OrderState, isAsk bool, tokenSDecimal, tokenBDecimal *big.Int) (amountS, amountB *big.Rat, err error) {
+func (w *WalletServiceImpl) calculateOrderBookAmount(state types.OrderState, isAsk bool, tokenSDecimal, tokenBDecimal, displayAmountS *big.Int) (amountS, amountB *big.Rat, err error) {
 
 	amountS, amountB = state.RemainedAmount()
+	if displayAmountS != nil {
+		amountS, amountB = icebergVisibleAmount(state, amountS, amountB, displayAmountS)
+	}
 	amountS = amountS.Quo(amountS, new(big.Rat).SetFrac(tokenSDecimal, big.NewInt(1)))
 	amountB = amountB.Quo(amountB, new(big.Rat).SetFrac(tokenBDecimal, big.NewInt(1)))
 
@@ -1625,40 +1855,19 @@ func (w *WalletServiceImpl) NotifyCirculr(req NotifyCirculrBody) (owner string,
 	return req.Owner, err
 }
 
-func (w *WalletServiceImpl) FlexCancelOrder(req CancelOrderQuery) (rst string, err error) {
+func (w *WalletServiceImpl) FlexCancelOrder(req CancelOrderQuery) (rst FlexCancelResult, err error) {
 
-	isCorrect, err := verifySign(req.Sign)
-	if !isCorrect {
+	nums, err := applyFlexCancel(&req)
+	if err != nil {
 		return rst, err
 	}
 
-	cancelOrderEvent := types.FlexCancelOrderEvent{}
-	cancelOrderEvent.OrderHash = common.HexToHash(req.OrderHash)
-	cancelOrderEvent.Owner = common.HexToAddress(req.Sign.Owner)
-	cancelOrderEvent.TokenS = common.HexToAddress(req.TokenS)
-	cancelOrderEvent.TokenB = common.HexToAddress(req.TokenB)
-	cancelOrderEvent.CutoffTime = req.CutoffTime
-	cancelOrderEvent.Type = types.FlexCancelType(req.Type)
-	err = manager.FlexCancelOrder(&cancelOrderEvent)
-	if err == nil {
-		go func() {
-
-			orderQuery := OrderQuery{Owner: req.Sign.Owner, OrderType: types.ORDER_TYPE_MARKET}
-			if cancelOrderEvent.Type == types.FLEX_CANCEL_BY_HASH {
-				orderQuery.OrderHash = cancelOrderEvent.OrderHash.Hex()
-			} else if cancelOrderEvent.Type == types.FLEX_CANCEL_BY_MARKET {
-				orderQuery.Market, _ = util.WrapMarketByAddress(req.TokenS, req.TokenB)
-			} else {
-				// other conditions will notify all market, not implement now
-			}
-			orderQueryMap, _, _, _ := convertFromQuery(&orderQuery)
-			ot, err := w.orderViewer.GetLatestOrders(orderQueryMap, 1)
-			if err == nil && len(ot) > 0 {
-				kafkaUtil.ProducerSocketIOMessage(kafka.Kafka_Topic_SocketIO_Order_Updated, ot[0])
-			}
-		}()
+	// 本地没有受影响的订单时仍需广播, 订单可能只存在于其他relay
+	rst.AffectedOrders = nums
+	if gateway.isBroadcast {
+		go pubFlexCancel(&req)
 	}
-	return rst, err
+	return rst, nil
 }
 
 func (w *WalletServiceImpl) SetOrderTransfer(req OrderTransfer) (hash string, err error) {
@@ -0,0 +1,142 @@
+/*
+
+  Copyright 2017 Loopring Project Ltd (Loopring Foundation).
+
+  Licensed under the Apache License, Version 2.0 (the "License");
+  you may not use this file except in compliance with the License.
+  You may obtain a copy of the License at
+
+  http://www.apache.org/licenses/LICENSE-2.0
+
+  Unless required by applicable law or agreed to in writing, software
+  distributed under the License is distributed on an "AS IS" BASIS,
+  WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+  See the License for the specific language governing permissions and
+  limitations under the License.
+
+*/
+
+package market
+
+import (
+	"fmt"
+	"github.com/Loopring/relay-cluster/dao"
+	"github.com/Loopring/relay-lib/log"
+	"github.com/Loopring/relay-lib/types"
+	"github.com/Loopring/relay-lib/zklock"
+	"github.com/robfig/cron"
+	"math"
+	"time"
+)
+
+const orderSchedulerCronSpec = "@every 5s"
+const orderSchedulerZkLock = "orderSchedulerZkLock"
+
+// maxDeviation为相对referencePrice的比例, 小于等于0时不做价格保护
+func IsPriceDeviated(referencePrice, lastPrice, maxDeviation float64) bool {
+	if maxDeviation <= 0 || referencePrice <= 0 || lastPrice <= 0 {
+		return false
+	}
+	return math.Abs(lastPrice-referencePrice)/referencePrice > maxDeviation
+}
+
+type OrderScheduler struct {
+	rds    *dao.RdsService
+	feed   PriceFeed
+	submit func(order *types.Order) error
+	cron   *cron.Cron
+}
+
+func NewOrderScheduler(rds *dao.RdsService, feed PriceFeed, submit func(order *types.Order) error) *OrderScheduler {
+	return &OrderScheduler{rds: rds, feed: feed, submit: submit, cron: cron.New()}
+}
+
+func (s *OrderScheduler) Start() {
+	go func() {
+		if zklock.TryLock(orderSchedulerZkLock) == nil {
+			s.cron.AddFunc(orderSchedulerCronSpec, s.releaseDueOrders)
+			log.Info("start order scheduler cron job......... ")
+			s.cron.Start()
+		} else {
+			log.Info("order scheduler try lock failed, other node is releasing")
+		}
+	}()
+}
+
+func (s *OrderScheduler) Stop() {
+	s.cron.Stop()
+}
+
+func (s *OrderScheduler) releaseDueOrders() {
+	list, err := s.rds.GetActiveOrderSchedules()
+	if err != nil {
+		log.Errorf("order scheduler, get active schedules error:%s", err.Error())
+		return
+	}
+
+	now := time.Now().Unix()
+	for _, v := range list {
+		if !s.checkPrice(&v) {
+			continue
+		}
+		s.release(v, now)
+	}
+}
+
+// 未设置参考价格时以首次获取的价格作为参考价格, 价格偏离超限时暂停计划
+func (s *OrderScheduler) checkPrice(schedule *dao.OrderSchedule) bool {
+	if schedule.MaxDeviation <= 0 {
+		return true
+	}
+
+	price, err := s.feed.GetLastPrice(schedule.Market, schedule.PriceSource)
+	if err != nil || price <= 0 {
+		log.Debugf("order scheduler, get price of market:%s source:%s failed, schedule:%s waiting", schedule.Market, schedule.PriceSource, schedule.ScheduleId)
+		return false
+	}
+
+	if schedule.ReferencePrice <= 0 {
+		if err := s.rds.SetOrderScheduleReferencePrice(schedule.ScheduleId, price); err != nil {
+			log.Errorf("order scheduler, set reference price of schedule:%s error:%s", schedule.ScheduleId, err.Error())
+			return false
+		}
+		schedule.ReferencePrice = price
+	}
+
+	if IsPriceDeviated(schedule.ReferencePrice, price, schedule.MaxDeviation) {
+		reason := fmt.Sprintf("price %v deviated from reference %v over %v", price, schedule.ReferencePrice, schedule.MaxDeviation)
+		if s.rds.UpdateOrderScheduleStatus(schedule.ScheduleId, dao.SCHEDULE_STATUS_ACTIVE, dao.SCHEDULE_STATUS_PAUSED, reason) > 0 {
+			log.Infof("order scheduler, schedule:%s paused, %s", schedule.ScheduleId, reason)
+		}
+		return false
+	}
+	return true
+}
+
+func (s *OrderScheduler) release(schedule dao.OrderSchedule, now int64) {
+	due, err := s.rds.GetDueScheduledOrders(schedule.ScheduleId, now)
+	if err != nil {
+		log.Errorf("order scheduler, get due orders of schedule:%s error:%s", schedule.ScheduleId, err.Error())
+		return
+	}
+
+	for _, v := range due {
+		if s.rds.UpdateScheduledOrderStatus(v.OrderHash, dao.SCHEDULED_ORDER_PENDING, dao.SCHEDULED_ORDER_RELEASED, "") == 0 {
+			continue
+		}
+
+		order := &types.Order{}
+		err := v.ConvertUp(order)
+		if err == nil {
+			err = s.submit(order)
+		}
+		if err != nil {
+			log.Errorf("order scheduler, submit order:%s of schedule:%s error:%s", v.OrderHash, schedule.ScheduleId, err.Error())
+			s.rds.UpdateScheduledOrderStatus(v.OrderHash, dao.SCHEDULED_ORDER_RELEASED, dao.SCHEDULED_ORDER_FAILED, err.Error())
+		}
+	}
+
+	if count, err := s.rds.CountPendingScheduledOrders(schedule.ScheduleId); err == nil && count == 0 {
+		s.rds.UpdateOrderScheduleStatus(schedule.ScheduleId, dao.SCHEDULE_STATUS_ACTIVE, dao.SCHEDULE_STATUS_FINISHED, "")
+	}
+}
@@ -0,0 +1,45 @@
+/*
+
+  Copyright 2017 Loopring Project Ltd (Loopring Foundation).
+
+  Licensed under the Apache License, Version 2.0 (the "License");
+  you may not use this file except in compliance with the License.
+  You may obtain a copy of the License at
+
+  http://www.apache.org/licenses/LICENSE-2.0
+
+  Unless required by applicable law or agreed to in writing, software
+  distributed under the License is distributed on an "AS IS" BASIS,
+  WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+  See the License for the specific language governing permissions and
+  limitations under the License.
+
+*/
+
+package market_test
+
+import (
+	"github.com/Loopring/relay-cluster/market"
+	"testing"
+)
+
+func TestIsPriceDeviated(t *testing.T) {
+	cases := []struct {
+		reference, last, maxDeviation float64
+		deviated                      bool
+	}{
+		{0.001, 0.00105, 0.1, false},
+		{0.001, 0.0012, 0.1, true},
+		{0.001, 0.0008, 0.1, true},
+		{0.001, 0.00109, 0.1, false},
+		{0.001, 0.002, 0, false},
+		{0, 0.002, 0.1, false},
+		{0.001, 0, 0.1, false},
+	}
+
+	for _, c := range cases {
+		if deviated := market.IsPriceDeviated(c.reference, c.last, c.maxDeviation); deviated != c.deviated {
+			t.Errorf("reference:%f last:%f max:%f, expect %t got %t", c.reference, c.last, c.maxDeviation, c.deviated, deviated)
+		}
+	}
+}
@@ -0,0 +1,182 @@
+/*
+
+  Copyright 2017 Loopring Project Ltd (Loopring Foundation).
+
+  Licensed under the Apache License, Version 2.0 (the "License");
+  you may not use this file except in compliance with the License.
+  You may obtain a copy of the License at
+
+  http://www.apache.org/licenses/LICENSE-2.0
+
+  Unless required by applicable law or agreed to in writing, software
+  distributed under the License is distributed on an "AS IS" BASIS,
+  WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+  See the License for the specific language governing permissions and
+  limitations under the License.
+
+*/
+
+package market
+
+import (
+	"fmt"
+	"github.com/Loopring/relay-cluster/dao"
+	socketioUtil "github.com/Loopring/relay-cluster/util"
+	"github.com/Loopring/relay-lib/log"
+	util "github.com/Loopring/relay-lib/marketutil"
+	"github.com/Loopring/relay-lib/types"
+	"github.com/Loopring/relay-lib/zklock"
+	"github.com/robfig/cron"
+	"strings"
+)
+
+const PriceSourceLoopring = "loopring"
+const triggerWatcherCronSpec = "@every 5s"
+const triggerWatcherZkLock = "triggerWatcherZkLock"
+
+type PriceFeed interface {
+	GetLastPrice(market, source string) (float64, error)
+}
+
+// loopring来源使用TrendManager的ticker, 其他来源使用交易所ticker
+type TickerPriceFeed struct {
+	trendManager *TrendManager
+	collector    *CollectorImpl
+}
+
+func NewTickerPriceFeed(trendManager *TrendManager, collector *CollectorImpl) *TickerPriceFeed {
+	return &TickerPriceFeed{trendManager: trendManager, collector: collector}
+}
+
+func (f *TickerPriceFeed) GetLastPrice(market, source string) (float64, error) {
+	if source == "" || source == PriceSourceLoopring {
+		ticker, err := f.trendManager.GetTickerByMarket(market)
+		if err != nil {
+			return 0, err
+		}
+		return ticker.Last, nil
+	}
+
+	tickers, err := f.collector.GetTickers(market)
+	if err != nil {
+		return 0, err
+	}
+	for _, v := range tickers {
+		if strings.ToLower(v.Exchange) == strings.ToLower(source) {
+			return v.Last, nil
+		}
+	}
+	return 0, fmt.Errorf("no ticker of market:%s found in source:%s", market, source)
+}
+
+func IsValidPriceSource(source string) bool {
+	if source == PriceSourceLoopring {
+		return true
+	}
+	_, ok := exchanges[strings.ToLower(source)]
+	return ok
+}
+
+// 卖单止损在价格跌破时触发, 止盈在价格涨破时触发, 买单相反
+func IsTriggerFired(side, triggerType string, triggerPrice, lastPrice float64) bool {
+	if lastPrice <= 0 {
+		return false
+	}
+
+	below := lastPrice <= triggerPrice
+	above := lastPrice >= triggerPrice
+	switch {
+	case side == util.SideSell && triggerType == dao.TRIGGER_TYPE_STOP_LOSS:
+		return below
+	case side == util.SideSell && triggerType == dao.TRIGGER_TYPE_TAKE_PROFIT:
+		return above
+	case side == util.SideBuy && triggerType == dao.TRIGGER_TYPE_STOP_LOSS:
+		return above
+	case side == util.SideBuy && triggerType == dao.TRIGGER_TYPE_TAKE_PROFIT:
+		return below
+	}
+	return false
+}
+
+type TriggerWatcher struct {
+	rds      *dao.RdsService
+	feed     PriceFeed
+	activate func(order *types.Order) error
+	cron     *cron.Cron
+}
+
+func NewTriggerWatcher(rds *dao.RdsService, feed PriceFeed, activate func(order *types.Order) error) *TriggerWatcher {
+	return &TriggerWatcher{rds: rds, feed: feed, activate: activate, cron: cron.New()}
+}
+
+func (w *TriggerWatcher) Start() {
+	go func() {
+		if zklock.TryLock(triggerWatcherZkLock) == nil {
+			w.cron.AddFunc(triggerWatcherCronSpec, w.checkPendingTriggers)
+			log.Info("start trigger watcher cron job......... ")
+			w.cron.Start()
+		} else {
+			log.Info("trigger watcher try lock failed, other node is watching")
+		}
+	}()
+}
+
+func (w *TriggerWatcher) Stop() {
+	w.cron.Stop()
+}
+
+func (w *TriggerWatcher) checkPendingTriggers() {
+	list, err := w.rds.GetPendingTriggerOrders()
+	if err != nil {
+		log.Errorf("trigger watcher, get pending trigger orders error:%s", err.Error())
+		return
+	}
+
+	for _, v := range w.FiredTriggers(list) {
+		w.fire(v)
+	}
+}
+
+// 同一批次中每个market、来源只查询一次价格
+func (w *TriggerWatcher) FiredTriggers(list []dao.TriggerOrder) []dao.TriggerOrder {
+	fired := make([]dao.TriggerOrder, 0)
+	prices := make(map[string]float64)
+
+	for _, v := range list {
+		key := v.Market + "_" + v.PriceSource
+		price, ok := prices[key]
+		if !ok {
+			var err error
+			if price, err = w.feed.GetLastPrice(v.Market, v.PriceSource); err != nil {
+				log.Debugf("trigger watcher, get price of market:%s source:%s error:%s", v.Market, v.PriceSource, err.Error())
+			}
+			prices[key] = price
+		}
+
+		if IsTriggerFired(v.Side, v.TriggerType, v.TriggerPrice, price) {
+			fired = append(fired, v)
+		}
+	}
+	return fired
+}
+
+func (w *TriggerWatcher) fire(trigger dao.TriggerOrder) {
+	if w.rds.UpdateTriggerOrderStatus(trigger.OrderHash, dao.TRIGGER_STATUS_PENDING, dao.TRIGGER_STATUS_TRIGGERED, "") == 0 {
+		return
+	}
+	trigger.Status = dao.TRIGGER_STATUS_TRIGGERED
+
+	order := &types.Order{}
+	err := trigger.ConvertUp(order)
+	if err == nil {
+		err = w.activate(order)
+	}
+	if err != nil {
+		log.Errorf("trigger watcher, activate order:%s error:%s", trigger.OrderHash, err.Error())
+		w.rds.UpdateTriggerOrderStatus(trigger.OrderHash, dao.TRIGGER_STATUS_TRIGGERED, dao.TRIGGER_STATUS_FAILED, err.Error())
+		trigger.Status = dao.TRIGGER_STATUS_FAILED
+		trigger.ErrMsg = err.Error()
+	}
+
+	socketioUtil.NotifyTriggerOrder(&trigger)
+}
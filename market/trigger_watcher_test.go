@@ -0,0 +1,80 @@
+/*
+
+  Copyright 2017 Loopring Project Ltd (Loopring Foundation).
+
+  Licensed under the Apache License, Version 2.0 (the "License");
+  you may not use this file except in compliance with the License.
+  You may obtain a copy of the License at
+
+  http://www.apache.org/licenses/LICENSE-2.0
+
+  Unless required by applicable law or agreed to in writing, software
+  distributed under the License is distributed on an "AS IS" BASIS,
+  WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+  See the License for the specific language governing permissions and
+  limitations under the License.
+
+*/
+
+package market_test
+
+import (
+	"github.com/Loopring/relay-cluster/dao"
+	"github.com/Loopring/relay-cluster/market"
+	"testing"
+)
+
+type mockPriceFeed struct {
+	prices map[string]float64
+	calls  int
+}
+
+func (f *mockPriceFeed) GetLastPrice(mkt, source string) (float64, error) {
+	f.calls++
+	return f.prices[mkt+"_"+source], nil
+}
+
+func TestIsTriggerFired(t *testing.T) {
+	cases := []struct {
+		side, triggerType string
+		trigger, last     float64
+		fired             bool
+	}{
+		{"sell", dao.TRIGGER_TYPE_STOP_LOSS, 0.001, 0.0009, true},
+		{"sell", dao.TRIGGER_TYPE_STOP_LOSS, 0.001, 0.0011, false},
+		{"sell", dao.TRIGGER_TYPE_TAKE_PROFIT, 0.001, 0.0011, true},
+		{"buy", dao.TRIGGER_TYPE_STOP_LOSS, 0.001, 0.0011, true},
+		{"buy", dao.TRIGGER_TYPE_TAKE_PROFIT, 0.001, 0.0011, false},
+		{"buy", dao.TRIGGER_TYPE_TAKE_PROFIT, 0.001, 0.0009, true},
+		{"sell", dao.TRIGGER_TYPE_STOP_LOSS, 0.001, 0, false},
+	}
+
+	for _, c := range cases {
+		if fired := market.IsTriggerFired(c.side, c.triggerType, c.trigger, c.last); fired != c.fired {
+			t.Errorf("side:%s type:%s trigger:%f last:%f, expect %t got %t", c.side, c.triggerType, c.trigger, c.last, c.fired, fired)
+		}
+	}
+}
+
+func TestTriggerWatcher_FiredTriggers(t *testing.T) {
+	feed := &mockPriceFeed{prices: map[string]float64{
+		"LRC-WETH_loopring": 0.0009,
+		"LRC-WETH_binance":  0.0012,
+	}}
+	w := market.NewTriggerWatcher(nil, feed, nil)
+
+	list := []dao.TriggerOrder{
+		{OrderHash: "0x1", Market: "LRC-WETH", Side: "sell", TriggerType: dao.TRIGGER_TYPE_STOP_LOSS, TriggerPrice: 0.001, PriceSource: "loopring"},
+		{OrderHash: "0x2", Market: "LRC-WETH", Side: "sell", TriggerType: dao.TRIGGER_TYPE_TAKE_PROFIT, TriggerPrice: 0.001, PriceSource: "loopring"},
+		{OrderHash: "0x3", Market: "LRC-WETH", Side: "sell", TriggerType: dao.TRIGGER_TYPE_TAKE_PROFIT, TriggerPrice: 0.001, PriceSource: "binance"},
+		{OrderHash: "0x4", Market: "LRC-WETH", Side: "buy", TriggerType: dao.TRIGGER_TYPE_STOP_LOSS, TriggerPrice: 0.0015, PriceSource: "binance"},
+	}
+
+	fired := w.FiredTriggers(list)
+	if len(fired) != 2 || fired[0].OrderHash != "0x1" || fired[1].OrderHash != "0x3" {
+		t.Fatalf("unexpected fired triggers:%v", fired)
+	}
+	if feed.calls != 2 {
+		t.Fatalf("price should be fetched once per market and source, got %d calls", feed.calls)
+	}
+}
@@ -53,13 +53,19 @@ type Node struct {
 	rdsService   *dao.RdsService
 	//ipfsSubService    gateway.IPFSSubService
 	orderManager      ordermanager.OrderManager
+	stuckSweeper      *ordermanager.StuckOrderSweeper
+	tifCanceller      *ordermanager.TimeInForceCanceller
+	dmsWatcher        *ordermanager.DeadManSwitchWatcher
 	orderViewer       orderviewer.OrderViewer
 	userManager       usermanager.UserManager
 	marketCapProvider marketcap.MarketCapProvider
 	accountManager    accountmanager.AccountManager
+	fillPriceRecorder *accountmanager.FillPriceRecorder
 	trendManager      market.TrendManager
 	tickerCollector   market.CollectorImpl
 	globalMarket      market.GlobalMarket
+	triggerWatcher    *market.TriggerWatcher
+	orderScheduler    *market.OrderScheduler
 	jsonRpcService    gateway.JsonrpcServiceImpl
 	websocketService  gateway.WebsocketServiceImpl
 	socketIOService   gateway.SocketIOServiceImpl
@@ -93,6 +99,7 @@ func NewNode(logger *zap.Logger, globalConfig *GlobalConfig) *Node {
 	n.registerOrderViewer()
 
 	n.registerAccountManager()
+	n.registerFillPriceRecorder()
 	n.registerGateway()
 	n.registerCrypto(nil)
 
@@ -102,6 +109,8 @@ func NewNode(logger *zap.Logger, globalConfig *GlobalConfig) *Node {
 	n.registerTrendManager()
 	n.registerTickerCollector()
 	n.registerGlobalMarket()
+	n.registerTriggerWatcher()
+	n.registerOrderScheduler()
 	n.registerWalletService()
 	n.registerJsonRpcService()
 	n.registerWebsocketService()
@@ -115,13 +124,19 @@ func NewNode(logger *zap.Logger, globalConfig *GlobalConfig) *Node {
 
 func (n *Node) Start() {
 	n.orderManager.Start()
+	n.stuckSweeper.Start()
+	n.tifCanceller.Start()
+	n.dmsWatcher.Start()
 	n.marketCapProvider.Start()
 	n.accountManager.Start()
+	n.fillPriceRecorder.Start()
 	n.txManager.Start()
 	//gateway.NewJsonrpcService("8080").Start()
 	fmt.Println("step in relay node start")
 	n.tickerCollector.Start()
 	n.globalMarket.Start()
+	n.triggerWatcher.Start()
+	n.orderScheduler.Start()
 	go n.jsonRpcService.Start()
 	//n.websocketService.Start()
 	go n.socketIOService.Start()
@@ -137,7 +152,13 @@ func (n *Node) Wait() {
 // todo release zklock and kafka producers and consumers
 func (n *Node) Stop() {
 	n.orderManager.Stop()
+	n.stuckSweeper.Stop()
+	n.tifCanceller.Stop()
+	n.dmsWatcher.Stop()
+	n.fillPriceRecorder.Stop()
 	n.txManager.Stop()
+	n.triggerWatcher.Stop()
+	n.orderScheduler.Stop()
 	n.wg.Done()
 }
 
@@ -168,6 +189,9 @@ func (n *Node) registerAccessor() {
 
 func (n *Node) registerOrderManager() {
 	n.orderManager = ordermanager.NewOrderManager(&n.globalConfig.OrderManager, n.rdsService, n.marketCapProvider, n.globalConfig.Kafka.Brokers)
+	n.stuckSweeper = ordermanager.NewStuckOrderSweeper(n.globalConfig.OrderManager.StuckTxSweepAge)
+	n.tifCanceller = ordermanager.NewTimeInForceCanceller(n.globalConfig.OrderManager.IocCancelBlocks, n.globalConfig.OrderManager.IocCancelSeconds)
+	n.dmsWatcher = ordermanager.NewDeadManSwitchWatcher()
 }
 
 func (n *Node) registerOrderViewer() {
@@ -179,7 +203,11 @@ func (n *Node) registerTrendManager() {
 }
 
 func (n *Node) registerAccountManager() {
-	n.accountManager = accountmanager.Initialize(&n.globalConfig.AccountManager, n.globalConfig.Kafka.Brokers)
+	n.accountManager = accountmanager.Initialize(&n.globalConfig.AccountManager, n.globalConfig.Kafka.Brokers, n.rdsService)
+}
+
+func (n *Node) registerFillPriceRecorder() {
+	n.fillPriceRecorder = accountmanager.NewFillPriceRecorder(n.rdsService, n.marketCapProvider)
 }
 
 func (n *Node) registerTransactionManager() {
@@ -198,6 +226,16 @@ func (n *Node) registerGlobalMarket() {
 	n.globalMarket = market.NewGlobalMarket(n.globalConfig.MyToken)
 }
 
+func (n *Node) registerTriggerWatcher() {
+	feed := market.NewTickerPriceFeed(&n.trendManager, &n.tickerCollector)
+	n.triggerWatcher = market.NewTriggerWatcher(n.rdsService, feed, gateway.SubmitDeferredOrder)
+}
+
+func (n *Node) registerOrderScheduler() {
+	feed := market.NewTickerPriceFeed(&n.trendManager, &n.tickerCollector)
+	n.orderScheduler = market.NewOrderScheduler(n.rdsService, feed, gateway.SubmitDeferredOrder)
+}
+
 func (n *Node) registerWalletService() {
 	n.walletService = *gateway.NewWalletService(n.trendManager, n.orderViewer,
 		n.accountManager, n.marketCapProvider, n.tickerCollector, n.rdsService, n.globalConfig.Market.OldVersionWethAddress, n.globalMarket)
@@ -216,7 +254,7 @@ func (n *Node) registerSocketIOService() {
 }
 
 func (n *Node) registerGateway() {
-	gateway.Initialize(&n.globalConfig.GatewayFilters, &n.globalConfig.Gateway, n.orderViewer, n.marketCapProvider, n.accountManager)
+	gateway.Initialize(&n.globalConfig.GatewayFilters, &n.globalConfig.Gateway, n.orderViewer, n.marketCapProvider, n.accountManager, n.rdsService)
 }
 
 func (n *Node) registerUserManager() {
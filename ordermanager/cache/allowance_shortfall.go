@@ -0,0 +1,84 @@
+/*
+
+  Copyright 2017 Loopring Project Ltd (Loopring Foundation).
+
+  Licensed under the Apache License, Version 2.0 (the "License");
+  you may not use this file except in compliance with the License.
+  You may obtain a copy of the License at
+
+  http://www.apache.org/licenses/LICENSE-2.0
+
+  Unless required by applicable law or agreed to in writing, software
+  distributed under the License is distributed on an "AS IS" BASIS,
+  WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+  See the License for the specific language governing permissions and
+  limitations under the License.
+
+*/
+
+package cache
+
+import (
+	"encoding/json"
+	"github.com/Loopring/relay-lib/cache"
+	"github.com/ethereum/go-ethereum/common"
+	"strings"
+)
+
+// 每个owner一个redis hash, 只保存授权不足的token/delegate, 用于判断不足是否为新出现
+const AllowanceShortfallKeyPrefix = "om_allowance_shortfall_"
+
+type AllowanceShortfall struct {
+	Owner           string `json:"owner"`
+	Token           string `json:"token"`
+	Symbol          string `json:"symbol"`
+	DelegateAddress string `json:"delegateAddress"`
+	Required        string `json:"required"`
+	Allowance       string `json:"allowance"`
+	Shortfall       string `json:"shortfall"`
+	UpdateTime      int64  `json:"updateTime"`
+}
+
+func (s *AllowanceShortfall) Field() []byte {
+	return shortfallField(common.HexToAddress(s.Token), common.HexToAddress(s.DelegateAddress))
+}
+
+func GetAllowanceShortfalls(owner common.Address) ([]*AllowanceShortfall, error) {
+	data, err := cache.HVals(shortfallKey(owner))
+	if err != nil {
+		return nil, err
+	}
+
+	var list []*AllowanceShortfall
+	for _, bs := range data {
+		s := &AllowanceShortfall{}
+		if err := json.Unmarshal(bs, s); err == nil {
+			list = append(list, s)
+		}
+	}
+	return list, nil
+}
+
+func SetAllowanceShortfall(s *AllowanceShortfall) error {
+	bs, err := json.Marshal(s)
+	if err != nil {
+		return err
+	}
+	return cache.HMSet(shortfallKey(common.HexToAddress(s.Owner)), 0, s.Field(), bs)
+}
+
+func DelAllowanceShortfall(owner common.Address, fields ...[]byte) error {
+	if len(fields) == 0 {
+		return nil
+	}
+	_, err := cache.HDel(shortfallKey(owner), fields...)
+	return err
+}
+
+func shortfallKey(owner common.Address) string {
+	return AllowanceShortfallKeyPrefix + strings.ToLower(owner.Hex())
+}
+
+func shortfallField(token, delegate common.Address) []byte {
+	return []byte(strings.ToLower(token.Hex() + delegate.Hex()))
+}
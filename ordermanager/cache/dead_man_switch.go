@@ -0,0 +1,111 @@
+/*
+
+  Copyright 2017 Loopring Project Ltd (Loopring Foundation).
+
+  Licensed under the Apache License, Version 2.0 (the "License");
+  you may not use this file except in compliance with the License.
+  You may obtain a copy of the License at
+
+  http://www.apache.org/licenses/LICENSE-2.0
+
+  Unless required by applicable law or agreed to in writing, software
+  distributed under the License is distributed on an "AS IS" BASIS,
+  WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+  See the License for the specific language governing permissions and
+  limitations under the License.
+
+*/
+
+package cache
+
+import (
+	"encoding/json"
+	"github.com/Loopring/relay-lib/cache"
+	"github.com/ethereum/go-ethereum/common"
+	"strings"
+	"time"
+)
+
+// 所有relay共享同一个redis hash, 重启后心跳状态不丢失
+const (
+	DeadManSwitchKey        = "om_dead_man_switch"
+	deadManSwitchLockPreKey = "om_dead_man_switch_lock_"
+	deadManSwitchLockExpire = 5
+)
+
+type DeadManSwitch struct {
+	Owner           string   `json:"owner"`
+	Timeout         int64    `json:"timeout"`
+	Markets         []string `json:"markets"`
+	LastHeartbeat   int64    `json:"lastHeartbeat"`
+	Deadline        int64    `json:"deadline"`
+	Armed           bool     `json:"armed"`
+	TriggerTime     int64    `json:"triggerTime"`
+	CancelledOrders int64    `json:"cancelledOrders"`
+}
+
+func (s *DeadManSwitch) IsExpired(now int64) bool {
+	return s.Armed && s.Deadline > 0 && now > s.Deadline
+}
+
+func GetDeadManSwitch(owner common.Address) (*DeadManSwitch, error) {
+	data, err := cache.HMGet(DeadManSwitchKey, dmsField(owner))
+	if err != nil {
+		return nil, err
+	}
+
+	// 未设置过心跳时返回nil
+	if len(data) == 0 || len(data[0]) == 0 {
+		return nil, nil
+	}
+	s := &DeadManSwitch{}
+	if err := json.Unmarshal(data[0], s); err != nil {
+		return nil, err
+	}
+	return s, nil
+}
+
+func GetDeadManSwitches() ([]*DeadManSwitch, error) {
+	data, err := cache.HVals(DeadManSwitchKey)
+	if err != nil {
+		return nil, err
+	}
+
+	var list []*DeadManSwitch
+	for _, bs := range data {
+		s := &DeadManSwitch{}
+		if err := json.Unmarshal(bs, s); err == nil {
+			list = append(list, s)
+		}
+	}
+	return list, nil
+}
+
+func SetDeadManSwitch(s *DeadManSwitch) error {
+	bs, err := json.Marshal(s)
+	if err != nil {
+		return err
+	}
+	return cache.HMSet(DeadManSwitchKey, 0, dmsField(common.HexToAddress(s.Owner)), bs)
+}
+
+func dmsField(owner common.Address) []byte {
+	return []byte(strings.ToLower(owner.Hex()))
+}
+
+// incr为1时获得该owner开关的锁, 多个relay对同一开关的读改写串行执行, 锁过期防止持有者宕机后无法释放
+func LockDeadManSwitch(owner common.Address) (bool, error) {
+	key := deadManSwitchLockPreKey + string(dmsField(owner))
+	count, err := cache.Incr(key)
+	if err != nil {
+		return false, err
+	}
+	if count != 1 {
+		return false, nil
+	}
+	return true, cache.ExpireAt(key, time.Now().Unix()+deadManSwitchLockExpire)
+}
+
+func UnlockDeadManSwitch(owner common.Address) error {
+	return cache.Del(deadManSwitchLockPreKey + string(dmsField(owner)))
+}
@@ -1,6 +1,10 @@
 package common
 
 type OrderManagerOptions struct {
-	CutoffCacheExpireTime int64
-	CutoffCacheCleanTime  int64
+	StuckTxSweepAge      int64
+	MinerLeaseBlocks     int64
+	IocCancelBlocks      int64
+	IocCancelSeconds     int64
+	RingFailLimit        int64
+	RingFailPauseSeconds int64
 }
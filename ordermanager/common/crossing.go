@@ -0,0 +1,40 @@
+/*
+
+  Copyright 2017 Loopring Project Ltd (Loopring Foundation).
+
+  Licensed under the Apache License, Version 2.0 (the "License");
+  you may not use this file except in compliance with the License.
+  You may obtain a copy of the License at
+
+  http://www.apache.org/licenses/LICENSE-2.0
+
+  Unless required by applicable law or agreed to in writing, software
+  distributed under the License is distributed on an "AS IS" BASIS,
+  WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+  See the License for the specific language governing permissions and
+  limitations under the License.
+
+*/
+
+package common
+
+import (
+	"github.com/Loopring/relay-lib/types"
+	"math/big"
+)
+
+// order与对手单opposite(opposite.TokenS == order.TokenB)价格是否交叉, 即两者可以直接成交
+// opposite愿意用amountS'个tokenB换amountB'个tokenS, order要求每个tokenS至少换到amountB/amountS个tokenB
+// amountS * amountS' >= amountB * amountB' 时交叉
+func IsOrderCrossing(order, opposite *types.Order) bool {
+	if order.AmountS == nil || order.AmountB == nil || opposite.AmountS == nil || opposite.AmountB == nil {
+		return false
+	}
+	if order.AmountS.Sign() <= 0 || order.AmountB.Sign() <= 0 || opposite.AmountS.Sign() <= 0 || opposite.AmountB.Sign() <= 0 {
+		return false
+	}
+
+	offered := new(big.Int).Mul(order.AmountS, opposite.AmountS)
+	wanted := new(big.Int).Mul(order.AmountB, opposite.AmountB)
+	return offered.Cmp(wanted) >= 0
+}
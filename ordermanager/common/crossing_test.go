@@ -0,0 +1,62 @@
+/*
+
+  Copyright 2017 Loopring Project Ltd (Loopring Foundation).
+
+  Licensed under the Apache License, Version 2.0 (the "License");
+  you may not use this file except in compliance with the License.
+  You may obtain a copy of the License at
+
+  http://www.apache.org/licenses/LICENSE-2.0
+
+  Unless required by applicable law or agreed to in writing, software
+  distributed under the License is distributed on an "AS IS" BASIS,
+  WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+  See the License for the specific language governing permissions and
+  limitations under the License.
+
+*/
+
+package common_test
+
+import (
+	omcm "github.com/Loopring/relay-cluster/ordermanager/common"
+	"github.com/Loopring/relay-lib/types"
+	"math/big"
+	"testing"
+)
+
+func newOrder(amountS, amountB int64) *types.Order {
+	return &types.Order{AmountS: big.NewInt(amountS), AmountB: big.NewInt(amountB)}
+}
+
+func TestIsOrderCrossing(t *testing.T) {
+	cases := []struct {
+		name             string
+		order, opposite  *types.Order
+		expectedCrossing bool
+	}{
+		// 卖1000 LRC换1 WETH, 对手单卖1 WETH换1000 LRC
+		{"same price", newOrder(1000, 1), newOrder(1, 1000), true},
+		// 对手单出价更高, 1 WETH只要900 LRC
+		{"opposite better", newOrder(1000, 1), newOrder(1, 900), true},
+		// 对手单出价更低, 1 WETH要1100 LRC
+		{"opposite worse", newOrder(1000, 1), newOrder(1, 1100), false},
+		{"order rests below best", newOrder(2000, 3), newOrder(1, 1000), false},
+		{"order crosses best", newOrder(2000, 1), newOrder(1, 1999), true},
+		{"empty opposite", newOrder(1000, 1), &types.Order{}, false},
+		{"zero amount", newOrder(1000, 0), newOrder(1, 1000), false},
+	}
+
+	for _, c := range cases {
+		if crossing := omcm.IsOrderCrossing(c.order, c.opposite); crossing != c.expectedCrossing {
+			t.Errorf("%s: expected crossing %t, got %t", c.name, c.expectedCrossing, crossing)
+		}
+	}
+}
+
+func TestIsOrderCrossingIsSymmetric(t *testing.T) {
+	order, opposite := newOrder(3000, 2), newOrder(5, 7000)
+	if omcm.IsOrderCrossing(order, opposite) != omcm.IsOrderCrossing(opposite, order) {
+		t.Errorf("crossing should be symmetric")
+	}
+}
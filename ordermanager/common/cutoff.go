@@ -0,0 +1,24 @@
+/*
+
+  Copyright 2017 Loopring Project Ltd (Loopring Foundation).
+
+  Licensed under the Apache License, Version 2.0 (the "License");
+  you may not use this file except in compliance with the License.
+  You may obtain a copy of the License at
+
+  http://www.apache.org/licenses/LICENSE-2.0
+
+  Unless required by applicable law or agreed to in writing, software
+  distributed under the License is distributed on an "AS IS" BASIS,
+  WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+  See the License for the specific language governing permissions and
+  limitations under the License.
+
+*/
+
+package common
+
+// 新cutoff不小于当前cutoff时生效, order manager处理cutoff事件与gateway预览使用相同的判断
+func IsCutoffApplicable(lastCutoff, cutoff int64) bool {
+	return cutoff >= lastCutoff
+}
@@ -0,0 +1,45 @@
+/*
+
+  Copyright 2017 Loopring Project Ltd (Loopring Foundation).
+
+  Licensed under the Apache License, Version 2.0 (the "License");
+  you may not use this file except in compliance with the License.
+  You may obtain a copy of the License at
+
+  http://www.apache.org/licenses/LICENSE-2.0
+
+  Unless required by applicable law or agreed to in writing, software
+  distributed under the License is distributed on an "AS IS" BASIS,
+  WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+  See the License for the specific language governing permissions and
+  limitations under the License.
+
+*/
+
+package common
+
+import "strings"
+
+const (
+	RingFailCauseInsufficientBalance   = "insufficient_balance"
+	RingFailCauseInsufficientAllowance = "insufficient_allowance"
+	RingFailCauseCutoff                = "cutoff"
+	RingFailCauseOutOfGas              = "out_of_gas"
+	RingFailCauseUnknown               = "unknown"
+)
+
+// 根据提交环路的错误信息归类失败原因, 同一原因连续失败的订单会被暂停撮合
+func ClassifyRingFailure(errMsg string) string {
+	msg := strings.ToLower(errMsg)
+	switch {
+	case strings.Contains(msg, "allowance"):
+		return RingFailCauseInsufficientAllowance
+	case strings.Contains(msg, "balance"):
+		return RingFailCauseInsufficientBalance
+	case strings.Contains(msg, "cutoff") || strings.Contains(msg, "cancel"):
+		return RingFailCauseCutoff
+	case strings.Contains(msg, "gas"):
+		return RingFailCauseOutOfGas
+	}
+	return RingFailCauseUnknown
+}
@@ -0,0 +1,41 @@
+/*
+
+  Copyright 2017 Loopring Project Ltd (Loopring Foundation).
+
+  Licensed under the Apache License, Version 2.0 (the "License");
+  you may not use this file except in compliance with the License.
+  You may obtain a copy of the License at
+
+  http://www.apache.org/licenses/LICENSE-2.0
+
+  Unless required by applicable law or agreed to in writing, software
+  distributed under the License is distributed on an "AS IS" BASIS,
+  WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+  See the License for the specific language governing permissions and
+  limitations under the License.
+
+*/
+
+package common_test
+
+import (
+	omcm "github.com/Loopring/relay-cluster/ordermanager/common"
+	"testing"
+)
+
+func TestClassifyRingFailure(t *testing.T) {
+	cases := map[string]string{
+		"owner Insufficient Balance":           omcm.RingFailCauseInsufficientBalance,
+		"insufficient allowance of token LRC":  omcm.RingFailCauseInsufficientAllowance,
+		"order had been cutoff":                omcm.RingFailCauseCutoff,
+		"out of gas":                           omcm.RingFailCauseOutOfGas,
+		"":                                     omcm.RingFailCauseUnknown,
+		"execution reverted with unknown code": omcm.RingFailCauseUnknown,
+	}
+
+	for msg, expect := range cases {
+		if cause := omcm.ClassifyRingFailure(msg); cause != expect {
+			t.Errorf("err:%s, expect cause %s got %s", msg, expect, cause)
+		}
+	}
+}
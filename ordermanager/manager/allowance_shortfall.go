@@ -0,0 +1,257 @@
+/*
+
+  Copyright 2017 Loopring Project Ltd (Loopring Foundation).
+
+  Licensed under the Apache License, Version 2.0 (the "License");
+  you may not use this file except in compliance with the License.
+  You may obtain a copy of the License at
+
+  http://www.apache.org/licenses/LICENSE-2.0
+
+  Unless required by applicable law or agreed to in writing, software
+  distributed under the License is distributed on an "AS IS" BASIS,
+  WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+  See the License for the specific language governing permissions and
+  limitations under the License.
+
+*/
+
+package manager
+
+import (
+	"github.com/Loopring/relay-cluster/accountmanager"
+	omcache "github.com/Loopring/relay-cluster/ordermanager/cache"
+	omcm "github.com/Loopring/relay-cluster/ordermanager/common"
+	notify "github.com/Loopring/relay-cluster/util"
+	"github.com/Loopring/relay-lib/eventemitter"
+	"github.com/Loopring/relay-lib/log"
+	util "github.com/Loopring/relay-lib/marketutil"
+	"github.com/Loopring/relay-lib/types"
+	"github.com/ethereum/go-ethereum/common"
+	"math/big"
+	"sort"
+	"sync"
+	"time"
+)
+
+// 同一owner在该时间内的多次变化合并为一次计算
+const allowanceShortfallDelay = 3 * time.Second
+
+type allowanceRequirement struct {
+	token    common.Address
+	delegate common.Address
+	amount   *big.Int
+}
+
+// 当前授权不足的token/delegate, 实时计算
+func GetAllowanceShortfalls(owner common.Address) ([]*omcache.AllowanceShortfall, error) {
+	return computeAllowanceShortfalls(owner, nil)
+}
+
+// 订单和授权变化时按owner合并, 延迟后在事件处理协程之外重新计算
+type shortfallDebouncer struct {
+	mtx     sync.Mutex
+	delay   time.Duration
+	pending map[common.Address]map[string]*types.ApprovalEvent
+	refresh func(owner common.Address, approvals []*types.ApprovalEvent)
+}
+
+var allowanceShortfalls = newShortfallDebouncer(allowanceShortfallDelay, RefreshAllowanceShortfall)
+
+func newShortfallDebouncer(delay time.Duration, refresh func(owner common.Address, approvals []*types.ApprovalEvent)) *shortfallDebouncer {
+	return &shortfallDebouncer{
+		delay:   delay,
+		pending: make(map[common.Address]map[string]*types.ApprovalEvent),
+		refresh: refresh,
+	}
+}
+
+// approval不为空时保留每个token/spender最新的授权事件
+func (d *shortfallDebouncer) schedule(owner common.Address, approval *types.ApprovalEvent) {
+	d.mtx.Lock()
+	defer d.mtx.Unlock()
+
+	approvals := d.pendingOf(owner)
+	if approval != nil {
+		approvals[approval.Protocol.Hex()+approval.Spender.Hex()] = approval
+	}
+}
+
+// 缓存已同步到最新授权, 之前的授权事件不再需要
+func (d *shortfallDebouncer) synced(owner common.Address) {
+	d.mtx.Lock()
+	defer d.mtx.Unlock()
+
+	approvals := d.pendingOf(owner)
+	for key := range approvals {
+		delete(approvals, key)
+	}
+}
+
+// 调用方需持有锁
+func (d *shortfallDebouncer) pendingOf(owner common.Address) map[string]*types.ApprovalEvent {
+	approvals, ok := d.pending[owner]
+	if !ok {
+		approvals = make(map[string]*types.ApprovalEvent)
+		d.pending[owner] = approvals
+		time.AfterFunc(d.delay, func() { d.flush(owner) })
+	}
+	return approvals
+}
+
+func (d *shortfallDebouncer) flush(owner common.Address) {
+	d.mtx.Lock()
+	approvals := d.pending[owner]
+	delete(d.pending, owner)
+	d.mtx.Unlock()
+
+	var list []*types.ApprovalEvent
+	for _, approval := range approvals {
+		list = append(list, approval)
+	}
+	d.refresh(owner, list)
+}
+
+// 重新计算owner的授权缺口, 由无到有时推送提醒, 缺口消失时删除记录
+func RefreshAllowanceShortfall(owner common.Address, approvals []*types.ApprovalEvent) {
+	current, err := computeAllowanceShortfalls(owner, approvals)
+	if err != nil {
+		log.Errorf("allowance shortfall, compute owner:%s error:%s", owner.Hex(), err.Error())
+		return
+	}
+	previous, err := omcache.GetAllowanceShortfalls(owner)
+	if err != nil {
+		log.Errorf("allowance shortfall, get owner:%s error:%s", owner.Hex(), err.Error())
+		return
+	}
+
+	stale := make(map[string]bool)
+	for _, v := range previous {
+		stale[string(v.Field())] = true
+	}
+	for _, v := range current {
+		field := string(v.Field())
+		if err := omcache.SetAllowanceShortfall(v); err != nil {
+			log.Errorf("allowance shortfall, set owner:%s token:%s error:%s", v.Owner, v.Token, err.Error())
+		}
+		if !stale[field] {
+			notify.NotifyAllowanceShortfall(v)
+		}
+		delete(stale, field)
+	}
+
+	var fields [][]byte
+	for field := range stale {
+		fields = append(fields, []byte(field))
+	}
+	if err := omcache.DelAllowanceShortfall(owner, fields...); err != nil {
+		log.Errorf("allowance shortfall, del owner:%s error:%s", owner.Hex(), err.Error())
+	}
+}
+
+// approve事件到达时accountmanager的缓存可能还未更新, 直接使用事件中的授权额度
+func computeAllowanceShortfalls(owner common.Address, approvals []*types.ApprovalEvent) ([]*omcache.AllowanceShortfall, error) {
+	orders, err := rds.GetOpenOrdersByOwner(owner, omcm.ValidMinerStatus)
+	if err != nil {
+		return nil, err
+	}
+
+	var states []types.OrderState
+	for _, v := range orders {
+		var state types.OrderState
+		if err := v.ConvertUp(&state); err != nil {
+			continue
+		}
+		states = append(states, state)
+	}
+
+	allowanceOf := func(token, delegate common.Address) *big.Int {
+		for _, approval := range approvals {
+			if approval.Protocol == token && approval.Spender == delegate && approval.Amount != nil {
+				return approval.Amount
+			}
+		}
+		_, allowance, _ := accountmanager.GetBalanceAndAllowance(owner, token, delegate)
+		return allowance
+	}
+
+	return calculateAllowanceShortfalls(owner, requiredAllowances(states, util.AliasToAddress("LRC")), allowanceOf), nil
+}
+
+// 按token和delegate汇总剩余未成交的amountS, lrcFee与GetFrozenLRCFee一致按全额计入LRC
+func requiredAllowances(states []types.OrderState, lrc common.Address) map[string]*allowanceRequirement {
+	required := make(map[string]*allowanceRequirement)
+	add := func(token, delegate common.Address, amount *big.Int) {
+		if amount == nil || amount.Sign() <= 0 {
+			return
+		}
+		key := token.Hex() + delegate.Hex()
+		if _, ok := required[key]; !ok {
+			required[key] = &allowanceRequirement{token: token, delegate: delegate, amount: big.NewInt(0)}
+		}
+		required[key].amount.Add(required[key].amount, amount)
+	}
+
+	for _, state := range states {
+		remained, _ := state.RemainedAmount()
+		if remained != nil {
+			add(state.RawOrder.TokenS, state.RawOrder.DelegateAddress, new(big.Int).Div(remained.Num(), remained.Denom()))
+		}
+		if state.RawOrder.LrcFee != nil && lrc != types.NilAddress {
+			add(lrc, state.RawOrder.DelegateAddress, state.RawOrder.LrcFee)
+		}
+	}
+	return required
+}
+
+func calculateAllowanceShortfalls(owner common.Address, required map[string]*allowanceRequirement, allowanceOf func(token, delegate common.Address) *big.Int) []*omcache.AllowanceShortfall {
+	var list []*omcache.AllowanceShortfall
+	now := time.Now().Unix()
+	for _, v := range required {
+		allowance := allowanceOf(v.token, v.delegate)
+		if allowance == nil {
+			allowance = big.NewInt(0)
+		}
+		shortfall := new(big.Int).Sub(v.amount, allowance)
+		if shortfall.Sign() <= 0 {
+			continue
+		}
+		list = append(list, &omcache.AllowanceShortfall{
+			Owner:           owner.Hex(),
+			Token:           v.token.Hex(),
+			Symbol:          util.AddressToAlias(v.token.Hex()),
+			DelegateAddress: v.delegate.Hex(),
+			Required:        types.BigintToHex(v.amount),
+			Allowance:       types.BigintToHex(allowance),
+			Shortfall:       types.BigintToHex(shortfall),
+			UpdateTime:      now,
+		})
+	}
+
+	sort.Slice(list, func(i, j int) bool {
+		if list[i].Token != list[j].Token {
+			return list[i].Token < list[j].Token
+		}
+		return list[i].DelegateAddress < list[j].DelegateAddress
+	})
+	return list
+}
+
+// 订单变化后需要重新计算的owner, ringmined/submitRing对应的成交会单独产生fill事件
+func orderRelatedOwner(input eventemitter.EventData) (common.Address, bool) {
+	switch event := input.(type) {
+	case *types.OrderState:
+		return event.RawOrder.Owner, true
+	case *types.OrderFilledEvent:
+		return event.Owner, true
+	case *types.OrderCancelledEvent:
+		if model, err := rds.GetOrderByHash(event.OrderHash); err == nil {
+			return common.HexToAddress(model.Owner), true
+		}
+	case *types.CutoffEvent:
+		return event.Owner, true
+	case *types.CutoffPairEvent:
+		return event.Owner, true
+	}
+	return types.NilAddress, false
+}
@@ -0,0 +1,243 @@
+/*
+
+  Copyright 2017 Loopring Project Ltd (Loopring Foundation).
+
+  Licensed under the Apache License, Version 2.0 (the "License");
+  you may not use this file except in compliance with the License.
+  You may obtain a copy of the License at
+
+  http://www.apache.org/licenses/LICENSE-2.0
+
+  Unless required by applicable law or agreed to in writing, software
+  distributed under the License is distributed on an "AS IS" BASIS,
+  WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+  See the License for the specific language governing permissions and
+  limitations under the License.
+
+*/
+
+package manager
+
+import (
+	"github.com/Loopring/relay-lib/types"
+	"github.com/ethereum/go-ethereum/common"
+	"math/big"
+	"sync"
+	"testing"
+	"time"
+)
+
+var (
+	shortfallOwner     = common.HexToAddress("0x1b978a1d302335a6f2ebe4b8823b5e17c3c84135")
+	shortfallTokenA    = common.HexToAddress("0x000000000000000000000000000000000000000a")
+	shortfallLrc       = common.HexToAddress("0x00000000000000000000000000000000000000ff")
+	shortfallDelegate1 = common.HexToAddress("0x0000000000000000000000000000000000000d01")
+	shortfallDelegate2 = common.HexToAddress("0x0000000000000000000000000000000000000d02")
+)
+
+func newShortfallState(token, delegate common.Address, amountS, amountB, dealtS, dealtB, lrcFee int64, buyNoMoreThanAmountB bool) types.OrderState {
+	state := types.OrderState{
+		DealtAmountS:     big.NewInt(dealtS),
+		DealtAmountB:     big.NewInt(dealtB),
+		SplitAmountS:     big.NewInt(0),
+		SplitAmountB:     big.NewInt(0),
+		CancelledAmountS: big.NewInt(0),
+		CancelledAmountB: big.NewInt(0),
+	}
+	state.RawOrder.TokenS = token
+	state.RawOrder.DelegateAddress = delegate
+	state.RawOrder.AmountS = big.NewInt(amountS)
+	state.RawOrder.AmountB = big.NewInt(amountB)
+	state.RawOrder.LrcFee = big.NewInt(lrcFee)
+	state.RawOrder.BuyNoMoreThanAmountB = buyNoMoreThanAmountB
+	return state
+}
+
+func TestRequiredAllowances(t *testing.T) {
+	tests := []struct {
+		name     string
+		states   []types.OrderState
+		lrc      common.Address
+		expected map[string]int64
+	}{
+		{
+			name:     "no orders",
+			lrc:      shortfallLrc,
+			expected: map[string]int64{},
+		},
+		{
+			name: "remained amountS and lrc fee grouped by token and delegate",
+			states: []types.OrderState{
+				newShortfallState(shortfallTokenA, shortfallDelegate1, 100, 50, 30, 15, 5, false),
+				// 按amountB计算剩余, 2*10/3向下取整
+				newShortfallState(shortfallTokenA, shortfallDelegate1, 10, 3, 3, 1, 0, true),
+				// 已全部成交, 只计lrcFee
+				newShortfallState(shortfallTokenA, shortfallDelegate2, 20, 10, 20, 10, 2, false),
+				newShortfallState(shortfallLrc, shortfallDelegate1, 40, 10, 0, 0, 1, false),
+			},
+			lrc: shortfallLrc,
+			expected: map[string]int64{
+				shortfallTokenA.Hex() + shortfallDelegate1.Hex(): 76,
+				shortfallLrc.Hex() + shortfallDelegate1.Hex():    46,
+				shortfallLrc.Hex() + shortfallDelegate2.Hex():    2,
+			},
+		},
+		{
+			name: "lrc fee ignored without lrc address",
+			states: []types.OrderState{
+				newShortfallState(shortfallTokenA, shortfallDelegate1, 100, 50, 0, 0, 5, false),
+			},
+			lrc: types.NilAddress,
+			expected: map[string]int64{
+				shortfallTokenA.Hex() + shortfallDelegate1.Hex(): 100,
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		required := requiredAllowances(tt.states, tt.lrc)
+		if len(required) != len(tt.expected) {
+			t.Fatalf("%s: required should have %d items, got %d", tt.name, len(tt.expected), len(required))
+		}
+		for key, amount := range tt.expected {
+			v, ok := required[key]
+			if !ok || v.amount.Int64() != amount || v.token.Hex()+v.delegate.Hex() != key {
+				t.Fatalf("%s: required of %s should be %d, got %+v", tt.name, key, amount, v)
+			}
+		}
+	}
+}
+
+type shortfallExpectation struct {
+	token     common.Address
+	delegate  common.Address
+	required  string
+	allowance string
+	shortfall string
+}
+
+func TestCalculateAllowanceShortfalls(t *testing.T) {
+	requirement := func(token, delegate common.Address, amount int64) *allowanceRequirement {
+		return &allowanceRequirement{token: token, delegate: delegate, amount: big.NewInt(amount)}
+	}
+
+	tests := []struct {
+		name       string
+		required   []*allowanceRequirement
+		allowances map[string]*big.Int
+		expected   []shortfallExpectation
+	}{
+		{
+			name:     "enough allowance",
+			required: []*allowanceRequirement{requirement(shortfallTokenA, shortfallDelegate1, 100)},
+			allowances: map[string]*big.Int{
+				shortfallTokenA.Hex() + shortfallDelegate1.Hex(): big.NewInt(100),
+			},
+		},
+		{
+			name:     "no allowance",
+			required: []*allowanceRequirement{requirement(shortfallTokenA, shortfallDelegate1, 100)},
+			expected: []shortfallExpectation{
+				{shortfallTokenA, shortfallDelegate1, "0x64", "0x0", "0x64"},
+			},
+		},
+		{
+			name: "only short ones sorted by token and delegate",
+			required: []*allowanceRequirement{
+				requirement(shortfallLrc, shortfallDelegate1, 46),
+				requirement(shortfallTokenA, shortfallDelegate2, 10),
+				requirement(shortfallTokenA, shortfallDelegate1, 76),
+			},
+			allowances: map[string]*big.Int{
+				shortfallLrc.Hex() + shortfallDelegate1.Hex():    big.NewInt(50),
+				shortfallTokenA.Hex() + shortfallDelegate2.Hex(): big.NewInt(4),
+				shortfallTokenA.Hex() + shortfallDelegate1.Hex(): big.NewInt(70),
+			},
+			expected: []shortfallExpectation{
+				{shortfallTokenA, shortfallDelegate1, "0x4c", "0x46", "0x6"},
+				{shortfallTokenA, shortfallDelegate2, "0xa", "0x4", "0x6"},
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		required := make(map[string]*allowanceRequirement)
+		for _, v := range tt.required {
+			required[v.token.Hex()+v.delegate.Hex()] = v
+		}
+		list := calculateAllowanceShortfalls(shortfallOwner, required, func(token, delegate common.Address) *big.Int {
+			return tt.allowances[token.Hex()+delegate.Hex()]
+		})
+
+		if len(list) != len(tt.expected) {
+			t.Fatalf("%s: shortfalls should be %d, got %d", tt.name, len(tt.expected), len(list))
+		}
+		for i, e := range tt.expected {
+			v := list[i]
+			if v.Owner != shortfallOwner.Hex() || v.Token != e.token.Hex() || v.DelegateAddress != e.delegate.Hex() ||
+				v.Required != e.required || v.Allowance != e.allowance || v.Shortfall != e.shortfall {
+				t.Fatalf("%s: shortfall %d should be %+v, got %+v", tt.name, i, e, v)
+			}
+		}
+	}
+}
+
+func TestShortfallDebouncer(t *testing.T) {
+	type refreshCall struct {
+		owner     common.Address
+		approvals []*types.ApprovalEvent
+	}
+	var (
+		mtx   sync.Mutex
+		calls []refreshCall
+	)
+	d := newShortfallDebouncer(20*time.Millisecond, func(owner common.Address, approvals []*types.ApprovalEvent) {
+		mtx.Lock()
+		defer mtx.Unlock()
+		calls = append(calls, refreshCall{owner, approvals})
+	})
+	approval := func(spender common.Address, amount int64) *types.ApprovalEvent {
+		return &types.ApprovalEvent{Owner: shortfallOwner, Protocol: shortfallTokenA, Spender: spender, Amount: big.NewInt(amount)}
+	}
+	other := common.HexToAddress("0x0000000000000000000000000000000000000002")
+
+	d.schedule(shortfallOwner, nil)
+	d.schedule(shortfallOwner, approval(shortfallDelegate1, 1))
+	d.schedule(shortfallOwner, approval(shortfallDelegate1, 2))
+	d.schedule(shortfallOwner, approval(shortfallDelegate2, 3))
+	d.schedule(other, nil)
+	time.Sleep(100 * time.Millisecond)
+
+	mtx.Lock()
+	if len(calls) != 2 {
+		t.Fatalf("owners should be refreshed once each, got %d calls", len(calls))
+	}
+	for _, c := range calls {
+		if c.owner == other {
+			if len(c.approvals) != 0 {
+				t.Fatalf("other owner shouldn't have approvals, got %d", len(c.approvals))
+			}
+			continue
+		}
+		amounts := make(map[common.Address]int64)
+		for _, a := range c.approvals {
+			amounts[a.Spender] = a.Amount.Int64()
+		}
+		if len(amounts) != 2 || amounts[shortfallDelegate1] != 2 || amounts[shortfallDelegate2] != 3 {
+			t.Fatalf("owner should keep the latest approval of each spender, got %+v", amounts)
+		}
+	}
+	calls = nil
+	mtx.Unlock()
+
+	// 同步后以缓存为准, 不再使用之前的授权事件
+	d.schedule(shortfallOwner, approval(shortfallDelegate1, 4))
+	d.synced(shortfallOwner)
+	time.Sleep(100 * time.Millisecond)
+
+	mtx.Lock()
+	defer mtx.Unlock()
+	if len(calls) != 1 || calls[0].owner != shortfallOwner || len(calls[0].approvals) != 0 {
+		t.Fatalf("synced owner should be refreshed once without approvals, got %+v", calls)
+	}
+}
@@ -0,0 +1,233 @@
+/*
+
+  Copyright 2017 Loopring Project Ltd (Loopring Foundation).
+
+  Licensed under the Apache License, Version 2.0 (the "License");
+  you may not use this file except in compliance with the License.
+  You may obtain a copy of the License at
+
+  http://www.apache.org/licenses/LICENSE-2.0
+
+  Unless required by applicable law or agreed to in writing, software
+  distributed under the License is distributed on an "AS IS" BASIS,
+  WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+  See the License for the specific language governing permissions and
+  limitations under the License.
+
+*/
+
+package manager
+
+import (
+	"errors"
+	omcache "github.com/Loopring/relay-cluster/ordermanager/cache"
+	notify "github.com/Loopring/relay-cluster/util"
+	"github.com/Loopring/relay-lib/log"
+	util "github.com/Loopring/relay-lib/marketutil"
+	"github.com/Loopring/relay-lib/types"
+	"github.com/Loopring/relay-lib/zklock"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/robfig/cron"
+	"time"
+)
+
+const (
+	deadManSwitchCronSpec      = "@every 1s"
+	deadManSwitchZkLock        = "deadManSwitchWatcherZkLock"
+	DeadManSwitchMinTimeout    = 5
+	deadManSwitchLockRetry     = 20
+	deadManSwitchLockRetryWait = 50 * time.Millisecond
+)
+
+// 心跳开关的存储, 生产环境为redis, Lock用于跨relay的读改写
+type deadManSwitchStore interface {
+	Get(owner common.Address) (*omcache.DeadManSwitch, error)
+	Set(s *omcache.DeadManSwitch) error
+	Lock(owner common.Address) (bool, error)
+	Unlock(owner common.Address) error
+}
+
+// 心跳开关的读写、撤单及通知, 生产环境使用redis存储、FlexCancelOrder撤单和socketio通知
+type deadManSwitchHandler struct {
+	store  deadManSwitchStore
+	cancel func(event *types.FlexCancelOrderEvent) (int64, error)
+	notify func(s *omcache.DeadManSwitch) error
+}
+
+func newDeadManSwitchHandler() *deadManSwitchHandler {
+	return &deadManSwitchHandler{store: &redisDeadManSwitchStore{}, cancel: FlexCancelOrder, notify: notify.NotifyDeadManSwitch}
+}
+
+type redisDeadManSwitchStore struct{}
+
+func (r *redisDeadManSwitchStore) Get(owner common.Address) (*omcache.DeadManSwitch, error) {
+	return omcache.GetDeadManSwitch(owner)
+}
+
+func (r *redisDeadManSwitchStore) Set(s *omcache.DeadManSwitch) error {
+	return omcache.SetDeadManSwitch(s)
+}
+
+func (r *redisDeadManSwitchStore) Lock(owner common.Address) (bool, error) {
+	return omcache.LockDeadManSwitch(owner)
+}
+
+func (r *redisDeadManSwitchStore) Unlock(owner common.Address) error {
+	return omcache.UnlockDeadManSwitch(owner)
+}
+
+// 持有owner的锁时读取开关并由update修改, update返回nil时不写入
+func (h *deadManSwitchHandler) update(owner common.Address, update func(s *omcache.DeadManSwitch) (*omcache.DeadManSwitch, error)) (*omcache.DeadManSwitch, error) {
+	for i := 0; ; i++ {
+		locked, err := h.store.Lock(owner)
+		if err != nil {
+			return nil, err
+		}
+		if locked {
+			break
+		}
+		if i >= deadManSwitchLockRetry {
+			return nil, errors.New("dead man switch is being updated, please retry")
+		}
+		time.Sleep(deadManSwitchLockRetryWait)
+	}
+	defer h.store.Unlock(owner)
+
+	s, err := h.store.Get(owner)
+	if err != nil {
+		return nil, err
+	}
+	if s, err = update(s); err != nil || s == nil {
+		return nil, err
+	}
+	if err := h.store.Set(s); err != nil {
+		return nil, err
+	}
+	return s, nil
+}
+
+// 心跳timestamp必须递增, timeout为0时解除, markets为空时撤销该owner所有订单
+func HeartbeatDeadManSwitch(owner common.Address, timeout int64, markets []string, timestamp int64) (*omcache.DeadManSwitch, error) {
+	return newDeadManSwitchHandler().heartbeat(owner, timeout, markets, timestamp)
+}
+
+func (h *deadManSwitchHandler) heartbeat(owner common.Address, timeout int64, markets []string, timestamp int64) (*omcache.DeadManSwitch, error) {
+	if timeout != 0 && timeout < DeadManSwitchMinTimeout {
+		return nil, errors.New("timeout is too short")
+	}
+	for _, v := range markets {
+		if !util.IsSupportedMarket(v) {
+			return nil, errors.New("unsupported market:" + v)
+		}
+	}
+
+	return h.update(owner, func(s *omcache.DeadManSwitch) (*omcache.DeadManSwitch, error) {
+		if s == nil {
+			s = &omcache.DeadManSwitch{Owner: owner.Hex()}
+		} else if timestamp <= s.LastHeartbeat {
+			return nil, errors.New("heartbeat timestamp must be bigger than the last one")
+		}
+
+		s.Timeout = timeout
+		s.Markets = markets
+		s.LastHeartbeat = timestamp
+		s.Armed = timeout > 0
+		s.Deadline = 0
+		if s.Armed {
+			s.Deadline = time.Now().Unix() + timeout
+		}
+		return s, nil
+	})
+}
+
+type DeadManSwitchWatcher struct {
+	cron     *cron.Cron
+	switches *deadManSwitchHandler
+}
+
+func NewDeadManSwitchWatcher() *DeadManSwitchWatcher {
+	return &DeadManSwitchWatcher{cron: cron.New(), switches: newDeadManSwitchHandler()}
+}
+
+func (w *DeadManSwitchWatcher) Start() {
+	go func() {
+		if zklock.TryLock(deadManSwitchZkLock) == nil {
+			w.cron.AddFunc(deadManSwitchCronSpec, w.checkDeadlines)
+			log.Info("start dead man switch watcher cron job......... ")
+			w.cron.Start()
+		} else {
+			log.Info("dead man switch watcher try lock failed, other node is watching")
+		}
+	}()
+}
+
+func (w *DeadManSwitchWatcher) Stop() {
+	w.cron.Stop()
+}
+
+func (w *DeadManSwitchWatcher) checkDeadlines() {
+	list, err := omcache.GetDeadManSwitches()
+	if err != nil {
+		log.Errorf("dead man switch watcher, get switches error:%s", err.Error())
+		return
+	}
+
+	now := time.Now().Unix()
+	for _, v := range list {
+		if v.IsExpired(now) {
+			w.switches.trigger(common.HexToAddress(v.Owner), v.Deadline, now)
+		}
+	}
+}
+
+// 先解除开关再撤单, 撤单期间到达的心跳会重新设置开关
+// 只有开关仍处于读取时的deadline且已过期才解除, 读取后到达的心跳会使本次触发失效
+func (h *deadManSwitchHandler) trigger(owner common.Address, deadline, now int64) {
+	s, err := h.update(owner, func(s *omcache.DeadManSwitch) (*omcache.DeadManSwitch, error) {
+		if s == nil || s.Deadline != deadline || !s.IsExpired(now) {
+			return nil, nil
+		}
+		s.Armed = false
+		s.TriggerTime = now
+		s.CancelledOrders = 0
+		return s, nil
+	})
+	if err != nil {
+		log.Errorf("dead man switch watcher, disarm owner:%s error:%s", owner.Hex(), err.Error())
+		return
+	}
+	if s == nil {
+		return
+	}
+
+	var events []*types.FlexCancelOrderEvent
+	if len(s.Markets) == 0 {
+		events = append(events, &types.FlexCancelOrderEvent{Owner: owner, Type: types.FLEX_CANCEL_BY_OWNER})
+	}
+	for _, v := range s.Markets {
+		symbolS, symbolB := util.UnWrap(v)
+		tokenS, tokenB := util.AliasToAddress(symbolS), util.AliasToAddress(symbolB)
+		events = append(events, &types.FlexCancelOrderEvent{Owner: owner, TokenS: tokenS, TokenB: tokenB, Type: types.FLEX_CANCEL_BY_MARKET})
+	}
+
+	for _, event := range events {
+		nums, err := h.cancel(event)
+		if err != nil {
+			log.Errorf("dead man switch watcher, flex cancel orders of owner:%s error:%s", s.Owner, err.Error())
+			continue
+		}
+		s.CancelledOrders += nums
+	}
+	log.Infof("dead man switch watcher, owner:%s missed heartbeat, %d orders flex cancelled", s.Owner, s.CancelledOrders)
+
+	// 只更新撤单数量, 避免覆盖撤单期间新到达的心跳
+	cancelled := s.CancelledOrders
+	h.update(owner, func(latest *omcache.DeadManSwitch) (*omcache.DeadManSwitch, error) {
+		if latest == nil || latest.LastHeartbeat != s.LastHeartbeat || latest.TriggerTime != s.TriggerTime {
+			return nil, nil
+		}
+		latest.CancelledOrders = cancelled
+		return latest, nil
+	})
+	h.notify(s)
+}
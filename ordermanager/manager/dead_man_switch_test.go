@@ -0,0 +1,193 @@
+/*
+
+  Copyright 2017 Loopring Project Ltd (Loopring Foundation).
+
+  Licensed under the Apache License, Version 2.0 (the "License");
+  you may not use this file except in compliance with the License.
+  You may obtain a copy of the License at
+
+  http://www.apache.org/licenses/LICENSE-2.0
+
+  Unless required by applicable law or agreed to in writing, software
+  distributed under the License is distributed on an "AS IS" BASIS,
+  WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+  See the License for the specific language governing permissions and
+  limitations under the License.
+
+*/
+
+package manager
+
+import (
+	omcache "github.com/Loopring/relay-cluster/ordermanager/cache"
+	"github.com/Loopring/relay-lib/types"
+	"github.com/ethereum/go-ethereum/common"
+	"sync"
+	"testing"
+	"time"
+)
+
+type memDeadManSwitchStore struct {
+	mtx      sync.Mutex
+	switches map[common.Address]omcache.DeadManSwitch
+	locked   map[common.Address]bool
+}
+
+func (m *memDeadManSwitchStore) Get(owner common.Address) (*omcache.DeadManSwitch, error) {
+	m.mtx.Lock()
+	defer m.mtx.Unlock()
+	if s, ok := m.switches[owner]; ok {
+		return &s, nil
+	}
+	return nil, nil
+}
+
+func (m *memDeadManSwitchStore) Set(s *omcache.DeadManSwitch) error {
+	m.mtx.Lock()
+	defer m.mtx.Unlock()
+	m.switches[common.HexToAddress(s.Owner)] = *s
+	return nil
+}
+
+func (m *memDeadManSwitchStore) Lock(owner common.Address) (bool, error) {
+	m.mtx.Lock()
+	defer m.mtx.Unlock()
+	if m.locked[owner] {
+		return false, nil
+	}
+	m.locked[owner] = true
+	return true, nil
+}
+
+func (m *memDeadManSwitchStore) Unlock(owner common.Address) error {
+	m.mtx.Lock()
+	defer m.mtx.Unlock()
+	delete(m.locked, owner)
+	return nil
+}
+
+var deadManSwitchTestOwner = common.HexToAddress("0x71C079107B5af8619D54537A93dbF16e5aab4900")
+
+func newDeadManSwitchTestHandler(cancel func(event *types.FlexCancelOrderEvent) (int64, error)) (*deadManSwitchHandler, *memDeadManSwitchStore, *int) {
+	initTestLogger()
+	store := &memDeadManSwitchStore{switches: make(map[common.Address]omcache.DeadManSwitch), locked: make(map[common.Address]bool)}
+	var notified int
+	h := &deadManSwitchHandler{store: store, cancel: cancel, notify: func(s *omcache.DeadManSwitch) error {
+		notified++
+		return nil
+	}}
+	return h, store, &notified
+}
+
+func TestTriggerDeadManSwitch_Expired(t *testing.T) {
+	h, store, notified := newDeadManSwitchTestHandler(func(event *types.FlexCancelOrderEvent) (int64, error) {
+		return 3, nil
+	})
+
+	s, err := h.heartbeat(deadManSwitchTestOwner, 10, nil, 100)
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	now := s.Deadline + 1
+	h.trigger(deadManSwitchTestOwner, s.Deadline, now)
+
+	latest := store.switches[deadManSwitchTestOwner]
+	if latest.Armed || latest.TriggerTime != now || latest.CancelledOrders != 3 {
+		t.Fatalf("expired switch should be disarmed with cancelled orders, got %+v", latest)
+	}
+	if *notified != 1 {
+		t.Fatalf("expected 1 notification, got %d", *notified)
+	}
+
+	// 已解除的开关不会重复触发
+	h.trigger(deadManSwitchTestOwner, s.Deadline, now+1)
+	if *notified != 1 {
+		t.Fatalf("disarmed switch should not trigger again")
+	}
+}
+
+// watcher读取开关后到达的心跳刷新了deadline, 旧deadline的触发不再撤单
+func TestTriggerDeadManSwitch_StaleDeadline(t *testing.T) {
+	var cancelled int
+	h, store, notified := newDeadManSwitchTestHandler(func(event *types.FlexCancelOrderEvent) (int64, error) {
+		cancelled++
+		return 1, nil
+	})
+
+	s, err := h.heartbeat(deadManSwitchTestOwner, 10, nil, 100)
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	stale := *s
+	renewed := stale
+	renewed.LastHeartbeat = 101
+	renewed.Deadline = stale.Deadline + 5
+	store.switches[deadManSwitchTestOwner] = renewed
+
+	h.trigger(deadManSwitchTestOwner, stale.Deadline, stale.Deadline+1)
+	if cancelled != 0 || *notified != 0 {
+		t.Fatalf("trigger of stale deadline should not cancel orders")
+	}
+	if latest := store.switches[deadManSwitchTestOwner]; !latest.Armed || latest.Deadline != renewed.Deadline {
+		t.Fatalf("renewed switch should stay armed, got %+v", latest)
+	}
+}
+
+// 撤单期间到达的心跳不被触发结果覆盖
+func TestTriggerDeadManSwitch_HeartbeatDuringCancel(t *testing.T) {
+	h, store, notified := newDeadManSwitchTestHandler(nil)
+	h.cancel = func(event *types.FlexCancelOrderEvent) (int64, error) {
+		if _, err := h.heartbeat(deadManSwitchTestOwner, 20, nil, 200); err != nil {
+			t.Fatal(err.Error())
+		}
+		return 2, nil
+	}
+
+	s, err := h.heartbeat(deadManSwitchTestOwner, 10, nil, 100)
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	h.trigger(deadManSwitchTestOwner, s.Deadline, s.Deadline+1)
+
+	latest := store.switches[deadManSwitchTestOwner]
+	if !latest.Armed || latest.LastHeartbeat != 200 || latest.Timeout != 20 {
+		t.Fatalf("heartbeat during cancel should be kept, got %+v", latest)
+	}
+	if *notified != 1 {
+		t.Fatalf("expected 1 notification, got %d", *notified)
+	}
+}
+
+func TestHeartbeatDeadManSwitch_Concurrent(t *testing.T) {
+	h, store, _ := newDeadManSwitchTestHandler(nil)
+
+	var wg sync.WaitGroup
+	for i := int64(1); i <= 20; i++ {
+		wg.Add(1)
+		go func(ts int64) {
+			defer wg.Done()
+			h.heartbeat(deadManSwitchTestOwner, 10, nil, ts)
+		}(i)
+	}
+	wg.Wait()
+
+	if latest := store.switches[deadManSwitchTestOwner]; latest.LastHeartbeat != 20 {
+		t.Fatalf("the latest heartbeat should win, got %d", latest.LastHeartbeat)
+	}
+	if len(store.locked) != 0 {
+		t.Fatalf("lock should be released")
+	}
+}
+
+func TestHeartbeatDeadManSwitch_Locked(t *testing.T) {
+	h, store, _ := newDeadManSwitchTestHandler(nil)
+	store.locked[deadManSwitchTestOwner] = true
+
+	start := time.Now()
+	if _, err := h.heartbeat(deadManSwitchTestOwner, 10, nil, 100); err == nil {
+		t.Fatalf("heartbeat should fail while the switch is locked by other relay")
+	}
+	if time.Since(start) < deadManSwitchLockRetryWait*deadManSwitchLockRetry {
+		t.Fatalf("heartbeat should retry before giving up")
+	}
+}
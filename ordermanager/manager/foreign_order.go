@@ -0,0 +1,106 @@
+/*
+
+  Copyright 2017 Loopring Project Ltd (Loopring Foundation).
+
+  Licensed under the Apache License, Version 2.0 (the "License");
+  you may not use this file except in compliance with the License.
+  You may obtain a copy of the License at
+
+  http://www.apache.org/licenses/LICENSE-2.0
+
+  Unless required by applicable law or agreed to in writing, software
+  distributed under the License is distributed on an "AS IS" BASIS,
+  WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+  See the License for the specific language governing permissions and
+  limitations under the License.
+
+*/
+
+package manager
+
+import (
+	"fmt"
+	"github.com/Loopring/relay-cluster/dao"
+	notify "github.com/Loopring/relay-cluster/util"
+	"github.com/Loopring/relay-lib/crypto"
+	"github.com/Loopring/relay-lib/log"
+	util "github.com/Loopring/relay-lib/marketutil"
+	"github.com/Loopring/relay-lib/types"
+	"github.com/ethereum/go-ethereum/common"
+	"math/big"
+	"time"
+)
+
+type foreignOrderStore interface {
+	GetOrderByHash(orderhash common.Hash) (*dao.Order, error)
+	SaveForeignOrder(state *types.OrderState, blockNumber *big.Int) error
+}
+
+type rdsForeignOrderStore struct {
+	rds *dao.RdsService
+}
+
+func (s *rdsForeignOrderStore) GetOrderByHash(orderhash common.Hash) (*dao.Order, error) {
+	return s.rds.GetOrderByHash(orderhash)
+}
+
+func (s *rdsForeignOrderStore) SaveForeignOrder(state *types.OrderState, blockNumber *big.Int) error {
+	model, err := NewOrderEntity(state, blockNumber)
+	if err != nil {
+		return err
+	}
+	model.Source = dao.ORDER_SOURCE_SUBMIT_RING
+	if err := s.rds.Add(model); err != nil {
+		return err
+	}
+	return notify.NotifyOrderUpdate(state)
+}
+
+// 其他relay提交的订单只出现在submitRing calldata中, 验证签名后作为外部订单入库
+// 之后该订单的fill事件可以正常处理, 成交记录及trend覆盖全网
+// 只导入提交成功的环路, 订单类型为ORDER_TYPE_FOREIGN, 不进入深度也不会提供给miner
+func importForeignOrders(store foreignOrderStore, event *types.SubmitRingMethodEvent) {
+	if event.Status != types.TX_STATUS_SUCCESS {
+		return
+	}
+
+	for _, v := range event.OrderList {
+		if _, err := store.GetOrderByHash(v.Hash); err == nil {
+			continue
+		}
+		if err := importForeignOrder(store, v, event.BlockNumber); err != nil {
+			log.Debugf("order manager, import foreign order:%s error:%s", v.Hash.Hex(), err.Error())
+		}
+	}
+}
+
+func importForeignOrder(store foreignOrderStore, order types.Order, blockNumber *big.Int) error {
+	// 订单签名的v为27/28, 校验时换算为recovery id
+	if order.V < 27 || !crypto.ValidateSignatureValues(order.V-27, order.R.Bytes(), order.S.Bytes()) {
+		return fmt.Errorf("signature values invalid")
+	}
+	if addr, err := order.SignerAddress(); err != nil {
+		return err
+	} else if addr != order.Owner {
+		return fmt.Errorf("owner %s and signer address %s are not match", order.Owner.Hex(), addr.Hex())
+	}
+
+	market, err := util.WrapMarketByAddress(order.TokenB.Hex(), order.TokenS.Hex())
+	if err != nil {
+		return err
+	}
+	order.Market = market
+	order.Side = util.GetSide(order.TokenS.Hex(), order.TokenB.Hex())
+	order.OrderType = dao.ORDER_TYPE_FOREIGN
+	order.CreateTime = time.Now().Unix()
+	order.GeneratePrice()
+
+	state := &types.OrderState{RawOrder: order}
+	if err := store.SaveForeignOrder(state, blockNumber); err != nil {
+		return err
+	}
+
+	log.Debugf("order manager, import foreign order:%s, owner:%s, market:%s", order.Hash.Hex(), order.Owner.Hex(), market)
+
+	return nil
+}
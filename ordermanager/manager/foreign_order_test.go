@@ -0,0 +1,126 @@
+/*
+
+  Copyright 2017 Loopring Project Ltd (Loopring Foundation).
+
+  Licensed under the Apache License, Version 2.0 (the "License");
+  you may not use this file except in compliance with the License.
+  You may obtain a copy of the License at
+
+  http://www.apache.org/licenses/LICENSE-2.0
+
+  Unless required by applicable law or agreed to in writing, software
+  distributed under the License is distributed on an "AS IS" BASIS,
+  WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+  See the License for the specific language governing permissions and
+  limitations under the License.
+
+*/
+
+package manager
+
+import (
+	"errors"
+	"github.com/Loopring/relay-cluster/dao"
+	"github.com/Loopring/relay-lib/crypto"
+	util "github.com/Loopring/relay-lib/marketutil"
+	"github.com/Loopring/relay-lib/types"
+	"github.com/ethereum/go-ethereum/common"
+	"math/big"
+	"testing"
+)
+
+const foreignTestSignerKey = "4c0883a69102937d6231471b5dbb6204fe5129617082792ae468d01a3f362318"
+
+type memForeignOrderStore struct {
+	existed map[common.Hash]bool
+	saved   []*types.OrderState
+}
+
+func (s *memForeignOrderStore) GetOrderByHash(orderhash common.Hash) (*dao.Order, error) {
+	if s.existed[orderhash] {
+		return &dao.Order{OrderHash: orderhash.Hex()}, nil
+	}
+	return nil, errors.New("record not found")
+}
+
+func (s *memForeignOrderStore) SaveForeignOrder(state *types.OrderState, blockNumber *big.Int) error {
+	s.saved = append(s.saved, state)
+	return nil
+}
+
+// 测试用的LRC-WETH市场
+func setForeignTestTokens(t *testing.T) (lrc, weth common.Address) {
+	lrc = common.HexToAddress("0xef68e7c694f40c8202821edf525de3782458639f")
+	weth = common.HexToAddress("0xc02aaa39b223fe8d0a0e5c4f27ead9083c756cc2")
+	lrcToken := types.Token{Protocol: lrc, Symbol: "LRC", Decimals: big.NewInt(1e18)}
+	wethToken := types.Token{Protocol: weth, Symbol: "WETH", Decimals: big.NewInt(1e18), IsMarket: true}
+
+	prevAll, prevTokens, prevMarkets := util.AllTokens, util.SupportTokens, util.SupportMarkets
+	util.AllTokens = map[string]types.Token{"LRC": lrcToken, "WETH": wethToken}
+	util.SupportTokens = map[string]types.Token{"LRC": lrcToken}
+	util.SupportMarkets = map[string]types.Token{"WETH": wethToken}
+	t.Cleanup(func() {
+		util.AllTokens, util.SupportTokens, util.SupportMarkets = prevAll, prevTokens, prevMarkets
+	})
+	return lrc, weth
+}
+
+func newForeignTestOrder(t *testing.T, signer crypto.EthPrivateKeyCrypto, owner common.Address, amountS int64) types.Order {
+	lrc, weth := setForeignTestTokens(t)
+	order := types.Order{
+		TokenS:     lrc,
+		TokenB:     weth,
+		AmountS:    big.NewInt(amountS),
+		AmountB:    big.NewInt(1),
+		ValidSince: big.NewInt(0),
+		ValidUntil: big.NewInt(0),
+		LrcFee:     big.NewInt(0),
+		Owner:      owner,
+	}
+	order.Hash = order.GenerateHash()
+	sig, err := signer.Sign(order.Hash.Bytes(), signer.Address())
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	order.V, order.R, order.S = sig[64]+27, types.BytesToBytes32(sig[0:32]), types.BytesToBytes32(sig[32:64])
+	return order
+}
+
+func TestImportForeignOrders(t *testing.T) {
+	initTestLogger()
+	signer, err := crypto.NewPrivateKeyCrypto(false, foreignTestSignerKey)
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	crypto.Initialize(signer)
+
+	existed := newForeignTestOrder(t, signer, signer.Address(), 1000)
+	imported := newForeignTestOrder(t, signer, signer.Address(), 2000)
+	// 签名地址与owner不一致
+	forged := newForeignTestOrder(t, signer, common.HexToAddress("0x71C079107B5af8619D54537A93dbF16e5aab4900"), 3000)
+	orders := []types.Order{existed, imported, forged}
+
+	// 未成功的环路不导入订单
+	for _, status := range []types.TxStatus{types.TX_STATUS_PENDING, types.TX_STATUS_FAILED} {
+		store := &memForeignOrderStore{}
+		event := &types.SubmitRingMethodEvent{OrderList: orders}
+		event.Status = status
+		importForeignOrders(store, event)
+		if len(store.saved) != 0 {
+			t.Fatalf("orders of ring with status %s should not be imported, got %d", types.StatusStr(status), len(store.saved))
+		}
+	}
+
+	store := &memForeignOrderStore{existed: map[common.Hash]bool{existed.Hash: true}}
+	event := &types.SubmitRingMethodEvent{OrderList: orders}
+	event.Status = types.TX_STATUS_SUCCESS
+	event.BlockNumber = big.NewInt(100)
+	importForeignOrders(store, event)
+	if len(store.saved) != 1 {
+		t.Fatalf("only the new order signed by its owner should be imported, got %d", len(store.saved))
+	}
+	order := store.saved[0].RawOrder
+	if order.Hash != imported.Hash || order.OrderType != dao.ORDER_TYPE_FOREIGN || order.Market != "LRC-WETH" {
+		t.Fatalf("imported order should be a foreign order of LRC-WETH, got %s %s %s", order.Hash.Hex(), order.OrderType, order.Market)
+	}
+}
@@ -71,6 +71,10 @@ func (p *ForkProcessor) Fork(event *types.ForkedEvent) error {
 		}
 	}
 
+	if err := p.RollBackOrderGroups(from, to); err != nil {
+		return err
+	}
+
 	return p.MarkForkEvents(from, to)
 }
 
@@ -210,6 +214,9 @@ func (p *ForkProcessor) MarkForkEvents(from, to int64) error {
 	if err := rds.RollBackCutoffPair(from, to); err != nil {
 		return fmt.Errorf("fork rollback cutoffPair events error:%s", err.Error())
 	}
+	if err := rds.RollBackCutoffIndex(from, to); err != nil {
+		return fmt.Errorf("fork rollback cutoff index error:%s", err.Error())
+	}
 
 	return nil
 }
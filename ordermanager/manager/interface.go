@@ -20,7 +20,6 @@ package manager
 
 import (
 	"github.com/Loopring/relay-cluster/dao"
-	omcm "github.com/Loopring/relay-cluster/ordermanager/common"
 	"github.com/Loopring/relay-lib/marketcap"
 	"github.com/Loopring/relay-lib/types"
 )
@@ -32,8 +31,7 @@ type EventStatusHandler interface {
 }
 
 type BaseHandler struct {
-	Rds         *dao.RdsService
-	MarketCap   marketcap.MarketCapProvider
-	CutoffCache *omcm.CutoffCache
-	TxInfo      types.TxInfo
+	Rds       *dao.RdsService
+	MarketCap marketcap.MarketCapProvider
+	TxInfo    types.TxInfo
 }
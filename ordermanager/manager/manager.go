@@ -19,6 +19,7 @@
 package manager
 
 import (
+	"github.com/Loopring/relay-cluster/accountmanager"
 	"github.com/Loopring/relay-cluster/dao"
 	"github.com/Loopring/relay-cluster/ordermanager/cache"
 	"github.com/Loopring/relay-cluster/ordermanager/common"
@@ -52,12 +53,13 @@ type OrderManagerImpl struct {
 	forkWatcher                *eventemitter.Watcher
 	warningWatcher             *eventemitter.Watcher
 	submitRingMethodWatcher    *eventemitter.Watcher
+	blockNewWatcher            *eventemitter.Watcher
+	allowanceSyncedWatcher     *eventemitter.Watcher
 }
 
 var (
 	rds               *dao.RdsService
 	marketCapProvider marketcap.MarketCapProvider
-	cutoffcache       *common.CutoffCache
 )
 
 func NewOrderManager(
@@ -70,10 +72,18 @@ func NewOrderManager(
 	om.options = options
 	om.brokers = brokers
 	om.processor = NewForkProcess()
-	cutoffcache = common.NewCutoffCache(options.CutoffCacheCleanTime)
 
 	marketCapProvider = market
 	rds = db
+	if options.MinerLeaseBlocks > 0 {
+		minerLeaseBlocks = options.MinerLeaseBlocks
+	}
+	if options.RingFailLimit > 0 {
+		ringFailLimit = options.RingFailLimit
+	}
+	if options.RingFailPauseSeconds > 0 {
+		ringFailPauseSeconds = options.RingFailPauseSeconds
+	}
 
 	if cache.Invalid() {
 		cache.Initialize(rds)
@@ -104,6 +114,8 @@ func (om *OrderManagerImpl) Start() {
 	// procedure related
 	om.forkWatcher = &eventemitter.Watcher{Concurrent: false, Handle: om.handleFork}
 	om.warningWatcher = &eventemitter.Watcher{Concurrent: false, Handle: om.handleWarning}
+	om.blockNewWatcher = &eventemitter.Watcher{Concurrent: false, Handle: om.handleBlockNew}
+	om.allowanceSyncedWatcher = &eventemitter.Watcher{Concurrent: false, Handle: om.handleAllowanceSynced}
 
 	eventemitter.On(eventemitter.NewOrder, om.newOrderWatcher)
 	eventemitter.On(eventemitter.Miner_SubmitRing_Method, om.submitRingMethodWatcher)
@@ -122,6 +134,8 @@ func (om *OrderManagerImpl) Start() {
 
 	eventemitter.On(eventemitter.ChainForkDetected, om.forkWatcher)
 	eventemitter.On(eventemitter.ExtractorWarning, om.warningWatcher)
+	eventemitter.On(eventemitter.Block_New, om.blockNewWatcher)
+	eventemitter.On(accountmanager.AllowanceSynced, om.allowanceSyncedWatcher)
 }
 
 func (om *OrderManagerImpl) Stop() {
@@ -142,6 +156,8 @@ func (om *OrderManagerImpl) Stop() {
 
 	eventemitter.Un(eventemitter.ChainForkDetected, om.forkWatcher)
 	eventemitter.Un(eventemitter.ExtractorWarning, om.warningWatcher)
+	eventemitter.Un(eventemitter.Block_New, om.blockNewWatcher)
+	eventemitter.Un(accountmanager.AllowanceSynced, om.allowanceSyncedWatcher)
 }
 
 func (om *OrderManagerImpl) handleFork(input eventemitter.EventData) error {
@@ -188,6 +204,10 @@ func (om *OrderManagerImpl) HandlerOrderRelatedEvent(input eventemitter.EventDat
 		log.Errorf(err.Error())
 	}
 
+	if owner, ok := orderRelatedOwner(input); ok {
+		allowanceShortfalls.schedule(owner, nil)
+	}
+
 	return nil
 }
 
@@ -216,5 +236,16 @@ func (om *OrderManagerImpl) HandleOrderCorrelatedEvent(input eventemitter.EventD
 		log.Errorf(err.Error())
 	}
 
+	if event, ok := input.(*types.ApprovalEvent); ok && event.Status == types.TX_STATUS_SUCCESS {
+		allowanceShortfalls.schedule(event.Owner, event)
+	}
+
+	return nil
+}
+
+// 成交消耗的授权只在accountmanager区块结束同步时更新
+func (om *OrderManagerImpl) handleAllowanceSynced(input eventemitter.EventData) error {
+	event := input.(*accountmanager.AllowanceSyncedEvent)
+	allowanceShortfalls.synced(event.Owner)
 	return nil
 }
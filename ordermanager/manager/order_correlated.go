@@ -26,6 +26,7 @@ import (
 	omtyp "github.com/Loopring/relay-cluster/ordermanager/types"
 	"github.com/Loopring/relay-lib/types"
 	"github.com/ethereum/go-ethereum/common"
+	"time"
 )
 
 // orderTx中同一个order 最多有三条记录 分别属于order owner&miner
@@ -141,6 +142,7 @@ func (handler *OrderTxHandler) addOrderPendingTx() error {
 	}
 
 	model.ConvertDown(event)
+	model.CreateTime = time.Now().Unix()
 	rds.Add(model)
 
 	if !cache.ExistPendingOrder(event.Owner, event.OrderHash) {
@@ -0,0 +1,127 @@
+/*
+
+  Copyright 2017 Loopring Project Ltd (Loopring Foundation).
+
+  Licensed under the Apache License, Version 2.0 (the "License");
+  you may not use this file except in compliance with the License.
+  You may obtain a copy of the License at
+
+  http://www.apache.org/licenses/LICENSE-2.0
+
+  Unless required by applicable law or agreed to in writing, software
+  distributed under the License is distributed on an "AS IS" BASIS,
+  WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+  See the License for the specific language governing permissions and
+  limitations under the License.
+
+*/
+
+package manager
+
+import (
+	"fmt"
+	"github.com/Loopring/relay-cluster/dao"
+	cm "github.com/Loopring/relay-cluster/ordermanager/common"
+	"github.com/Loopring/relay-lib/log"
+	"github.com/Loopring/relay-lib/types"
+	"github.com/ethereum/go-ethereum/common"
+)
+
+// 订单组依赖的存储, 生产环境为rds
+type orderGroupStore interface {
+	GetOrderByHash(orderhash common.Hash) (*dao.Order, error)
+	FlexCancelOrderByHash(owner common.Address, orderhash common.Hash, validStatus []types.OrderStatus, status types.OrderStatus) int64
+	RestoreFlexCancelledOrder(orderHash string, status types.OrderStatus) int64
+	GetOrderGroupMember(orderHash string) (dao.OrderGroupMember, error)
+	GetOrderGroupMembers(groupId string) ([]dao.OrderGroupMember, error)
+	TriggerOrderGroupMember(orderHash, txhash string, blockNumber int64) int64
+	CancelOrderGroupMember(orderHash, txhash string, blockNumber int64, prevStatus types.OrderStatus) error
+	GetForkedOrderGroupMembers(from, to int64) ([]dao.OrderGroupMember, error)
+	ResetOrderGroupMember(orderHash string) error
+}
+
+// 订单组的存储及被取消、恢复订单的通知
+type orderGroupHandler struct {
+	store  orderGroupStore
+	notify func(orderHash common.Hash) error
+}
+
+func newOrderGroupHandler(store orderGroupStore) *orderGroupHandler {
+	return &orderGroupHandler{store: store, notify: notifyOrderState}
+}
+
+func handleOrderGroupFill(event *types.OrderFilledEvent) {
+	newOrderGroupHandler(rds).handleFill(event)
+}
+
+// 订单成交后软取消同组的其他订单
+func (h *orderGroupHandler) handleFill(event *types.OrderFilledEvent) {
+	member, err := h.store.GetOrderGroupMember(event.OrderHash.Hex())
+	if err != nil || member.Status != dao.ORDER_GROUP_MEMBER_ACTIVE {
+		return
+	}
+
+	blockNumber := event.BlockNumber.Int64()
+	if h.store.TriggerOrderGroupMember(member.OrderHash, event.TxHash.Hex(), blockNumber) == 0 {
+		return
+	}
+
+	siblings, err := h.store.GetOrderGroupMembers(member.GroupId)
+	if err != nil {
+		log.Errorf("order manager, order group:%s get members error:%s", member.GroupId, err.Error())
+		return
+	}
+
+	for _, v := range siblings {
+		if v.OrderHash == member.OrderHash || v.Status != dao.ORDER_GROUP_MEMBER_ACTIVE {
+			continue
+		}
+		if err := h.cancelSibling(v, event.TxHash.Hex(), blockNumber); err != nil {
+			log.Errorf("order manager, order group:%s cancel order:%s error:%s", v.GroupId, v.OrderHash, err.Error())
+		}
+	}
+}
+
+func (h *orderGroupHandler) cancelSibling(member dao.OrderGroupMember, txhash string, blockNumber int64) error {
+	orderhash := common.HexToHash(member.OrderHash)
+	model, err := h.store.GetOrderByHash(orderhash)
+	if err != nil {
+		return err
+	}
+
+	// 订单已完成或已取消时只更新组成员状态
+	h.store.FlexCancelOrderByHash(common.HexToAddress(member.Owner), orderhash, cm.ValidFlexCancelStatus, types.ORDER_FLEX_CANCEL)
+	if err := h.store.CancelOrderGroupMember(member.OrderHash, txhash, blockNumber, types.OrderStatus(model.Status)); err != nil {
+		return err
+	}
+
+	log.Debugf("order manager, order group:%s order:%s flex cancelled by tx:%s", member.GroupId, member.OrderHash, txhash)
+
+	return h.notify(orderhash)
+}
+
+func (p *ForkProcessor) RollBackOrderGroups(from, to int64) error {
+	return newOrderGroupHandler(rds).rollBack(from, to)
+}
+
+// 触发订单组的fill被分叉回滚时, 恢复被软取消的同组订单
+func (h *orderGroupHandler) rollBack(from, to int64) error {
+	list, err := h.store.GetForkedOrderGroupMembers(from, to)
+	if err != nil {
+		return fmt.Errorf("fork rollback order groups error:%s", err.Error())
+	}
+
+	for _, v := range list {
+		if v.Status == dao.ORDER_GROUP_MEMBER_CANCELLED {
+			if h.store.RestoreFlexCancelledOrder(v.OrderHash, types.OrderStatus(v.PrevStatus)) > 0 {
+				h.notify(common.HexToHash(v.OrderHash))
+			}
+		}
+		if err := h.store.ResetOrderGroupMember(v.OrderHash); err != nil {
+			return fmt.Errorf("fork rollback order group member:%s error:%s", v.OrderHash, err.Error())
+		}
+		log.Debugf("fork order group:%s, order:%s reset to active", v.GroupId, v.OrderHash)
+	}
+
+	return nil
+}
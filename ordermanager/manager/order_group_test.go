@@ -0,0 +1,249 @@
+/*
+
+  Copyright 2017 Loopring Project Ltd (Loopring Foundation).
+
+  Licensed under the Apache License, Version 2.0 (the "License");
+  you may not use this file except in compliance with the License.
+  You may obtain a copy of the License at
+
+  http://www.apache.org/licenses/LICENSE-2.0
+
+  Unless required by applicable law or agreed to in writing, software
+  distributed under the License is distributed on an "AS IS" BASIS,
+  WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+  See the License for the specific language governing permissions and
+  limitations under the License.
+
+*/
+
+package manager
+
+import (
+	"errors"
+	"github.com/Loopring/relay-cluster/dao"
+	"github.com/Loopring/relay-lib/log"
+	"github.com/Loopring/relay-lib/types"
+	"github.com/ethereum/go-ethereum/common"
+	"go.uber.org/zap"
+	"math/big"
+	"sync"
+	"testing"
+)
+
+var testLogger sync.Once
+
+func initTestLogger() {
+	testLogger.Do(func() {
+		cfg := zap.NewDevelopmentConfig()
+		cfg.OutputPaths = []string{"stdout"}
+		log.Initialize(cfg)
+	})
+}
+
+type memOrderGroupStore struct {
+	orders  map[string]*dao.Order
+	members []*dao.OrderGroupMember
+}
+
+func (s *memOrderGroupStore) GetOrderByHash(orderhash common.Hash) (*dao.Order, error) {
+	if order, ok := s.orders[orderhash.Hex()]; ok {
+		model := *order
+		return &model, nil
+	}
+	return nil, errors.New("record not found")
+}
+
+func (s *memOrderGroupStore) FlexCancelOrderByHash(owner common.Address, orderhash common.Hash, validStatus []types.OrderStatus, status types.OrderStatus) int64 {
+	order, ok := s.orders[orderhash.Hex()]
+	if !ok || order.Owner != owner.Hex() {
+		return 0
+	}
+	for _, v := range validStatus {
+		if order.Status == uint8(v) {
+			order.Status = uint8(status)
+			return 1
+		}
+	}
+	return 0
+}
+
+func (s *memOrderGroupStore) RestoreFlexCancelledOrder(orderHash string, status types.OrderStatus) int64 {
+	order, ok := s.orders[orderHash]
+	if !ok || order.Status != uint8(types.ORDER_FLEX_CANCEL) {
+		return 0
+	}
+	order.Status = uint8(status)
+	return 1
+}
+
+func (s *memOrderGroupStore) member(orderHash string) *dao.OrderGroupMember {
+	for _, v := range s.members {
+		if v.OrderHash == orderHash {
+			return v
+		}
+	}
+	return nil
+}
+
+func (s *memOrderGroupStore) GetOrderGroupMember(orderHash string) (dao.OrderGroupMember, error) {
+	if m := s.member(orderHash); m != nil {
+		return *m, nil
+	}
+	return dao.OrderGroupMember{}, errors.New("record not found")
+}
+
+func (s *memOrderGroupStore) GetOrderGroupMembers(groupId string) ([]dao.OrderGroupMember, error) {
+	var list []dao.OrderGroupMember
+	for _, v := range s.members {
+		if v.GroupId == groupId {
+			list = append(list, *v)
+		}
+	}
+	return list, nil
+}
+
+func (s *memOrderGroupStore) TriggerOrderGroupMember(orderHash, txhash string, blockNumber int64) int64 {
+	m := s.member(orderHash)
+	if m == nil || m.Status != dao.ORDER_GROUP_MEMBER_ACTIVE {
+		return 0
+	}
+	m.Status, m.TriggerTx, m.TriggerBlock = dao.ORDER_GROUP_MEMBER_TRIGGERED, txhash, blockNumber
+	return 1
+}
+
+func (s *memOrderGroupStore) CancelOrderGroupMember(orderHash, txhash string, blockNumber int64, prevStatus types.OrderStatus) error {
+	m := s.member(orderHash)
+	if m == nil || m.Status != dao.ORDER_GROUP_MEMBER_ACTIVE {
+		return nil
+	}
+	m.Status, m.PrevStatus, m.TriggerTx, m.TriggerBlock = dao.ORDER_GROUP_MEMBER_CANCELLED, uint8(prevStatus), txhash, blockNumber
+	return nil
+}
+
+func (s *memOrderGroupStore) GetForkedOrderGroupMembers(from, to int64) ([]dao.OrderGroupMember, error) {
+	var list []dao.OrderGroupMember
+	for _, v := range s.members {
+		if v.TriggerBlock > from && v.TriggerBlock <= to && v.Status != dao.ORDER_GROUP_MEMBER_ACTIVE {
+			list = append(list, *v)
+		}
+	}
+	return list, nil
+}
+
+func (s *memOrderGroupStore) ResetOrderGroupMember(orderHash string) error {
+	if m := s.member(orderHash); m != nil {
+		m.Status, m.PrevStatus, m.TriggerTx, m.TriggerBlock = dao.ORDER_GROUP_MEMBER_ACTIVE, 0, "", 0
+	}
+	return nil
+}
+
+const (
+	orderGroupTestOwner = "0x71C079107B5af8619D54537A93dbF16e5aab4900"
+	orderGroupTestTx    = "0x8f61c0913a96116b26b73fe05b099e2b2f54803ed15b3a4ca053ee5c2d2dd158"
+)
+
+var orderGroupTestHashes = []string{
+	common.HexToHash("0x01").Hex(),
+	common.HexToHash("0x02").Hex(),
+	common.HexToHash("0x03").Hex(),
+}
+
+// 三个订单的OCO组, 第三个订单已部分成交
+func newOrderGroupTestStore() *memOrderGroupStore {
+	store := &memOrderGroupStore{orders: make(map[string]*dao.Order)}
+	statuses := []types.OrderStatus{types.ORDER_NEW, types.ORDER_NEW, types.ORDER_PARTIAL}
+	for i, hash := range orderGroupTestHashes {
+		store.orders[hash] = &dao.Order{OrderHash: hash, Owner: orderGroupTestOwner, Status: uint8(statuses[i])}
+		store.members = append(store.members, &dao.OrderGroupMember{GroupId: "group", GroupType: dao.ORDER_GROUP_TYPE_OCO, OrderHash: hash, Owner: orderGroupTestOwner, Status: dao.ORDER_GROUP_MEMBER_ACTIVE})
+	}
+	return store
+}
+
+func newOrderGroupTestHandler(store *memOrderGroupStore) (*orderGroupHandler, *[]common.Hash) {
+	initTestLogger()
+	var notified []common.Hash
+	h := &orderGroupHandler{store: store, notify: func(orderhash common.Hash) error {
+		notified = append(notified, orderhash)
+		return nil
+	}}
+	return h, &notified
+}
+
+func orderGroupTestFill(orderHash string, blockNumber int64) *types.OrderFilledEvent {
+	event := &types.OrderFilledEvent{OrderHash: common.HexToHash(orderHash)}
+	event.TxHash = common.HexToHash(orderGroupTestTx)
+	event.BlockNumber = big.NewInt(blockNumber)
+	return event
+}
+
+func TestHandleOrderGroupFill_CancelSiblings(t *testing.T) {
+	store := newOrderGroupTestStore()
+	h, notified := newOrderGroupTestHandler(store)
+
+	h.handleFill(orderGroupTestFill(orderGroupTestHashes[0], 100))
+
+	if m := store.member(orderGroupTestHashes[0]); m.Status != dao.ORDER_GROUP_MEMBER_TRIGGERED || m.TriggerBlock != 100 {
+		t.Fatalf("filled order should trigger the group, got %+v", m)
+	}
+	if store.orders[orderGroupTestHashes[0]].Status != uint8(types.ORDER_NEW) {
+		t.Fatalf("filled order should not be cancelled")
+	}
+	for i, hash := range orderGroupTestHashes[1:] {
+		m := store.member(hash)
+		if m.Status != dao.ORDER_GROUP_MEMBER_CANCELLED || m.TriggerBlock != 100 {
+			t.Fatalf("sibling %d should be cancelled, got %+v", i+1, m)
+		}
+		if store.orders[hash].Status != uint8(types.ORDER_FLEX_CANCEL) {
+			t.Fatalf("sibling order %d should be flex cancelled", i+1)
+		}
+	}
+	if m := store.member(orderGroupTestHashes[2]); m.PrevStatus != uint8(types.ORDER_PARTIAL) {
+		t.Fatalf("sibling prev status should be saved, got %d", m.PrevStatus)
+	}
+	if len(*notified) != 2 {
+		t.Fatalf("expected 2 cancelled siblings notified, got %d", len(*notified))
+	}
+
+	// 之后同组其他订单的fill不再触发
+	h.handleFill(orderGroupTestFill(orderGroupTestHashes[1], 101))
+	if m := store.member(orderGroupTestHashes[1]); m.Status != dao.ORDER_GROUP_MEMBER_CANCELLED || m.TriggerBlock != 100 {
+		t.Fatalf("cancelled sibling should not trigger the group again, got %+v", m)
+	}
+}
+
+func TestRollBackOrderGroups_RestoreSiblings(t *testing.T) {
+	store := newOrderGroupTestStore()
+	h, notified := newOrderGroupTestHandler(store)
+
+	h.handleFill(orderGroupTestFill(orderGroupTestHashes[0], 100))
+	*notified = nil
+
+	// 分叉区间不包含触发区块时不恢复, from为分叉点本身不回滚
+	if err := h.rollBack(100, 110); err != nil {
+		t.Fatal(err.Error())
+	}
+	if store.member(orderGroupTestHashes[1]).Status != dao.ORDER_GROUP_MEMBER_CANCELLED {
+		t.Fatalf("group triggered before fork should not be restored")
+	}
+
+	if err := h.rollBack(99, 110); err != nil {
+		t.Fatal(err.Error())
+	}
+	for _, hash := range orderGroupTestHashes {
+		if m := store.member(hash); m.Status != dao.ORDER_GROUP_MEMBER_ACTIVE || m.TriggerBlock != 0 {
+			t.Fatalf("member %s should be reset to active, got %+v", hash, m)
+		}
+	}
+	if store.orders[orderGroupTestHashes[1]].Status != uint8(types.ORDER_NEW) || store.orders[orderGroupTestHashes[2]].Status != uint8(types.ORDER_PARTIAL) {
+		t.Fatalf("cancelled siblings should be restored to their prev status")
+	}
+	if len(*notified) != 2 {
+		t.Fatalf("expected 2 restored siblings notified, got %d", len(*notified))
+	}
+
+	// 恢复后可以再次触发
+	h.handleFill(orderGroupTestFill(orderGroupTestHashes[2], 105))
+	if store.member(orderGroupTestHashes[0]).Status != dao.ORDER_GROUP_MEMBER_CANCELLED {
+		t.Fatalf("restored group should be triggered again")
+	}
+}
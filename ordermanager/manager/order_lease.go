@@ -0,0 +1,84 @@
+/*
+
+  Copyright 2017 Loopring Project Ltd (Loopring Foundation).
+
+  Licensed under the Apache License, Version 2.0 (the "License");
+  you may not use this file except in compliance with the License.
+  You may obtain a copy of the License at
+
+  http://www.apache.org/licenses/LICENSE-2.0
+
+  Unless required by applicable law or agreed to in writing, software
+  distributed under the License is distributed on an "AS IS" BASIS,
+  WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+  See the License for the specific language governing permissions and
+  limitations under the License.
+
+*/
+
+package manager
+
+import (
+	"github.com/Loopring/relay-cluster/dao"
+	"github.com/Loopring/relay-lib/eth/accessor"
+	"github.com/Loopring/relay-lib/eventemitter"
+	"github.com/Loopring/relay-lib/log"
+	"github.com/Loopring/relay-lib/types"
+	"github.com/ethereum/go-ethereum/common"
+	"sync/atomic"
+)
+
+const defaultMinerLeaseBlocks = 5
+
+var (
+	minerLeaseBlocks int64 = defaultMinerLeaseBlocks
+
+	// 由Block_New事件更新, 避免每次获取订单都请求节点
+	latestBlockNumber int64
+)
+
+// 将订单租给minerId, 返回租用成功的订单, 被其他miner抢先租用的订单不再返回
+func leaseMinerOrders(minerId string, blockNumber int64, list []*dao.Order) []*dao.Order {
+	var leased []*dao.Order
+
+	expireBlock := blockNumber + minerLeaseBlocks
+	for _, v := range list {
+		if rds.TryLeaseOrder(v.OrderHash, minerId, blockNumber, expireBlock) {
+			leased = append(leased, v)
+		} else {
+			log.Debugf("order manager, order:%s already leased by other miner", v.OrderHash)
+		}
+	}
+
+	return leased
+}
+
+func (om *OrderManagerImpl) handleBlockNew(input eventemitter.EventData) error {
+	event := input.(*types.BlockEvent)
+	atomic.StoreInt64(&latestBlockNumber, event.BlockNumber.Int64())
+	return nil
+}
+
+// 启动后尚未收到区块事件时从节点获取
+func currentBlockNumber() (int64, error) {
+	if blockNumber := atomic.LoadInt64(&latestBlockNumber); blockNumber > 0 {
+		return blockNumber, nil
+	}
+
+	var blockNumber types.Big
+	if err := accessor.BlockNumber(&blockNumber); err != nil {
+		return 0, err
+	}
+	atomic.CompareAndSwapInt64(&latestBlockNumber, 0, blockNumber.Int64())
+	return blockNumber.Int64(), nil
+}
+
+func releaseOrderLeases(orderHashes ...common.Hash) {
+	var hashes []string
+	for _, v := range orderHashes {
+		hashes = append(hashes, v.Hex())
+	}
+	if err := rds.ReleaseOrderLeases(hashes); err != nil {
+		log.Errorf("order manager, release order lease error:%s", err.Error())
+	}
+}
@@ -0,0 +1,42 @@
+/*
+
+  Copyright 2017 Loopring Project Ltd (Loopring Foundation).
+
+  Licensed under the Apache License, Version 2.0 (the "License");
+  you may not use this file except in compliance with the License.
+  You may obtain a copy of the License at
+
+  http://www.apache.org/licenses/LICENSE-2.0
+
+  Unless required by applicable law or agreed to in writing, software
+  distributed under the License is distributed on an "AS IS" BASIS,
+  WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+  See the License for the specific language governing permissions and
+  limitations under the License.
+
+*/
+
+package manager
+
+import (
+	"github.com/Loopring/relay-lib/types"
+	"math/big"
+	"testing"
+)
+
+// 收到区块事件后不再请求节点
+func TestCurrentBlockNumberFromBlockEvent(t *testing.T) {
+	om := &OrderManagerImpl{}
+	for _, number := range []int64{100, 101} {
+		if err := om.handleBlockNew(&types.BlockEvent{BlockNumber: big.NewInt(number)}); err != nil {
+			t.Fatal(err.Error())
+		}
+		blockNumber, err := currentBlockNumber()
+		if err != nil {
+			t.Fatal(err.Error())
+		}
+		if blockNumber != number {
+			t.Fatalf("expected block number %d, got %d", number, blockNumber)
+		}
+	}
+}
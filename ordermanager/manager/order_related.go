@@ -73,11 +73,24 @@ func HandleSubmitRingMethodEvent(event *types.SubmitRingMethodEvent) error {
 
 	log.Debugf("order manager, submitRingHandler, tx:%s, txstatus:%s", event.TxHash.Hex(), types.StatusStr(event.Status))
 
+	importForeignOrders(&rdsForeignOrderStore{rds: rds}, event)
+
 	for _, v := range event.OrderList {
 		txhandler := FullOrderTxHandler(event.TxInfo, v.Hash, types.ORDER_PENDING)
 		txhandler.HandlerOrderRelatedTx()
 	}
 
+	recordRingSubmitResult(event)
+
+	// 提交失败, 释放租约使订单可以被其他miner撮合
+	if event.Status == types.TX_STATUS_FAILED {
+		var hashes []common.Hash
+		for _, v := range event.OrderList {
+			hashes = append(hashes, v.Hash)
+		}
+		releaseOrderLeases(hashes...)
+	}
+
 	return nil
 }
 
@@ -88,6 +101,10 @@ func HandleRingMinedEvent(event *types.RingMinedEvent) error {
 
 	log.Debugf("order manager, ringMinedHandler, tx:%s, txstatus:%s", event.TxHash.Hex(), types.StatusStr(event.Status))
 
+	if err := rds.DelExpiredOrderLeases(event.BlockNumber.Int64()); err != nil {
+		log.Errorf("order manager, ringMinedHandler, delete expired order lease error:%s", err.Error())
+	}
+
 	var (
 		model = &dao.RingMinedEvent{}
 		err   error
@@ -129,6 +146,10 @@ func HandleOrderFilledEvent(event *types.OrderFilledEvent) error {
 		return err
 	}
 
+	// 订单已成交, 释放租约
+	releaseOrderLeases(event.OrderHash)
+	resetRingSubmitFails(event.OrderHash)
+
 	// judge order status
 	if omcm.IsInvalidFillStatus(state.Status) {
 		return fmt.Errorf("order manager fillHandler, tx:%s, fillIndex:%s, orderhash:%s, err:order status(%d) invalid", event.TxHash.Hex(), event.FillIndex.String(), event.OrderHash.Hex(), state.Status)
@@ -160,6 +181,9 @@ func HandleOrderFilledEvent(event *types.OrderFilledEvent) error {
 
 	notify.NotifyOrderFilled(newFillModel)
 
+	handleTimeInForceFill(state)
+	handleOrderGroupFill(event)
+
 	// 只需发送一次
 	if event.FillIndex.Int64() == 0 {
 		var ringminedEvent types.RingMinedEvent
@@ -272,11 +296,13 @@ func HandleCutoffEvent(event *types.CutoffEvent) error {
 	log.Debugf("order manager, CutoffHandler, tx:%s, owner:%s, cutofftime:%s, txstatus:%s", event.TxHash.Hex(), event.Owner.Hex(), event.Cutoff.String(), types.StatusStr(event.Status))
 
 	if event.Status == types.TX_STATUS_SUCCESS {
-		if lastCutoff := cutoffcache.GetCutoff(event.Protocol, event.Owner); event.Cutoff.Cmp(lastCutoff) < 0 {
-			return fmt.Errorf("order manager, CutoffHandler, tx:%s, lastCutofftime:%s > currentCutoffTime:%s", event.TxHash.Hex(), lastCutoff.String(), event.Cutoff.String())
+		if lastCutoff := rds.GetCutoffIndex(event.DelegateAddress, event.Owner); !omcm.IsCutoffApplicable(lastCutoff, event.Cutoff.Int64()) {
+			return fmt.Errorf("order manager, CutoffHandler, tx:%s, lastCutofftime:%d > currentCutoffTime:%s", event.TxHash.Hex(), lastCutoff, event.Cutoff.String())
 		}
 
-		cutoffcache.UpdateCutoff(event.Protocol, event.Owner, event.Cutoff)
+		if err := rds.UpdateCutoffIndex(event); err != nil {
+			return fmt.Errorf("order manager, CutoffHandler, tx:%s, update cutoff index error:%s", event.TxHash.Hex(), err.Error())
+		}
 		rds.SetCutOffOrders(orderhashList, event.BlockNumber)
 
 		notify.NotifyCutoff(event)
@@ -323,13 +349,14 @@ func HandleCutoffPair(event *types.CutoffPairEvent) error {
 	log.Debugf("order manager cutoffPairHandler, tx:%s, owner:%s, token1:%s, token2:%s, cutoffTimestamp:%s, txstatus:%s", event.TxHash.Hex(), event.Owner.Hex(), event.Token1.Hex(), event.Token2.Hex(), event.Cutoff.String(), types.StatusStr(event.Status))
 
 	if event.Status == types.TX_STATUS_SUCCESS {
-		// 首次存储到缓存，lastCutoffPair == currentCutoffPair
-		lastCutoffPair := cutoffcache.GetCutoffPair(event.Protocol, event.Owner, event.Token1, event.Token2)
-		if event.Cutoff.Cmp(lastCutoffPair) < 0 {
-			return fmt.Errorf("order manager cutoffPairHandler, tx:%s, lastCutoffPairTime:%s > currentCutoffPairTime:%s", event.TxHash.Hex(), lastCutoffPair.String(), event.Cutoff.String())
+		lastCutoffPair := rds.GetCutoffPairIndex(event.DelegateAddress, event.Owner, event.Token1, event.Token2)
+		if !omcm.IsCutoffApplicable(lastCutoffPair, event.Cutoff.Int64()) {
+			return fmt.Errorf("order manager cutoffPairHandler, tx:%s, lastCutoffPairTime:%d > currentCutoffPairTime:%s", event.TxHash.Hex(), lastCutoffPair, event.Cutoff.String())
 		}
 
-		cutoffcache.UpdateCutoffPair(event.Protocol, event.Owner, event.Token1, event.Token2, event.Cutoff)
+		if err := rds.UpdateCutoffPairIndex(event); err != nil {
+			return fmt.Errorf("order manager cutoffPairHandler, tx:%s, update cutoff index error:%s", event.TxHash.Hex(), err.Error())
+		}
 		rds.SetCutOffOrders(orderhashlist, event.BlockNumber)
 
 		notify.NotifyCutoffPair(event)
@@ -21,6 +21,7 @@ package manager
 import (
 	"github.com/Loopring/relay-lib/cache"
 	"github.com/Loopring/relay-lib/eventemitter"
+	"github.com/Loopring/relay-lib/log"
 	"github.com/Loopring/relay-lib/types"
 	"math/big"
 	"strings"
@@ -50,7 +51,9 @@ func SaveP2POrderRelation(takerOwner, taker, makerOwner, maker, txHash, pendingA
 	cache.Set(p2pRelationPreKey+taker, []byte(txHash), DefaultP2POrderExpireTime)
 	cache.Set(p2pRelationPreKey+maker, []byte(txHash), DefaultP2POrderExpireTime)
 	cache.ZAdd(maker, time.Now().UnixNano()/int64(1000000), []byte(txHash+splitMark+pendingAmount))
-	return nil
+
+	// 与relation的过期时间一致, p2p订单列表按该字段过滤已锁定的订单
+	return rds.LockP2POrders([]string{taker, maker}, time.Now().Unix()+DefaultP2POrderExpireTime)
 }
 
 func IsP2PMakerLocked(maker string) bool {
@@ -66,6 +69,9 @@ func HandleP2PRingMined(input eventemitter.EventData) error {
 		cache.SRem(p2pOrderPreKey+strings.ToLower(evt.Owner.Hex()), []byte(strings.ToLower(evt.OrderHash.Hex())))
 		cache.Del(p2pRelationPreKey + strings.ToLower(evt.OrderHash.Hex()))
 		cache.Del(p2pRelationPreKey + strings.ToLower(evt.NextOrderHash.Hex()))
+		if err := rds.UnlockP2POrders([]string{strings.ToLower(evt.OrderHash.Hex()), strings.ToLower(evt.NextOrderHash.Hex())}); err != nil {
+			log.Errorf("unlock p2p orders of tx:%s error:%s", evt.TxHash.Hex(), err.Error())
+		}
 	}
 	return nil
 }
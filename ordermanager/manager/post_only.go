@@ -0,0 +1,93 @@
+/*
+
+  Copyright 2017 Loopring Project Ltd (Loopring Foundation).
+
+  Licensed under the Apache License, Version 2.0 (the "License");
+  you may not use this file except in compliance with the License.
+  You may obtain a copy of the License at
+
+  http://www.apache.org/licenses/LICENSE-2.0
+
+  Unless required by applicable law or agreed to in writing, software
+  distributed under the License is distributed on an "AS IS" BASIS,
+  WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+  See the License for the specific language governing permissions and
+  limitations under the License.
+
+*/
+
+package manager
+
+import (
+	"github.com/Loopring/relay-cluster/dao"
+	omcm "github.com/Loopring/relay-cluster/ordermanager/common"
+	"github.com/Loopring/relay-lib/log"
+	"github.com/Loopring/relay-lib/types"
+	"github.com/ethereum/go-ethereum/common"
+	"strings"
+)
+
+const postOnlyOppositeLength = 20
+
+// post-only订单只能作为maker, 存在比它更早且价格交叉的对手单时它会成为taker, 不提供给miner
+// 同一交易对的对手盘只查询一次
+func filterPostOnlyTakers(list []*dao.Order) []*dao.Order {
+	var hashes []string
+	for _, v := range list {
+		hashes = append(hashes, v.OrderHash)
+	}
+
+	postOnlys, err := rds.GetPostOnlyOrderHashes(hashes)
+	if err != nil {
+		log.Errorf("order manager, get post-only orders error:%s", err.Error())
+		return list
+	}
+	if len(postOnlys) == 0 {
+		return list
+	}
+
+	books := make(map[string][]dao.Order)
+	var ret []*dao.Order
+	for _, v := range list {
+		if postOnlys[v.OrderHash] {
+			key := strings.Join([]string{v.DelegateAddress, v.Protocol, v.TokenB, v.TokenS}, "_")
+			opposites, ok := books[key]
+			if !ok {
+				if opposites, err = rds.GetOrderBook(common.HexToAddress(v.DelegateAddress), common.HexToAddress(v.Protocol), common.HexToAddress(v.TokenB), common.HexToAddress(v.TokenS), postOnlyOppositeLength); err != nil {
+					log.Errorf("order manager, get opposite orders of post-only order:%s error:%s", v.OrderHash, err.Error())
+					continue
+				}
+				books[key] = opposites
+			}
+			if isPostOnlyTaker(v, opposites) {
+				log.Debugf("order manager, post-only order:%s would be taker, skip it", v.OrderHash)
+				continue
+			}
+		}
+		ret = append(ret, v)
+	}
+	return ret
+}
+
+// opposites为对手盘, 按价格从优到劣排序, 第一个不交叉的之后都不会交叉
+func isPostOnlyTaker(model *dao.Order, opposites []dao.Order) bool {
+	state := &types.OrderState{}
+	if err := model.ConvertUp(state); err != nil {
+		return true
+	}
+
+	order := state.RawOrder
+	for _, v := range opposites {
+		opposite := &types.OrderState{}
+		if err := v.ConvertUp(opposite); err != nil {
+			continue
+		}
+		if !omcm.IsOrderCrossing(&order, &opposite.RawOrder) {
+			break
+		}
+		if v.CreateTime < model.CreateTime {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,114 @@
+/*
+
+  Copyright 2017 Loopring Project Ltd (Loopring Foundation).
+
+  Licensed under the Apache License, Version 2.0 (the "License");
+  you may not use this file except in compliance with the License.
+  You may obtain a copy of the License at
+
+  http://www.apache.org/licenses/LICENSE-2.0
+
+  Unless required by applicable law or agreed to in writing, software
+  distributed under the License is distributed on an "AS IS" BASIS,
+  WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+  See the License for the specific language governing permissions and
+  limitations under the License.
+
+*/
+
+package manager
+
+import (
+	"github.com/Loopring/relay-cluster/dao"
+	omcm "github.com/Loopring/relay-cluster/ordermanager/common"
+	"github.com/Loopring/relay-lib/types"
+	"github.com/ethereum/go-ethereum/common"
+	"math/big"
+	"sort"
+	"testing"
+)
+
+var (
+	postOnlyTestLrc  = common.HexToAddress("0xEF68e7C694F40c8202821eDF525dE3782458639f")
+	postOnlyTestWeth = common.HexToAddress("0xC02aaA39b223FE8D0A0e5C4F27eAD9083C756Cc2")
+)
+
+func newPostOnlyTestOrder(tokenS, tokenB common.Address, amountS, amountB, createTime int64) dao.Order {
+	order := types.Order{
+		DelegateAddress: common.HexToAddress("0x17233e07c67d086464fD408148c3ABB56245FA64"),
+		Owner:           common.HexToAddress("0x71C079107B5af8619D54537A93dbF16e5aab4900"),
+		TokenS:          tokenS,
+		TokenB:          tokenB,
+		AmountS:         big.NewInt(amountS),
+		AmountB:         big.NewInt(amountB),
+		ValidSince:      big.NewInt(0),
+		ValidUntil:      big.NewInt(0),
+		LrcFee:          big.NewInt(0),
+	}
+
+	return dao.Order{
+		DelegateAddress: order.DelegateAddress.Hex(),
+		Owner:           order.Owner.Hex(),
+		TokenS:          tokenS.Hex(),
+		TokenB:          tokenB.Hex(),
+		AmountS:         order.AmountS.String(),
+		AmountB:         order.AmountB.String(),
+		LrcFee:          "0",
+		OrderHash:       order.GenerateHash().Hex(),
+		CreateTime:      createTime,
+	}
+}
+
+func TestIsPostOnlyTaker(t *testing.T) {
+	// 100 LRC换1 WETH
+	order := newPostOnlyTestOrder(postOnlyTestLrc, postOnlyTestWeth, 100, 1, 100)
+	crossingOlder := newPostOnlyTestOrder(postOnlyTestWeth, postOnlyTestLrc, 1, 100, 50)
+	crossingNewer := newPostOnlyTestOrder(postOnlyTestWeth, postOnlyTestLrc, 1, 90, 150)
+	notCrossingOlder := newPostOnlyTestOrder(postOnlyTestWeth, postOnlyTestLrc, 1, 200, 50)
+
+	cases := []struct {
+		name      string
+		opposites []dao.Order
+		taker     bool
+	}{
+		{"empty book", nil, false},
+		{"crossing older opposite", []dao.Order{crossingOlder}, true},
+		{"crossing newer opposite", []dao.Order{crossingNewer}, false},
+		{"newer before older crossing opposite", []dao.Order{crossingNewer, crossingOlder}, true},
+		// 对手盘按价格从优到劣排序, 第一个不交叉的之后不再检查
+		{"stop at first not crossing opposite", []dao.Order{notCrossingOlder, crossingOlder}, false},
+	}
+
+	for _, c := range cases {
+		if taker := isPostOnlyTaker(&order, c.opposites); taker != c.taker {
+			t.Errorf("%s: expected taker %t, got %t", c.name, c.taker, taker)
+		}
+	}
+}
+
+// GetOrderBook按price(amountS/amountB)降序返回, 对手盘从优到劣, 交叉的订单都排在不交叉的之前
+func TestPostOnlyOppositeBookOrdering(t *testing.T) {
+	order := &types.Order{TokenS: postOnlyTestLrc, TokenB: postOnlyTestWeth, AmountS: big.NewInt(100), AmountB: big.NewInt(1)}
+
+	var opposites []*types.Order
+	for _, amountB := range []int64{200, 80, 100, 150, 50} {
+		opposite := &types.Order{TokenS: postOnlyTestWeth, TokenB: postOnlyTestLrc, AmountS: big.NewInt(1), AmountB: big.NewInt(amountB)}
+		opposite.GeneratePrice()
+		opposites = append(opposites, opposite)
+	}
+	sort.Slice(opposites, func(i, j int) bool {
+		return opposites[i].Price.Cmp(opposites[j].Price) > 0
+	})
+
+	crossing := true
+	for _, v := range opposites {
+		c := omcm.IsOrderCrossing(order, v)
+		if c && !crossing {
+			t.Fatalf("crossing opposite %s/%s found after a not crossing one", v.AmountS.String(), v.AmountB.String())
+		}
+		crossing = c
+	}
+	if !omcm.IsOrderCrossing(order, opposites[0]) {
+		t.Fatalf("best opposite should cross")
+	}
+}
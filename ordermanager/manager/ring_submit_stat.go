@@ -0,0 +1,127 @@
+/*
+
+  Copyright 2017 Loopring Project Ltd (Loopring Foundation).
+
+  Licensed under the Apache License, Version 2.0 (the "License");
+  you may not use this file except in compliance with the License.
+  You may obtain a copy of the License at
+
+  http://www.apache.org/licenses/LICENSE-2.0
+
+  Unless required by applicable law or agreed to in writing, software
+  distributed under the License is distributed on an "AS IS" BASIS,
+  WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+  See the License for the specific language governing permissions and
+  limitations under the License.
+
+*/
+
+package manager
+
+import (
+	"github.com/Loopring/relay-cluster/dao"
+	omcm "github.com/Loopring/relay-cluster/ordermanager/common"
+	"github.com/Loopring/relay-lib/log"
+	"github.com/Loopring/relay-lib/types"
+	"github.com/ethereum/go-ethereum/common"
+	"time"
+)
+
+const (
+	defaultRingFailLimit        = 3
+	defaultRingFailPauseSeconds = 600
+	maxRingFailErrLength        = 255
+)
+
+var (
+	ringFailLimit        int64 = defaultRingFailLimit
+	ringFailPauseSeconds int64 = defaultRingFailPauseSeconds
+)
+
+// 记录环路中每个订单的提交结果, 失败的订单立即释放回撮合池,
+// 同一原因连续失败ringFailLimit次后暂停撮合ringFailPauseSeconds秒
+func recordRingSubmitResult(event *types.SubmitRingMethodEvent) {
+	var hashes []string
+	for _, v := range event.OrderList {
+		hashes = append(hashes, v.Hash.Hex())
+		recordOrderSubmitResult(v, event)
+	}
+
+	if event.Status == types.TX_STATUS_FAILED {
+		if err := rds.ResetMinerOrders(hashes); err != nil {
+			log.Errorf("order manager, reset miner orders of failed ring tx:%s error:%s", event.TxHash.Hex(), err.Error())
+		}
+	}
+}
+
+func recordOrderSubmitResult(order types.Order, event *types.SubmitRingMethodEvent) {
+	stat, err := rds.GetRingSubmitStat(order.Hash.Hex())
+	if err != nil {
+		stat = dao.RingSubmitStat{OrderHash: order.Hash.Hex(), Owner: order.Owner.Hex()}
+	}
+	if !applyRingSubmitResult(&stat, event, time.Now().Unix()) {
+		return
+	}
+
+	if err := rds.SaveRingSubmitStat(&stat); err != nil {
+		log.Errorf("order manager, save ring submit stat of order:%s error:%s", stat.OrderHash, err.Error())
+	}
+}
+
+// pending时计入提交次数, 只有failed计入失败, success时重置连续失败次数, 其他状态不更新
+func applyRingSubmitResult(stat *dao.RingSubmitStat, event *types.SubmitRingMethodEvent, now int64) bool {
+	switch event.Status {
+	case types.TX_STATUS_PENDING:
+		stat.SubmitCount++
+
+	case types.TX_STATUS_SUCCESS:
+		if stat.SubmitCount <= stat.FailCount {
+			stat.SubmitCount++
+		}
+		stat.ConsecutiveFails = 0
+		stat.LastCause = ""
+
+	case types.TX_STATUS_FAILED:
+		if stat.SubmitCount <= stat.FailCount {
+			stat.SubmitCount++
+		}
+		stat.FailCount++
+
+		cause := omcm.ClassifyRingFailure(event.Err)
+		if cause == stat.LastCause {
+			stat.ConsecutiveFails++
+		} else {
+			stat.ConsecutiveFails = 1
+		}
+		stat.LastCause = cause
+		stat.LastErr = event.Err
+		if len(stat.LastErr) > maxRingFailErrLength {
+			stat.LastErr = stat.LastErr[:maxRingFailErrLength]
+		}
+
+		if stat.ConsecutiveFails >= ringFailLimit {
+			stat.IneligibleUntil = now + ringFailPauseSeconds
+			log.Infof("order manager, order:%s failed %d times for %s, paused mining until %d", stat.OrderHash, stat.ConsecutiveFails, cause, stat.IneligibleUntil)
+		}
+
+	default:
+		return false
+	}
+
+	stat.LastTxHash = event.TxHash.Hex()
+	return true
+}
+
+func resetRingSubmitFails(orderHash common.Hash) {
+	if err := rds.ResetRingSubmitFails(orderHash.Hex()); err != nil {
+		log.Errorf("order manager, reset ring submit fails of order:%s error:%s", orderHash.Hex(), err.Error())
+	}
+}
+
+func ineligibleOrderHashes() []string {
+	hashes, err := rds.GetIneligibleOrderHashes(time.Now().Unix())
+	if err != nil {
+		log.Errorf("order manager, get ineligible orders error:%s", err.Error())
+	}
+	return hashes
+}
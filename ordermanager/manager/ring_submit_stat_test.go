@@ -0,0 +1,81 @@
+/*
+
+  Copyright 2017 Loopring Project Ltd (Loopring Foundation).
+
+  Licensed under the Apache License, Version 2.0 (the "License");
+  you may not use this file except in compliance with the License.
+  You may obtain a copy of the License at
+
+  http://www.apache.org/licenses/LICENSE-2.0
+
+  Unless required by applicable law or agreed to in writing, software
+  distributed under the License is distributed on an "AS IS" BASIS,
+  WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+  See the License for the specific language governing permissions and
+  limitations under the License.
+
+*/
+
+package manager
+
+import (
+	"github.com/Loopring/relay-cluster/dao"
+	omcm "github.com/Loopring/relay-cluster/ordermanager/common"
+	"github.com/Loopring/relay-lib/types"
+	"testing"
+)
+
+func newRingSubmitTestEvent(status types.TxStatus, errMsg string) *types.SubmitRingMethodEvent {
+	event := &types.SubmitRingMethodEvent{Err: errMsg}
+	event.Status = status
+	return event
+}
+
+func TestApplyRingSubmitResult_SuccessNotFailure(t *testing.T) {
+	initTestLogger()
+	stat := &dao.RingSubmitStat{}
+
+	applyRingSubmitResult(stat, newRingSubmitTestEvent(types.TX_STATUS_PENDING, ""), 1000)
+	applyRingSubmitResult(stat, newRingSubmitTestEvent(types.TX_STATUS_FAILED, "insufficient balance"), 1000)
+	if stat.SubmitCount != 1 || stat.FailCount != 1 || stat.ConsecutiveFails != 1 || stat.LastCause != omcm.RingFailCauseInsufficientBalance {
+		t.Fatalf("failed ring should be counted, got %+v", stat)
+	}
+
+	applyRingSubmitResult(stat, newRingSubmitTestEvent(types.TX_STATUS_PENDING, ""), 1000)
+	applyRingSubmitResult(stat, newRingSubmitTestEvent(types.TX_STATUS_SUCCESS, ""), 1000)
+	if stat.SubmitCount != 2 || stat.FailCount != 1 {
+		t.Fatalf("success ring should not be counted as failure, got %+v", stat)
+	}
+	if stat.ConsecutiveFails != 0 || stat.LastCause != "" {
+		t.Fatalf("success ring should reset consecutive fails, got %+v", stat)
+	}
+}
+
+func TestApplyRingSubmitResult_PauseAfterConsecutiveFails(t *testing.T) {
+	initTestLogger()
+	stat := &dao.RingSubmitStat{}
+
+	for i := int64(1); i < ringFailLimit; i++ {
+		applyRingSubmitResult(stat, newRingSubmitTestEvent(types.TX_STATUS_FAILED, "insufficient allowance"), 1000)
+	}
+	// 中间的成功打断连续失败
+	applyRingSubmitResult(stat, newRingSubmitTestEvent(types.TX_STATUS_SUCCESS, ""), 1000)
+	applyRingSubmitResult(stat, newRingSubmitTestEvent(types.TX_STATUS_FAILED, "insufficient allowance"), 1000)
+	if stat.IneligibleUntil != 0 || stat.ConsecutiveFails != 1 {
+		t.Fatalf("fails separated by success should not pause the order, got %+v", stat)
+	}
+
+	for i := int64(1); i < ringFailLimit; i++ {
+		applyRingSubmitResult(stat, newRingSubmitTestEvent(types.TX_STATUS_FAILED, "insufficient allowance"), 1000)
+	}
+	if stat.IneligibleUntil != 1000+ringFailPauseSeconds {
+		t.Fatalf("consecutive fails should pause the order, got %+v", stat)
+	}
+}
+
+func TestApplyRingSubmitResult_UnknownStatus(t *testing.T) {
+	stat := &dao.RingSubmitStat{}
+	if applyRingSubmitResult(stat, newRingSubmitTestEvent(types.TX_STATUS_UNKNOWN, ""), 1000) {
+		t.Fatalf("unknown status should not update the stat")
+	}
+}
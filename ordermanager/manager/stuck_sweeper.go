@@ -0,0 +1,196 @@
+/*
+
+  Copyright 2017 Loopring Project Ltd (Loopring Foundation).
+
+  Licensed under the Apache License, Version 2.0 (the "License");
+  you may not use this file except in compliance with the License.
+  You may obtain a copy of the License at
+
+  http://www.apache.org/licenses/LICENSE-2.0
+
+  Unless required by applicable law or agreed to in writing, software
+  distributed under the License is distributed on an "AS IS" BASIS,
+  WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+  See the License for the specific language governing permissions and
+  limitations under the License.
+
+*/
+
+package manager
+
+import (
+	"github.com/Loopring/relay-cluster/dao"
+	omtyp "github.com/Loopring/relay-cluster/ordermanager/types"
+	notify "github.com/Loopring/relay-cluster/util"
+	"github.com/Loopring/relay-lib/eth/accessor"
+	ethtyp "github.com/Loopring/relay-lib/eth/types"
+	"github.com/Loopring/relay-lib/log"
+	"github.com/Loopring/relay-lib/types"
+	"github.com/Loopring/relay-lib/zklock"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/rpc"
+	"github.com/robfig/cron"
+	"time"
+)
+
+const (
+	stuckSweeperCronSpec   = "@every 5m"
+	stuckSweeperZkLock     = "stuckOrderSweeperZkLock"
+	defaultStuckTxSweepAge = 1800
+)
+
+type StuckSweepReport struct {
+	Checked   int                      `json:"checked"`
+	Recovered []dao.StuckOrderRecovery `json:"recovered"`
+}
+
+// 定时清理链上已丢弃或被同nonce交易替换的pending tx, 并恢复订单状态
+type StuckOrderSweeper struct {
+	age  int64
+	cron *cron.Cron
+}
+
+func NewStuckOrderSweeper(age int64) *StuckOrderSweeper {
+	if age <= 0 {
+		age = defaultStuckTxSweepAge
+	}
+	return &StuckOrderSweeper{age: age, cron: cron.New()}
+}
+
+func (s *StuckOrderSweeper) Start() {
+	go func() {
+		if zklock.TryLock(stuckSweeperZkLock) == nil {
+			if n, err := rds.BackfillPendingOrderTxCreateTime(time.Now().Unix()); err != nil {
+				log.Errorf("stuck order sweeper, backfill pending tx create time error:%s", err.Error())
+			} else if n > 0 {
+				log.Infof("stuck order sweeper, backfill create time of %d pending txs", n)
+			}
+			s.cron.AddFunc(stuckSweeperCronSpec, func() { s.Sweep() })
+			log.Info("start stuck order sweeper cron job......... ")
+			s.cron.Start()
+		} else {
+			log.Info("stuck order sweeper try lock failed, other node is sweeping")
+		}
+	}()
+}
+
+func (s *StuckOrderSweeper) Stop() {
+	s.cron.Stop()
+}
+
+func (s *StuckOrderSweeper) Sweep() StuckSweepReport {
+	var report StuckSweepReport
+
+	list, err := rds.GetStalePendingOrderTxs(time.Now().Unix() - s.age)
+	if err != nil {
+		log.Errorf("stuck order sweeper, get stale pending txs error:%s", err.Error())
+		return report
+	}
+	report.Checked = len(list)
+
+	nonces := make(map[string]int64)
+	for _, v := range list {
+		reason := stuckTxReason(v, nonces)
+		if reason == "" {
+			continue
+		}
+		if record, err := recoverStuckOrder(v, reason); err != nil {
+			log.Errorf("stuck order sweeper, order:%s tx:%s recover error:%s", v.OrderHash, v.TxHash, err.Error())
+		} else {
+			report.Recovered = append(report.Recovered, record)
+		}
+	}
+
+	log.Infof("stuck order sweeper, checked %d pending txs, recovered %d orders", report.Checked, len(report.Recovered))
+	return report
+}
+
+// 单独查询tx, 以区分节点调用失败与tx不存在
+type pendingTxReq struct {
+	TxHash string
+	Tx     ethtyp.Transaction
+	Err    error
+}
+
+func (req *pendingTxReq) ToBatchElem() []rpc.BatchElem {
+	return []rpc.BatchElem{{Method: "eth_getTransactionByHash", Args: []interface{}{req.TxHash}, Result: &req.Tx}}
+}
+
+func (req *pendingTxReq) FromBatchElem(batchElems []rpc.BatchElem) {
+	req.Err = batchElems[0].Error
+}
+
+// 节点正常返回空结果时tx才算不存在
+func (req *pendingTxReq) NotFound() bool {
+	return req.Err == nil && req.Tx.IsNull()
+}
+
+// 只有节点确认tx不存在时才处理, 再根据owner当前nonce判断是被替换还是被丢弃
+// 节点调用失败或owner nonce查询失败时说明节点不可用, 不处理
+func stuckTxReason(tx dao.OrderPendingTransaction, nonces map[string]int64) string {
+	req := &pendingTxReq{TxHash: tx.TxHash}
+	if err := accessor.BatchCall("latest", []accessor.BatchReq{req}); err != nil || !req.NotFound() {
+		return ""
+	}
+
+	nonce, ok := nonces[tx.Owner]
+	if !ok {
+		var count types.Big
+		if err := accessor.GetTransactionCount(&count, common.HexToAddress(tx.Owner), "latest"); err != nil {
+			return ""
+		}
+		nonce = count.Int64()
+		nonces[tx.Owner] = nonce
+	}
+
+	if tx.Nonce < nonce {
+		return dao.STUCK_TX_REPLACED
+	}
+	return dao.STUCK_TX_DROPPED
+}
+
+func recoverStuckOrder(tx dao.OrderPendingTransaction, reason string) (dao.StuckOrderRecovery, error) {
+	record := dao.StuckOrderRecovery{
+		OrderHash:  tx.OrderHash,
+		Owner:      tx.Owner,
+		TxHash:     tx.TxHash,
+		Nonce:      tx.Nonce,
+		Reason:     reason,
+		CreateTime: time.Now().Unix(),
+	}
+
+	orderhash := common.HexToHash(tx.OrderHash)
+	model, err := rds.GetOrderByHash(orderhash)
+	if err != nil {
+		return record, err
+	}
+	record.StuckStatus = model.Status
+
+	// 按失败tx处理, 删除该tx以及nonce更小的tx后重新计算订单状态
+	handler := &OrderTxHandler{
+		TxStatus: types.TX_STATUS_FAILED,
+		Event: &omtyp.OrderTx{
+			Owner:     common.HexToAddress(tx.Owner),
+			TxHash:    common.HexToHash(tx.TxHash),
+			OrderHash: orderhash,
+			Nonce:     tx.Nonce,
+		},
+	}
+	if err := handler.updateOrder(); err != nil {
+		return record, err
+	}
+
+	if model, err = rds.GetOrderByHash(orderhash); err != nil {
+		return record, err
+	}
+	record.RestoreStatus = model.Status
+	if err := rds.Add(&record); err != nil {
+		return record, err
+	}
+
+	state := &types.OrderState{}
+	model.ConvertUp(state)
+	notify.NotifyOrderUpdate(state)
+
+	return record, nil
+}
@@ -0,0 +1,55 @@
+/*
+
+  Copyright 2017 Loopring Project Ltd (Loopring Foundation).
+
+  Licensed under the Apache License, Version 2.0 (the "License");
+  you may not use this file except in compliance with the License.
+  You may obtain a copy of the License at
+
+  http://www.apache.org/licenses/LICENSE-2.0
+
+  Unless required by applicable law or agreed to in writing, software
+  distributed under the License is distributed on an "AS IS" BASIS,
+  WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+  See the License for the specific language governing permissions and
+  limitations under the License.
+
+*/
+
+package manager
+
+import (
+	"errors"
+	"github.com/ethereum/go-ethereum/rpc"
+	"testing"
+)
+
+func TestPendingTxReq_NotFound(t *testing.T) {
+	txhash := "0x8f61c0913a96116b26b73fe05b099e2b2f54803ed15b3a4ca053ee5c2d2dd158"
+
+	// 节点返回null
+	req := &pendingTxReq{TxHash: txhash}
+	elems := req.ToBatchElem()
+	req.FromBatchElem(elems)
+	if !req.NotFound() {
+		t.Fatalf("null result should be treated as not found")
+	}
+
+	// 节点调用失败
+	req = &pendingTxReq{TxHash: txhash}
+	elems = req.ToBatchElem()
+	elems[0].Error = errors.New("connection refused")
+	req.FromBatchElem(elems)
+	if req.NotFound() {
+		t.Fatalf("rpc error should not be treated as not found")
+	}
+
+	// tx仍在链上或pending池中
+	req = &pendingTxReq{TxHash: txhash}
+	elems = req.ToBatchElem()
+	req.Tx.Hash = txhash
+	req.FromBatchElem([]rpc.BatchElem{elems[0]})
+	if req.NotFound() {
+		t.Fatalf("existing tx should not be treated as not found")
+	}
+}
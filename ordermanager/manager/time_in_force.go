@@ -0,0 +1,194 @@
+/*
+
+  Copyright 2017 Loopring Project Ltd (Loopring Foundation).
+
+  Licensed under the Apache License, Version 2.0 (the "License");
+  you may not use this file except in compliance with the License.
+  You may obtain a copy of the License at
+
+  http://www.apache.org/licenses/LICENSE-2.0
+
+  Unless required by applicable law or agreed to in writing, software
+  distributed under the License is distributed on an "AS IS" BASIS,
+  WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+  See the License for the specific language governing permissions and
+  limitations under the License.
+
+*/
+
+package manager
+
+import (
+	"fmt"
+	"github.com/Loopring/relay-cluster/dao"
+	notify "github.com/Loopring/relay-cluster/util"
+	"github.com/Loopring/relay-lib/log"
+	"github.com/Loopring/relay-lib/types"
+	"github.com/Loopring/relay-lib/zklock"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/robfig/cron"
+	"time"
+)
+
+const (
+	timeInForceCronSpec      = "@every 10s"
+	timeInForceZkLock        = "timeInForceCancellerZkLock"
+	defaultIocCancelSeconds  = 60
+	timeInForceReasonExpired = "not filled in time"
+	timeInForceReasonPartial = "not fully filled in one ring"
+	timeInForceReasonNoRing  = "not matched in the first ring round"
+
+	// FOK订单提交后的下一个区块为第一轮撮合
+	fokRoundBlocks = 1
+)
+
+// IOC/FOK订单超过cancelBlocks个区块或cancelSeconds秒后自动软取消
+// cancelBlocks和cancelSeconds为0时不按该条件取消
+type TimeInForceCanceller struct {
+	cancelBlocks  int64
+	cancelSeconds int64
+	cron          *cron.Cron
+}
+
+func NewTimeInForceCanceller(cancelBlocks, cancelSeconds int64) *TimeInForceCanceller {
+	if cancelBlocks <= 0 && cancelSeconds <= 0 {
+		cancelSeconds = defaultIocCancelSeconds
+	}
+	return &TimeInForceCanceller{cancelBlocks: cancelBlocks, cancelSeconds: cancelSeconds, cron: cron.New()}
+}
+
+func (c *TimeInForceCanceller) Start() {
+	go func() {
+		if zklock.TryLock(timeInForceZkLock) == nil {
+			c.cron.AddFunc(timeInForceCronSpec, c.cancelExpiredOrders)
+			log.Info("start time in force canceller cron job......... ")
+			c.cron.Start()
+		} else {
+			log.Info("time in force canceller try lock failed, other node is cancelling")
+		}
+	}()
+}
+
+func (c *TimeInForceCanceller) Stop() {
+	c.cron.Stop()
+}
+
+func (c *TimeInForceCanceller) cancelExpiredOrders() {
+	list, err := rds.GetActiveTimeInForceOrders()
+	if err != nil {
+		log.Errorf("time in force canceller, get active orders error:%s", err.Error())
+		return
+	}
+	if len(list) == 0 {
+		return
+	}
+
+	blockNumber, err := currentBlockNumber()
+	if err != nil {
+		log.Errorf("time in force canceller, get block number error:%s", err.Error())
+	}
+
+	now := time.Now().Unix()
+	for _, v := range list {
+		if c.isExpired(v, blockNumber, now) {
+			autoCancelTimeInForceOrder(v, timeInForceReasonExpired)
+		} else if v.TimeInForce == dao.TIME_IN_FORCE_FOK && isFokRoundPassed(v, timeInForceOrderStatus(v.OrderHash), blockNumber) {
+			autoCancelTimeInForceOrder(v, timeInForceReasonNoRing)
+		}
+	}
+}
+
+// 第一轮撮合结束后仍没有包含该订单的环路(订单状态不是pending)时取消, 不等待IOC超时
+func isFokRoundPassed(order dao.TimeInForceOrder, status types.OrderStatus, blockNumber int64) bool {
+	if order.TimeInForce != dao.TIME_IN_FORCE_FOK || blockNumber <= 0 || order.CreateBlock <= 0 {
+		return false
+	}
+	return blockNumber-order.CreateBlock >= fokRoundBlocks && status != types.ORDER_PENDING
+}
+
+// 查询失败时按pending处理, 不取消订单
+func timeInForceOrderStatus(orderHash string) types.OrderStatus {
+	model, err := rds.GetOrderByHash(common.HexToHash(orderHash))
+	if err != nil {
+		return types.ORDER_PENDING
+	}
+	return types.OrderStatus(model.Status)
+}
+
+// 提供给miner的订单中IOC/FOK订单的类型, key为订单hash
+func MinerOrderTimeInForces(list []*types.OrderState) map[string]string {
+	var hashes []string
+	for _, v := range list {
+		hashes = append(hashes, v.RawOrder.Hash.Hex())
+	}
+
+	ret, err := rds.GetActiveTimeInForces(hashes)
+	if err != nil {
+		log.Errorf("order manager, get time in force of miner orders error:%s", err.Error())
+	}
+	return ret
+}
+
+func (c *TimeInForceCanceller) isExpired(order dao.TimeInForceOrder, blockNumber, now int64) bool {
+	if c.cancelBlocks > 0 && blockNumber > 0 && blockNumber-order.CreateBlock >= c.cancelBlocks {
+		return true
+	}
+	if c.cancelSeconds > 0 && now-order.CreateTime >= c.cancelSeconds {
+		return true
+	}
+	return false
+}
+
+// fill之后调用, 完全成交的订单不再需要取消, FOK订单部分成交时立即取消剩余部分
+func handleTimeInForceFill(state *types.OrderState) {
+	order, err := rds.GetTimeInForceOrderByHash(state.RawOrder.Hash.Hex())
+	if err != nil || order.Status != dao.TIME_IN_FORCE_STATUS_ACTIVE {
+		return
+	}
+
+	if state.Status == types.ORDER_FINISHED {
+		rds.FinishTimeInForceOrder(order.OrderHash, dao.TIME_IN_FORCE_STATUS_FILLED, "")
+		return
+	}
+
+	if order.TimeInForce == dao.TIME_IN_FORCE_FOK {
+		autoCancelTimeInForceOrder(order, timeInForceReasonPartial)
+	}
+}
+
+func autoCancelTimeInForceOrder(order dao.TimeInForceOrder, reason string) {
+	if rds.FinishTimeInForceOrder(order.OrderHash, dao.TIME_IN_FORCE_STATUS_CANCELLED, reason) == 0 {
+		return
+	}
+
+	event := &types.FlexCancelOrderEvent{
+		Owner:     common.HexToAddress(order.Owner),
+		OrderHash: common.HexToHash(order.OrderHash),
+		Type:      types.FLEX_CANCEL_BY_HASH,
+	}
+	if _, err := FlexCancelOrder(event); err != nil {
+		log.Errorf("time in force canceller, flex cancel order:%s error:%s", order.OrderHash, err.Error())
+		return
+	}
+	log.Debugf("time in force canceller, %s order:%s flex cancelled, reason:%s", order.TimeInForce, order.OrderHash, reason)
+
+	order.Status = dao.TIME_IN_FORCE_STATUS_CANCELLED
+	order.CancelReason = reason
+	notify.NotifyTimeInForceOrder(&order)
+
+	if err := notifyOrderState(event.OrderHash); err != nil {
+		log.Errorf("time in force canceller, notify order:%s error:%s", order.OrderHash, err.Error())
+	}
+}
+
+func notifyOrderState(orderHash common.Hash) error {
+	model, err := rds.GetOrderByHash(orderHash)
+	if err != nil {
+		return err
+	}
+	state := &types.OrderState{}
+	if err := model.ConvertUp(state); err != nil {
+		return fmt.Errorf("convert order error:%s", err.Error())
+	}
+	return notify.NotifyOrderUpdate(state)
+}
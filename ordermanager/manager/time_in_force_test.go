@@ -0,0 +1,72 @@
+/*
+
+  Copyright 2017 Loopring Project Ltd (Loopring Foundation).
+
+  Licensed under the Apache License, Version 2.0 (the "License");
+  you may not use this file except in compliance with the License.
+  You may obtain a copy of the License at
+
+  http://www.apache.org/licenses/LICENSE-2.0
+
+  Unless required by applicable law or agreed to in writing, software
+  distributed under the License is distributed on an "AS IS" BASIS,
+  WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+  See the License for the specific language governing permissions and
+  limitations under the License.
+
+*/
+
+package manager
+
+import (
+	"github.com/Loopring/relay-cluster/dao"
+	"github.com/Loopring/relay-lib/types"
+	"testing"
+)
+
+func TestTimeInForceCanceller_IsExpired(t *testing.T) {
+	c := NewTimeInForceCanceller(3, 60)
+	order := dao.TimeInForceOrder{TimeInForce: dao.TIME_IN_FORCE_IOC, CreateBlock: 100, CreateTime: 1000}
+
+	cases := []struct {
+		blockNumber int64
+		now         int64
+		expired     bool
+	}{
+		{101, 1010, false},
+		{103, 1010, true},
+		{101, 1060, true},
+		// 获取区块失败时只按时间判断
+		{0, 1010, false},
+		{0, 1060, true},
+	}
+	for _, v := range cases {
+		if expired := c.isExpired(order, v.blockNumber, v.now); expired != v.expired {
+			t.Errorf("block:%d now:%d, expected expired %t, got %t", v.blockNumber, v.now, v.expired, expired)
+		}
+	}
+}
+
+func TestIsFokRoundPassed(t *testing.T) {
+	fok := dao.TimeInForceOrder{TimeInForce: dao.TIME_IN_FORCE_FOK, CreateBlock: 100}
+	ioc := dao.TimeInForceOrder{TimeInForce: dao.TIME_IN_FORCE_IOC, CreateBlock: 100}
+
+	cases := []struct {
+		name        string
+		order       dao.TimeInForceOrder
+		status      types.OrderStatus
+		blockNumber int64
+		passed      bool
+	}{
+		{"same block", fok, types.ORDER_NEW, 100, false},
+		{"first round without ring", fok, types.ORDER_NEW, 101, true},
+		{"ring pending", fok, types.ORDER_PENDING, 101, false},
+		{"block number unknown", fok, types.ORDER_NEW, 0, false},
+		{"ioc waits for timeout", ioc, types.ORDER_NEW, 101, false},
+	}
+	for _, v := range cases {
+		if passed := isFokRoundPassed(v.order, v.status, v.blockNumber); passed != v.passed {
+			t.Errorf("%s: expected %t, got %t", v.name, v.passed, passed)
+		}
+	}
+}
@@ -19,18 +19,26 @@
 package manager
 
 import (
+	"errors"
 	"fmt"
 	"github.com/Loopring/relay-cluster/dao"
 	cm "github.com/Loopring/relay-cluster/ordermanager/common"
+	"github.com/Loopring/relay-lib/eth/loopringaccessor"
 	"github.com/Loopring/relay-lib/log"
 	util "github.com/Loopring/relay-lib/marketutil"
 	"github.com/Loopring/relay-lib/types"
 	"github.com/ethereum/go-ethereum/common"
 )
 
-func MinerOrders(delegate, tokenS, tokenB common.Address, length int, reservedTime, startBlockNumber, endBlockNumber int64, filterOrderHashLists ...*types.OrderDelayList) []*types.OrderState {
+// 返回的订单租给该miner minerLeaseBlocks个区块, 其他miner在租期内不会获取到
+// 各miner必须使用不同的minerId, 共用同一id时租用不能阻止重复撮合
+func MinerOrders(minerId string, delegate, tokenS, tokenB common.Address, length int, reservedTime, startBlockNumber, endBlockNumber int64, filterOrderHashLists ...*types.OrderDelayList) ([]*types.OrderState, error) {
 	var list []*types.OrderState
 
+	if minerId == "" {
+		return list, errors.New("miner id can't be null")
+	}
+
 	var (
 		modelList []*dao.Order
 		err       error
@@ -48,10 +56,29 @@ func MinerOrders(delegate, tokenS, tokenB common.Address, length int, reservedTi
 		}
 	}
 
-	// 从数据库获取订单
-	if modelList, err = rds.GetOrdersForMiner(delegate.Hex(), tokenS.Hex(), tokenB.Hex(), length, cm.ValidMinerStatus, reservedTime, startBlockNumber, endBlockNumber); err != nil {
+	// miner可以传入delegate或protocol地址, 传入protocol时只返回该protocol版本的订单
+	// 共用delegate的不同protocol版本的订单不能在同一个环路中撮合
+	delegateAddress, protocol := delegate.Hex(), ""
+	if impl, ok := loopringaccessor.ProtocolAddresses()[delegate]; ok {
+		delegateAddress, protocol = impl.DelegateAddress.Hex(), delegate.Hex()
+	}
+
+	// 获取区块失败时不做租用, 与未启用租用时一致
+	blockNumber, err := currentBlockNumber()
+	if err != nil {
+		log.Errorf("order manager, get block number for miner lease error:%s", err.Error())
+	}
+
+	// 其他miner租用中的订单在查询时排除, 连续提交失败被暂停撮合的订单同样排除
+	if modelList, err = rds.GetOrdersForMiner(delegateAddress, protocol, tokenS.Hex(), tokenB.Hex(), length, cm.ValidMinerStatus, reservedTime, startBlockNumber, endBlockNumber, minerId, blockNumber, ineligibleOrderHashes()); err != nil {
 		log.Errorf("err:%s", err.Error())
-		return list
+		return list, err
+	}
+
+	modelList = filterPostOnlyTakers(modelList)
+
+	if blockNumber > 0 {
+		modelList = leaseMinerOrders(minerId, blockNumber, modelList)
 	}
 
 	for _, v := range modelList {
@@ -66,16 +93,16 @@ func MinerOrders(delegate, tokenS, tokenB common.Address, length int, reservedTi
 		//}
 	}
 
-	return list
+	return list, nil
 }
 
 func UpdateBroadcastTimeByHash(hash common.Hash, bt int) error {
 	return rds.UpdateBroadcastTimeByHash(hash.Hex(), bt)
 }
 
-func FlexCancelOrder(event *types.FlexCancelOrderEvent) error {
+func FlexCancelOrder(event *types.FlexCancelOrderEvent) (int64, error) {
 	if types.IsZeroAddress(event.Owner) {
-		return fmt.Errorf("params owner invalid")
+		return 0, fmt.Errorf("params owner invalid")
 	}
 
 	validStatus := cm.ValidFlexCancelStatus
@@ -85,7 +112,7 @@ func FlexCancelOrder(event *types.FlexCancelOrderEvent) error {
 	switch event.Type {
 	case types.FLEX_CANCEL_BY_HASH:
 		if types.IsZeroHash(event.OrderHash) {
-			return fmt.Errorf("params orderhash invalid")
+			return 0, fmt.Errorf("params orderhash invalid")
 		}
 		nums = rds.FlexCancelOrderByHash(event.Owner, event.OrderHash, validStatus, status)
 
@@ -94,24 +121,20 @@ func FlexCancelOrder(event *types.FlexCancelOrderEvent) error {
 
 	case types.FLEX_CANCEL_BY_TIME:
 		if event.CutoffTime <= 0 {
-			return fmt.Errorf("params cutoffTimeStamp invalid")
+			return 0, fmt.Errorf("params cutoffTimeStamp invalid")
 		}
 		nums = rds.FlexCancelOrderByTime(event.Owner, event.CutoffTime, validStatus, status)
 
 	case types.FLEX_CANCEL_BY_MARKET:
 		market, err := util.WrapMarketByAddress(event.TokenS.Hex(), event.TokenB.Hex())
 		if err != nil {
-			return fmt.Errorf("params market invalid")
+			return 0, fmt.Errorf("params market invalid")
 		}
 		nums = rds.FlexCancelOrderByMarket(event.Owner, event.CutoffTime, market, validStatus, status)
 
 	default:
-		return fmt.Errorf("event type invalid")
-	}
-
-	if nums == 0 {
-		return fmt.Errorf("no valid order exist")
+		return 0, fmt.Errorf("event type invalid")
 	}
 
-	return nil
+	return nums, nil
 }
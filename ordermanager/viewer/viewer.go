@@ -30,24 +30,27 @@ import (
 )
 
 type OrderViewer interface {
-	GetOrderBook(protocol, tokenS, tokenB common.Address, length int) ([]types.OrderState, error)
-	GetOrders(query map[string]interface{}, statusList []types.OrderStatus, pageIndex, pageSize int) (dao.PageResult, error)
+	GetOrderBook(delegate, protocol, tokenS, tokenB common.Address, length int) ([]types.OrderState, error)
+	GetOrders(query map[string]interface{}, filter *dao.OrderFilter, statusList []types.OrderStatus, pageIndex, pageSize int) (dao.PageResult, error)
+	GetOrdersByCursor(query map[string]interface{}, filter *dao.OrderFilter, statusList []types.OrderStatus, cursor string, pageSize int) (dao.PageResult, error)
+	GetP2POrders(query map[string]interface{}, minAmountS *big.Int, minPrice, maxPrice float64, pageIndex, pageSize int) (dao.PageResult, error)
 	GetLatestOrders(query map[string]interface{}, length int) ([]types.OrderState, error)
 	GetOrderByHash(hash common.Hash) (*types.OrderState, error)
 	GetOrdersByHashes(hash []common.Hash) ([]types.OrderState, error)
 	FillsPageQuery(query map[string]interface{}, pageIndex, pageSize int) (dao.PageResult, error)
+	FillsCursorQuery(query map[string]interface{}, cursor string, pageSize int) (dao.PageResult, error)
 	GetLatestFills(query map[string]interface{}, limit int) ([]dao.FillEvent, error)
 	FindFillsByRingHash(ringHash common.Hash) (result []dao.FillEvent, err error)
 	RingMinedPageQuery(query map[string]interface{}, pageIndex, pageSize int) (dao.PageResult, error)
-	IsOrderCutoff(protocol, owner, token1, token2 common.Address, validsince *big.Int) bool
+	RingMinedCursorQuery(query map[string]interface{}, cursor string, pageSize int) (dao.PageResult, error)
+	IsOrderCutoff(delegate, owner, token1, token2 common.Address, validsince *big.Int) bool
 	GetFrozenAmount(owner common.Address, token common.Address, statusSet []types.OrderStatus, delegateAddress common.Address) (*big.Int, error)
-	GetFrozenLRCFee(owner common.Address, statusSet []types.OrderStatus) (*big.Int, error)
+	GetFrozenLRCFee(owner common.Address, statusSet []types.OrderStatus, delegateAddress common.Address) (*big.Int, error)
 }
 
 type OrderViewerImpl struct {
-	mc          marketcap.MarketCapProvider
-	rds         *dao.RdsService
-	cutoffCache *CutoffCache
+	mc  marketcap.MarketCapProvider
+	rds *dao.RdsService
 }
 
 func NewOrderViewer(options *OrderManagerOptions,
@@ -57,7 +60,6 @@ func NewOrderViewer(options *OrderManagerOptions,
 	var viewer OrderViewerImpl
 	viewer.mc = market
 	viewer.rds = rds
-	viewer.cutoffCache = NewCutoffCache(options.CutoffCacheCleanTime)
 
 	if cache.Invalid() {
 		cache.Initialize(viewer.rds)
@@ -66,9 +68,9 @@ func NewOrderViewer(options *OrderManagerOptions,
 	return &viewer
 }
 
-func (om *OrderViewerImpl) GetOrderBook(protocol, tokenS, tokenB common.Address, length int) ([]types.OrderState, error) {
+func (om *OrderViewerImpl) GetOrderBook(delegate, protocol, tokenS, tokenB common.Address, length int) ([]types.OrderState, error) {
 	var list []types.OrderState
-	models, err := om.rds.GetOrderBook(protocol, tokenS, tokenB, length)
+	models, err := om.rds.GetOrderBook(delegate, protocol, tokenS, tokenB, length)
 	if err != nil {
 		return list, err
 	}
@@ -84,7 +86,7 @@ func (om *OrderViewerImpl) GetOrderBook(protocol, tokenS, tokenB common.Address,
 	return list, nil
 }
 
-func (om *OrderViewerImpl) GetOrders(query map[string]interface{}, statusList []types.OrderStatus, pageIndex, pageSize int) (dao.PageResult, error) {
+func (om *OrderViewerImpl) GetOrders(query map[string]interface{}, filter *dao.OrderFilter, statusList []types.OrderStatus, pageIndex, pageSize int) (dao.PageResult, error) {
 	var (
 		pageRes dao.PageResult
 	)
@@ -92,7 +94,61 @@ func (om *OrderViewerImpl) GetOrders(query map[string]interface{}, statusList []
 	for _, s := range statusList {
 		sL = append(sL, int(s))
 	}
-	tmp, err := om.rds.OrderPageQuery(query, sL, pageIndex, pageSize)
+	tmp, err := om.rds.OrderPageQuery(query, filter, sL, pageIndex, pageSize)
+
+	if err != nil {
+		return pageRes, err
+	}
+	pageRes.PageIndex = tmp.PageIndex
+	pageRes.PageSize = tmp.PageSize
+	pageRes.Total = tmp.Total
+
+	for _, v := range tmp.Data {
+		var state types.OrderState
+		model := v.(dao.Order)
+		if err := model.ConvertUp(&state); err != nil {
+			log.Debug("convertUp error occurs " + err.Error())
+			continue
+		}
+		pageRes.Data = append(pageRes.Data, state)
+	}
+	return pageRes, nil
+}
+
+func (om *OrderViewerImpl) GetOrdersByCursor(query map[string]interface{}, filter *dao.OrderFilter, statusList []types.OrderStatus, cursor string, pageSize int) (dao.PageResult, error) {
+	var (
+		pageRes dao.PageResult
+	)
+	sL := make([]int, 0)
+	for _, s := range statusList {
+		sL = append(sL, int(s))
+	}
+	tmp, err := om.rds.OrderCursorQuery(query, filter, sL, cursor, pageSize)
+
+	if err != nil {
+		return pageRes, err
+	}
+	pageRes.PageSize = tmp.PageSize
+	pageRes.NextCursor = tmp.NextCursor
+
+	for _, v := range tmp.Data {
+		var state types.OrderState
+		model := v.(dao.Order)
+		if err := model.ConvertUp(&state); err != nil {
+			log.Debug("convertUp error occurs " + err.Error())
+			continue
+		}
+		pageRes.Data = append(pageRes.Data, state)
+	}
+
+	return pageRes, nil
+}
+
+func (om *OrderViewerImpl) GetP2POrders(query map[string]interface{}, minAmountS *big.Int, minPrice, maxPrice float64, pageIndex, pageSize int) (dao.PageResult, error) {
+	var (
+		pageRes dao.PageResult
+	)
+	tmp, err := om.rds.P2POrderPageQuery(query, minAmountS, minPrice, maxPrice, pageIndex, pageSize)
 
 	if err != nil {
 		return pageRes, err
@@ -167,6 +223,10 @@ func (om *OrderViewerImpl) FillsPageQuery(query map[string]interface{}, pageInde
 	return om.rds.FillsPageQuery(query, pageIndex, pageSize)
 }
 
+func (om *OrderViewerImpl) FillsCursorQuery(query map[string]interface{}, cursor string, pageSize int) (result dao.PageResult, err error) {
+	return om.rds.FillsCursorQuery(query, cursor, pageSize)
+}
+
 func (om *OrderViewerImpl) GetLatestFills(query map[string]interface{}, limit int) (result []dao.FillEvent, err error) {
 	return om.rds.GetLatestFills(query, limit)
 }
@@ -179,8 +239,12 @@ func (om *OrderViewerImpl) RingMinedPageQuery(query map[string]interface{}, page
 	return om.rds.RingMinedPageQuery(query, pageIndex, pageSize)
 }
 
-func (om *OrderViewerImpl) IsOrderCutoff(protocol, owner, token1, token2 common.Address, validsince *big.Int) bool {
-	return om.cutoffCache.IsOrderCutoff(protocol, owner, token1, token2, validsince)
+func (om *OrderViewerImpl) RingMinedCursorQuery(query map[string]interface{}, cursor string, pageSize int) (result dao.PageResult, err error) {
+	return om.rds.RingMinedCursorQuery(query, cursor, pageSize)
+}
+
+func (om *OrderViewerImpl) IsOrderCutoff(delegate, owner, token1, token2 common.Address, validsince *big.Int) bool {
+	return om.rds.IsOrderCutoff(delegate, owner, token1, token2, validsince.Int64())
 }
 
 func (om *OrderViewerImpl) GetFrozenAmount(owner common.Address, token common.Address, statusSet []types.OrderStatus, delegateAddress common.Address) (*big.Int, error) {
@@ -207,8 +271,8 @@ func (om *OrderViewerImpl) GetFrozenAmount(owner common.Address, token common.Ad
 	return totalAmount, nil
 }
 
-func (om *OrderViewerImpl) GetFrozenLRCFee(owner common.Address, statusSet []types.OrderStatus) (*big.Int, error) {
-	orderList, err := om.rds.GetFrozenLrcFee(owner, statusSet)
+func (om *OrderViewerImpl) GetFrozenLRCFee(owner common.Address, statusSet []types.OrderStatus, delegateAddress common.Address) (*big.Int, error) {
+	orderList, err := om.rds.GetFrozenLrcFee(owner, statusSet, delegateAddress)
 	if err != nil {
 		return nil, err
 	}
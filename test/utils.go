@@ -227,7 +227,7 @@ func GenerateOrderView() *orderviewer.OrderViewerImpl {
 }
 
 func GenerateAccountManager() accountmanager.AccountManager {
-	return accountmanager.Initialize(&cfg.AccountManager, cfg.Kafka.Brokers)
+	return accountmanager.Initialize(&cfg.AccountManager, cfg.Kafka.Brokers, rds)
 }
 
 func GenerateUserManager() *usermanager.UserManagerImpl {
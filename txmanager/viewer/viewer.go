@@ -126,6 +126,31 @@ func (impl *TransactionViewerImpl) GetAllTransactions(ownerStr, symbolStr, statu
 	return list, nil
 }
 
+// 游标错误直接返回, 便于调用方区分非法游标和无数据
+func (impl *TransactionViewerImpl) GetTransactionsByCursor(ownerStr, symbolStr, statusStr, typStr, cursor string, limit int) ([]txtyp.TransactionJsonResult, string, error) {
+	list := make([]txtyp.TransactionJsonResult, 0)
+
+	if !validateOwner(ownerStr) {
+		return list, "", ErrOwnerAddressInvalid
+	}
+
+	owner := safeOwner(ownerStr)
+	symbol := safeSymbol(symbolStr)
+	status := safeStatus(statusStr)
+	typ := safeType(typStr)
+
+	views, next, err := impl.db.GetTxViewByOwnerCursor(owner, symbol, status, typ, cursor, limit)
+	if err == dao.ErrInvalidCursor {
+		return list, "", err
+	} else if err != nil {
+		return list, "", ErrNonTransaction
+	}
+
+	list = impl.assemble(views)
+
+	return list, next, nil
+}
+
 // 如果transaction包含多条记录,则将protocol不同的记录放到content里
 func (impl *TransactionViewerImpl) assemble(daoviews []dao.TransactionView) []txtyp.TransactionJsonResult {
 	list := make([]txtyp.TransactionJsonResult, 0)
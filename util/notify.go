@@ -20,12 +20,18 @@ package util
 
 import (
 	"github.com/Loopring/relay-cluster/dao"
+	omcache "github.com/Loopring/relay-cluster/ordermanager/cache"
 	"github.com/Loopring/relay-cluster/txmanager/types"
 	"github.com/Loopring/relay-lib/kafka"
 	"github.com/Loopring/relay-lib/log"
 	libTypes "github.com/Loopring/relay-lib/types"
 )
 
+const Kafka_Topic_SocketIO_Trigger_Order = "Kafka_Topic_SocketIO_Trigger_Order"
+const Kafka_Topic_SocketIO_Time_In_Force_Order = "Kafka_Topic_SocketIO_Time_In_Force_Order"
+const Kafka_Topic_SocketIO_Dead_Man_Switch = "Kafka_Topic_SocketIO_Dead_Man_Switch"
+const Kafka_Topic_SocketIO_Allowance_Shortfall = "Kafka_Topic_SocketIO_Allowance_Shortfall"
+
 // todo delete return after test
 
 func NotifyOrderUpdate(o *libTypes.OrderState) error {
@@ -86,3 +92,35 @@ func NotifyRingMined(event *libTypes.RingMinedEvent) error {
 	}
 	return err
 }
+
+func NotifyTriggerOrder(t *dao.TriggerOrder) error {
+	err := ProducerSocketIOMessage(Kafka_Topic_SocketIO_Trigger_Order, t)
+	if err != nil {
+		log.Error("notify trigger order failed. " + t.OrderHash)
+	}
+	return err
+}
+
+func NotifyTimeInForceOrder(t *dao.TimeInForceOrder) error {
+	err := ProducerSocketIOMessage(Kafka_Topic_SocketIO_Time_In_Force_Order, t)
+	if err != nil {
+		log.Error("notify time in force order failed. " + t.OrderHash)
+	}
+	return err
+}
+
+func NotifyDeadManSwitch(s *omcache.DeadManSwitch) error {
+	err := ProducerSocketIOMessage(Kafka_Topic_SocketIO_Dead_Man_Switch, s)
+	if err != nil {
+		log.Error("notify dead man switch failed. " + s.Owner)
+	}
+	return err
+}
+
+func NotifyAllowanceShortfall(s *omcache.AllowanceShortfall) error {
+	err := ProducerSocketIOMessage(Kafka_Topic_SocketIO_Allowance_Shortfall, s)
+	if err != nil {
+		log.Error("notify allowance shortfall failed. " + s.Owner)
+	}
+	return err
+}